@@ -344,7 +344,8 @@ func main() {
 	}
 
 	// Initialiser le S3Service
-	s3Service := services.NewS3Service("http://my-s3-clone:9090")
+	s3Service := services.NewS3Service("http://my-s3-clone:9090", os.Getenv("S3_ACCESS_KEY_ID"), os.Getenv("S3_SECRET_ACCESS_KEY"))
+	s3Service.PublicURL = os.Getenv("S3_PUBLIC_URL")
 
 	// Initialize services
 	albumService := services.NewAlbumService(dbManager, s3Service)
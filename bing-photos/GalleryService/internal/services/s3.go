@@ -6,14 +6,23 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"mime"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"time"
 )
 
 // S3Service gère la communication avec l'API S3-like
 type S3Service struct {
-	APIURL string
+	APIURL    string
+	AccessKey string
+	SecretKey string
+	Region    string
+	// PublicURL est l'adresse de l'API vue par les navigateurs, utilisée pour les URLs présignées
+	// (APIURL si elle est vide)
+	PublicURL string
 }
 
 type ListAllMyBucketsResult struct {
@@ -26,12 +35,56 @@ type Bucket struct {
 	CreationDate       time.Time `xml:"CreationDate"`
 	LocationConstraint string    `xml:"LocationConstraint,omitempty"`
 	ObjectLockConfig   string    `xml:"ObjectLockConfiguration,omitempty"`
-	ObjectDelimiter    string    `xml:"ObjectDelimiter,omitempty"`
 }
 
-// NewS3Service initialise un S3Service
-func NewS3Service(apiURL string) *S3Service {
-	return &S3Service{APIURL: apiURL}
+// DeleteRequest est le corps d'une requête DeleteObjects
+type DeleteRequest struct {
+	XMLName xml.Name         `xml:"Delete"`
+	Quiet   bool             `xml:"Quiet"`
+	Objects []ObjectToDelete `xml:"Object"`
+}
+
+type ObjectToDelete struct {
+	Key       string `xml:"Key"`
+	VersionId string `xml:"VersionId,omitempty"`
+}
+
+// DeleteResult est la réponse de DeleteObjects (et du déplacement d'objets) : une entrée par clé
+type DeleteResult struct {
+	XMLName xml.Name         `xml:"DeleteResult"`
+	Deleted []ObjectToDelete `xml:"Deleted"`
+	Errors  []DeleteError    `xml:"Error"`
+}
+
+type DeleteError struct {
+	Key     string `xml:"Key"`
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// NewS3Service initialise un S3Service dont les requêtes sont signées avec les identifiants fournis
+func NewS3Service(apiURL, accessKey, secretKey string) *S3Service {
+	return &S3Service{
+		APIURL:    apiURL,
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+		Region:    "us-east-1",
+	}
+}
+
+// do signe puis envoie une requête vers l'API S3-like
+func (s *S3Service) do(req *http.Request) (*http.Response, error) {
+	s.signRequest(req, time.Now())
+	return http.DefaultClient.Do(req)
+}
+
+// get envoie une requête GET signée
+func (s *S3Service) get(url string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return s.do(req)
 }
 
 // CreateFolder crée un dossier dans l'API S3-like
@@ -46,7 +99,7 @@ func (s *S3Service) CreateBucket(folderPath string) error {
 
 	req.Header.Set("Content-Type", "application/octet-stream") // Type MIME pour un objet vide
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := s.do(req)
 	if err != nil {
 		return fmt.Errorf("failed to send request: %v", err)
 	}
@@ -56,6 +109,37 @@ func (s *S3Service) CreateBucket(folderPath string) error {
 		return fmt.Errorf("failed to create folder, status: %s", resp.Status)
 	}
 
+	// Les photos supprimées par erreur restent récupérables dans leurs versions précédentes.
+	// Un bucket sans versioning est supprimé : CreateBucket peut alors être rappelé.
+	if err := s.EnableVersioning(folderPath); err != nil {
+		if deleteErr := s.deleteEmptyBucket(folderPath); deleteErr != nil {
+			log.Printf("Échec de la suppression du bucket %s sans versioning : %v", folderPath, deleteErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// EnableVersioning active le versioning d'un bucket : un upload ou une suppression conserve l'ancienne version
+func (s *S3Service) EnableVersioning(bucketName string) error {
+	url := fmt.Sprintf("%s/%s/?versioning", s.APIURL, bucketName)
+	payload := []byte(`<VersioningConfiguration><Status>Enabled</Status></VersioningConfiguration>`)
+
+	req, err := http.NewRequest("PUT", url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/xml")
+
+	resp, err := s.do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to enable versioning, status: %s", resp.Status)
+	}
 	return nil
 }
 
@@ -65,7 +149,7 @@ func (s *S3Service) ListBuckets() ([]Bucket, error) {
 	url := fmt.Sprintf("%s/", s.APIURL)
 
 	// Envoyer une requête HTTP GET
-	resp, err := http.Get(url)
+	resp, err := s.get(url)
 	if err != nil {
 		return nil, fmt.Errorf("erreur lors de l'appel à l'API S3-like : %v", err)
 	}
@@ -87,6 +171,19 @@ func (s *S3Service) ListBuckets() ([]Bucket, error) {
 }
 
 func (s *S3Service) DeleteBucket(bucketName string) error {
+	// Seul un bucket vide peut être supprimé : supprimer d'abord toutes les versions de ses objets
+	versions, err := s.listObjectVersions(bucketName)
+	if err != nil {
+		return fmt.Errorf("échec du listage du bucket avant suppression : %v", err)
+	}
+	if err := s.deleteObjects(bucketName, versions); err != nil {
+		return fmt.Errorf("échec de la suppression du contenu du bucket : %v", err)
+	}
+	return s.deleteEmptyBucket(bucketName)
+}
+
+// deleteEmptyBucket supprime un bucket sans objets
+func (s *S3Service) deleteEmptyBucket(bucketName string) error {
 	// Construire l'URL pour supprimer le bucket
 	url := fmt.Sprintf("%s/%s/", s.APIURL, bucketName)
 
@@ -96,7 +193,7 @@ func (s *S3Service) DeleteBucket(bucketName string) error {
 		return fmt.Errorf("échec de la création de la requête de suppression : %v", err)
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := s.do(req)
 	if err != nil {
 		return fmt.Errorf("échec de la suppression du bucket : %v", err)
 	}
@@ -120,12 +217,16 @@ func (s *S3Service) UploadFile(objectPath string, file io.Reader, fileSize int64
 		return fmt.Errorf("échec de la création de la requête : %v", err)
 	}
 
-	// Ajouter les en-têtes requis
-	req.Header.Set("Content-Type", "application/octet-stream")
+	// Ajouter les en-têtes requis : le type MIME est enregistré avec l'objet et renvoyé au téléchargement
+	contentType := mime.TypeByExtension(path.Ext(objectPath))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	req.Header.Set("Content-Type", contentType)
 	req.Header.Set("X-Amz-Decoded-Content-Length", fmt.Sprintf("%d", fileSize))
 
 	// Envoyer la requête
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := s.do(req)
 	if err != nil {
 		return fmt.Errorf("échec de l'upload : %v", err)
 	}
@@ -162,7 +263,7 @@ func (s *S3Service) MoveObject(sourceBucket, sourceKey, targetBucket string) err
 	req.Header.Set("Content-Type", "application/xml")
 
 	// Envoyer la requête
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := s.do(req)
 	if err != nil {
 		return fmt.Errorf("échec de la requête de déplacement : %v", err)
 	}
@@ -172,6 +273,9 @@ func (s *S3Service) MoveObject(sourceBucket, sourceKey, targetBucket string) err
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
 		return fmt.Errorf("échec de la requête de déplacement, statut : %s", resp.Status)
 	}
+	if err := checkDeleteResult(resp.Body); err != nil {
+		return fmt.Errorf("échec du déplacement : %v", err)
+	}
 
 	// Déplacement réussi
 	return nil
@@ -182,7 +286,7 @@ func (s *S3Service) DownloadFile(path string, w io.Writer) error {
 	url := fmt.Sprintf("%s/%s", s.APIURL, path)
 
 	// Envoyer une requête HTTP GET
-	resp, err := http.Get(url)
+	resp, err := s.get(url)
 	if err != nil {
 		return fmt.Errorf("échec de la requête de téléchargement : %v", err)
 	}
@@ -206,44 +310,9 @@ func (s *S3Service) DeleteObject(bucketName, objectName string) error {
 	// Journaliser la tentative de suppression
 	log.Printf("Tentative de suppression : bucket=%s, key=%s", bucketName, objectName)
 
-	// Construire l'URL pour supprimer l'objet
-	url := fmt.Sprintf("%s/%s/?delete=", s.APIURL, bucketName)
-	log.Printf("URL construite pour la suppression : %s", url)
-
-	// Construire le corps de la requête en XML
-	payload := fmt.Sprintf(`
-    <Delete>
-        <Object>
-            <Key>%s</Key>
-        </Object>
-    </Delete>
-    `, objectName)
-	log.Printf("Corps XML généré : %s", payload)
-
-	// Créer une requête HTTP POST
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte(payload)))
-	if err != nil {
-		return fmt.Errorf("échec de la création de la requête de suppression : %v", err)
-	}
-	req.Header.Set("Content-Type", "application/xml")
-
-	// Journaliser les en-têtes
-	log.Printf("Headers envoyés : %v", req.Header)
-
-	// Envoyer la requête HTTP
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("échec de la requête de suppression : %v", err)
-	}
-	defer resp.Body.Close()
-
-	// Lire le corps de la réponse pour le log
-	responseBody, _ := io.ReadAll(resp.Body)
-	log.Printf("Code de réponse HTTP : %d, Corps : %s", resp.StatusCode, string(responseBody))
-
-	// Vérifier le code de réponse
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("échec de la suppression de l'objet, statut : %s", resp.Status)
+	// Dans un album versionné, la photo est masquée par un marqueur de suppression et reste récupérable
+	if err := s.deleteObjects(bucketName, []ObjectToDelete{{Key: objectName}}); err != nil {
+		return err
 	}
 
 	// Journaliser la réussite
@@ -251,39 +320,160 @@ func (s *S3Service) DeleteObject(bucketName, objectName string) error {
 	return nil
 }
 
-func (s *S3Service) GetFilesInAlbum(bucketName string) ([]string, error) {
-	url := fmt.Sprintf("%s/%s/", s.APIURL, bucketName)
+// deleteObjects supprime des clés (ou des versions précises) par lots de 1000 avec DeleteObjects en mode Quiet
+func (s *S3Service) deleteObjects(bucketName string, objects []ObjectToDelete) error {
+	url := fmt.Sprintf("%s/%s/?delete=", s.APIURL, bucketName)
 
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, fmt.Errorf("échec de la récupération des fichiers pour l'album %s : %v", bucketName, err)
+	for len(objects) > 0 {
+		batch := objects
+		if len(batch) > 1000 {
+			batch = batch[:1000]
+		}
+		objects = objects[len(batch):]
+
+		deleteReq := DeleteRequest{Quiet: true, Objects: batch}
+		payload, err := xml.Marshal(deleteReq)
+		if err != nil {
+			return fmt.Errorf("échec de la génération de la requête de suppression : %v", err)
+		}
+
+		req, err := http.NewRequest("POST", url, bytes.NewReader(payload))
+		if err != nil {
+			return fmt.Errorf("échec de la création de la requête de suppression : %v", err)
+		}
+		req.Header.Set("Content-Type", "application/xml")
+
+		resp, err := s.do(req)
+		if err != nil {
+			return fmt.Errorf("échec de la requête de suppression : %v", err)
+		}
+
+		// Le statut ne concerne que la requête : les échecs par clé sont dans le corps
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return fmt.Errorf("échec de la suppression des objets, statut : %s", resp.Status)
+		}
+		err = checkDeleteResult(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
 	}
-	defer resp.Body.Close()
+	return nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("échec de la requête, statut HTTP : %d", resp.StatusCode)
+// checkDeleteResult renvoie la première erreur par clé d'une réponse DeleteResult
+func checkDeleteResult(body io.Reader) error {
+	var result DeleteResult
+	if err := xml.NewDecoder(body).Decode(&result); err != nil {
+		return fmt.Errorf("erreur lors du décodage de la réponse XML : %v", err)
 	}
-
-	var listResponse struct {
-		Objects []struct {
-			Key string `xml:"Key"`
-		} `xml:"Contents"`
-	}
-
-	err = xml.NewDecoder(resp.Body).Decode(&listResponse)
-	if err != nil {
-		return nil, fmt.Errorf("échec du décodage XML : %v", err)
+	if len(result.Errors) > 0 {
+		first := result.Errors[0]
+		return fmt.Errorf("%d clé(s) en échec, %s : %s (%s)", len(result.Errors), first.Key, first.Code, first.Message)
 	}
+	return nil
+}
 
+// GetFilesInAlbum liste toutes les clés d'un bucket en suivant les pages de ListObjectsV2
+func (s *S3Service) GetFilesInAlbum(bucketName string) ([]string, error) {
 	fileNames := []string{}
-	for _, obj := range listResponse.Objects {
-		fileNames = append(fileNames, obj.Key)
+	continuationToken := ""
+
+	for {
+		query := url.Values{"list-type": {"2"}}
+		if continuationToken != "" {
+			query.Set("continuation-token", continuationToken)
+		}
+		listURL := fmt.Sprintf("%s/%s/?%s", s.APIURL, bucketName, query.Encode())
+
+		resp, err := s.get(listURL)
+		if err != nil {
+			return nil, fmt.Errorf("échec de la récupération des fichiers pour l'album %s : %v", bucketName, err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("échec de la requête, statut HTTP : %d", resp.StatusCode)
+		}
+
+		var listResponse struct {
+			Objects []struct {
+				Key string `xml:"Key"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+
+		err = xml.NewDecoder(resp.Body).Decode(&listResponse)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("échec du décodage XML : %v", err)
+		}
+
+		for _, obj := range listResponse.Objects {
+			fileNames = append(fileNames, obj.Key)
+		}
+
+		if !listResponse.IsTruncated || listResponse.NextContinuationToken == "" {
+			break
+		}
+		continuationToken = listResponse.NextContinuationToken
 	}
 
 	log.Printf("Fichiers récupérés pour l'album %s : %v", bucketName, fileNames)
 	return fileNames, nil
 }
 
+// listObjectVersions liste toutes les versions et marqueurs de suppression d'un bucket en suivant les pages
+func (s *S3Service) listObjectVersions(bucketName string) ([]ObjectToDelete, error) {
+	versions := []ObjectToDelete{}
+	keyMarker, versionIDMarker := "", ""
+
+	for {
+		query := url.Values{"versions": {""}}
+		if keyMarker != "" {
+			query.Set("key-marker", keyMarker)
+			query.Set("version-id-marker", versionIDMarker)
+		}
+		listURL := fmt.Sprintf("%s/%s/?%s", s.APIURL, bucketName, query.Encode())
+
+		resp, err := s.get(listURL)
+		if err != nil {
+			return nil, fmt.Errorf("échec du listage des versions de %s : %v", bucketName, err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("échec de la requête, statut HTTP : %d", resp.StatusCode)
+		}
+
+		var listResponse struct {
+			Versions            []ObjectToDelete `xml:"Version"`
+			DeleteMarkers       []ObjectToDelete `xml:"DeleteMarker"`
+			IsTruncated         bool             `xml:"IsTruncated"`
+			NextKeyMarker       string           `xml:"NextKeyMarker"`
+			NextVersionIdMarker string           `xml:"NextVersionIdMarker"`
+		}
+
+		err = xml.NewDecoder(resp.Body).Decode(&listResponse)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("échec du décodage XML : %v", err)
+		}
+
+		versions = append(versions, listResponse.Versions...)
+		versions = append(versions, listResponse.DeleteMarkers...)
+
+		if !listResponse.IsTruncated || listResponse.NextKeyMarker == "" {
+			break
+		}
+		keyMarker, versionIDMarker = listResponse.NextKeyMarker, listResponse.NextVersionIdMarker
+	}
+
+	return versions, nil
+}
+
 func (s *S3Service) DownloadTempFile(bucketName, objectName string) (string, error) {
 	localPath := fmt.Sprintf("/tmp/%s", objectName)
 
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	signV4Algorithm = "AWS4-HMAC-SHA256"
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+	iso8601Format   = "20060102T150405Z"
+	yyyymmdd        = "20060102"
+
+	// Durée de validité maximale d'une URL présignée acceptée par l'API S3-like
+	maxPresignExpires = 7 * 24 * time.Hour
+)
+
+// signRequest signe une requête vers l'API S3-like avec AWS Signature Version 4
+func (s *S3Service) signRequest(req *http.Request, t time.Time) {
+	t = t.UTC()
+	req.Header.Set("X-Amz-Date", t.Format(iso8601Format))
+	if req.Header.Get("X-Amz-Content-Sha256") == "" {
+		req.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+	}
+
+	signedHeaders := []string{"host"}
+	for name := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") || lower == "content-type" {
+			signedHeaders = append(signedHeaders, lower)
+		}
+	}
+	sort.Strings(signedHeaders)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range signedHeaders {
+		value := req.Host
+		if value == "" {
+			value = req.URL.Host
+		}
+		if name != "host" {
+			value = strings.Join(strings.Fields(req.Header.Get(name)), " ")
+		}
+		canonicalHeaders.WriteString(name + ":" + value + "\n")
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s3URIEncode(req.URL.Path, false),
+		s3CanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		strings.Join(signedHeaders, ";"),
+		req.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+
+	scope := s.credentialScope(t)
+	signature := hex.EncodeToString(hmacSHA256(s.signingKey(t), s.stringToSign(t, scope, canonicalRequest)))
+
+	req.Header.Set("Authorization", signV4Algorithm+
+		" Credential="+s.AccessKey+"/"+scope+
+		", SignedHeaders="+strings.Join(signedHeaders, ";")+
+		", Signature="+signature)
+}
+
+// PresignURL renvoie une URL de l'objet signée dans sa query string : le navigateur peut l'utiliser
+// directement (GET pour télécharger, PUT pour uploader) jusqu'à son expiration, sans passer par la gateway
+func (s *S3Service) PresignURL(method, objectPath string, expires time.Duration) (string, error) {
+	if expires < time.Second || expires > maxPresignExpires {
+		return "", fmt.Errorf("durée de validité invalide : %s (maximum %s)", expires, maxPresignExpires)
+	}
+
+	baseURL := s.PublicURL
+	if baseURL == "" {
+		baseURL = s.APIURL
+	}
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return "", fmt.Errorf("URL publique invalide : %v", err)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + strings.TrimPrefix(objectPath, "/")
+
+	t := time.Now().UTC()
+	scope := s.credentialScope(t)
+	query := url.Values{
+		"X-Amz-Algorithm":     {signV4Algorithm},
+		"X-Amz-Credential":    {s.AccessKey + "/" + scope},
+		"X-Amz-Date":          {t.Format(iso8601Format)},
+		"X-Amz-Expires":       {strconv.Itoa(int(expires / time.Second))},
+		"X-Amz-SignedHeaders": {"host"},
+	}
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		s3URIEncode(u.Path, false),
+		s3CanonicalQuery(query),
+		"host:" + u.Host + "\n",
+		"host",
+		unsignedPayload,
+	}, "\n")
+	query.Set("X-Amz-Signature", hex.EncodeToString(hmacSHA256(s.signingKey(t), s.stringToSign(t, scope, canonicalRequest))))
+
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+func (s *S3Service) credentialScope(t time.Time) string {
+	return t.Format(yyyymmdd) + "/" + s.Region + "/s3/aws4_request"
+}
+
+func (s *S3Service) stringToSign(t time.Time, scope, canonicalRequest string) string {
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	return strings.Join([]string{signV4Algorithm, t.Format(iso8601Format), scope, hex.EncodeToString(hash[:])}, "\n")
+}
+
+func (s *S3Service) signingKey(t time.Time) []byte {
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), t.Format(yyyymmdd))
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, "s3")
+	return hmacSHA256(key, "aws4_request")
+}
+
+func s3CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var params []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			params = append(params, s3URIEncode(key, true)+"="+s3URIEncode(value, true))
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+// s3URIEncode encode une chaîne selon les règles de SigV4 (caractères non réservés en clair)
+func s3URIEncode(value string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			b.WriteString("%" + strings.ToUpper(hex.EncodeToString([]byte{c})))
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
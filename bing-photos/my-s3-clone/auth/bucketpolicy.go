@@ -0,0 +1,231 @@
+package auth
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/s3errors"
+)
+
+// Préfixe des ressources d'une politique de bucket : "arn:aws:s3:::photos/public/*"
+const resourceARNPrefix = "arn:aws:s3:::"
+
+// Versions acceptées du langage des politiques
+var policyVersions = map[string]bool{"": true, "2012-10-17": true, "2008-10-17": true}
+
+// Opérateurs de condition pris en charge, et si la valeur de la requête doit n'égaler aucune des valeurs données
+var conditionOperators = map[string]bool{
+	"StringEquals":    false,
+	"StringNotEquals": true,
+	"StringLike":      false,
+	"StringNotLike":   true,
+	"IpAddress":       false,
+	"NotIpAddress":    true,
+	"Bool":            false,
+}
+
+// Clés de condition prises en charge, en minuscules comme dans leur comparaison
+var conditionKeys = map[string]bool{
+	"aws:sourceip":        true,
+	"aws:referer":         true,
+	"aws:useragent":       true,
+	"aws:securetransport": true,
+	"s3:prefix":           true,
+}
+
+// PolicyRequest décrit une requête anonyme soumise à une politique de bucket
+type PolicyRequest struct {
+	Action          string
+	Resource        string // "bucket/clé" pour un objet, le bucket sinon (un listing est filtré par Prefix)
+	SourceIP        net.IP
+	Referer         string
+	UserAgent       string
+	SecureTransport bool
+	Prefix          string // préfixe d'un listing, clé de condition s3:prefix
+}
+
+// ValidateBucketPolicy vérifie qu'une politique n'utilise que la partie prise en charge de la grammaire S3 :
+// Principal "*", des actions connues, des ressources du bucket lui-même et des conditions simples
+func ValidateBucketPolicy(bucketName string, policy dto.BucketPolicy) error {
+	if !policyVersions[policy.Version] {
+		return fmt.Errorf("%w: unsupported version %q", s3errors.ErrMalformedPolicy, policy.Version)
+	}
+	if len(policy.Statements) == 0 {
+		return fmt.Errorf("%w: at least one statement is required", s3errors.ErrMalformedPolicy)
+	}
+	for i, statement := range policy.Statements {
+		if statement.Effect != "Allow" && statement.Effect != "Deny" {
+			return fmt.Errorf("%w: statement %d effect must be Allow or Deny", s3errors.ErrMalformedPolicy, i+1)
+		}
+		if len(statement.Principal.AWS) != 1 || statement.Principal.AWS[0] != "*" {
+			return fmt.Errorf("%w: statement %d principal must be \"*\"", s3errors.ErrMalformedPolicy, i+1)
+		}
+		if len(statement.Actions) == 0 || len(statement.Resources) == 0 {
+			return fmt.Errorf("%w: statement %d needs actions and resources", s3errors.ErrMalformedPolicy, i+1)
+		}
+		for _, pattern := range statement.Actions {
+			if !strings.HasPrefix(pattern, "s3:") || !matchesKnownAction(pattern) {
+				return fmt.Errorf("%w: unknown action %q", s3errors.ErrMalformedPolicy, pattern)
+			}
+		}
+		for _, pattern := range statement.Resources {
+			resource := strings.TrimPrefix(pattern, resourceARNPrefix)
+			if resource == pattern || (resource != bucketName && !strings.HasPrefix(resource, bucketName+"/")) {
+				return fmt.Errorf("%w: resource %q is not in bucket %s", s3errors.ErrMalformedPolicy, pattern, bucketName)
+			}
+		}
+		if err := validateConditions(statement.Conditions); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateConditions(conditions map[string]map[string]dto.StringList) error {
+	for operator, values := range conditions {
+		if _, ok := conditionOperators[operator]; !ok {
+			return fmt.Errorf("%w: unsupported condition operator %q", s3errors.ErrMalformedPolicy, operator)
+		}
+		for key, patterns := range values {
+			if !conditionKeys[strings.ToLower(key)] {
+				return fmt.Errorf("%w: unsupported condition key %q", s3errors.ErrMalformedPolicy, key)
+			}
+			if len(patterns) == 0 {
+				return fmt.Errorf("%w: condition %s on %s has no value", s3errors.ErrMalformedPolicy, operator, key)
+			}
+			for _, pattern := range patterns {
+				if err := validateConditionValue(operator, pattern); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func validateConditionValue(operator, value string) error {
+	switch operator {
+	case "IpAddress", "NotIpAddress":
+		if _, err := parseIPRange(value); err != nil {
+			return fmt.Errorf("%w: %v", s3errors.ErrMalformedPolicy, err)
+		}
+	case "Bool":
+		if value != "true" && value != "false" {
+			return fmt.Errorf("%w: %q is not a boolean", s3errors.ErrMalformedPolicy, value)
+		}
+	}
+	return nil
+}
+
+// BucketPolicyAllows évalue une politique pour une requête anonyme : un refus explicite l'emporte,
+// sinon une déclaration Allow dont les conditions sont toutes remplies est nécessaire.
+// Seules les actions S3 peuvent être autorisées, jamais celles de l'API d'administration.
+func BucketPolicyAllows(policy dto.BucketPolicy, req PolicyRequest) bool {
+	if !strings.HasPrefix(req.Action, "s3:") {
+		return false
+	}
+	allowed := false
+	for _, statement := range policy.Statements {
+		if !statementApplies(statement, req) {
+			continue
+		}
+		if statement.Effect == "Deny" {
+			return false
+		}
+		allowed = true
+	}
+	return allowed
+}
+
+func statementApplies(statement dto.BucketPolicyStatement, req PolicyRequest) bool {
+	if !matchesAny(statement.Actions, req.Action) {
+		return false
+	}
+	resourceMatches := false
+	for _, pattern := range statement.Resources {
+		if matchWildcard(strings.TrimPrefix(pattern, resourceARNPrefix), req.Resource) {
+			resourceMatches = true
+			break
+		}
+	}
+	if !resourceMatches {
+		return false
+	}
+	for operator, values := range statement.Conditions {
+		for key, patterns := range values {
+			if !conditionHolds(operator, strings.ToLower(key), patterns, req) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Une condition est remplie si la valeur de la requête correspond à l'une des valeurs données,
+// ou à aucune pour les opérateurs négatifs
+func conditionHolds(operator, key string, patterns []string, req PolicyRequest) bool {
+	matched := false
+	for _, pattern := range patterns {
+		if conditionMatches(operator, key, pattern, req) {
+			matched = true
+			break
+		}
+	}
+	return matched != conditionOperators[operator]
+}
+
+func conditionMatches(operator, key, pattern string, req PolicyRequest) bool {
+	switch operator {
+	case "IpAddress", "NotIpAddress":
+		ipRange, err := parseIPRange(pattern)
+		return err == nil && key == "aws:sourceip" && req.SourceIP != nil && ipRange.Contains(req.SourceIP)
+	case "StringLike", "StringNotLike":
+		return matchWildcard(pattern, conditionValue(key, req))
+	default:
+		return pattern == conditionValue(key, req)
+	}
+}
+
+func conditionValue(key string, req PolicyRequest) string {
+	switch key {
+	case "aws:sourceip":
+		if req.SourceIP == nil {
+			return ""
+		}
+		return req.SourceIP.String()
+	case "aws:referer":
+		return req.Referer
+	case "aws:useragent":
+		return req.UserAgent
+	case "aws:securetransport":
+		if req.SecureTransport {
+			return "true"
+		}
+		return "false"
+	case "s3:prefix":
+		return req.Prefix
+	}
+	return ""
+}
+
+// Plage d'adresses en notation CIDR ("203.0.113.0/24"), ou adresse seule
+func parseIPRange(value string) (*net.IPNet, error) {
+	if !strings.Contains(value, "/") {
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address %q", value)
+		}
+		bits := 8 * net.IPv6len
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 8*net.IPv4len
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, ipRange, err := net.ParseCIDR(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid IP range %q", value)
+	}
+	return ipRange, nil
+}
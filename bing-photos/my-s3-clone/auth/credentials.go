@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"log"
+	"strings"
+)
+
+// CredentialStore fournit la clé secrète associée à une access key
+type CredentialStore interface {
+	GetSecretKey(accessKey string) (string, bool)
+}
+
+// StaticCredentials est un CredentialStore en mémoire (access key -> secret key)
+type StaticCredentials map[string]string
+
+func (c StaticCredentials) GetSecretKey(accessKey string) (string, bool) {
+	secretKey, ok := c[accessKey]
+	return secretKey, ok
+}
+
+// ParseCredentials lit une liste de paires au format "ak1:sk1,ak2:sk2" (S3_CREDENTIALS, -credentials) ;
+// les entrées mal formées sont ignorées
+func ParseCredentials(list string) StaticCredentials {
+	creds := StaticCredentials{}
+	for i, pair := range strings.Split(list, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			// Seule la position est journalisée : une entrée mal formée peut contenir un secret
+			log.Printf("Ignoring malformed credentials entry %d", i+1)
+			continue
+		}
+		creds[parts[0]] = parts[1]
+	}
+	return creds
+}
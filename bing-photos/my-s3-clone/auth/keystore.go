@@ -0,0 +1,223 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/s3errors"
+)
+
+// Authorizer décide des droits d'une access key dont la signature a été vérifiée
+type Authorizer interface {
+	Authorize(accessKey, action, resource string) bool
+}
+
+// KeyStore réunit les clés de la configuration, qui ont tous les droits, et les access keys gérées
+// par l'API d'administration, limitées chacune par sa politique. Les clés gérées sont persistées
+// dans un fichier JSON, relu au démarrage.
+type KeyStore struct {
+	path string // vide : les clés gérées ne sont gardées qu'en mémoire
+	root CredentialStore
+
+	mu   sync.RWMutex
+	keys map[string]dto.AccessKey
+}
+
+// NewKeyStore crée un KeyStore en mémoire au-dessus des clés root
+func NewKeyStore(root CredentialStore) *KeyStore {
+	return &KeyStore{root: root, keys: make(map[string]dto.AccessKey)}
+}
+
+// OpenKeyStore charge les clés gérées enregistrées dans path, qui est créé à la première clé
+func OpenKeyStore(path string, root CredentialStore) (*KeyStore, error) {
+	store := NewKeyStore(root)
+	store.path = path
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read access keys: %v", err)
+	}
+	var keys []dto.AccessKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("invalid access keys file %s: %v", path, err)
+	}
+	for _, key := range keys {
+		store.keys[key.AccessKey] = key
+	}
+	return store, nil
+}
+
+func (s *KeyStore) GetSecretKey(accessKey string) (string, bool) {
+	if secretKey, ok := s.root.GetSecretKey(accessKey); ok {
+		return secretKey, true
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	key, ok := s.keys[accessKey]
+	return key.SecretKey, ok
+}
+
+// Authorize applique la politique d'une clé gérée ; les clés de la configuration ont tous les droits
+func (s *KeyStore) Authorize(accessKey, action, resource string) bool {
+	if _, ok := s.root.GetSecretKey(accessKey); ok {
+		return true
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	key, ok := s.keys[accessKey]
+	return ok && PolicyAllows(key.Policy, action, resource)
+}
+
+// ListKeys renvoie les clés gérées triées par access key, sans leur clé secrète
+func (s *KeyStore) ListKeys() []dto.AccessKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]dto.AccessKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		key.SecretKey = ""
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].AccessKey < keys[j].AccessKey })
+	return keys
+}
+
+// GetKey renvoie une clé gérée sans sa clé secrète
+func (s *KeyStore) GetKey(accessKey string) (dto.AccessKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	key, ok := s.keys[accessKey]
+	if !ok {
+		return dto.AccessKey{}, s3errors.ErrNoSuchAccessKey
+	}
+	key.SecretKey = ""
+	return key, nil
+}
+
+// CreateKey génère une nouvelle paire de clés liée à policy. La clé secrète n'est renvoyée qu'ici.
+func (s *KeyStore) CreateKey(description string, policy dto.KeyPolicy) (dto.AccessKey, error) {
+	if err := ValidatePolicy(policy); err != nil {
+		return dto.AccessKey{}, err
+	}
+	key := dto.AccessKey{Description: description, CreationDate: time.Now().UTC(), Policy: policy}
+	var err error
+	if key.SecretKey, err = randomSecretKey(); err != nil {
+		return dto.AccessKey{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for {
+		if key.AccessKey, err = randomAccessKey(); err != nil {
+			return dto.AccessKey{}, err
+		}
+		_, managed := s.keys[key.AccessKey]
+		if _, root := s.root.GetSecretKey(key.AccessKey); !managed && !root {
+			break
+		}
+	}
+	s.keys[key.AccessKey] = key
+	if err := s.save(); err != nil {
+		delete(s.keys, key.AccessKey)
+		return dto.AccessKey{}, err
+	}
+	log.Printf("Access key %s created", key.AccessKey)
+	return key, nil
+}
+
+// PutKeyPolicy remplace la politique d'une clé gérée ; elle s'applique dès la requête suivante
+func (s *KeyStore) PutKeyPolicy(accessKey string, policy dto.KeyPolicy) error {
+	if err := ValidatePolicy(policy); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, ok := s.keys[accessKey]
+	if !ok {
+		return s3errors.ErrNoSuchAccessKey
+	}
+	previous := key.Policy
+	key.Policy = policy
+	s.keys[accessKey] = key
+	if err := s.save(); err != nil {
+		key.Policy = previous
+		s.keys[accessKey] = key
+		return err
+	}
+	return nil
+}
+
+// DeleteKey révoque une clé gérée
+func (s *KeyStore) DeleteKey(accessKey string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, ok := s.keys[accessKey]
+	if !ok {
+		return s3errors.ErrNoSuchAccessKey
+	}
+	delete(s.keys, accessKey)
+	if err := s.save(); err != nil {
+		s.keys[accessKey] = key
+		return err
+	}
+	log.Printf("Access key %s deleted", accessKey)
+	return nil
+}
+
+// Écriture des clés gérées, à appeler avec mu pris. Le fichier contient les clés secrètes :
+// il n'est lisible que par le serveur et est écrit à côté puis renommé pour ne jamais être lu à moitié écrit.
+func (s *KeyStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+	keys := make([]dto.AccessKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].AccessKey < keys[j].AccessKey })
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create access keys directory: %v", err)
+	}
+	if err := os.WriteFile(s.path+".tmp", data, 0o600); err != nil {
+		return fmt.Errorf("failed to write access keys: %v", err)
+	}
+	if err := os.Rename(s.path+".tmp", s.path); err != nil {
+		return fmt.Errorf("failed to save access keys: %v", err)
+	}
+	return nil
+}
+
+// Access key au format AWS : "AKIA" suivi de 16 caractères majuscules ou chiffres
+func randomAccessKey() (string, error) {
+	buf := make([]byte, 10)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "AKIA" + base32.StdEncoding.EncodeToString(buf)[:16], nil
+}
+
+// Clé secrète de 40 caractères, comme celles d'AWS
+func randomSecretKey() (string, error) {
+	buf := make([]byte, 30)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf), nil
+}
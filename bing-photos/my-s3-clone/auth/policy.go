@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/s3errors"
+)
+
+// Actions autorisables par la politique d'une access key, une par route du serveur
+const (
+	ActionListAllMyBuckets                 = "s3:ListAllMyBuckets"
+	ActionCreateBucket                     = "s3:CreateBucket"
+	ActionDeleteBucket                     = "s3:DeleteBucket"
+	ActionListBucket                       = "s3:ListBucket"
+	ActionListBucketVersions               = "s3:ListBucketVersions"
+	ActionListBucketMultipartUploads       = "s3:ListBucketMultipartUploads"
+	ActionGetBucketLocation                = "s3:GetBucketLocation"
+	ActionGetBucketVersioning              = "s3:GetBucketVersioning"
+	ActionPutBucketVersioning              = "s3:PutBucketVersioning"
+	ActionGetLifecycleConfiguration        = "s3:GetLifecycleConfiguration"
+	ActionPutLifecycleConfiguration        = "s3:PutLifecycleConfiguration"
+	ActionGetBucketCORS                    = "s3:GetBucketCORS"
+	ActionPutBucketCORS                    = "s3:PutBucketCORS"
+	ActionGetBucketPolicy                  = "s3:GetBucketPolicy"
+	ActionPutBucketPolicy                  = "s3:PutBucketPolicy"
+	ActionDeleteBucketPolicy               = "s3:DeleteBucketPolicy"
+	ActionGetBucketNotification            = "s3:GetBucketNotification"
+	ActionPutBucketNotification            = "s3:PutBucketNotification"
+	ActionGetBucketObjectLockConfiguration = "s3:GetBucketObjectLockConfiguration"
+	ActionPutBucketObjectLockConfiguration = "s3:PutBucketObjectLockConfiguration"
+	ActionGetObject                        = "s3:GetObject"
+	ActionPutObject                        = "s3:PutObject"
+	ActionDeleteObject                     = "s3:DeleteObject"
+	ActionListMultipartUploadParts         = "s3:ListMultipartUploadParts"
+	ActionAbortMultipartUpload             = "s3:AbortMultipartUpload"
+	ActionGetObjectRetention               = "s3:GetObjectRetention"
+	ActionPutObjectRetention               = "s3:PutObjectRetention"
+	ActionBypassGovernanceRetention        = "s3:BypassGovernanceRetention"
+	ActionGetObjectLegalHold               = "s3:GetObjectLegalHold"
+	ActionPutObjectLegalHold               = "s3:PutObjectLegalHold"
+	ActionGetObjectTagging                 = "s3:GetObjectTagging"
+	ActionPutObjectTagging                 = "s3:PutObjectTagging"
+	ActionDeleteObjectTagging              = "s3:DeleteObjectTagging"
+
+	// API d'administration
+	ActionGetUsage     = "admin:GetUsage"
+	ActionManageQuotas = "admin:ManageQuotas"
+	ActionManageKeys   = "admin:ManageKeys"
+)
+
+var knownActions = []string{
+	ActionListAllMyBuckets, ActionCreateBucket, ActionDeleteBucket, ActionListBucket, ActionListBucketVersions,
+	ActionListBucketMultipartUploads, ActionGetBucketLocation, ActionGetBucketVersioning, ActionPutBucketVersioning,
+	ActionGetLifecycleConfiguration, ActionPutLifecycleConfiguration, ActionGetBucketCORS, ActionPutBucketCORS,
+	ActionGetBucketPolicy, ActionPutBucketPolicy, ActionDeleteBucketPolicy, ActionGetBucketNotification,
+	ActionPutBucketNotification,
+	ActionGetBucketObjectLockConfiguration, ActionPutBucketObjectLockConfiguration, ActionGetObject, ActionPutObject,
+	ActionDeleteObject, ActionListMultipartUploadParts, ActionAbortMultipartUpload, ActionGetObjectRetention,
+	ActionPutObjectRetention, ActionBypassGovernanceRetention, ActionGetObjectLegalHold, ActionPutObjectLegalHold, ActionGetObjectTagging,
+	ActionPutObjectTagging, ActionDeleteObjectTagging, ActionGetUsage, ActionManageQuotas, ActionManageKeys,
+}
+
+// IsListAction indique si action porte sur un préfixe du bucket ("bucket/préfixe") plutôt que sur le bucket lui-même
+func IsListAction(action string) bool {
+	return action == ActionListBucket || action == ActionListBucketVersions || action == ActionListBucketMultipartUploads
+}
+
+// ObjectResource est la ressource d'un objet dans les politiques
+func ObjectResource(bucketName, objectName string) string {
+	return bucketName + "/" + objectName
+}
+
+// ValidatePolicy vérifie qu'une politique ne contient que des déclarations complètes,
+// dont chaque action désigne au moins une action connue
+func ValidatePolicy(policy dto.KeyPolicy) error {
+	for i, statement := range policy.Statements {
+		if len(statement.Actions) == 0 || len(statement.Resources) == 0 {
+			return fmt.Errorf("%w: statement %d needs actions and resources", s3errors.ErrMalformedPolicy, i+1)
+		}
+		for _, pattern := range statement.Actions {
+			if !matchesKnownAction(pattern) {
+				return fmt.Errorf("%w: unknown action %q", s3errors.ErrMalformedPolicy, pattern)
+			}
+		}
+		for _, pattern := range statement.Resources {
+			if pattern == "" {
+				return fmt.Errorf("%w: statement %d has an empty resource", s3errors.ErrMalformedPolicy, i+1)
+			}
+		}
+	}
+	return nil
+}
+
+func matchesKnownAction(pattern string) bool {
+	for _, action := range knownActions {
+		if matchWildcard(pattern, action) {
+			return true
+		}
+	}
+	return false
+}
+
+// PolicyAllows indique si une déclaration de policy autorise action sur resource
+func PolicyAllows(policy dto.KeyPolicy, action, resource string) bool {
+	for _, statement := range policy.Statements {
+		if matchesAny(statement.Actions, action) && matchesAny(statement.Resources, resource) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if matchWildcard(pattern, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchWildcard compare value à un motif dont chaque "*" remplace une suite quelconque de caractères, "/" compris
+func matchWildcard(pattern, value string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == value
+	}
+	if !strings.HasPrefix(value, parts[0]) {
+		return false
+	}
+	value = value[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(value, part)
+		if i < 0 {
+			return false
+		}
+		value = value[i+len(part):]
+	}
+	return len(value) >= len(last) && strings.HasSuffix(value, last)
+}
@@ -0,0 +1,503 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"my-s3-clone/s3errors"
+)
+
+const (
+	SignV4Algorithm  = "AWS4-HMAC-SHA256"
+	UnsignedPayload  = "UNSIGNED-PAYLOAD"
+	StreamingPayload = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	// Préfixe des autres encodages aws-chunked (checksums en trailer, signatures ECDSA), non pris en charge
+	StreamingPrefix = "STREAMING-"
+
+	iso8601Format = "20060102T150405Z"
+	yyyymmdd      = "20060102"
+	scopeSuffix   = "aws4_request"
+
+	// Écart maximal toléré entre l'horloge du client et celle du serveur
+	maxClockSkew = 15 * time.Minute
+
+	// Durée de validité maximale d'une URL présignée (7 jours)
+	MaxPresignExpires = 7 * 24 * time.Hour
+)
+
+// Credential décrit une requête dont la signature a été vérifiée
+type Credential struct {
+	AccessKey  string
+	Date       time.Time
+	Region     string
+	Service    string
+	Signature  string
+	SigningKey []byte
+}
+
+// Scope renvoie le credential scope "date/region/service/aws4_request"
+func (c *Credential) Scope() string {
+	return strings.Join([]string{c.Date.Format(yyyymmdd), c.Region, c.Service, scopeSuffix}, "/")
+}
+
+// Verifier vérifie les signatures AWS Signature Version 4
+type Verifier struct {
+	Credentials CredentialStore
+	Now         func() time.Time
+}
+
+// NewVerifier crée un Verifier basé sur l'horloge système
+func NewVerifier(creds CredentialStore) *Verifier {
+	return &Verifier{Credentials: creds, Now: time.Now}
+}
+
+// authHeader représente les composants de l'en-tête Authorization
+type authHeader struct {
+	accessKey     string
+	date          string
+	region        string
+	service       string
+	signedHeaders []string
+	signature     string
+}
+
+// Verify recalcule la signature de la requête et la compare à celle fournie par le client,
+// dans l'en-tête Authorization ou dans la query string d'une URL présignée
+func (v *Verifier) Verify(r *http.Request) (*Credential, error) {
+	authorization := r.Header.Get("Authorization")
+	if _, presigned := r.URL.Query()["X-Amz-Algorithm"]; presigned {
+		if authorization != "" {
+			return nil, s3errors.ErrMultipleAuthMechanisms
+		}
+		return v.verifyPresigned(r)
+	}
+	if authorization == "" {
+		return nil, s3errors.ErrAccessDenied
+	}
+	if !strings.HasPrefix(authorization, SignV4Algorithm+" ") {
+		return nil, s3errors.ErrSignatureVersionNotSupported
+	}
+
+	header, err := parseAuthHeader(strings.TrimPrefix(authorization, SignV4Algorithm+" "))
+	if err != nil {
+		return nil, err
+	}
+
+	requestTime, err := parseRequestTime(r)
+	if err != nil {
+		return nil, err
+	}
+	if requestTime.Format(yyyymmdd) != header.date {
+		return nil, s3errors.ErrAuthorizationHeaderMalformed
+	}
+	if skew := v.Now().Sub(requestTime); skew > maxClockSkew || skew < -maxClockSkew {
+		return nil, s3errors.ErrRequestTimeTooSkewed
+	}
+
+	secretKey, ok := v.Credentials.GetSecretKey(header.accessKey)
+	if !ok {
+		return nil, s3errors.ErrInvalidAccessKeyID
+	}
+
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash == "" {
+		payloadHash = UnsignedPayload
+	}
+
+	cred := &Credential{
+		AccessKey:  header.accessKey,
+		Date:       requestTime,
+		Region:     header.region,
+		Service:    header.service,
+		SigningKey: SigningKey(secretKey, requestTime, header.region, header.service),
+	}
+
+	canonicalRequest := CanonicalRequest(r, header.signedHeaders, payloadHash, "")
+	expected := cred.sign(StringToSign(requestTime, cred.Scope(), canonicalRequest))
+	if !hmac.Equal([]byte(expected), []byte(header.signature)) {
+		return nil, s3errors.ErrSignatureDoesNotMatch
+	}
+
+	cred.Signature = header.signature
+	return cred, nil
+}
+
+// verifyPresigned vérifie une URL présignée : la signature, la date et la durée de validité
+// sont portées par les paramètres X-Amz-* de la query string
+func (v *Verifier) verifyPresigned(r *http.Request) (*Credential, error) {
+	// Les URLs présignées ne servent qu'au téléchargement et à l'upload direct d'un objet
+	if r.Method != http.MethodGet && r.Method != http.MethodHead && r.Method != http.MethodPut {
+		return nil, s3errors.ErrAccessDenied
+	}
+
+	query := r.URL.Query()
+	if query.Get("X-Amz-Algorithm") != SignV4Algorithm {
+		return nil, s3errors.ErrSignatureVersionNotSupported
+	}
+	for _, param := range []string{"X-Amz-Credential", "X-Amz-Date", "X-Amz-Expires", "X-Amz-SignedHeaders", "X-Amz-Signature"} {
+		if query.Get(param) == "" {
+			return nil, s3errors.ErrAuthorizationQueryParametersError
+		}
+	}
+
+	scope, err := parseCredentialScope(query.Get("X-Amz-Credential"))
+	if err != nil {
+		return nil, s3errors.ErrAuthorizationQueryParametersError
+	}
+	signedHeaders := strings.Split(query.Get("X-Amz-SignedHeaders"), ";")
+	if !signsHost(signedHeaders) {
+		return nil, s3errors.ErrAuthorizationQueryParametersError
+	}
+
+	requestTime, err := time.Parse(iso8601Format, query.Get("X-Amz-Date"))
+	if err != nil {
+		return nil, s3errors.ErrAuthorizationQueryParametersError
+	}
+	if requestTime.Format(yyyymmdd) != scope.date {
+		return nil, s3errors.ErrAuthorizationQueryParametersError
+	}
+
+	expires, err := strconv.Atoi(query.Get("X-Amz-Expires"))
+	if err != nil || expires < 1 || time.Duration(expires)*time.Second > MaxPresignExpires {
+		return nil, s3errors.ErrInvalidPresignExpires
+	}
+	now := v.Now()
+	if now.Before(requestTime.Add(-maxClockSkew)) {
+		return nil, s3errors.ErrRequestNotReadyYet
+	}
+	if now.After(requestTime.Add(time.Duration(expires) * time.Second)) {
+		return nil, s3errors.ErrExpiredPresignRequest
+	}
+
+	secretKey, ok := v.Credentials.GetSecretKey(scope.accessKey)
+	if !ok {
+		return nil, s3errors.ErrInvalidAccessKeyID
+	}
+
+	// Le contenu d'un upload présigné n'est pas signé, sauf si le client fournit son hash
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash == "" {
+		payloadHash = UnsignedPayload
+	}
+
+	cred := &Credential{
+		AccessKey:  scope.accessKey,
+		Date:       requestTime,
+		Region:     scope.region,
+		Service:    scope.service,
+		SigningKey: SigningKey(secretKey, requestTime, scope.region, scope.service),
+	}
+
+	canonicalRequest := CanonicalRequest(r, signedHeaders, payloadHash, "X-Amz-Signature")
+	expected := cred.sign(StringToSign(requestTime, cred.Scope(), canonicalRequest))
+	if !hmac.Equal([]byte(expected), []byte(query.Get("X-Amz-Signature"))) {
+		return nil, s3errors.ErrSignatureDoesNotMatch
+	}
+
+	cred.Signature = query.Get("X-Amz-Signature")
+	return cred, nil
+}
+
+// parseAuthHeader découpe "Credential=..., SignedHeaders=..., Signature=..."
+func parseAuthHeader(value string) (authHeader, error) {
+	var header authHeader
+	fields := map[string]string{}
+	for _, part := range strings.Split(value, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			return header, s3errors.ErrAuthorizationHeaderMalformed
+		}
+		fields[kv[0]] = kv[1]
+	}
+
+	scope, err := parseCredentialScope(fields["Credential"])
+	if err != nil {
+		return header, err
+	}
+	if fields["SignedHeaders"] == "" || fields["Signature"] == "" {
+		return header, s3errors.ErrAuthorizationHeaderMalformed
+	}
+
+	header = scope
+	header.signedHeaders = strings.Split(fields["SignedHeaders"], ";")
+	header.signature = fields["Signature"]
+	if !signsHost(header.signedHeaders) {
+		return header, s3errors.ErrAuthorizationHeaderMalformed
+	}
+	return header, nil
+}
+
+// Host doit toujours être signé : sans lui, une signature resterait valable envoyée à un autre serveur
+func signsHost(signedHeaders []string) bool {
+	for _, name := range signedHeaders {
+		if name == "host" {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCredentialScope découpe "accessKey/date/region/service/aws4_request"
+func parseCredentialScope(value string) (authHeader, error) {
+	scope := strings.Split(value, "/")
+	if len(scope) != 5 || scope[0] == "" || scope[4] != scopeSuffix {
+		return authHeader{}, s3errors.ErrAuthorizationHeaderMalformed
+	}
+	return authHeader{accessKey: scope[0], date: scope[1], region: scope[2], service: scope[3]}, nil
+}
+
+// parseRequestTime lit X-Amz-Date, ou Date à défaut
+func parseRequestTime(r *http.Request) (time.Time, error) {
+	if amzDate := r.Header.Get("X-Amz-Date"); amzDate != "" {
+		t, err := time.Parse(iso8601Format, amzDate)
+		if err != nil {
+			return time.Time{}, s3errors.ErrMissingDateHeader
+		}
+		return t, nil
+	}
+	if date := r.Header.Get("Date"); date != "" {
+		t, err := http.ParseTime(date)
+		if err != nil {
+			return time.Time{}, s3errors.ErrMissingDateHeader
+		}
+		return t.UTC(), nil
+	}
+	return time.Time{}, s3errors.ErrMissingDateHeader
+}
+
+// CanonicalRequest construit la requête canonique SigV4.
+// skipQuery permet d'exclure un paramètre (X-Amz-Signature pour les URLs présignées).
+func CanonicalRequest(r *http.Request, signedHeaders []string, payloadHash, skipQuery string) string {
+	var headers strings.Builder
+	for _, name := range signedHeaders {
+		headers.WriteString(name)
+		headers.WriteByte(':')
+		headers.WriteString(canonicalHeaderValue(r, name))
+		headers.WriteByte('\n')
+	}
+
+	return strings.Join([]string{
+		r.Method,
+		canonicalURI(r.URL.Path),
+		canonicalQuery(r.URL.RawQuery, skipQuery),
+		headers.String(),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+}
+
+// StringToSign construit la chaîne à signer à partir de la requête canonique
+func StringToSign(t time.Time, scope, canonicalRequest string) string {
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	return strings.Join([]string{
+		SignV4Algorithm,
+		t.Format(iso8601Format),
+		scope,
+		hex.EncodeToString(hash[:]),
+	}, "\n")
+}
+
+// SigningKey dérive la clé de signature à partir de la clé secrète
+func SigningKey(secretKey string, t time.Time, region, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secretKey), t.Format(yyyymmdd))
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	return hmacSHA256(key, scopeSuffix)
+}
+
+func (c *Credential) sign(stringToSign string) string {
+	return hex.EncodeToString(hmacSHA256(c.SigningKey, stringToSign))
+}
+
+// SignRequest signe une requête avec l'en-tête Authorization (clients internes et tests)
+func SignRequest(r *http.Request, accessKey, secretKey, region string, t time.Time) {
+	t = t.UTC()
+	r.Header.Set("X-Amz-Date", t.Format(iso8601Format))
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash == "" {
+		payloadHash = UnsignedPayload
+		r.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	signedHeaders := []string{"host"}
+	for name := range r.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") || lower == "content-md5" || lower == "content-type" {
+			signedHeaders = append(signedHeaders, lower)
+		}
+	}
+	sort.Strings(signedHeaders)
+
+	cred := &Credential{
+		AccessKey:  accessKey,
+		Date:       t,
+		Region:     region,
+		Service:    "s3",
+		SigningKey: SigningKey(secretKey, t, region, "s3"),
+	}
+	signature := cred.sign(StringToSign(t, cred.Scope(), CanonicalRequest(r, signedHeaders, payloadHash, "")))
+
+	r.Header.Set("Authorization", SignV4Algorithm+
+		" Credential="+accessKey+"/"+cred.Scope()+
+		", SignedHeaders="+strings.Join(signedHeaders, ";")+
+		", Signature="+signature)
+}
+
+// PresignURL signe une URL dans sa query string : elle reste valable pendant expires,
+// sans en-tête Authorization (liens de téléchargement ou d'upload donnés à un navigateur)
+func PresignURL(method, rawURL, accessKey, secretKey, region string, t time.Time, expires time.Duration) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	t = t.UTC()
+
+	cred := &Credential{
+		AccessKey:  accessKey,
+		Date:       t,
+		Region:     region,
+		Service:    "s3",
+		SigningKey: SigningKey(secretKey, t, region, "s3"),
+	}
+
+	query := u.Query()
+	query.Set("X-Amz-Algorithm", SignV4Algorithm)
+	query.Set("X-Amz-Credential", accessKey+"/"+cred.Scope())
+	query.Set("X-Amz-Date", t.Format(iso8601Format))
+	query.Set("X-Amz-Expires", strconv.Itoa(int(expires/time.Second)))
+	query.Set("X-Amz-SignedHeaders", "host")
+	u.RawQuery = query.Encode()
+
+	r := &http.Request{Method: method, URL: u, Host: u.Host, Header: http.Header{}}
+	signature := cred.sign(StringToSign(t, cred.Scope(), CanonicalRequest(r, []string{"host"}, UnsignedPayload, "")))
+
+	u.RawQuery += "&X-Amz-Signature=" + signature
+	return u.String(), nil
+}
+
+func canonicalHeaderValue(r *http.Request, name string) string {
+	if name == "host" {
+		return r.Host
+	}
+
+	values := r.Header.Values(name)
+	if len(values) == 0 && name == "content-length" && r.ContentLength >= 0 {
+		return strconv.FormatInt(r.ContentLength, 10)
+	}
+
+	trimmed := make([]string, len(values))
+	for i, value := range values {
+		trimmed[i] = strings.Join(strings.Fields(value), " ")
+	}
+	return strings.Join(trimmed, ",")
+}
+
+func canonicalURI(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return uriEncode(path, false)
+}
+
+func canonicalQuery(rawQuery, skip string) string {
+	type param struct{ key, value string }
+	var params []param
+
+	for _, pair := range strings.Split(rawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		key, err := url.QueryUnescape(kv[0])
+		if err != nil {
+			key = kv[0]
+		}
+		if key == skip {
+			continue
+		}
+		value := ""
+		if len(kv) == 2 {
+			if value, err = url.QueryUnescape(kv[1]); err != nil {
+				value = kv[1]
+			}
+		}
+		params = append(params, param{uriEncode(key, true), uriEncode(value, true)})
+	}
+
+	sort.Slice(params, func(i, j int) bool {
+		if params[i].key != params[j].key {
+			return params[i].key < params[j].key
+		}
+		return params[i].value < params[j].value
+	})
+
+	encoded := make([]string, len(params))
+	for i, p := range params {
+		encoded[i] = p.key + "=" + p.value
+	}
+	return strings.Join(encoded, "&")
+}
+
+// uriEncode applique l'encodage URI de SigV4 : seuls les caractères non réservés restent en clair
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			b.WriteString("%" + strings.ToUpper(hex.EncodeToString([]byte{c})))
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// ChunkVerifier vérifie la chaîne de signatures d'un upload STREAMING-AWS4-HMAC-SHA256-PAYLOAD :
+// chaque chunk est signé à partir de la signature du chunk précédent, en partant de la signature de l'en-tête.
+type ChunkVerifier struct {
+	cred          *Credential
+	prevSignature string
+}
+
+// NewChunkVerifier initialise la chaîne avec la signature de la requête (seed signature)
+func NewChunkVerifier(cred *Credential) *ChunkVerifier {
+	return &ChunkVerifier{cred: cred, prevSignature: cred.Signature}
+}
+
+// VerifyChunk vérifie la signature d'un chunk à partir du SHA-256 de ses données
+func (v *ChunkVerifier) VerifyChunk(chunkSHA256 []byte, signature string) error {
+	emptyHash := sha256.Sum256(nil)
+	stringToSign := strings.Join([]string{
+		SignV4Algorithm + "-PAYLOAD",
+		v.cred.Date.Format(iso8601Format),
+		v.cred.Scope(),
+		v.prevSignature,
+		hex.EncodeToString(emptyHash[:]),
+		hex.EncodeToString(chunkSHA256),
+	}, "\n")
+
+	expected := v.cred.sign(stringToSign)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return s3errors.ErrSignatureDoesNotMatch
+	}
+
+	v.prevSignature = signature
+	return nil
+}
@@ -0,0 +1,238 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"my-s3-clone/auth"
+	"my-s3-clone/storage"
+)
+
+// Niveaux de journalisation
+const (
+	LogLevelInfo  = "info"  // démarrage, erreurs et opérations notables
+	LogLevelDebug = "debug" // en plus, chaque requête et chaque réponse avec son corps
+)
+
+// Config regroupe les paramètres du serveur. Chaque valeur vient, par ordre de priorité croissante,
+// des valeurs par défaut, du fichier YAML (-config ou S3_CONFIG_FILE), de l'environnement puis des options.
+type Config struct {
+	DataDir        string                 `yaml:"dataDir"`     // répertoire des buckets
+	Address        string                 `yaml:"address"`     // adresse d'écoute, ex: ":9090"
+	TLSCertFile    string                 `yaml:"tlsCertFile"` // certificat et clé TLS : HTTPS si renseignés
+	TLSKeyFile     string                 `yaml:"tlsKeyFile"`
+	AllowedOrigins []string               `yaml:"allowedOrigins"` // origines autorisées par CORS, "*" pour toutes
+	Credentials    auth.StaticCredentials `yaml:"credentials"`    // access key -> secret key, avec tous les droits
+	KeysFile       string                 `yaml:"keysFile"`       // access keys gérées par /_admin/keys, KeysPath si vide
+	LogLevel       string                 `yaml:"logLevel"`       // LogLevelInfo ou LogLevelDebug
+
+	// Clé maîtresse SSE-S3 (32 octets encodés en base64), vide pour stocker les objets en clair
+	MasterKey string `yaml:"masterKey"`
+
+	MultipartExpiry   time.Duration `yaml:"multipartExpiry"`   // âge des uploads multipart abandonnés supprimés
+	LifecycleInterval time.Duration `yaml:"lifecycleInterval"` // intervalle entre deux applications des règles de cycle de vie
+	LifecycleDryRun   bool          `yaml:"lifecycleDryRun"`   // journaliser les expirations sans rien supprimer
+}
+
+// Default renvoie la configuration utilisée sans fichier, variable d'environnement ni option
+func Default() Config {
+	return Config{
+		DataDir:           storage.DefaultRoot,
+		Address:           ":9090",
+		AllowedOrigins:    []string{"http://localhost:3000"},
+		Credentials:       auth.StaticCredentials{},
+		LogLevel:          LogLevelInfo,
+		MultipartExpiry:   7 * 24 * time.Hour,
+		LifecycleInterval: time.Hour,
+	}
+}
+
+// Load construit la configuration à partir des options de la ligne de commande (sans le nom du programme)
+func Load(args []string) (Config, error) {
+	flags := flag.NewFlagSet("my-s3-clone", flag.ContinueOnError)
+	configFile := flags.String("config", os.Getenv("S3_CONFIG_FILE"), "YAML configuration file")
+	dataDir := flags.String("data-dir", "", "directory holding the buckets")
+	address := flags.String("addr", "", "listen address, e.g. :9090")
+	tlsCert := flags.String("tls-cert", "", "TLS certificate file, serving HTTPS with -tls-key")
+	tlsKey := flags.String("tls-key", "", "TLS private key file")
+	origins := flags.String("allowed-origins", "", "comma-separated origins allowed by CORS, * for any")
+	credentials := flags.String("credentials", "", "comma-separated access key pairs, ak1:sk1,ak2:sk2")
+	keysFile := flags.String("keys-file", "", "JSON file of the access keys managed through /_admin/keys")
+	logLevel := flags.String("log-level", "", "log verbosity: info or debug")
+	multipartExpiry := flags.Duration("multipart-expiry", 0, "age after which incomplete multipart uploads are removed")
+	lifecycleInterval := flags.Duration("lifecycle-interval", 0, "interval between two lifecycle sweeps")
+	lifecycleDryRun := flags.Bool("lifecycle-dry-run", false, "only log the objects lifecycle rules would expire")
+	if err := flags.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	cfg := Default()
+	if *configFile != "" {
+		if err := cfg.loadFile(*configFile); err != nil {
+			return Config{}, err
+		}
+	}
+	if err := cfg.loadEnv(); err != nil {
+		return Config{}, err
+	}
+
+	// Seules les options présentes sur la ligne de commande remplacent les valeurs déjà lues
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "data-dir":
+			cfg.DataDir = *dataDir
+		case "addr":
+			cfg.Address = *address
+		case "tls-cert":
+			cfg.TLSCertFile = *tlsCert
+		case "tls-key":
+			cfg.TLSKeyFile = *tlsKey
+		case "allowed-origins":
+			cfg.AllowedOrigins = splitList(*origins)
+		case "credentials":
+			cfg.Credentials = auth.ParseCredentials(*credentials)
+		case "keys-file":
+			cfg.KeysFile = *keysFile
+		case "log-level":
+			cfg.LogLevel = *logLevel
+		case "multipart-expiry":
+			cfg.MultipartExpiry = *multipartExpiry
+		case "lifecycle-interval":
+			cfg.LifecycleInterval = *lifecycleInterval
+		case "lifecycle-dry-run":
+			cfg.LifecycleDryRun = *lifecycleDryRun
+		}
+	})
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// Lecture du fichier YAML : les clés absentes conservent leur valeur par défaut
+func (c *Config) loadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open configuration file: %w", err)
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid configuration file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Lecture des variables d'environnement S3_*
+func (c *Config) loadEnv() error {
+	setString := func(name string, value *string) {
+		if v := os.Getenv(name); v != "" {
+			*value = v
+		}
+	}
+	setString("S3_DATA_DIR", &c.DataDir)
+	setString("S3_ADDRESS", &c.Address)
+	setString("S3_TLS_CERT_FILE", &c.TLSCertFile)
+	setString("S3_TLS_KEY_FILE", &c.TLSKeyFile)
+	setString("S3_LOG_LEVEL", &c.LogLevel)
+	setString("S3_MASTER_KEY", &c.MasterKey)
+	setString("S3_KEYS_FILE", &c.KeysFile)
+	if v := os.Getenv("S3_ALLOWED_ORIGINS"); v != "" {
+		c.AllowedOrigins = splitList(v)
+	}
+
+	// S3_ACCESS_KEY_ID / S3_SECRET_ACCESS_KEY pour la paire principale, S3_CREDENTIALS pour des paires supplémentaires
+	creds := auth.ParseCredentials(os.Getenv("S3_CREDENTIALS"))
+	if accessKey, secretKey := os.Getenv("S3_ACCESS_KEY_ID"), os.Getenv("S3_SECRET_ACCESS_KEY"); accessKey != "" && secretKey != "" {
+		creds[accessKey] = secretKey
+	}
+	if len(creds) > 0 {
+		c.Credentials = creds
+	}
+
+	for name, value := range map[string]*time.Duration{
+		"S3_MULTIPART_EXPIRY":   &c.MultipartExpiry,
+		"S3_LIFECYCLE_INTERVAL": &c.LifecycleInterval,
+	} {
+		if v := os.Getenv(name); v != "" {
+			duration, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q: %w", name, v, err)
+			}
+			*value = duration
+		}
+	}
+	if v := os.Getenv("S3_LIFECYCLE_DRY_RUN"); v != "" {
+		dryRun, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid S3_LIFECYCLE_DRY_RUN %q: %w", v, err)
+		}
+		c.LifecycleDryRun = dryRun
+	}
+	return nil
+}
+
+// Validate vérifie la cohérence de la configuration
+func (c Config) Validate() error {
+	if c.DataDir == "" {
+		return errors.New("data directory must not be empty")
+	}
+	if c.Address == "" {
+		return errors.New("listen address must not be empty")
+	}
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return errors.New("TLS certificate and key must be provided together")
+	}
+	if c.LogLevel != LogLevelInfo && c.LogLevel != LogLevelDebug {
+		return fmt.Errorf("log level must be %s or %s, got %q", LogLevelInfo, LogLevelDebug, c.LogLevel)
+	}
+	if c.MultipartExpiry <= 0 || c.LifecycleInterval <= 0 {
+		return errors.New("multipart expiry and lifecycle interval must be positive durations")
+	}
+	if _, err := storage.ParseMasterKey(c.MasterKey); err != nil {
+		return err
+	}
+	return nil
+}
+
+// TLS indique si le serveur doit écouter en HTTPS
+func (c Config) TLS() bool {
+	return c.TLSCertFile != ""
+}
+
+// KeysPath renvoie le fichier des access keys gérées : KeysFile, ou keys.json dans le répertoire
+// système du répertoire de données
+func (c Config) KeysPath() string {
+	if c.KeysFile != "" {
+		return c.KeysFile
+	}
+	return filepath.Join(c.DataDir, ".s3clone", "keys.json")
+}
+
+// NotificationQueueDir renvoie le répertoire de la file d'attente des événements destinés aux webhooks,
+// dans le répertoire système du répertoire de données
+func (c Config) NotificationQueueDir() string {
+	return filepath.Join(c.DataDir, ".s3clone", "notifications")
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,38 @@
+package dto
+
+import "time"
+
+// KeyPolicy énumère les droits d'une access key gérée : une requête est permise si une déclaration
+// mentionne son action pour la ressource visée. Actions ("s3:GetObject", "s3:*") et ressources
+// ("photos", "photos/tenant-a/*", "*") acceptent le caractère générique "*".
+type KeyPolicy struct {
+	Statements []KeyPolicyStatement `json:"statements"`
+}
+
+// KeyPolicyStatement autorise des actions sur des ressources. Une ressource est "bucket" pour les actions
+// sur le bucket, "bucket/clé" pour les objets et "bucket/préfixe" pour les listings.
+type KeyPolicyStatement struct {
+	Actions   []string `json:"actions"`
+	Resources []string `json:"resources"`
+}
+
+// AccessKey est une access key gérée par l'API d'administration (/_admin/keys) et persistée avec sa politique.
+// La clé secrète n'est renvoyée qu'à la création.
+type AccessKey struct {
+	AccessKey    string    `json:"accessKey"`
+	SecretKey    string    `json:"secretKey,omitempty"`
+	Description  string    `json:"description,omitempty"`
+	CreationDate time.Time `json:"creationDate"`
+	Policy       KeyPolicy `json:"policy"`
+}
+
+// CreateAccessKeyRequest est le corps de POST /_admin/keys
+type CreateAccessKeyRequest struct {
+	Description string    `json:"description,omitempty"`
+	Policy      KeyPolicy `json:"policy"`
+}
+
+// AccessKeyList est la réponse de GET /_admin/keys, sans les clés secrètes
+type AccessKeyList struct {
+	Keys []AccessKey `json:"keys"`
+}
@@ -14,6 +14,24 @@ type Bucket struct {
     Name         string    `xml:"Name"`
     CreationDate time.Time `xml:"CreationDate"`
     LocationConstraint   string   `xml:"LocationConstraint,omitempty"`
-    ObjectLockConfig   string   `xml:"ObjectLockConfiguration,omitempty"`
-    ObjectDelimiter   string   `xml:"ObjectDelimiter,omitempty"`
+}
+
+// BucketInfo décrit un bucket tel qu'enregistré à sa création
+type BucketInfo struct {
+    Name         string
+    CreationDate time.Time
+    Owner        string // access key du créateur, vide sans authentification
+    Region       string
+}
+
+// CreateBucketConfiguration est le corps facultatif de PUT /{bucket}/
+type CreateBucketConfiguration struct {
+    XMLName            xml.Name `xml:"CreateBucketConfiguration"`
+    LocationConstraint string   `xml:"LocationConstraint"`
+}
+
+// LocationConstraint est la réponse de GET /{bucket}/?location
+type LocationConstraint struct {
+    XMLName xml.Name `xml:"LocationConstraint"`
+    Region  string   `xml:",chardata"`
 }
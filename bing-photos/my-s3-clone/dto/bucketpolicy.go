@@ -0,0 +1,85 @@
+package dto
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// BucketPolicy est le corps de PUT et la réponse de GET /{bucket}/?policy, un sous-ensemble de la
+// grammaire JSON des politiques S3. Elle ne s'applique qu'aux requêtes anonymes (non signées) :
+// les access keys restent limitées par leur propre politique.
+type BucketPolicy struct {
+	Version    string                  `json:"Version,omitempty"`
+	ID         string                  `json:"Id,omitempty"`
+	Statements []BucketPolicyStatement `json:"Statement"`
+}
+
+// BucketPolicyStatement autorise ou refuse des actions ("s3:GetObject", "s3:Get*") sur des ressources
+// ("arn:aws:s3:::photos/public/*"), éventuellement sous conditions. Un refus l'emporte sur toute autorisation.
+type BucketPolicyStatement struct {
+	Sid       string          `json:"Sid,omitempty"`
+	Effect    string          `json:"Effect"` // Allow ou Deny
+	Principal PolicyPrincipal `json:"Principal"`
+	Actions   StringList      `json:"Action"`
+	Resources StringList      `json:"Resource"`
+	// Opérateur ("IpAddress", "StringLike"...) -> clé de condition ("aws:SourceIp"...) -> valeurs
+	Conditions map[string]map[string]StringList `json:"Condition,omitempty"`
+}
+
+// PolicyPrincipal désigne à qui s'applique une déclaration. Seul "*" (tout le monde) est pris en charge,
+// écrit "*" ou {"AWS": "*"}.
+type PolicyPrincipal struct {
+	AWS StringList `json:"AWS"`
+}
+
+func (p *PolicyPrincipal) UnmarshalJSON(data []byte) error {
+	var wildcard string
+	if err := json.Unmarshal(data, &wildcard); err == nil {
+		if wildcard != "*" {
+			return fmt.Errorf("invalid principal %q", wildcard)
+		}
+		p.AWS = StringList{wildcard}
+		return nil
+	}
+	var principal struct {
+		AWS StringList `json:"AWS"`
+	}
+	if err := json.Unmarshal(data, &principal); err != nil {
+		return err
+	}
+	p.AWS = principal.AWS
+	return nil
+}
+
+func (p PolicyPrincipal) MarshalJSON() ([]byte, error) {
+	if len(p.AWS) == 1 && p.AWS[0] == "*" {
+		return json.Marshal("*")
+	}
+	return json.Marshal(struct {
+		AWS StringList `json:"AWS"`
+	}{p.AWS})
+}
+
+// StringList accepte une chaîne seule ou une liste de chaînes, comme les champs Action, Resource
+// et les valeurs de condition des politiques S3. Un booléen ("aws:SecureTransport": false) est lu comme "false".
+type StringList []string
+
+func (l *StringList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*l = StringList{single}
+		return nil
+	}
+	var boolean bool
+	if err := json.Unmarshal(data, &boolean); err == nil {
+		*l = StringList{strconv.FormatBool(boolean)}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
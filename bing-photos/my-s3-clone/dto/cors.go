@@ -0,0 +1,22 @@
+package dto
+
+import "encoding/xml"
+
+// CORSConfiguration est le corps de PUT et la réponse de GET /{bucket}/?cors.
+// Elle est aussi persistée telle quelle (JSON) dans la configuration du bucket.
+type CORSConfiguration struct {
+	XMLName xml.Name   `xml:"CORSConfiguration" json:"-"`
+	Xmlns   string     `xml:"xmlns,attr,omitempty" json:"-"`
+	Rules   []CORSRule `xml:"CORSRule" json:"rules"`
+}
+
+// CORSRule autorise les requêtes d'une origine pour certaines méthodes et certains en-têtes.
+// Une origine ou un en-tête autorisé peut contenir un caractère générique "*".
+type CORSRule struct {
+	ID             string   `xml:"ID,omitempty" json:"id,omitempty"`
+	AllowedOrigins []string `xml:"AllowedOrigin" json:"allowedOrigins"`
+	AllowedMethods []string `xml:"AllowedMethod" json:"allowedMethods"` // GET, PUT, POST, DELETE ou HEAD
+	AllowedHeaders []string `xml:"AllowedHeader" json:"allowedHeaders,omitempty"`
+	ExposeHeaders  []string `xml:"ExposeHeader" json:"exposeHeaders,omitempty"`
+	MaxAgeSeconds  int      `xml:"MaxAgeSeconds,omitempty" json:"maxAgeSeconds,omitempty"` // durée de cache d'une réponse preflight
+}
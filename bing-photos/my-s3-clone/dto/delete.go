@@ -11,20 +11,37 @@ import (
 // }
 
 type DeleteResult struct {
-	DeletedResult []Deleted `xml:"Deleted"`
+	XMLName       xml.Name      `xml:"DeleteResult"`
+	Xmlns         string        `xml:"xmlns,attr,omitempty"`
+	DeletedResult []Deleted     `xml:"Deleted"`
+	Errors        []DeleteError `xml:"Error"`
 }
 
 type Deleted struct {
-	Key string `xml:"Key"`
+	Key                   string `xml:"Key"`
+	VersionId             string `xml:"VersionId,omitempty"`
+	DeleteMarker          bool   `xml:"DeleteMarker,omitempty"`
+	DeleteMarkerVersionId string `xml:"DeleteMarkerVersionId,omitempty"`
 }
 
-// DeleteObjectRequest représente la requête de suppression d'objets en batch
+// DeleteError décrit l'échec de la suppression d'une clé, sans interrompre le reste du batch
+type DeleteError struct {
+	Key       string `xml:"Key"`
+	VersionId string `xml:"VersionId,omitempty"`
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// DeleteObjectRequest représente la requête de suppression d'objets en batch.
+// En mode Quiet, seules les erreurs sont renvoyées.
 type DeleteObjectRequest struct {
     XMLName xml.Name         `xml:"Delete"`
+    Quiet   bool             `xml:"Quiet"`
     Objects []ObjectToDelete  `xml:"Object"`
 }
 
 // ObjectToDelete représente un objet à supprimer
 type ObjectToDelete struct {
-    Key string `xml:"Key"`
+    Key       string `xml:"Key"`
+    VersionId string `xml:"VersionId,omitempty"`
 }
@@ -9,6 +9,7 @@ type ErrorResponse struct {
     Code      string   `xml:"Code"`
     Message   string   `xml:"Message"`
     BucketName string  `xml:"BucketName,omitempty"`
+    Resource  string   `xml:"Resource,omitempty"`
     RequestId string   `xml:"RequestId"`
     HostId    string   `xml:"HostId"`
 }
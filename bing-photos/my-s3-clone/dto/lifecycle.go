@@ -0,0 +1,56 @@
+package dto
+
+import "encoding/xml"
+
+// LifecycleConfiguration est le corps de PUT et la réponse de GET /{bucket}/?lifecycle.
+// Elle est aussi persistée telle quelle (JSON) dans la configuration du bucket.
+type LifecycleConfiguration struct {
+	XMLName xml.Name        `xml:"LifecycleConfiguration" json:"-"`
+	Xmlns   string          `xml:"xmlns,attr,omitempty" json:"-"`
+	Rules   []LifecycleRule `xml:"Rule" json:"rules"`
+}
+
+// LifecycleRule décrit les objets concernés (Filter) et les actions qui leur sont appliquées
+type LifecycleRule struct {
+	ID     string           `xml:"ID,omitempty" json:"id,omitempty"`
+	Status string           `xml:"Status" json:"status"` // Enabled ou Disabled
+	Filter *LifecycleFilter `xml:"Filter,omitempty" json:"filter,omitempty"`
+	Prefix string           `xml:"Prefix,omitempty" json:"prefix,omitempty"` // ancienne syntaxe, sans Filter
+
+	Expiration                     *LifecycleExpiration            `xml:"Expiration,omitempty" json:"expiration,omitempty"`
+	NoncurrentVersionExpiration    *NoncurrentVersionExpiration    `xml:"NoncurrentVersionExpiration,omitempty" json:"noncurrentVersionExpiration,omitempty"`
+	AbortIncompleteMultipartUpload *AbortIncompleteMultipartUpload `xml:"AbortIncompleteMultipartUpload,omitempty" json:"abortIncompleteMultipartUpload,omitempty"`
+}
+
+// LifecycleFilter sélectionne les objets par préfixe, par étiquette, ou par les deux avec And
+type LifecycleFilter struct {
+	Prefix string        `xml:"Prefix,omitempty" json:"prefix,omitempty"`
+	Tag    *Tag          `xml:"Tag,omitempty" json:"tag,omitempty"`
+	And    *LifecycleAnd `xml:"And,omitempty" json:"and,omitempty"`
+}
+
+type LifecycleAnd struct {
+	Prefix string `xml:"Prefix,omitempty" json:"prefix,omitempty"`
+	Tags   []Tag  `xml:"Tag" json:"tags,omitempty"`
+}
+
+// Tag est une étiquette clé/valeur d'objet
+type Tag struct {
+	Key   string `xml:"Key" json:"key"`
+	Value string `xml:"Value" json:"value"`
+}
+
+// LifecycleExpiration supprime la version courante Days jours après sa création
+type LifecycleExpiration struct {
+	Days int `xml:"Days" json:"days"`
+}
+
+// NoncurrentVersionExpiration supprime une version NoncurrentDays jours après qu'elle a été remplacée
+type NoncurrentVersionExpiration struct {
+	NoncurrentDays int `xml:"NoncurrentDays" json:"noncurrentDays"`
+}
+
+// AbortIncompleteMultipartUpload abandonne un upload multipart DaysAfterInitiation jours après son démarrage
+type AbortIncompleteMultipartUpload struct {
+	DaysAfterInitiation int `xml:"DaysAfterInitiation" json:"daysAfterInitiation"`
+}
@@ -0,0 +1,75 @@
+package dto
+
+import "encoding/xml"
+
+// NotificationConfiguration est le corps de PUT et la réponse de GET /{bucket}/?notification.
+// Une configuration sans webhook supprime les notifications du bucket.
+// Elle est aussi persistée telle quelle (JSON) dans la configuration du bucket.
+type NotificationConfiguration struct {
+	XMLName  xml.Name               `xml:"NotificationConfiguration" json:"-"`
+	Xmlns    string                 `xml:"xmlns,attr,omitempty" json:"-"`
+	Webhooks []WebhookConfiguration `xml:"WebhookConfiguration" json:"webhooks"`
+}
+
+// WebhookConfiguration envoie à Url les événements ("s3:ObjectCreated:*", "s3:ObjectRemoved:Delete"...)
+// des objets dont la clé passe le filtre
+type WebhookConfiguration struct {
+	ID     string              `xml:"Id,omitempty" json:"id,omitempty"`
+	URL    string              `xml:"Url" json:"url"`
+	Events []string            `xml:"Event" json:"events"`
+	Filter *NotificationFilter `xml:"Filter,omitempty" json:"filter,omitempty"`
+}
+
+// NotificationFilter retient les clés qui commencent par le préfixe et finissent par le suffixe donnés
+type NotificationFilter struct {
+	Key KeyFilter `xml:"S3Key" json:"key"`
+}
+
+// KeyFilter contient au plus une règle "prefix" et une règle "suffix"
+type KeyFilter struct {
+	Rules []FilterRule `xml:"FilterRule" json:"rules"`
+}
+
+type FilterRule struct {
+	Name  string `xml:"Name" json:"name"` // prefix ou suffix
+	Value string `xml:"Value" json:"value"`
+}
+
+// EventRecords est le corps JSON envoyé à un webhook, au format des notifications S3
+type EventRecords struct {
+	Records []EventRecord `json:"Records"`
+}
+
+type EventRecord struct {
+	EventVersion string  `json:"eventVersion"`
+	EventSource  string  `json:"eventSource"`
+	AWSRegion    string  `json:"awsRegion"`
+	EventTime    string  `json:"eventTime"`
+	EventName    string  `json:"eventName"` // sans le préfixe "s3:", comme "ObjectCreated:Put"
+	S3           EventS3 `json:"s3"`
+}
+
+type EventS3 struct {
+	SchemaVersion   string      `json:"s3SchemaVersion"`
+	ConfigurationID string      `json:"configurationId"`
+	Bucket          EventBucket `json:"bucket"`
+	Object          EventObject `json:"object"`
+}
+
+type EventBucket struct {
+	Name          string        `json:"name"`
+	OwnerIdentity EventIdentity `json:"ownerIdentity"`
+	ARN           string        `json:"arn"`
+}
+
+type EventIdentity struct {
+	PrincipalID string `json:"principalId"`
+}
+
+type EventObject struct {
+	Key       string `json:"key"` // encodée comme dans une URL
+	Size      int64  `json:"size,omitempty"`
+	ETag      string `json:"eTag,omitempty"`
+	VersionID string `json:"versionId,omitempty"`
+	Sequencer string `json:"sequencer"` // ordonne les événements d'une même clé
+}
@@ -5,19 +5,63 @@ import (
     "time"
 )
 
+// ListObjectsResponse est la réponse à GET /{bucket}/ (ListObjects v1)
 type ListObjectsResponse struct {
-    XMLName     xml.Name `xml:"ListBucketResult"`
-    Xmlns       string   `xml:"xmlns,attr"`
-    Name        string   `xml:"Name"`
-    Prefix      string   `xml:"Prefix"`
-    Marker      string   `xml:"Marker"`
-    MaxKeys     int      `xml:"MaxKeys"`
-    IsTruncated bool     `xml:"IsTruncated"`
-    Contents    []Object `xml:"Contents"`
+    XMLName        xml.Name       `xml:"ListBucketResult"`
+    Xmlns          string         `xml:"xmlns,attr"`
+    Name           string         `xml:"Name"`
+    Prefix         string         `xml:"Prefix"`
+    Marker         string         `xml:"Marker"`
+    NextMarker     string         `xml:"NextMarker,omitempty"`
+    MaxKeys        int            `xml:"MaxKeys"`
+    Delimiter      string         `xml:"Delimiter,omitempty"`
+    EncodingType   string         `xml:"EncodingType,omitempty"`
+    IsTruncated    bool           `xml:"IsTruncated"`
+    Contents       []Object       `xml:"Contents"`
+    CommonPrefixes []CommonPrefix `xml:"CommonPrefixes,omitempty"`
+}
+
+// ListObjectsV2Response est la réponse à GET /{bucket}/?list-type=2
+type ListObjectsV2Response struct {
+    XMLName               xml.Name       `xml:"ListBucketResult"`
+    Xmlns                 string         `xml:"xmlns,attr"`
+    Name                  string         `xml:"Name"`
+    Prefix                string         `xml:"Prefix"`
+    StartAfter            string         `xml:"StartAfter,omitempty"`
+    ContinuationToken     string         `xml:"ContinuationToken,omitempty"`
+    NextContinuationToken string         `xml:"NextContinuationToken,omitempty"`
+    KeyCount              int            `xml:"KeyCount"`
+    MaxKeys               int            `xml:"MaxKeys"`
+    Delimiter             string         `xml:"Delimiter,omitempty"`
+    EncodingType          string         `xml:"EncodingType,omitempty"`
+    IsTruncated           bool           `xml:"IsTruncated"`
+    Contents              []Object       `xml:"Contents"`
+    CommonPrefixes        []CommonPrefix `xml:"CommonPrefixes,omitempty"`
+}
+
+// CommonPrefix regroupe les clés partageant un préfixe jusqu'au délimiteur
+type CommonPrefix struct {
+    Prefix string `xml:"Prefix"`
+}
+
+// ObjectListing est une page de listing renvoyée par le stockage, mise en forme en v1 ou v2 par les handlers
+type ObjectListing struct {
+    Objects        []Object
+    CommonPrefixes []string
+    IsTruncated    bool
+    NextMarker     string // dernière clé ou dernier préfixe commun de la page
 }
 
 type Object struct {
     Key          string    `xml:"Key"`
     LastModified time.Time `xml:"LastModified"`
+    ETag         string    `xml:"ETag"`
     Size         int       `xml:"Size"`
 }
+
+// CopyObjectResult est la réponse à PUT /{bucket}/{key} avec x-amz-copy-source
+type CopyObjectResult struct {
+    XMLName      xml.Name  `xml:"CopyObjectResult"`
+    LastModified time.Time `xml:"LastModified"`
+    ETag         string    `xml:"ETag"`
+}
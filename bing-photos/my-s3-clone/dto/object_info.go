@@ -0,0 +1,39 @@
+package dto
+
+import (
+	"time"
+)
+
+// ObjectInfo représente les métadonnées d'un objet stocké
+type ObjectInfo struct {
+	Key          string         // Clé de l'objet dans son bucket
+	Size         int64          // Taille de l'objet en octets
+	LastModified time.Time      // Date de dernière modification
+	ETag         string         // MD5 du contenu entre guillemets, "md5-N" pour un upload multipart
+	Metadata     ObjectMetadata // En-têtes fournis à l'upload
+
+	// Versioning : identifiant de la version ("" pour un bucket jamais versionné, "null" pour une version
+	// écrite alors que le versioning était suspendu) et marqueur de suppression
+	VersionID      string
+	IsDeleteMarker bool
+
+	// Étiquettes de l'objet, utilisées par les filtres des règles de cycle de vie
+	Tags map[string]string
+
+	// Object Lock : rétention et conservation légale de la version
+	Lock ObjectLock
+
+	// Chiffrement côté serveur : "SSE-S3", "SSE-C" ou "" pour un objet en clair,
+	// et MD5 (base64) de la clé fournie par le client pour SSE-C
+	Encryption        string
+	SSECustomerKeyMD5 string
+}
+
+// ObjectMetadata regroupe les en-têtes enregistrés avec un objet et renvoyés sur HEAD/GET
+type ObjectMetadata struct {
+	ContentType        string            `json:"contentType,omitempty"`
+	ContentDisposition string            `json:"contentDisposition,omitempty"`
+	CacheControl       string            `json:"cacheControl,omitempty"`
+	ContentEncoding    string            `json:"contentEncoding,omitempty"`
+	UserMetadata       map[string]string `json:"userMetadata,omitempty"` // x-amz-meta-*, clés en minuscules sans le préfixe
+}
@@ -0,0 +1,22 @@
+package dto
+
+// BucketQuota limite l'occupation d'un bucket. Une limite nulle n'est pas appliquée.
+// Elle est le corps de PUT /_admin/quota/{bucket} (JSON) et est persistée dans la configuration du bucket.
+type BucketQuota struct {
+	MaxBytes   int64 `json:"maxBytes,omitempty"`
+	MaxObjects int64 `json:"maxObjects,omitempty"`
+}
+
+// BucketUsage est l'occupation d'un bucket renvoyée par GET /_admin/usage : taille et nombre de toutes
+// les versions conservées, hors marqueurs de suppression et uploads multipart en cours
+type BucketUsage struct {
+	Bucket  string       `json:"bucket"`
+	Bytes   int64        `json:"bytes"`
+	Objects int64        `json:"objects"`
+	Quota   *BucketQuota `json:"quota,omitempty"`
+}
+
+// UsageReport est l'occupation de tous les buckets renvoyée par GET /_admin/usage
+type UsageReport struct {
+	Buckets []BucketUsage `json:"buckets"`
+}
@@ -0,0 +1,15 @@
+package dto
+
+import "encoding/xml"
+
+// Tagging est le corps de PUT et la réponse de GET /{bucket}/{key}?tagging
+type Tagging struct {
+	XMLName xml.Name `xml:"Tagging"`
+	Xmlns   string   `xml:"xmlns,attr,omitempty"`
+	TagSet  TagSet   `xml:"TagSet"`
+}
+
+// TagSet regroupe les étiquettes d'un objet, toujours présent même vide
+type TagSet struct {
+	Tags []Tag `xml:"Tag"`
+}
@@ -0,0 +1,64 @@
+package dto
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// VersioningConfiguration est le corps de PUT et la réponse de GET /{bucket}/?versioning.
+// Status est vide tant que le versioning n'a jamais été activé sur le bucket.
+type VersioningConfiguration struct {
+	XMLName xml.Name `xml:"VersioningConfiguration"`
+	Xmlns   string   `xml:"xmlns,attr,omitempty"`
+	Status  string   `xml:"Status,omitempty"`
+}
+
+// ObjectVersion est une version d'objet ou un marqueur de suppression renvoyé par le stockage
+type ObjectVersion struct {
+	Key            string
+	VersionID      string
+	IsLatest       bool
+	IsDeleteMarker bool
+	LastModified   time.Time
+	ETag           string
+	Size           int64
+}
+
+// ObjectVersionListing est une page de listing des versions, triée par clé puis de la plus récente à la plus ancienne
+type ObjectVersionListing struct {
+	Versions            []ObjectVersion
+	CommonPrefixes      []string
+	IsTruncated         bool
+	NextKeyMarker       string
+	NextVersionIDMarker string
+}
+
+// ListVersionsResponse est la réponse à GET /{bucket}/?versions
+type ListVersionsResponse struct {
+	XMLName             xml.Name       `xml:"ListVersionsResult"`
+	Xmlns               string         `xml:"xmlns,attr"`
+	Name                string         `xml:"Name"`
+	Prefix              string         `xml:"Prefix"`
+	KeyMarker           string         `xml:"KeyMarker"`
+	VersionIdMarker     string         `xml:"VersionIdMarker"`
+	NextKeyMarker       string         `xml:"NextKeyMarker,omitempty"`
+	NextVersionIdMarker string         `xml:"NextVersionIdMarker,omitempty"`
+	MaxKeys             int            `xml:"MaxKeys"`
+	Delimiter           string         `xml:"Delimiter,omitempty"`
+	EncodingType        string         `xml:"EncodingType,omitempty"`
+	IsTruncated         bool           `xml:"IsTruncated"`
+	Entries             []VersionEntry // éléments <Version> et <DeleteMarker>, dans l'ordre du listing
+	CommonPrefixes      []CommonPrefix `xml:"CommonPrefixes,omitempty"`
+}
+
+// VersionEntry est un élément <Version> ou <DeleteMarker> selon XMLName. Size est absent des marqueurs.
+type VersionEntry struct {
+	XMLName      xml.Name
+	Key          string    `xml:"Key"`
+	VersionId    string    `xml:"VersionId"`
+	IsLatest     bool      `xml:"IsLatest"`
+	LastModified time.Time `xml:"LastModified"`
+	ETag         string    `xml:"ETag,omitempty"`
+	Size         *int64    `xml:"Size,omitempty"`
+	StorageClass string    `xml:"StorageClass,omitempty"`
+}
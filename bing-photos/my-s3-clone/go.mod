@@ -4,4 +4,7 @@ go 1.21
 
 toolchain go1.23.0
 
-require github.com/gorilla/mux v1.8.1
+require (
+	github.com/gorilla/mux v1.8.1
+	gopkg.in/yaml.v3 v3.0.1
+)
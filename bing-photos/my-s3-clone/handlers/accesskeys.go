@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"my-s3-clone/auth"
+	"my-s3-clone/dto"
+	"my-s3-clone/s3errors"
+)
+
+// List the managed access keys, without their secret key
+func HandleListAccessKeys(keys *auth.KeyStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSONResponse(w, r, http.StatusOK, dto.AccessKeyList{Keys: keys.ListKeys()})
+	}
+}
+
+// Create an access key bound to a policy. Its secret key is only returned here.
+func HandleCreateAccessKey(keys *auth.KeyStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request dto.CreateAccessKeyRequest
+		if !decodeJSONBody(w, r, &request, s3errors.ErrMalformedPolicy) {
+			return
+		}
+
+		key, err := keys.CreateKey(request.Description, request.Policy)
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		writeJSONResponse(w, r, http.StatusOK, key)
+	}
+}
+
+// Get a managed access key and its policy
+func HandleGetAccessKey(keys *auth.KeyStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, err := keys.GetKey(mux.Vars(r)["accessKey"])
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		writeJSONResponse(w, r, http.StatusOK, key)
+	}
+}
+
+// Replace the policy of a managed access key
+func HandlePutAccessKeyPolicy(keys *auth.KeyStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var policy dto.KeyPolicy
+		if !decodeJSONBody(w, r, &policy, s3errors.ErrMalformedPolicy) {
+			return
+		}
+
+		if err := keys.PutKeyPolicy(mux.Vars(r)["accessKey"], policy); err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// Revoke a managed access key
+func HandleDeleteAccessKey(keys *auth.KeyStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := keys.DeleteKey(mux.Vars(r)["accessKey"]); err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// decodeJSONBody parses a JSON admin request body, rejecting unknown fields, and answers with
+// malformed when it cannot be parsed
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, v interface{}, malformed s3errors.APIError) bool {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		log.Printf("Error parsing %s %s body: %v", r.Method, r.URL.Path, err)
+		s3errors.WriteErrorResponse(w, r, malformed)
+		return false
+	}
+	return true
+}
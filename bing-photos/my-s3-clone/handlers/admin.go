@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"my-s3-clone/dto"
+	"my-s3-clone/s3errors"
+	"my-s3-clone/storage"
+)
+
+// The admin API lives under /_admin/, which cannot be a bucket name, and answers in JSON
+
+// Report the usage and quota of every bucket
+func HandleListUsage(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := dto.UsageReport{Buckets: []dto.BucketUsage{}}
+		for _, bucketName := range s.ListBuckets() {
+			usage, err := s.GetBucketUsage(bucketName)
+			if err != nil {
+				// The bucket may have been deleted since it was listed
+				log.Printf("Skipping usage of bucket %s: %v", bucketName, err)
+				continue
+			}
+			report.Buckets = append(report.Buckets, usage)
+		}
+
+		writeJSONResponse(w, r, http.StatusOK, report)
+	}
+}
+
+// Report the usage and quota of one bucket
+func HandleGetBucketUsage(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		usage, err := s.GetBucketUsage(mux.Vars(r)["bucketName"])
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		writeJSONResponse(w, r, http.StatusOK, usage)
+	}
+}
+
+// Get the quota of a bucket, with no limit set when it has none
+func HandleGetBucketQuota(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		usage, err := s.GetBucketUsage(mux.Vars(r)["bucketName"])
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		quota := dto.BucketQuota{}
+		if usage.Quota != nil {
+			quota = *usage.Quota
+		}
+		writeJSONResponse(w, r, http.StatusOK, quota)
+	}
+}
+
+// Set the quota of a bucket. Writes already stored are kept even if they exceed it.
+func HandlePutBucketQuota(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var quota dto.BucketQuota
+		if !decodeJSONBody(w, r, &quota, s3errors.ErrInvalidQuota) {
+			return
+		}
+
+		if err := s.PutBucketQuota(mux.Vars(r)["bucketName"], quota); err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// Remove the quota of a bucket
+func HandleDeleteBucketQuota(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := s.DeleteBucketQuota(mux.Vars(r)["bucketName"]); err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
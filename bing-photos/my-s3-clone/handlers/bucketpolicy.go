@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"my-s3-clone/auth"
+	"my-s3-clone/dto"
+	"my-s3-clone/s3errors"
+	"my-s3-clone/storage"
+)
+
+// Maximum size of a bucket policy, as on S3
+const maxBucketPolicySize = 20 << 10
+
+// Get the policy of a bucket, as JSON
+func HandleGetBucketPolicy(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		policy, err := s.GetBucketPolicy(mux.Vars(r)["bucketName"])
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		writeJSONResponse(w, r, http.StatusOK, policy)
+	}
+}
+
+// Replace the policy of a bucket. It applies to the next anonymous request.
+func HandlePutBucketPolicy(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bucketName := mux.Vars(r)["bucketName"]
+		r.Body = http.MaxBytesReader(w, r.Body, maxBucketPolicySize)
+		var policy dto.BucketPolicy
+		if !decodeJSONBody(w, r, &policy, s3errors.ErrMalformedPolicy) {
+			return
+		}
+		if err := auth.ValidateBucketPolicy(bucketName, policy); err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		if err := s.PutBucketPolicy(bucketName, policy); err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// Remove the policy of a bucket: anonymous requests are denied again
+func HandleDeleteBucketPolicy(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := s.DeleteBucketPolicy(mux.Vars(r)["bucketName"]); err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/s3errors"
+	"my-s3-clone/storage"
+)
+
+var errUnsatisfiableRange = errors.New("unsatisfiable range")
+
+// Prefix of the conditional headers applying to the source of a copy (x-amz-copy-source-if-match, ...)
+const copySourceConditionPrefix = "X-Amz-Copy-Source-"
+
+// checkPreconditions evaluates If-Match, If-Unmodified-Since, If-None-Match and If-Modified-Since
+// (RFC 7232 order). When a condition fails the response is written and false is returned.
+func checkPreconditions(w http.ResponseWriter, r *http.Request, eTag string, lastModified time.Time) bool {
+	switch preconditionStatus(r.Header, "", eTag, lastModified) {
+	case http.StatusPreconditionFailed:
+		s3errors.WriteErrorResponse(w, r, s3errors.ErrPreconditionFailed)
+		return false
+	case http.StatusNotModified:
+		w.WriteHeader(http.StatusNotModified)
+		return false
+	}
+	return true
+}
+
+// copySourceCondition checks the x-amz-copy-source-if-* headers against the source of a copy,
+// in the same order as checkPreconditions; any failed condition fails the copy with 412.
+// It returns nil when the request has no such header.
+func copySourceCondition(header http.Header) func(source dto.ObjectInfo) error {
+	for _, name := range []string{"If-Match", "If-None-Match", "If-Modified-Since", "If-Unmodified-Since"} {
+		if header.Get(copySourceConditionPrefix+name) != "" {
+			return func(source dto.ObjectInfo) error {
+				if preconditionStatus(header, copySourceConditionPrefix, source.ETag, source.LastModified) != 0 {
+					return fmt.Errorf("%w: copy source %s", storage.ErrPreconditionFailed, source.Key)
+				}
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// preconditionStatus evaluates the conditional headers named with the given prefix and returns
+// 412 or 304 for the first failed condition, 0 when they all hold
+func preconditionStatus(header http.Header, prefix, eTag string, lastModified time.Time) int {
+	lastModified = lastModified.Truncate(time.Second)
+
+	if ifMatch := header.Get(prefix + "If-Match"); ifMatch != "" {
+		if !eTagMatches(ifMatch, eTag) {
+			return http.StatusPreconditionFailed
+		}
+	} else if since, err := http.ParseTime(header.Get(prefix + "If-Unmodified-Since")); err == nil {
+		if lastModified.After(since) {
+			return http.StatusPreconditionFailed
+		}
+	}
+
+	if ifNoneMatch := header.Get(prefix + "If-None-Match"); ifNoneMatch != "" {
+		if eTagMatches(ifNoneMatch, eTag) {
+			return http.StatusNotModified
+		}
+	} else if since, err := http.ParseTime(header.Get(prefix + "If-Modified-Since")); err == nil {
+		if !lastModified.After(since) {
+			return http.StatusNotModified
+		}
+	}
+
+	return 0
+}
+
+// eTagMatches reports whether the header value ("*" or a comma separated list) matches eTag
+func eTagMatches(header, eTag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || strings.Trim(candidate, `"`) == strings.Trim(eTag, `"`) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRange parses a single "bytes=" range against an object of the given size.
+// ok is false when the header should be ignored (syntax not supported) and the whole object served.
+func parseRange(header string, size int64) (start, length int64, ok bool, err error) {
+	spec := strings.TrimSpace(header)
+	if !strings.HasPrefix(spec, "bytes=") || strings.Contains(spec, ",") {
+		return 0, 0, false, nil
+	}
+	bounds := strings.SplitN(strings.TrimPrefix(spec, "bytes="), "-", 2)
+	if len(bounds) != 2 {
+		return 0, 0, false, nil
+	}
+	first, last := strings.TrimSpace(bounds[0]), strings.TrimSpace(bounds[1])
+
+	if first == "" {
+		// Suffix range: the last N bytes
+		suffix, convErr := strconv.ParseInt(last, 10, 64)
+		if convErr != nil || suffix < 0 {
+			return 0, 0, false, nil
+		}
+		if suffix == 0 || size == 0 {
+			return 0, 0, true, errUnsatisfiableRange
+		}
+		if suffix > size {
+			suffix = size
+		}
+		return size - suffix, suffix, true, nil
+	}
+
+	start, convErr := strconv.ParseInt(first, 10, 64)
+	if convErr != nil || start < 0 {
+		return 0, 0, false, nil
+	}
+	end := size - 1
+	if last != "" {
+		end, convErr = strconv.ParseInt(last, 10, 64)
+		if convErr != nil || end < start {
+			return 0, 0, false, nil
+		}
+		if end > size-1 {
+			end = size - 1
+		}
+	}
+	if start >= size {
+		return 0, 0, true, errUnsatisfiableRange
+	}
+	return start, end - start + 1, true, nil
+}
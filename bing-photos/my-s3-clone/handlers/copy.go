@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"my-s3-clone/auth"
+	"my-s3-clone/dto"
+	"my-s3-clone/middleware"
+	"my-s3-clone/s3errors"
+	"my-s3-clone/storage"
+)
+
+// Copy an object (PUT with x-amz-copy-source), keeping or replacing its metadata
+func HandleCopyObject(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		bucketName := vars["bucketName"]
+		objectName := vars["objectName"]
+
+		sourceBucket, sourceKey, sourceVersionID, ok := parseCopySource(r.Header.Get("X-Amz-Copy-Source"))
+		if !ok {
+			s3errors.WriteErrorResponse(w, r, s3errors.ErrInvalidCopySource)
+			return
+		}
+		// The route only checks the right to write the target
+		if !middleware.Allowed(r, auth.ActionGetObject, auth.ObjectResource(sourceBucket, sourceKey)) {
+			log.Printf("Access denied: cannot read copy source %s/%s", sourceBucket, sourceKey)
+			s3errors.WriteErrorResponse(w, r, s3errors.ErrAccessDenied)
+			return
+		}
+
+		opts := storage.CopyObjectOptions{
+			SourceVersionID: sourceVersionID,
+			SourceCondition: copySourceCondition(r.Header),
+		}
+		var err error
+		if opts.SourceCustomerKey, err = parseCustomerKey(r.Header, copySourceSSEHeaderPrefix); err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+		if opts.Encryption, err = parseSSEHeaders(r.Header); err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+		reencrypt := opts.Encryption.S3 || opts.Encryption.CustomerKey != nil
+
+		// COPY (default) keeps the source metadata, REPLACE takes it from the request headers.
+		// An object can be copied onto itself only to change its metadata or its encryption.
+		switch directive := r.Header.Get("X-Amz-Metadata-Directive"); directive {
+		case "", "COPY":
+			if sourceBucket == bucketName && sourceKey == objectName && sourceVersionID == "" && !reencrypt {
+				s3errors.WriteErrorResponse(w, r, s3errors.ErrInvalidCopyDest)
+				return
+			}
+		case "REPLACE":
+			replaced, err := parseObjectMetadata(r.Header)
+			if err != nil {
+				writeStorageError(w, r, err)
+				return
+			}
+			opts.Metadata = &replaced
+		default:
+			log.Printf("Unknown metadata directive: %q", directive)
+			s3errors.WriteErrorResponse(w, r, s3errors.ErrInvalidMetadataDirective)
+			return
+		}
+
+		// Tags follow the same COPY / REPLACE rule, with x-amz-tagging-directive
+		switch directive := r.Header.Get("X-Amz-Tagging-Directive"); directive {
+		case "", "COPY":
+		case "REPLACE":
+			tags, err := parseTaggingHeader(r.Header)
+			if err != nil {
+				writeStorageError(w, r, err)
+				return
+			}
+			if tags == nil {
+				tags = map[string]string{}
+			}
+			opts.Tags = tags
+		default:
+			log.Printf("Unknown tagging directive: %q", directive)
+			s3errors.WriteErrorResponse(w, r, s3errors.ErrInvalidTaggingDirective)
+			return
+		}
+
+		info, err := s.CopyObject(sourceBucket, sourceKey, bucketName, objectName, opts)
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		if sourceVersionID != "" {
+			w.Header().Set("x-amz-copy-source-version-id", sourceVersionID)
+		}
+		if info.VersionID != "" {
+			w.Header().Set("x-amz-version-id", info.VersionID)
+		}
+		setEncryptionHeaders(w, info)
+
+		log.Printf("Copied %s/%s to %s/%s", sourceBucket, sourceKey, bucketName, objectName)
+		writeXMLResponse(w, r, http.StatusOK, dto.CopyObjectResult{
+			LastModified: info.LastModified.UTC(),
+			ETag:         info.ETag,
+		})
+	}
+}
+
+// parseCopySource splits "/bucket/key[?versionId=id]" (URL-encoded, leading slash optional)
+// into bucket, key and source version
+func parseCopySource(value string) (string, string, string, bool) {
+	source, versionID := value, ""
+	if i := strings.Index(source, "?"); i >= 0 {
+		query, err := url.ParseQuery(source[i+1:])
+		if err != nil {
+			return "", "", "", false
+		}
+		source, versionID = source[:i], query.Get("versionId")
+	}
+	source, err := url.PathUnescape(source)
+	if err != nil {
+		return "", "", "", false
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(source, "/"), "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", "", false
+	}
+	return parts[0], parts[1], versionID, true
+}
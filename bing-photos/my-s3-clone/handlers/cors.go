@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"my-s3-clone/dto"
+	"my-s3-clone/s3errors"
+	"my-s3-clone/storage"
+)
+
+// Get the CORS rules of a bucket
+func HandleGetBucketCors(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		config, err := s.GetBucketCors(mux.Vars(r)["bucketName"])
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		config.Xmlns = s3Xmlns
+		writeXMLResponse(w, r, http.StatusOK, config)
+	}
+}
+
+// Replace the CORS rules of a bucket
+func HandlePutBucketCors(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var config dto.CORSConfiguration
+		if err := xml.NewDecoder(r.Body).Decode(&config); err != nil {
+			log.Printf("Error parsing CORSConfiguration body: %v", err)
+			s3errors.WriteErrorResponse(w, r, s3errors.ErrMalformedXML)
+			return
+		}
+
+		if err := s.PutBucketCors(mux.Vars(r)["bucketName"], config); err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// Remove the CORS rules of a bucket
+func HandleDeleteBucketCors(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := s.DeleteBucketCors(mux.Vars(r)["bucketName"]); err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"log"
+	"net/http"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/s3errors"
+	"my-s3-clone/storage"
+)
+
+const (
+	sseHeader                 = "x-amz-server-side-encryption"
+	sseCustomerHeaderPrefix   = "x-amz-server-side-encryption-customer-"
+	copySourceSSEHeaderPrefix = "x-amz-copy-source-server-side-encryption-customer-"
+)
+
+// parseSSEHeaders reads the encryption requested for a new object: SSE-S3 with
+// x-amz-server-side-encryption: AES256, or SSE-C with a customer-provided key
+func parseSSEHeaders(header http.Header) (storage.SSEOptions, error) {
+	var opts storage.SSEOptions
+
+	customerKey, err := parseCustomerKey(header, sseCustomerHeaderPrefix)
+	if err != nil {
+		return opts, err
+	}
+	opts.CustomerKey = customerKey
+
+	if algorithm := header.Get(sseHeader); algorithm != "" {
+		if algorithm != storage.SSEAlgorithm {
+			log.Printf("Unsupported server-side encryption: %q", algorithm)
+			return opts, s3errors.ErrInvalidEncryptionAlgorithm
+		}
+		if customerKey != nil {
+			return opts, s3errors.ErrIncompatibleEncryption
+		}
+		opts.S3 = true
+	}
+	return opts, nil
+}
+
+// parseCustomerKey reads the SSE-C headers starting with prefix (algorithm, key and key-MD5),
+// returning a nil key when none is present
+func parseCustomerKey(header http.Header, prefix string) ([]byte, error) {
+	algorithm, encodedKey, keyMD5 := header.Get(prefix+"algorithm"), header.Get(prefix+"key"), header.Get(prefix+"key-MD5")
+	if algorithm == "" && encodedKey == "" && keyMD5 == "" {
+		return nil, nil
+	}
+	if algorithm != storage.SSEAlgorithm {
+		log.Printf("Unsupported customer key algorithm: %q", algorithm)
+		return nil, s3errors.ErrInvalidEncryptionAlgorithm
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil || len(key) != 32 {
+		return nil, s3errors.ErrInvalidSSECustomerKey
+	}
+	if storage.CustomerKeyMD5(key) != keyMD5 {
+		return nil, s3errors.ErrSSECustomerKeyMD5Mismatch
+	}
+	return key, nil
+}
+
+// setEncryptionHeaders reports how an object version is encrypted at rest
+func setEncryptionHeaders(w http.ResponseWriter, info dto.ObjectInfo) {
+	switch info.Encryption {
+	case storage.SSEModeS3:
+		w.Header().Set(sseHeader, storage.SSEAlgorithm)
+	case storage.SSEModeCustomer:
+		w.Header().Set(sseCustomerHeaderPrefix+"algorithm", storage.SSEAlgorithm)
+		w.Header().Set(sseCustomerHeaderPrefix+"key-MD5", info.SSECustomerKeyMD5)
+	}
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+
+	"my-s3-clone/s3errors"
+	"my-s3-clone/storage"
+)
+
+// storageErrors maps storage sentinel errors to their S3 error
+var storageErrors = []struct {
+	err    error
+	apiErr s3errors.APIError
+}{
+	{storage.ErrNoSuchBucket, s3errors.ErrNoSuchBucket},
+	{storage.ErrBucketAlreadyExists, s3errors.ErrBucketAlreadyOwnedByYou},
+	{storage.ErrBucketNotEmpty, s3errors.ErrBucketNotEmpty},
+	{storage.ErrInvalidRegion, s3errors.ErrInvalidLocationConstraint},
+	{os.ErrNotExist, s3errors.ErrNoSuchKey},
+	{storage.ErrInvalidBucketName, s3errors.ErrInvalidBucketName},
+	{storage.ErrInvalidObjectName, s3errors.ErrInvalidObjectName},
+	{storage.ErrKeyTooLong, s3errors.ErrKeyTooLong},
+	{storage.ErrObjectPathConflict, s3errors.ErrObjectPathConflict},
+	{storage.ErrChunkSignatureMismatch, s3errors.ErrSignatureDoesNotMatch},
+	{storage.ErrIncompleteBody, s3errors.ErrIncompleteBody},
+	{storage.ErrMalformedChunk, s3errors.ErrIncompleteBody},
+	{storage.ErrNoSuchUpload, s3errors.ErrNoSuchUpload},
+	{storage.ErrInvalidPartNumber, s3errors.ErrInvalidPartNumber},
+	{storage.ErrInvalidPart, s3errors.ErrInvalidPart},
+	{storage.ErrInvalidPartOrder, s3errors.ErrInvalidPartOrder},
+	{storage.ErrEntityTooSmall, s3errors.ErrEntityTooSmall},
+	{storage.ErrBadDigest, s3errors.ErrBadDigest},
+	{storage.ErrContentSHA256Mismatch, s3errors.ErrContentSHA256Mismatch},
+	{storage.ErrNoSuchVersion, s3errors.ErrNoSuchVersion},
+	{storage.ErrInvalidVersionID, s3errors.ErrInvalidVersionID},
+	{storage.ErrDeleteMarker, s3errors.ErrMethodNotAllowed},
+	{storage.ErrInvalidVersioning, s3errors.ErrIllegalVersioningConfiguration},
+	{storage.ErrNoSuchLifecycle, s3errors.ErrNoSuchLifecycleConfiguration},
+	{storage.ErrInvalidLifecycle, s3errors.ErrInvalidLifecycle},
+	{storage.ErrNoSuchCORS, s3errors.ErrNoSuchCORSConfiguration},
+	{storage.ErrInvalidCORS, s3errors.ErrInvalidCORS},
+	{storage.ErrNoSuchBucketPolicy, s3errors.ErrNoSuchBucketPolicy},
+	{storage.ErrInvalidTag, s3errors.ErrInvalidTag},
+	{storage.ErrQuotaExceeded, s3errors.ErrQuotaExceeded},
+	{storage.ErrInvalidQuota, s3errors.ErrInvalidQuota},
+	{storage.ErrPreconditionFailed, s3errors.ErrPreconditionFailed},
+	{storage.ErrInvalidMove, s3errors.ErrInvalidCopyDest},
+	{storage.ErrObjectLocked, s3errors.ErrObjectLocked},
+	{storage.ErrObjectLockNotEnabled, s3errors.ErrObjectLockNotEnabled},
+	{storage.ErrInvalidObjectLock, s3errors.ErrInvalidObjectLock},
+	{storage.ErrInvalidBucketState, s3errors.ErrInvalidBucketState},
+	{storage.ErrSSENotConfigured, s3errors.ErrSSENotConfigured},
+	{storage.ErrSSECustomerKeyNeeded, s3errors.ErrSSECustomerKeyRequired},
+	{storage.ErrSSECustomerKeyMismatch, s3errors.ErrSSECustomerKeyMismatch},
+	{storage.ErrInvalidSSECustomerKey, s3errors.ErrInvalidSSECustomerKey},
+}
+
+// toAPIError converts an error returned by the storage into an S3 error
+func toAPIError(err error) s3errors.APIError {
+	var apiErr s3errors.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	for _, candidate := range storageErrors {
+		if errors.Is(err, candidate.err) {
+			return candidate.apiErr
+		}
+	}
+	return s3errors.ErrInternalError
+}
+
+// writeStorageError logs a storage failure and answers with the matching S3 error document
+func writeStorageError(w http.ResponseWriter, r *http.Request, err error) {
+	log.Printf("Error handling %s %s: %v", r.Method, r.URL.Path, err)
+	s3errors.WriteErrorResponse(w, r, toAPIError(err))
+}
+
+// HandleMethodNotAllowed answers requests whose path matches a route but not its method
+func HandleMethodNotAllowed() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("Method not allowed: %s %s", r.Method, r.URL.Path)
+		s3errors.WriteErrorResponse(w, r, s3errors.ErrMethodNotAllowed)
+	}
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"my-s3-clone/dto"
+	"my-s3-clone/s3errors"
+	"my-s3-clone/storage"
+)
+
+// Get the lifecycle rules of a bucket
+func HandleGetBucketLifecycle(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		config, err := s.GetBucketLifecycle(mux.Vars(r)["bucketName"])
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		config.Xmlns = s3Xmlns
+		writeXMLResponse(w, r, http.StatusOK, config)
+	}
+}
+
+// Replace the lifecycle rules of a bucket
+func HandlePutBucketLifecycle(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var config dto.LifecycleConfiguration
+		if err := xml.NewDecoder(r.Body).Decode(&config); err != nil {
+			log.Printf("Error parsing LifecycleConfiguration body: %v", err)
+			s3errors.WriteErrorResponse(w, r, s3errors.ErrMalformedXML)
+			return
+		}
+
+		if err := s.PutBucketLifecycle(mux.Vars(r)["bucketName"], config); err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// Remove the lifecycle rules of a bucket
+func HandleDeleteBucketLifecycle(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := s.DeleteBucketLifecycle(mux.Vars(r)["bucketName"]); err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
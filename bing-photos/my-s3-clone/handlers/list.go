@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"my-s3-clone/dto"
+	"my-s3-clone/s3errors"
+	"my-s3-clone/storage"
+)
+
+// Maximum number of keys returned in one listing page
+const maxListKeys = 1000
+
+// List objects in a bucket (ListObjects v1, or v2 with list-type=2)
+func HandleListObjects(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		bucketName := vars["bucketName"]
+		query := r.URL.Query()
+
+		maxKeys, ok := parseIntParam(query.Get("max-keys"), maxListKeys)
+		if !ok {
+			s3errors.WriteErrorResponse(w, r, s3errors.ErrInvalidMaxKeys)
+			return
+		}
+		if maxKeys > maxListKeys {
+			maxKeys = maxListKeys
+		}
+
+		encodingType := query.Get("encoding-type")
+		if encodingType != "" && encodingType != "url" {
+			s3errors.WriteErrorResponse(w, r, s3errors.ErrInvalidEncodingType)
+			return
+		}
+
+		tags, err := parseTagFilter(query)
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		opts := storage.ListObjectsOptions{
+			Prefix:    query.Get("prefix"),
+			Delimiter: query.Get("delimiter"),
+			MaxKeys:   maxKeys,
+			Tags:      tags,
+		}
+
+		if query.Get("list-type") != "2" {
+			opts.StartAfter = query.Get("marker")
+			listing, err := s.ListObjects(bucketName, opts)
+			if err != nil {
+				writeStorageError(w, r, err)
+				return
+			}
+
+			response := dto.ListObjectsResponse{
+				Xmlns:        s3Xmlns,
+				Name:         bucketName,
+				Prefix:       encodeListValue(opts.Prefix, encodingType),
+				Marker:       encodeListValue(opts.StartAfter, encodingType),
+				MaxKeys:      maxKeys,
+				Delimiter:    encodeListValue(opts.Delimiter, encodingType),
+				EncodingType: encodingType,
+				IsTruncated:  listing.IsTruncated,
+			}
+			// Without a delimiter, clients use the last returned key as the next marker
+			if listing.IsTruncated && opts.Delimiter != "" {
+				response.NextMarker = encodeListValue(listing.NextMarker, encodingType)
+			}
+			response.Contents, response.CommonPrefixes = encodeListing(listing, encodingType)
+
+			writeXMLResponse(w, r, http.StatusOK, response)
+			return
+		}
+
+		// The continuation token takes precedence over start-after
+		continuationToken := query.Get("continuation-token")
+		opts.StartAfter = query.Get("start-after")
+		if _, ok := query["continuation-token"]; ok {
+			marker, err := decodeContinuationToken(continuationToken)
+			if err != nil {
+				s3errors.WriteErrorResponse(w, r, s3errors.ErrInvalidContinuationToken)
+				return
+			}
+			opts.StartAfter = marker
+		}
+
+		listing, err := s.ListObjects(bucketName, opts)
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		response := dto.ListObjectsV2Response{
+			Xmlns:             s3Xmlns,
+			Name:              bucketName,
+			Prefix:            encodeListValue(opts.Prefix, encodingType),
+			StartAfter:        encodeListValue(query.Get("start-after"), encodingType),
+			ContinuationToken: continuationToken,
+			KeyCount:          len(listing.Objects) + len(listing.CommonPrefixes),
+			MaxKeys:           maxKeys,
+			Delimiter:         encodeListValue(opts.Delimiter, encodingType),
+			EncodingType:      encodingType,
+			IsTruncated:       listing.IsTruncated,
+		}
+		if listing.IsTruncated {
+			response.NextContinuationToken = encodeContinuationToken(listing.NextMarker)
+		}
+		response.Contents, response.CommonPrefixes = encodeListing(listing, encodingType)
+
+		writeXMLResponse(w, r, http.StatusOK, response)
+	}
+}
+
+// encodeListing converts a storage listing into the XML elements shared by v1 and v2
+func encodeListing(listing dto.ObjectListing, encodingType string) ([]dto.Object, []dto.CommonPrefix) {
+	contents := make([]dto.Object, 0, len(listing.Objects))
+	for _, object := range listing.Objects {
+		object.Key = encodeListValue(object.Key, encodingType)
+		contents = append(contents, object)
+	}
+
+	var prefixes []dto.CommonPrefix
+	for _, prefix := range listing.CommonPrefixes {
+		prefixes = append(prefixes, dto.CommonPrefix{Prefix: encodeListValue(prefix, encodingType)})
+	}
+	return contents, prefixes
+}
+
+// encodeListValue URL-encodes keys and prefixes when the client asked for encoding-type=url
+func encodeListValue(value, encodingType string) string {
+	if encodingType != "url" {
+		return value
+	}
+	return strings.ReplaceAll(url.QueryEscape(value), "%2F", "/")
+}
+
+// Continuation tokens are opaque to clients: they carry the last key or common prefix returned
+func encodeContinuationToken(marker string) string {
+	return base64.StdEncoding.EncodeToString([]byte(marker))
+}
+
+func decodeContinuationToken(token string) (string, error) {
+	marker, err := base64.StdEncoding.DecodeString(token)
+	if err != nil || len(marker) == 0 {
+		return "", s3errors.ErrInvalidContinuationToken
+	}
+	return string(marker), nil
+}
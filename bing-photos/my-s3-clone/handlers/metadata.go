@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/s3errors"
+)
+
+const (
+	userMetadataPrefix = "x-amz-meta-"
+
+	// Taille maximale des métadonnées utilisateur acceptée par S3
+	maxUserMetadataSize = 2 * 1024
+)
+
+// parseObjectMetadata reads the headers stored with an object on PUT, copy and multipart initiation
+func parseObjectMetadata(header http.Header) (dto.ObjectMetadata, error) {
+	metadata := dto.ObjectMetadata{
+		ContentType:        header.Get("Content-Type"),
+		ContentDisposition: header.Get("Content-Disposition"),
+		CacheControl:       header.Get("Cache-Control"),
+		ContentEncoding:    header.Get("Content-Encoding"),
+	}
+
+	size := 0
+	for name, values := range header {
+		lower := strings.ToLower(name)
+		if !strings.HasPrefix(lower, userMetadataPrefix) || len(lower) == len(userMetadataPrefix) {
+			continue
+		}
+		key := strings.TrimPrefix(lower, userMetadataPrefix)
+		value := strings.Join(values, ",")
+		size += len(key) + len(value)
+		if size > maxUserMetadataSize {
+			return metadata, s3errors.ErrMetadataTooLarge
+		}
+		if metadata.UserMetadata == nil {
+			metadata.UserMetadata = map[string]string{}
+		}
+		metadata.UserMetadata[key] = value
+	}
+
+	return metadata, nil
+}
+
+// setObjectHeaders writes the stored metadata of an object on HEAD and GET responses
+func setObjectHeaders(w http.ResponseWriter, info dto.ObjectInfo) {
+	contentType := info.Metadata.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+
+	if info.Metadata.ContentDisposition != "" {
+		w.Header().Set("Content-Disposition", info.Metadata.ContentDisposition)
+	}
+	if info.Metadata.CacheControl != "" {
+		w.Header().Set("Cache-Control", info.Metadata.CacheControl)
+	}
+	if info.Metadata.ContentEncoding != "" {
+		w.Header().Set("Content-Encoding", info.Metadata.ContentEncoding)
+	}
+	for key, value := range info.Metadata.UserMetadata {
+		w.Header().Set(userMetadataPrefix+key, value)
+	}
+
+	if info.VersionID != "" {
+		w.Header().Set("x-amz-version-id", info.VersionID)
+	}
+	if len(info.Tags) > 0 {
+		w.Header().Set("x-amz-tagging-count", strconv.Itoa(len(info.Tags)))
+	}
+	setObjectLockHeaders(w, info.Lock)
+	setEncryptionHeaders(w, info)
+	w.Header().Set("ETag", info.ETag)
+	w.Header().Set("Last-Modified", info.LastModified.UTC().Format(http.TimeFormat))
+	w.Header().Set("Accept-Ranges", "bytes")
+}
@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"my-s3-clone/dto"
+	"my-s3-clone/s3errors"
+	"my-s3-clone/storage"
+)
+
+const s3Xmlns = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+// Start a multipart upload
+func HandleCreateMultipartUpload(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		bucketName := vars["bucketName"]
+		objectName := vars["objectName"]
+
+		metadata, err := parseObjectMetadata(r.Header)
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		encryption, err := parseSSEHeaders(r.Header)
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		lock, err := parseObjectLockHeaders(r.Header)
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		tags, err := parseTaggingHeader(r.Header)
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		uploadID, err := s.CreateMultipartUpload(bucketName, objectName, storage.MultipartUploadOptions{
+			Metadata:   metadata,
+			Encryption: encryption,
+			Lock:       lock,
+			Tags:       tags,
+		})
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		// The parts of an SSE-C upload must all be sent with the same customer key
+		if encryption.CustomerKey != nil {
+			setEncryptionHeaders(w, dto.ObjectInfo{Encryption: storage.SSEModeCustomer, SSECustomerKeyMD5: storage.CustomerKeyMD5(encryption.CustomerKey)})
+		} else if encryption.S3 {
+			setEncryptionHeaders(w, dto.ObjectInfo{Encryption: storage.SSEModeS3})
+		}
+
+		writeXMLResponse(w, r, http.StatusOK, dto.InitiateMultipartUploadResult{
+			Xmlns:    s3Xmlns,
+			Bucket:   bucketName,
+			Key:      objectName,
+			UploadId: uploadID,
+		})
+	}
+}
+
+// Upload one part of a multipart upload
+func HandleUploadPart(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		bucketName := vars["bucketName"]
+		objectName := vars["objectName"]
+		uploadID := r.URL.Query().Get("uploadId")
+
+		partNumber, err := strconv.Atoi(r.URL.Query().Get("partNumber"))
+		if err != nil {
+			s3errors.WriteErrorResponse(w, r, s3errors.ErrInvalidPartNumber)
+			return
+		}
+
+		opts, err := parsePutObjectOptions(r)
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		eTag, err := s.UploadPart(bucketName, objectName, uploadID, partNumber, r.Body, opts)
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		w.Header().Set("ETag", eTag)
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// List the parts uploaded so far
+func HandleListParts(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		query := r.URL.Query()
+
+		maxParts, ok := parseIntParam(query.Get("max-parts"), 1000)
+		if !ok {
+			s3errors.WriteErrorResponse(w, r, s3errors.ErrInvalidMaxParts)
+			return
+		}
+		partNumberMarker, ok := parseIntParam(query.Get("part-number-marker"), 0)
+		if !ok {
+			s3errors.WriteErrorResponse(w, r, s3errors.ErrInvalidPartNumber)
+			return
+		}
+
+		result, err := s.ListParts(vars["bucketName"], vars["objectName"], query.Get("uploadId"), partNumberMarker, maxParts)
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		writeXMLResponse(w, r, http.StatusOK, result)
+	}
+}
+
+// Complete a multipart upload by assembling the listed parts
+func HandleCompleteMultipartUpload(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		bucketName := vars["bucketName"]
+		objectName := vars["objectName"]
+
+		var completeReq dto.CompleteMultipartUpload
+		if err := xml.NewDecoder(r.Body).Decode(&completeReq); err != nil {
+			log.Printf("Error parsing CompleteMultipartUpload body: %v", err)
+			s3errors.WriteErrorResponse(w, r, s3errors.ErrMalformedXML)
+			return
+		}
+
+		info, err := s.CompleteMultipartUpload(bucketName, objectName, r.URL.Query().Get("uploadId"), completeReq.Parts)
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		if info.VersionID != "" {
+			w.Header().Set("x-amz-version-id", info.VersionID)
+		}
+		setEncryptionHeaders(w, info)
+		writeXMLResponse(w, r, http.StatusOK, dto.CompleteMultipartUploadResult{
+			Xmlns:    s3Xmlns,
+			Location: fmt.Sprintf("http://%s/%s/%s", r.Host, bucketName, objectName),
+			Bucket:   bucketName,
+			Key:      objectName,
+			ETag:     info.ETag,
+		})
+	}
+}
+
+// Abort a multipart upload and discard its parts
+func HandleAbortMultipartUpload(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+
+		if err := s.AbortMultipartUpload(vars["bucketName"], vars["objectName"], r.URL.Query().Get("uploadId")); err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// List the multipart uploads in progress in a bucket
+func HandleListMultipartUploads(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		query := r.URL.Query()
+
+		maxUploads, ok := parseIntParam(query.Get("max-uploads"), 1000)
+		if !ok {
+			s3errors.WriteErrorResponse(w, r, s3errors.ErrInvalidMaxUploads)
+			return
+		}
+
+		result, err := s.ListMultipartUploads(vars["bucketName"], query.Get("prefix"), query.Get("key-marker"), query.Get("upload-id-marker"), maxUploads)
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		writeXMLResponse(w, r, http.StatusOK, result)
+	}
+}
+
+// parseIntParam parses a non-negative integer query parameter, using def when it is absent
+func parseIntParam(value string, def int) (int, bool) {
+	if value == "" {
+		return def, true
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return n, true
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"my-s3-clone/dto"
+	"my-s3-clone/notification"
+	"my-s3-clone/s3errors"
+	"my-s3-clone/storage"
+)
+
+// Get the webhooks of a bucket, an empty configuration if it has none
+func HandleGetBucketNotification(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		config, err := s.GetBucketNotification(mux.Vars(r)["bucketName"])
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		config.Xmlns = s3Xmlns
+		writeXMLResponse(w, r, http.StatusOK, config)
+	}
+}
+
+// Replace the webhooks of a bucket. An empty configuration turns notifications off.
+func HandlePutBucketNotification(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var config dto.NotificationConfiguration
+		if err := xml.NewDecoder(r.Body).Decode(&config); err != nil {
+			log.Printf("Error parsing NotificationConfiguration body: %v", err)
+			s3errors.WriteErrorResponse(w, r, s3errors.ErrMalformedXML)
+			return
+		}
+		if err := notification.ValidateConfiguration(config); err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		if err := s.PutBucketNotification(mux.Vars(r)["bucketName"], config); err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"my-s3-clone/auth"
+	"my-s3-clone/dto"
+	"my-s3-clone/middleware"
+	"my-s3-clone/s3errors"
+	"my-s3-clone/storage"
+)
+
+// Get the Object Lock configuration of a bucket
+func HandleGetObjectLockConfig(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		config, err := s.GetObjectLockConfiguration(mux.Vars(r)["bucketName"])
+		if errors.Is(err, storage.ErrObjectLockNotEnabled) {
+			s3errors.WriteErrorResponse(w, r, s3errors.ErrObjectLockConfigurationNotFound)
+			return
+		}
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		config.Xmlns = s3Xmlns
+		writeXMLResponse(w, r, http.StatusOK, config)
+	}
+}
+
+// Enable Object Lock on a versioned bucket or replace its default retention
+func HandlePutObjectLockConfig(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bucketName := mux.Vars(r)["bucketName"]
+
+		var config dto.ObjectLockConfiguration
+		if err := xml.NewDecoder(r.Body).Decode(&config); err != nil {
+			log.Printf("Error parsing ObjectLockConfiguration body: %v", err)
+			s3errors.WriteErrorResponse(w, r, s3errors.ErrMalformedXML)
+			return
+		}
+
+		if err := s.PutObjectLockConfiguration(bucketName, config); err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// Get the retention of an object version (GET ?retention)
+func HandleGetObjectRetention(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		info, ok := statLockedObject(s, w, r)
+		if !ok {
+			return
+		}
+		if info.Lock.Mode == "" {
+			s3errors.WriteErrorResponse(w, r, s3errors.ErrNoSuchObjectLockConfiguration)
+			return
+		}
+
+		retainUntil := info.Lock.RetainUntilDate.UTC()
+		writeXMLResponse(w, r, http.StatusOK, dto.ObjectRetention{Xmlns: s3Xmlns, Mode: info.Lock.Mode, RetainUntilDate: &retainUntil})
+	}
+}
+
+// Set, extend or remove the retention of an object version (PUT ?retention).
+// Shortening a GOVERNANCE retention requires x-amz-bypass-governance-retention.
+func HandlePutObjectRetention(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+
+		var retention dto.ObjectRetention
+		if err := xml.NewDecoder(r.Body).Decode(&retention); err != nil {
+			log.Printf("Error parsing Retention body: %v", err)
+			s3errors.WriteErrorResponse(w, r, s3errors.ErrMalformedXML)
+			return
+		}
+		var retainUntil time.Time
+		if retention.RetainUntilDate != nil {
+			retainUntil = *retention.RetainUntilDate
+		}
+
+		info, err := s.PutObjectRetention(vars["bucketName"], vars["objectName"], r.URL.Query().Get("versionId"),
+			retention.Mode, retainUntil, bypassGovernance(r, vars["bucketName"], vars["objectName"]))
+		if err != nil {
+			writeObjectError(w, r, info, err)
+			return
+		}
+
+		if info.VersionID != "" {
+			w.Header().Set("x-amz-version-id", info.VersionID)
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// Get the legal hold status of an object version (GET ?legal-hold)
+func HandleGetObjectLegalHold(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		info, ok := statLockedObject(s, w, r)
+		if !ok {
+			return
+		}
+
+		writeXMLResponse(w, r, http.StatusOK, dto.ObjectLegalHold{Xmlns: s3Xmlns, Status: legalHoldStatus(info.Lock)})
+	}
+}
+
+// Place or release a legal hold on an object version (PUT ?legal-hold)
+func HandlePutObjectLegalHold(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+
+		var legalHold dto.ObjectLegalHold
+		if err := xml.NewDecoder(r.Body).Decode(&legalHold); err != nil || (legalHold.Status != "ON" && legalHold.Status != "OFF") {
+			log.Printf("Invalid LegalHold body (status %q): %v", legalHold.Status, err)
+			s3errors.WriteErrorResponse(w, r, s3errors.ErrMalformedXML)
+			return
+		}
+
+		info, err := s.PutObjectLegalHold(vars["bucketName"], vars["objectName"], r.URL.Query().Get("versionId"), legalHold.Status == "ON")
+		if err != nil {
+			writeObjectError(w, r, info, err)
+			return
+		}
+
+		if info.VersionID != "" {
+			w.Header().Set("x-amz-version-id", info.VersionID)
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// statLockedObject reads the object version targeted by a ?retention or ?legal-hold request,
+// answering InvalidRequest when the bucket does not have Object Lock enabled
+func statLockedObject(s storage.Storage, w http.ResponseWriter, r *http.Request) (dto.ObjectInfo, bool) {
+	vars := mux.Vars(r)
+	if _, err := s.GetObjectLockConfiguration(vars["bucketName"]); err != nil {
+		writeStorageError(w, r, err)
+		return dto.ObjectInfo{}, false
+	}
+
+	info, err := s.StatObject(vars["bucketName"], vars["objectName"], r.URL.Query().Get("versionId"))
+	if err != nil {
+		writeObjectError(w, r, info, err)
+		return dto.ObjectInfo{}, false
+	}
+	return info, true
+}
+
+// enableObjectLock turns versioning and Object Lock on for a bucket created with
+// x-amz-bucket-object-lock-enabled: true
+func enableObjectLock(s storage.Storage, bucketName string) error {
+	if err := s.SetBucketVersioning(bucketName, storage.VersioningEnabled); err != nil {
+		return err
+	}
+	return s.PutObjectLockConfiguration(bucketName, dto.ObjectLockConfiguration{ObjectLockEnabled: "Enabled"})
+}
+
+// parseObjectLockHeaders reads the lock requested on upload: a mode with its retain-until date, and a legal hold
+func parseObjectLockHeaders(header http.Header) (dto.ObjectLock, error) {
+	var lock dto.ObjectLock
+
+	mode, retainUntil := header.Get("x-amz-object-lock-mode"), header.Get("x-amz-object-lock-retain-until-date")
+	if (mode == "") != (retainUntil == "") {
+		return lock, s3errors.ErrInvalidObjectLock
+	}
+	if mode != "" {
+		date, err := time.Parse(time.RFC3339, retainUntil)
+		if err != nil {
+			log.Printf("Invalid x-amz-object-lock-retain-until-date header: %q", retainUntil)
+			return lock, s3errors.ErrInvalidObjectLock
+		}
+		lock.Mode, lock.RetainUntilDate = mode, date.UTC()
+	}
+
+	switch header.Get("x-amz-object-lock-legal-hold") {
+	case "", "OFF":
+	case "ON":
+		lock.LegalHold = true
+	default:
+		return lock, s3errors.ErrInvalidObjectLock
+	}
+	return lock, nil
+}
+
+// bypassGovernance honours x-amz-bypass-governance-retention only for callers allowed s3:BypassGovernanceRetention
+// on the object: being allowed to delete it or to change its retention is not enough
+func bypassGovernance(r *http.Request, bucketName, objectName string) bool {
+	if !strings.EqualFold(r.Header.Get("x-amz-bypass-governance-retention"), "true") {
+		return false
+	}
+	if !middleware.Allowed(r, auth.ActionBypassGovernanceRetention, auth.ObjectResource(bucketName, objectName)) {
+		log.Printf("Ignoring x-amz-bypass-governance-retention on %s/%s: %s not allowed", bucketName, objectName, auth.ActionBypassGovernanceRetention)
+		return false
+	}
+	return true
+}
+
+func legalHoldStatus(lock dto.ObjectLock) string {
+	if lock.LegalHold {
+		return "ON"
+	}
+	return "OFF"
+}
+
+// setObjectLockHeaders reports the lock of an object version on HEAD and GET responses
+func setObjectLockHeaders(w http.ResponseWriter, lock dto.ObjectLock) {
+	if lock.Mode != "" {
+		w.Header().Set("x-amz-object-lock-mode", lock.Mode)
+		w.Header().Set("x-amz-object-lock-retain-until-date", lock.RetainUntilDate.UTC().Format(time.RFC3339))
+	}
+	if lock.LegalHold {
+		w.Header().Set("x-amz-object-lock-legal-hold", legalHoldStatus(lock))
+	}
+}
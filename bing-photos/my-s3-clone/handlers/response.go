@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"log"
+	"net/http"
+
+	"my-s3-clone/s3errors"
+)
+
+// writeXMLResponse encodes v as an XML document with the given status code
+func writeXMLResponse(w http.ResponseWriter, r *http.Request, statusCode int, v interface{}) {
+	response, err := xml.Marshal(v)
+	if err != nil {
+		log.Printf("Error generating XML response: %v", err)
+		s3errors.WriteErrorResponse(w, r, s3errors.ErrInternalError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(statusCode)
+	w.Write([]byte(xml.Header))
+	w.Write(response)
+}
+
+// writeJSONResponse encodes v as a JSON document with the given status code, for the admin API
+func writeJSONResponse(w http.ResponseWriter, r *http.Request, statusCode int, v interface{}) {
+	response, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("Error generating JSON response: %v", err)
+		s3errors.WriteErrorResponse(w, r, s3errors.ErrInternalError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(response)
+}
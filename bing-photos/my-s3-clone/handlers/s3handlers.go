@@ -2,6 +2,9 @@ package handlers
 
 import (
     "io"
+    "my-s3-clone/auth"
+    "my-s3-clone/middleware"
+    "my-s3-clone/s3errors"
     "my-s3-clone/storage"
     "my-s3-clone/dto"
     "net/http"
@@ -13,8 +16,16 @@ import (
     "os"
     "strconv"
     "errors"
+    "strings"
+    "crypto/md5"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/base64"
 )
 
+// DeleteObjects accepts at most 1000 keys per request
+const maxDeleteObjects = 1000
+
 // List all buckets
 func HandleListBuckets(s storage.Storage) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
@@ -31,10 +42,16 @@ func HandleListBuckets(s storage.Storage) http.HandlerFunc {
 
         var bucketList []dto.Bucket
         for _, bucketName := range buckets {
+            info, err := s.GetBucketInfo(bucketName)
+            if err != nil {
+                // The bucket may have been deleted since it was listed
+                log.Printf("Skipping bucket %s: %v", bucketName, err)
+                continue
+            }
             log.Printf("Adding bucket: %s", bucketName)
             bucketList = append(bucketList, dto.Bucket{
                 Name:         bucketName,
-                CreationDate: time.Now(),
+                CreationDate: info.CreationDate,
             })
         }
 
@@ -42,14 +59,8 @@ func HandleListBuckets(s storage.Storage) http.HandlerFunc {
             Buckets: bucketList,
         }
 
-        w.Header().Set("Content-Type", "application/xml")
-        w.WriteHeader(http.StatusOK)
-
         log.Println("Encoding response as XML and sending it.")
-        if err := xml.NewEncoder(w).Encode(response); err != nil {
-            http.Error(w, "Erreur lors de l'encodage des buckets en XML", http.StatusInternalServerError)
-            log.Printf("Erreur lors de l'encodage des buckets: %v", err)
-        }
+        writeXMLResponse(w, r, http.StatusOK, response)
     }
 }
 
@@ -59,7 +70,7 @@ func HandleCreateBucket(s storage.Storage) http.HandlerFunc {
         log.Printf("Received request: %s %s", r.Method, r.URL.Path)
 
         if r.Method != "PUT" {
-            http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+            s3errors.WriteErrorResponse(w, r, s3errors.ErrMethodNotAllowed)
             return
         }
 
@@ -69,22 +80,52 @@ func HandleCreateBucket(s storage.Storage) http.HandlerFunc {
         // Vérification si le bucket existe déjà
         exists, err := s.CheckBucketExists(bucketName) 
         if err != nil {
-            http.Error(w, "Erreur lors de la vérification du bucket", http.StatusInternalServerError)
+            writeStorageError(w, r, err)
             return
         }
 
         if exists {
-            http.Error(w, fmt.Sprintf("Bucket '%s' already exists", bucketName), http.StatusConflict)
+            log.Printf("Bucket %s already exists", bucketName)
+            s3errors.WriteErrorResponse(w, r, s3errors.ErrBucketAlreadyOwnedByYou)
             return
         }
 
+        // The optional body selects the region of the bucket
+        opts := storage.CreateBucketOptions{Owner: requestOwner(r)}
+        if r.Body != nil {
+            body, err := io.ReadAll(r.Body)
+            if err != nil {
+                writeStorageError(w, r, err)
+                return
+            }
+            if strings.TrimSpace(string(body)) != "" {
+                var config dto.CreateBucketConfiguration
+                if err := xml.Unmarshal(body, &config); err != nil {
+                    s3errors.WriteErrorResponse(w, r, s3errors.ErrMalformedXML)
+                    return
+                }
+                opts.Region = config.LocationConstraint
+            }
+        }
+
         // Création du bucket si il n'existe pas
-        err = s.CreateBucket(bucketName)
+        err = s.CreateBucket(bucketName, opts)
         if err != nil {
-            http.Error(w, err.Error(), http.StatusInternalServerError)
+            writeStorageError(w, r, err)
             return
         }
 
+        // Object Lock must be requested at creation; it also enables versioning
+        if strings.EqualFold(r.Header.Get("x-amz-bucket-object-lock-enabled"), "true") {
+            if err := enableObjectLock(s, bucketName); err != nil {
+                if deleteErr := s.DeleteBucket(bucketName); deleteErr != nil {
+                    log.Printf("Failed to roll back bucket %s: %v", bucketName, deleteErr)
+                }
+                writeStorageError(w, r, err)
+                return
+            }
+        }
+
         // Réponse pour indiquer que le bucket a été créé avec succès
         bucketResponse := dto.ListAllMyBucketsResult{
             Buckets: []dto.Bucket{
@@ -98,11 +139,19 @@ func HandleCreateBucket(s storage.Storage) http.HandlerFunc {
         w.Header().Set("Location", r.URL.String())
         w.WriteHeader(http.StatusOK)
         if err := xml.NewEncoder(w).Encode(bucketResponse); err != nil {
-            http.Error(w, "Erreur lors de l'encodage XML", http.StatusInternalServerError)
+            log.Printf("Error encoding CreateBucket response: %v", err)
         }
     }
 }
 
+// requestOwner returns the access key that signed the request, empty when it is not authenticated
+func requestOwner(r *http.Request) string {
+    if cred, ok := r.Context().Value(middleware.CredentialKey).(*auth.Credential); ok {
+        return cred.AccessKey
+    }
+    return ""
+}
+
 // Get bucket info or location
 func HandleGetBucket(s storage.Storage) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
@@ -117,23 +166,20 @@ func HandleGetBucket(s storage.Storage) http.HandlerFunc {
 
         if locationParam != "" {
             log.Printf("Demande de localisation pour le bucket: %s", bucketName)
-            w.Header().Set("Content-Type", "application/xml")
-            w.WriteHeader(http.StatusOK)
-            w.Write([]byte(`<LocationConstraint>us-east-1</LocationConstraint>`))
+            writeBucketLocation(w, r, s, bucketName)
             return
         }
 
         // Vérifier si le bucket existe
         exists, err := s.CheckBucketExists(bucketName)
         if err != nil {
-            log.Printf("Erreur lors de la vérification du bucket: %v", err)
-            http.Error(w, "Internal server error", http.StatusInternalServerError)
+            writeStorageError(w, r, err)
             return
         }
 
         if !exists {
             log.Printf("Bucket non trouvé: %s", bucketName)
-            http.Error(w, fmt.Sprintf("Bucket '%s' not found", bucketName), http.StatusNotFound)
+            s3errors.WriteErrorResponse(w, r, s3errors.ErrNoSuchBucket)
             return
         }
 
@@ -154,38 +200,53 @@ func HandleAddObject(s storage.Storage) http.HandlerFunc {
         objectName := vars["objectName"]
 
         if bucketName == "" || objectName == "" {
-            http.Error(w, "Bucket name and object name are required", http.StatusBadRequest)
             log.Printf("Bucket name or object name missing: bucketName=%s, objectName=%s", bucketName, objectName)
+            s3errors.WriteErrorResponse(w, r, s3errors.ErrInvalidObjectName)
             return
         }
 
         log.Printf("Uploading object: %s to bucket: %s", objectName, bucketName)
 
-        // Get the total content length from the X-Amz-Decoded-Content-Length header
-        contentLength := r.Header.Get("X-Amz-Decoded-Content-Length")
-        if contentLength == "" {
-            log.Printf("Missing X-Amz-Decoded-Content-Length header")
-            http.Error(w, "Missing X-Amz-Decoded-Content-Length header", http.StatusBadRequest)
+        opts, err := parsePutObjectOptions(r)
+        if err != nil {
+            writeStorageError(w, r, err)
+            return
+        }
+        if opts.Metadata, err = parseObjectMetadata(r.Header); err != nil {
+            writeStorageError(w, r, err)
+            return
+        }
+        if opts.Lock, err = parseObjectLockHeaders(r.Header); err != nil {
+            writeStorageError(w, r, err)
+            return
+        }
+        if opts.Tags, err = parseTaggingHeader(r.Header); err != nil {
+            writeStorageError(w, r, err)
             return
         }
 
-        log.Printf("Total upload size: %s bytes", contentLength)
+        // Conditional upload: only If-None-Match: * (the key must not exist yet) is supported
+        if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+            if ifNoneMatch != "*" {
+                s3errors.WriteErrorResponse(w, r, s3errors.ErrNotImplemented)
+                return
+            }
+            opts.IfNoneMatch = true
+        }
 
         // Process the uploaded object
-        err := s.AddObject(bucketName, objectName, r.Body, r.Header.Get("X-Amz-Content-Sha256"))
+        info, err := s.AddObject(bucketName, objectName, r.Body, opts)
         if err != nil {
-            http.Error(w, err.Error(), http.StatusInternalServerError)
-            log.Printf("Error uploading object: %v", err)
+            writeStorageError(w, r, err)
             return
         }
 
-        // Generate an ETag for the object
-        eTag := `"1b2cf535f27731c974343645a3985328"`
-
         // Set the appropriate headers
-        w.Header().Set("ETag", eTag)
-        w.Header().Set("x-amz-id-2", "LriYPLdmOdAiIfgSm/F1YsViT1LW94/xUQxMsF7xiEb1a0wiIOIxl+zbwZ163pt7")
-        w.Header().Set("x-amz-request-id", "0A49CE4060975EAC")
+        w.Header().Set("ETag", info.ETag)
+        setEncryptionHeaders(w, info)
+        if info.VersionID != "" {
+            w.Header().Set("x-amz-version-id", info.VersionID)
+        }
         w.Header().Set("Date", time.Now().Format(http.TimeFormat))
 
         // Send the response
@@ -198,6 +259,68 @@ func HandleAddObject(s storage.Storage) http.HandlerFunc {
     }
 }
 
+// parsePutObjectOptions reads the upload headers shared by PUT object and upload part.
+// Streaming uploads carry the decoded size in X-Amz-Decoded-Content-Length and one signature per chunk.
+func parsePutObjectOptions(r *http.Request) (storage.PutObjectOptions, error) {
+    opts := storage.PutObjectOptions{
+        ContentSha256:        r.Header.Get("X-Amz-Content-Sha256"),
+        DecodedContentLength: -1,
+    }
+    if err := checkContentSha256(opts.ContentSha256); err != nil {
+        log.Printf("Unsupported X-Amz-Content-Sha256 header: %q", opts.ContentSha256)
+        return opts, err
+    }
+
+    if contentMD5 := r.Header.Get("Content-MD5"); contentMD5 != "" {
+        digest, err := base64.StdEncoding.DecodeString(contentMD5)
+        if err != nil || len(digest) != md5.Size {
+            log.Printf("Invalid Content-MD5 header: %q", contentMD5)
+            return opts, s3errors.ErrInvalidDigest
+        }
+        opts.ContentMD5 = digest
+    }
+
+    encryption, err := parseSSEHeaders(r.Header)
+    if err != nil {
+        return opts, err
+    }
+    opts.Encryption = encryption
+
+    if opts.ContentSha256 == auth.StreamingPayload {
+        contentLength := r.Header.Get("X-Amz-Decoded-Content-Length")
+        decodedLength, err := strconv.ParseInt(contentLength, 10, 64)
+        if err != nil || decodedLength < 0 {
+            log.Printf("Missing or invalid X-Amz-Decoded-Content-Length header: %q", contentLength)
+            return opts, s3errors.ErrMissingContentLength
+        }
+        log.Printf("Total upload size: %d bytes", decodedLength)
+
+        cred, ok := r.Context().Value(middleware.CredentialKey).(*auth.Credential)
+        if !ok {
+            return opts, s3errors.ErrAccessDenied
+        }
+        opts.DecodedContentLength = decodedLength
+        opts.ChunkVerifier = auth.NewChunkVerifier(cred)
+    }
+
+    return opts, nil
+}
+
+// checkContentSha256 rejects an X-Amz-Content-Sha256 value the upload cannot be checked against, before the body is read.
+// Trailing checksums and the other aws-chunked variants are not implemented; anything else must be a hex SHA-256 digest.
+func checkContentSha256(value string) error {
+    switch {
+    case value == "" || value == auth.UnsignedPayload || value == auth.StreamingPayload:
+        return nil
+    case strings.HasPrefix(value, auth.StreamingPrefix):
+        return s3errors.ErrNotImplemented
+    }
+    if digest, err := hex.DecodeString(value); err != nil || len(digest) != sha256.Size {
+        return s3errors.ErrInvalidContentSHA256
+    }
+    return nil
+}
+
 // Check if an object exists
 func HandleCheckObjectExist(s storage.Storage) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
@@ -208,27 +331,37 @@ func HandleCheckObjectExist(s storage.Storage) http.HandlerFunc {
         objectName := vars["objectName"]
 
         if bucketName == "" || objectName == "" {
-            http.Error(w, "Bucket name and object name are required", http.StatusBadRequest)
+            s3errors.WriteErrorResponse(w, r, s3errors.ErrInvalidObjectName)
             return
         }
 
-        exists, lastModified, size, err := s.CheckObjectExist(bucketName, objectName)
-        if err != nil || !exists {
-            if !exists {
-                http.Error(w, "Object not found", http.StatusNotFound)
-                return
-            }
-            http.Error(w, err.Error(), http.StatusInternalServerError)
+        info, err := s.StatObject(bucketName, objectName, r.URL.Query().Get("versionId"))
+        if err != nil {
+            writeObjectError(w, r, info, err)
             return
         }
 
-        w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
-        w.Header().Set("Content-Length", fmt.Sprintf("%d", size))
+        // Comme GET, HEAD d'un objet SSE-C exige la clé qui l'a chiffré
+        customerKey, err := parseCustomerKey(r.Header, sseCustomerHeaderPrefix)
+        if err == nil {
+            err = storage.CheckCustomerKey(info.SSECustomerKeyMD5, customerKey)
+        }
+        if err != nil {
+            writeStorageError(w, r, err)
+            return
+        }
+
+        setObjectHeaders(w, info)
+        if !checkPreconditions(w, r, info.ETag, info.LastModified) {
+            return
+        }
+
+        w.Header().Set("Content-Length", fmt.Sprintf("%d", info.Size))
         w.WriteHeader(http.StatusOK)
     }
 }
 
-// Download an object
+// Download an object, honouring Range and conditional request headers
 func HandleDownloadObject(s storage.Storage) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
         log.Printf("Received request: %s %s", r.Method, r.URL.Path)
@@ -237,63 +370,58 @@ func HandleDownloadObject(s storage.Storage) http.HandlerFunc {
         bucketName := vars["bucketName"]
         objectName := vars["objectName"]
 
-        // Récupérer les données du fichier et ses métadonnées
-        data, fileInfo, err := s.GetObject(bucketName, objectName)
+        customerKey, err := parseCustomerKey(r.Header, sseCustomerHeaderPrefix)
         if err != nil {
-            if os.IsNotExist(err) {
-                http.Error(w, "File not found", http.StatusNotFound)
-                return
-            }
-            http.Error(w, err.Error(), http.StatusInternalServerError)
+            writeStorageError(w, r, err)
             return
         }
 
-        // Envoyer les métadonnées dans les en-têtes HTTP
-        w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=\"%s\"", objectName))
-        w.Header().Set("Content-Length", fmt.Sprintf("%d", fileInfo.Size()))
-        w.Header().Set("Last-Modified", fileInfo.ModTime().Format(http.TimeFormat))
-
-        // Envoyer le contenu du fichier
-        w.Header().Set("Content-Type", "application/octet-stream")
-        w.WriteHeader(http.StatusOK)
-
-        if _, err := w.Write(data); err != nil {
-            http.Error(w, "Failed to write file content", http.StatusInternalServerError)
+        // Ouvrir l'objet et récupérer ses métadonnées, déchiffrées à la lecture s'il est chiffré
+        reader, info, err := s.GetObject(bucketName, objectName, r.URL.Query().Get("versionId"), customerKey)
+        if err != nil {
+            writeObjectError(w, r, info, err)
+            return
         }
-    }
-}
+        defer reader.Close()
 
-// List objects in a bucket
-func HandleListObjects(s storage.Storage) http.HandlerFunc {
-    return func(w http.ResponseWriter, r *http.Request) {
-        vars := mux.Vars(r)
-        bucketName := vars["bucketName"]
+        size := info.Size
 
-        queryParams := r.URL.Query()
-        prefix := queryParams.Get("prefix")
-        marker := queryParams.Get("marker")
-        maxKeys := queryParams.Get("max-keys")
-
-        if maxKeys == "" {
-            maxKeys = "1000"
+        // Envoyer les métadonnées dans les en-têtes HTTP
+        setObjectHeaders(w, info)
+        if info.Metadata.ContentDisposition == "" {
+            w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=\"%s\"", objectName))
         }
-
-        maxKeysInt, err := strconv.Atoi(maxKeys)
-        if err != nil {
-            http.Error(w, "Invalid max-keys value", http.StatusBadRequest)
+        if !checkPreconditions(w, r, info.ETag, info.LastModified) {
             return
         }
 
-        objects, err := s.ListObjects(bucketName, prefix, marker, maxKeysInt)
-        if err != nil {
-            http.Error(w, err.Error(), http.StatusInternalServerError)
-            return
+        start, length, status := int64(0), size, http.StatusOK
+        if rangeHeader := r.Header.Get("Range"); rangeHeader != "" {
+            rangeStart, rangeLength, ok, err := parseRange(rangeHeader, size)
+            if err != nil {
+                w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+                s3errors.WriteErrorResponse(w, r, s3errors.ErrInvalidRange)
+                return
+            }
+            if ok {
+                start, length, status = rangeStart, rangeLength, http.StatusPartialContent
+                w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, start+length-1, size))
+            }
         }
 
-        w.Header().Set("Content-Type", "application/xml")
-        w.WriteHeader(http.StatusOK)
-        if err := xml.NewEncoder(w).Encode(objects); err != nil {
-            http.Error(w, "Erreur lors de l'encodage XML", http.StatusInternalServerError)
+        if start > 0 {
+            if _, err := reader.Seek(start, io.SeekStart); err != nil {
+                writeStorageError(w, r, err)
+                return
+            }
+        }
+
+        w.Header().Set("Content-Length", fmt.Sprintf("%d", length))
+        w.WriteHeader(status)
+
+        // Envoyer le contenu du fichier par morceaux
+        if _, err := io.CopyN(w, reader, length); err != nil {
+            log.Printf("Failed to stream object %s/%s: %v", bucketName, objectName, err)
         }
     }
 }
@@ -306,22 +434,14 @@ func HandleDeleteBucket(s storage.Storage) http.HandlerFunc {
         bucketName := vars["bucketName"]
         
         if bucketName == "" {
-            http.Error(w, "Bucket name is required", http.StatusBadRequest)
+            s3errors.WriteErrorResponse(w, r, s3errors.ErrInvalidBucketName)
             return
         }
 
-        // Tenter de supprimer le bucket
+        // Tenter de supprimer le bucket : NoSuchBucket s'il n'existe pas, BucketNotEmpty s'il contient des objets
         err := s.DeleteBucket(bucketName)
         if err != nil {
-            // Si l'erreur indique que le bucket n'existe pas, renvoyer un code 404
-            if os.IsNotExist(err) {
-                log.Printf("Bucket %s does not exist", bucketName)
-                http.Error(w, fmt.Sprintf("Bucket %s does not exist", bucketName), http.StatusNotFound)
-                return
-            }
-            // Pour toute autre erreur, renvoyer un code 500
-            log.Printf("Error deleting bucket %s: %v", bucketName, err)
-            http.Error(w, "Failed to delete bucket", http.StatusInternalServerError)
+            writeStorageError(w, r, err)
             return
         }
 
@@ -332,11 +452,11 @@ func HandleDeleteBucket(s storage.Storage) http.HandlerFunc {
 }
 
 
-// Batch delete objects
+// Batch delete objects (DeleteObjects): each key gets its own <Deleted> or <Error> entry
 func HandleDeleteObject(s storage.Storage) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
         if r.Method != http.MethodPost {
-            http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+            s3errors.WriteErrorResponse(w, r, s3errors.ErrMethodNotAllowed)
             return
         }
         log.Printf("Received POST ?delete request for batch deletion: %s %s", r.Method, r.URL.Path)
@@ -346,167 +466,95 @@ func HandleDeleteObject(s storage.Storage) http.HandlerFunc {
 
         body, err := io.ReadAll(r.Body)
         if err != nil {
-            http.Error(w, "Error reading request body", http.StatusInternalServerError)
-            log.Printf("Error reading request body: %v", err)
+            writeStorageError(w, r, err)
             return
         }
         log.Printf("Request body: %s", string(body))
 
         var deleteReq dto.DeleteObjectRequest
         err = xml.Unmarshal(body, &deleteReq)
-        if err != nil {
-            http.Error(w, "Error parsing XML", http.StatusBadRequest)
-            log.Printf("Error parsing XML: %v", err)
+        if err != nil || len(deleteReq.Objects) == 0 || len(deleteReq.Objects) > maxDeleteObjects {
+            log.Printf("Invalid DeleteObjects request (%d keys): %v", len(deleteReq.Objects), err)
+            s3errors.WriteErrorResponse(w, r, s3errors.ErrMalformedXML)
             return
         }
 
-        var deletedObjects []dto.Deleted
+        deleteResult := dto.DeleteResult{Xmlns: s3Xmlns}
         for _, objectToDelete := range deleteReq.Objects {
             log.Printf("Attempting to delete object: %s", objectToDelete.Key)
-            err := s.DeleteObject(bucketName, objectToDelete.Key)
-            if err != nil {
-                if errors.Is(err, os.ErrNotExist) { // Vérifie si l'erreur correspond à l'objet non trouvé
-                    http.Error(w, "Object not found", http.StatusNotFound)
-                    log.Printf("Object not found: %s", objectToDelete.Key)
-                    continue 
-                }
-                http.Error(w, "Error deleting object", http.StatusInternalServerError)
-                log.Printf("Error deleting object %s: %v", objectToDelete.Key, err)
+            if !middleware.Allowed(r, auth.ActionDeleteObject, auth.ObjectResource(bucketName, objectToDelete.Key)) {
+                deleteResult.Errors = append(deleteResult.Errors, accessDeniedError(objectToDelete.Key, objectToDelete.VersionId))
+                continue
+            }
+            info, err := s.DeleteObject(bucketName, objectToDelete.Key, storage.DeleteObjectOptions{
+                VersionID:        objectToDelete.VersionId,
+                BypassGovernance: bypassGovernance(r, bucketName, objectToDelete.Key),
+            })
+            if errors.Is(err, storage.ErrNoSuchBucket) {
+                writeStorageError(w, r, err)
                 return
             }
+            // Comme sur S3, supprimer une clé absente est un succès
+            if err != nil && !errors.Is(err, os.ErrNotExist) {
+                apiErr := toAPIError(err)
+                log.Printf("Error deleting object %s: %v", objectToDelete.Key, err)
+                deleteResult.Errors = append(deleteResult.Errors, dto.DeleteError{
+                    Key:       objectToDelete.Key,
+                    VersionId: objectToDelete.VersionId,
+                    Code:      apiErr.Code,
+                    Message:   apiErr.Description,
+                })
+                continue
+            }
             log.Printf("Successfully deleted object: %s", objectToDelete.Key)
 
-            deletedObjects = append(deletedObjects, dto.Deleted{Key: objectToDelete.Key})
-        }
-
-        deleteResult := dto.DeleteResult{
-            DeletedResult: deletedObjects,
-        }
-
-        response, err := xml.Marshal(deleteResult)
-        if err != nil {
-            http.Error(w, "Error generating XML response", http.StatusInternalServerError)
-            log.Printf("Error generating XML response: %v", err)
-            return
+            if !deleteReq.Quiet {
+                deleted := dto.Deleted{Key: objectToDelete.Key, VersionId: objectToDelete.VersionId}
+                if info.IsDeleteMarker {
+                    deleted.DeleteMarker = true
+                    deleted.DeleteMarkerVersionId = info.VersionID
+                }
+                deleteResult.DeletedResult = append(deleteResult.DeletedResult, deleted)
+            }
         }
 
-        w.Header().Set("Content-Type", "application/xml")
-        w.WriteHeader(http.StatusOK)
-        w.Write(response)
-
-                // Log response status and body
-        log.Printf("Response status: %d", http.StatusOK)
-        log.Printf("Response body: %s", string(response))
+        writeXMLResponse(w, r, http.StatusOK, deleteResult)
+        log.Printf("Batch deletion in bucket %s: %d deleted, %d errors", bucketName, len(deleteResult.DeletedResult), len(deleteResult.Errors))
     }
 }
 
-func HandleBucketLocation(s storage.Storage) http.HandlerFunc {
-    return func(w http.ResponseWriter, r *http.Request) {
-        vars := mux.Vars(r)
-        bucketName := vars["bucketName"]
-            // La chaîne représentant la date
-    dateString := "2024-09-16T10:12:24Z"
-
-    // Convertir la chaîne en time.Time
-    creationDate, err := time.Parse(time.RFC3339, dateString)
-    if err != nil {
-        fmt.Printf("Error parsing date: %v\n", err)
-        return
-    }
-        bucket := dto.Bucket{
-            Name:         bucketName,
-            CreationDate: creationDate,
-            LocationConstraint : "us-east-1",
-        }
-
-        response, err := xml.Marshal(bucket)
-        if err != nil {
-            http.Error(w, "Error generating XML response", http.StatusInternalServerError)
-            log.Printf("Error generating XML response: %v", err)
-            return
-        }
-
-        
-        w.Header().Set("Content-Type", "application/xml")
-        w.WriteHeader(http.StatusOK)
-        w.Write(response)
-            
+// accessDeniedError reports a key of a batch request that the access key may not act on
+func accessDeniedError(key, versionID string) dto.DeleteError {
+    log.Printf("Access denied on key %s", key)
+    return dto.DeleteError{
+        Key:       key,
+        VersionId: versionID,
+        Code:      s3errors.ErrAccessDenied.Code,
+        Message:   s3errors.ErrAccessDenied.Description,
     }
 }
 
-func HandleBucketLockConfig(s storage.Storage) http.HandlerFunc {
+// HandleBucketLocation returns the region recorded when the bucket was created
+func HandleBucketLocation(s storage.Storage) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
-        vars := mux.Vars(r)
-        bucketName := vars["bucketName"]
-
-        dateString := "2024-09-16T10:12:24Z"
-
-        // Convertir la chaîne en time.Time
-        creationDate, err := time.Parse(time.RFC3339, dateString)
-        if err != nil {
-            fmt.Printf("Error parsing date: %v\n", err)
-            return
-        }
-
-        bucket := dto.Bucket{
-            Name:         bucketName,
-            CreationDate: creationDate,
-            ObjectLockConfig : "true",
-        }
-    
-
-        response, err := xml.Marshal(bucket)
-        if err != nil {
-            http.Error(w, "Error generating XML response", http.StatusInternalServerError)
-            log.Printf("Error generating XML response: %v", err)
-            return
-        }
-
-        
-        w.Header().Set("Content-Type", "application/xml")
-        w.WriteHeader(http.StatusOK)
-        w.Write(response)
-            
-            
+        writeBucketLocation(w, r, s, mux.Vars(r)["bucketName"])
     }
 }
 
-
-func HandleBucketDelimiter(s storage.Storage) http.HandlerFunc {
-    return func(w http.ResponseWriter, r *http.Request) {
-        vars := mux.Vars(r)
-        bucketName := vars["bucketName"]
-
-        dateString := "2024-09-16T10:12:24Z"
-
-        // Convertir la chaîne en time.Time
-        creationDate, err := time.Parse(time.RFC3339, dateString)
-        if err != nil {
-            fmt.Printf("Error parsing date: %v\n", err)
-            return
-        }
-
-        bucket := dto.Bucket{
-            Name:         bucketName,
-            CreationDate: creationDate,
-            ObjectDelimiter : "true",
-        }
-    
-
-        response, err := xml.Marshal(bucket)
-        if err != nil {
-            http.Error(w, "Error generating XML response", http.StatusInternalServerError)
-            log.Printf("Error generating XML response: %v", err)
-            return
-        }
-
-        
-        w.Header().Set("Content-Type", "application/xml")
-        w.WriteHeader(http.StatusOK)
-        w.Write(response)
-            
-            
+func writeBucketLocation(w http.ResponseWriter, r *http.Request, s storage.Storage, bucketName string) {
+    info, err := s.GetBucketInfo(bucketName)
+    if err != nil {
+        writeStorageError(w, r, err)
+        return
     }
+    response, err := xml.Marshal(dto.LocationConstraint{Region: info.Region})
+    if err != nil {
+        writeStorageError(w, r, err)
+        return
+    }
+    w.Header().Set("Content-Type", "application/xml")
+    w.WriteHeader(http.StatusOK)
+    w.Write(response)
 }
 
 type MoveObjectRequest struct {
@@ -520,11 +568,12 @@ type ObjectToMove struct {
 	Key string `xml:"Key"`
 }
 
-// HandleMoveObject gère les requêtes pour déplacer des objets entre des buckets
+// HandleMoveObject gère les requêtes pour déplacer des objets entre des buckets.
+// Chaque objet est renommé quand la source n'est pas versionnée, sinon copié puis remplacé par un marqueur de suppression.
 func HandleMoveObject(s storage.Storage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
-			http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+			s3errors.WriteErrorResponse(w, r, s3errors.ErrMethodNotAllowed)
 			return
 		}
 		log.Printf("Received POST ?move request for moving objects: %s %s", r.Method, r.URL.Path)
@@ -534,8 +583,7 @@ func HandleMoveObject(s storage.Storage) http.HandlerFunc {
 
 		body, err := io.ReadAll(r.Body)
 		if err != nil {
-			http.Error(w, "Error reading request body", http.StatusInternalServerError)
-			log.Printf("Error reading request body: %v", err)
+			writeStorageError(w, r, err)
 			return
 		}
 		log.Printf("Request body: %s", string(body))
@@ -543,58 +591,47 @@ func HandleMoveObject(s storage.Storage) http.HandlerFunc {
 		var moveReq MoveObjectRequest
 		err = xml.Unmarshal(body, &moveReq)
 		if err != nil {
-			http.Error(w, "Error parsing XML", http.StatusBadRequest)
 			log.Printf("Error parsing XML: %v", err)
+			s3errors.WriteErrorResponse(w, r, s3errors.ErrMalformedXML)
 			return
 		}
 
 		if moveReq.TargetBucket == "" {
-			http.Error(w, "TargetBucket is missing in the request", http.StatusBadRequest)
 			log.Println("Error: TargetBucket is missing")
+			s3errors.WriteErrorResponse(w, r, s3errors.ErrMissingTargetBucket)
 			return
 		}
 
-		var movedObjects []dto.Deleted
+		moveResult := dto.DeleteResult{Xmlns: s3Xmlns}
 		for _, objectToMove := range moveReq.Objects {
 			log.Printf("Attempting to move object: %s", objectToMove.Key)
 
-			// Copier l'objet
-			err := s.CopyObject(sourceBucket, objectToMove.Key, moveReq.TargetBucket, objectToMove.Key)
-			if err != nil {
-				http.Error(w, "Error moving object", http.StatusInternalServerError)
-				log.Printf("Error moving object %s: %v", objectToMove.Key, err)
-				return
+			// Le déplacement lit et supprime la source et écrit la cible
+			if !middleware.Allowed(r, auth.ActionGetObject, auth.ObjectResource(sourceBucket, objectToMove.Key)) ||
+				!middleware.Allowed(r, auth.ActionDeleteObject, auth.ObjectResource(sourceBucket, objectToMove.Key)) ||
+				!middleware.Allowed(r, auth.ActionPutObject, auth.ObjectResource(moveReq.TargetBucket, objectToMove.Key)) {
+				moveResult.Errors = append(moveResult.Errors, accessDeniedError(objectToMove.Key, ""))
+				continue
 			}
 
-			// Supprimer l'objet source
-			err = s.DeleteObject(sourceBucket, objectToMove.Key)
+			// Renommage quand c'est possible : l'objet n'est jamais visible sous les deux noms
+			_, err := s.MoveObject(sourceBucket, objectToMove.Key, moveReq.TargetBucket, objectToMove.Key)
 			if err != nil {
-				http.Error(w, "Error deleting source object after move", http.StatusInternalServerError)
-				log.Printf("Error deleting object %s: %v", objectToMove.Key, err)
-				return
+				apiErr := toAPIError(err)
+				log.Printf("Error moving object %s: %v", objectToMove.Key, err)
+				moveResult.Errors = append(moveResult.Errors, dto.DeleteError{
+					Key:     objectToMove.Key,
+					Code:    apiErr.Code,
+					Message: apiErr.Description,
+				})
+				continue
 			}
 
 			log.Printf("Successfully moved object: %s", objectToMove.Key)
-			movedObjects = append(movedObjects, dto.Deleted{Key: objectToMove.Key})
-		}
-
-		moveResult := dto.DeleteResult{
-			DeletedResult: movedObjects,
+			moveResult.DeletedResult = append(moveResult.DeletedResult, dto.Deleted{Key: objectToMove.Key})
 		}
 
-		response, err := xml.Marshal(moveResult)
-		if err != nil {
-			http.Error(w, "Error generating XML response", http.StatusInternalServerError)
-			log.Printf("Error generating XML response: %v", err)
-			return
-		}
-
-		w.Header().Set("Content-Type", "application/xml")
-		w.WriteHeader(http.StatusOK)
-		w.Write(response)
-
-		// Log response status and body
-		log.Printf("Response status: %d", http.StatusOK)
-		log.Printf("Response body: %s", string(response))
+		writeXMLResponse(w, r, http.StatusOK, moveResult)
+		log.Printf("Move from bucket %s to %s: %d moved, %d errors", sourceBucket, moveReq.TargetBucket, len(moveResult.DeletedResult), len(moveResult.Errors))
 	}
 }
\ No newline at end of file
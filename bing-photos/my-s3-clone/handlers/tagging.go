@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/s3errors"
+	"my-s3-clone/storage"
+)
+
+// Get the tags of an object version (GET ?tagging)
+func HandleGetObjectTagging(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+
+		info, err := s.StatObject(vars["bucketName"], vars["objectName"], r.URL.Query().Get("versionId"))
+		if err != nil {
+			writeObjectError(w, r, info, err)
+			return
+		}
+
+		if info.VersionID != "" {
+			w.Header().Set("x-amz-version-id", info.VersionID)
+		}
+		tagging := tagSet(info.Tags)
+		tagging.Xmlns = s3Xmlns
+		writeXMLResponse(w, r, http.StatusOK, tagging)
+	}
+}
+
+// Replace the tags of an object version (PUT ?tagging)
+func HandlePutObjectTagging(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+
+		var tagging dto.Tagging
+		if err := xml.NewDecoder(r.Body).Decode(&tagging); err != nil {
+			log.Printf("Invalid Tagging body: %v", err)
+			s3errors.WriteErrorResponse(w, r, s3errors.ErrMalformedXML)
+			return
+		}
+		tags := make(map[string]string, len(tagging.TagSet.Tags))
+		for _, tag := range tagging.TagSet.Tags {
+			if _, duplicate := tags[tag.Key]; duplicate {
+				s3errors.WriteErrorResponse(w, r, s3errors.ErrInvalidTag)
+				return
+			}
+			tags[tag.Key] = tag.Value
+		}
+
+		info, err := s.PutObjectTagging(vars["bucketName"], vars["objectName"], r.URL.Query().Get("versionId"), tags)
+		if err != nil {
+			writeObjectError(w, r, info, err)
+			return
+		}
+
+		if info.VersionID != "" {
+			w.Header().Set("x-amz-version-id", info.VersionID)
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// Remove the tags of an object version (DELETE ?tagging)
+func HandleDeleteObjectTagging(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+
+		info, err := s.PutObjectTagging(vars["bucketName"], vars["objectName"], r.URL.Query().Get("versionId"), nil)
+		if err != nil {
+			writeObjectError(w, r, info, err)
+			return
+		}
+
+		if info.VersionID != "" {
+			w.Header().Set("x-amz-version-id", info.VersionID)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// parseTaggingHeader reads the x-amz-tagging header of an upload or a copy, URL-encoded
+// like a query string ("rendition=original&album=2024"); nil when the header is absent
+func parseTaggingHeader(header http.Header) (map[string]string, error) {
+	value := header.Get("x-amz-tagging")
+	if value == "" {
+		return nil, nil
+	}
+	query, err := url.ParseQuery(value)
+	if err != nil {
+		log.Printf("Invalid x-amz-tagging header %q: %v", value, err)
+		return nil, s3errors.ErrInvalidTag
+	}
+	tags := make(map[string]string, len(query))
+	for key, values := range query {
+		if len(values) != 1 {
+			return nil, s3errors.ErrInvalidTag
+		}
+		tags[key] = values[0]
+	}
+	return tags, nil
+}
+
+// parseTagFilter reads the tag=key=value parameters restricting a listing to the objects carrying all those tags
+func parseTagFilter(query url.Values) (map[string]string, error) {
+	if len(query["tag"]) == 0 {
+		return nil, nil
+	}
+	filter := make(map[string]string, len(query["tag"]))
+	for _, param := range query["tag"] {
+		key, value, ok := strings.Cut(param, "=")
+		if !ok || key == "" {
+			return nil, s3errors.ErrInvalidTag
+		}
+		filter[key] = value
+	}
+	return filter, nil
+}
+
+// tagSet converts tags into a Tagging document, sorted by key
+func tagSet(tags map[string]string) dto.Tagging {
+	tagging := dto.Tagging{TagSet: dto.TagSet{Tags: make([]dto.Tag, 0, len(tags))}}
+	for key, value := range tags {
+		tagging.TagSet.Tags = append(tagging.TagSet.Tags, dto.Tag{Key: key, Value: value})
+	}
+	sort.Slice(tagging.TagSet.Tags, func(i, j int) bool {
+		return tagging.TagSet.Tags[i].Key < tagging.TagSet.Tags[j].Key
+	})
+	return tagging
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/gorilla/mux"
+	"my-s3-clone/dto"
+	"my-s3-clone/s3errors"
+	"my-s3-clone/storage"
+)
+
+// Get the versioning status of a bucket (empty until versioning is first enabled)
+func HandleGetBucketVersioning(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status, err := s.GetBucketVersioning(mux.Vars(r)["bucketName"])
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		writeXMLResponse(w, r, http.StatusOK, dto.VersioningConfiguration{Xmlns: s3Xmlns, Status: status})
+	}
+}
+
+// Enable or suspend versioning on a bucket
+func HandlePutBucketVersioning(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bucketName := mux.Vars(r)["bucketName"]
+
+		var config dto.VersioningConfiguration
+		if err := xml.NewDecoder(r.Body).Decode(&config); err != nil {
+			log.Printf("Error parsing VersioningConfiguration body: %v", err)
+			s3errors.WriteErrorResponse(w, r, s3errors.ErrMalformedXML)
+			return
+		}
+
+		if err := s.SetBucketVersioning(bucketName, config.Status); err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// List every version and delete marker of the objects in a bucket (GET ?versions)
+func HandleListObjectVersions(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bucketName := mux.Vars(r)["bucketName"]
+		query := r.URL.Query()
+
+		maxKeys, ok := parseIntParam(query.Get("max-keys"), maxListKeys)
+		if !ok {
+			s3errors.WriteErrorResponse(w, r, s3errors.ErrInvalidMaxKeys)
+			return
+		}
+		if maxKeys > maxListKeys {
+			maxKeys = maxListKeys
+		}
+
+		encodingType := query.Get("encoding-type")
+		if encodingType != "" && encodingType != "url" {
+			s3errors.WriteErrorResponse(w, r, s3errors.ErrInvalidEncodingType)
+			return
+		}
+
+		// A version-id-marker is only meaningful after a key-marker
+		opts := storage.ListObjectVersionsOptions{
+			Prefix:          query.Get("prefix"),
+			Delimiter:       query.Get("delimiter"),
+			KeyMarker:       query.Get("key-marker"),
+			VersionIDMarker: query.Get("version-id-marker"),
+			MaxKeys:         maxKeys,
+		}
+		if opts.VersionIDMarker != "" && opts.KeyMarker == "" {
+			s3errors.WriteErrorResponse(w, r, s3errors.ErrInvalidVersionID)
+			return
+		}
+
+		listing, err := s.ListObjectVersions(bucketName, opts)
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		response := dto.ListVersionsResponse{
+			Xmlns:           s3Xmlns,
+			Name:            bucketName,
+			Prefix:          encodeListValue(opts.Prefix, encodingType),
+			KeyMarker:       encodeListValue(opts.KeyMarker, encodingType),
+			VersionIdMarker: opts.VersionIDMarker,
+			MaxKeys:         maxKeys,
+			Delimiter:       encodeListValue(opts.Delimiter, encodingType),
+			EncodingType:    encodingType,
+			IsTruncated:     listing.IsTruncated,
+		}
+		if listing.IsTruncated {
+			response.NextKeyMarker = encodeListValue(listing.NextKeyMarker, encodingType)
+			response.NextVersionIdMarker = listing.NextVersionIDMarker
+		}
+
+		for _, version := range listing.Versions {
+			entry := dto.VersionEntry{
+				XMLName:      xml.Name{Local: "Version"},
+				Key:          encodeListValue(version.Key, encodingType),
+				VersionId:    version.VersionID,
+				IsLatest:     version.IsLatest,
+				LastModified: version.LastModified.UTC(),
+			}
+			if version.IsDeleteMarker {
+				entry.XMLName.Local = "DeleteMarker"
+			} else {
+				size := version.Size
+				entry.ETag, entry.Size, entry.StorageClass = version.ETag, &size, "STANDARD"
+			}
+			response.Entries = append(response.Entries, entry)
+		}
+		for _, prefix := range listing.CommonPrefixes {
+			response.CommonPrefixes = append(response.CommonPrefixes, dto.CommonPrefix{Prefix: encodeListValue(prefix, encodingType)})
+		}
+
+		writeXMLResponse(w, r, http.StatusOK, response)
+	}
+}
+
+// Delete one object: in a versioned bucket this adds a delete marker,
+// with ?versionId it permanently removes that version unless Object Lock protects it
+func HandleDeleteSingleObject(s storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		bucketName := vars["bucketName"]
+		objectName := vars["objectName"]
+		opts := storage.DeleteObjectOptions{
+			VersionID:        r.URL.Query().Get("versionId"),
+			BypassGovernance: bypassGovernance(r, bucketName, objectName),
+		}
+
+		info, err := s.DeleteObject(bucketName, objectName, opts)
+		// As on S3, deleting a missing key succeeds
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			writeStorageError(w, r, err)
+			return
+		}
+
+		if info.VersionID != "" {
+			w.Header().Set("x-amz-version-id", info.VersionID)
+		}
+		if info.IsDeleteMarker {
+			w.Header().Set("x-amz-delete-marker", "true")
+		}
+		log.Printf("Deleted object %s/%s (version %q)", bucketName, objectName, info.VersionID)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// writeObjectError answers a failed GET or HEAD; a version that is a delete marker
+// is flagged with the x-amz-delete-marker header, as on S3
+func writeObjectError(w http.ResponseWriter, r *http.Request, info dto.ObjectInfo, err error) {
+	if errors.Is(err, storage.ErrDeleteMarker) {
+		w.Header().Set("x-amz-delete-marker", "true")
+		w.Header().Set("x-amz-version-id", info.VersionID)
+	}
+	writeStorageError(w, r, err)
+}
@@ -4,18 +4,113 @@ import (
     "log"
     "net/http"
     "os"
+    "time"
+    "my-s3-clone/config"
+    "my-s3-clone/notification"
     "my-s3-clone/router"
+    "my-s3-clone/storage"
 )
 
 func main() {
-    if _, err := os.Stat("./buckets"); os.IsNotExist(err) {
-        log.Printf("Le répertoire 'buckets' n'existe pas. Création...")
-        if err := os.Mkdir("./buckets", os.ModePerm); err != nil {
-            log.Fatalf("Erreur lors de la création du répertoire 'buckets': %v", err)
+    // "my-s3-clone rebuild-usage [options]" recalcule l'occupation des buckets puis s'arrête
+    if len(os.Args) > 1 && os.Args[1] == "rebuild-usage" {
+        rebuildUsage(os.Args[2:])
+        return
+    }
+
+    // Options, variables d'environnement S3_* et fichier YAML éventuel (-config)
+    cfg, err := config.Load(os.Args[1:])
+    if err != nil {
+        log.Fatalf("Configuration invalide : %v", err)
+    }
+
+    // Clé maîtresse SSE-S3 : sans elle, les objets sont stockés en clair sauf en SSE-C
+    masterKey, err := storage.ParseMasterKey(cfg.MasterKey)
+    if err != nil {
+        log.Fatalf("Clé maîtresse invalide : %v", err)
+    }
+    if masterKey == nil {
+        log.Println("Aucune clé maîtresse configurée : les objets sont stockés en clair sauf en SSE-C")
+    }
+    if len(cfg.Credentials) == 0 {
+        log.Println("Aucun identifiant S3 configuré : toutes les requêtes authentifiées seront rejetées")
+    }
+
+    fileStorage := &storage.FileStorage{Root: cfg.DataDir, MasterKey: masterKey}
+    // Écritures interrompues par un arrêt précédent
+    if removed, err := fileStorage.RemoveTempFiles(); err != nil {
+        log.Printf("Erreur lors de la suppression des fichiers temporaires: %v", err)
+    } else if removed > 0 {
+        log.Printf("%d fichier(s) temporaire(s) d'écritures interrompues supprimé(s)", removed)
+    }
+    notifier, err := notification.OpenNotifier(cfg.NotificationQueueDir(), fileStorage)
+    if err != nil {
+        log.Fatalf("File d'attente des notifications invalide : %v", err)
+    }
+    go purgeStaleMultipartUploads(fileStorage, cfg.MultipartExpiry)
+    // Les expirations sont publiées aux webhooks comme les suppressions demandées par les clients
+    go sweepLifecycle(fileStorage, notification.Wrap(fileStorage, notifier), cfg.LifecycleInterval, cfg.LifecycleDryRun)
+
+    r := router.SetupRouterWithNotifier(fileStorage, notifier, cfg)
+    log.Printf("Données dans %s, écoute sur %s", cfg.DataDir, cfg.Address)
+    if cfg.TLS() {
+        log.Fatal(http.ListenAndServeTLS(cfg.Address, cfg.TLSCertFile, cfg.TLSKeyFile, r))
+    }
+    log.Fatal(http.ListenAndServe(cfg.Address, r))
+}
+
+// purgeStaleMultipartUploads nettoie toutes les heures les uploads multipart abandonnés
+func purgeStaleMultipartUploads(fs *storage.FileStorage, expiry time.Duration) {
+    ticker := time.NewTicker(time.Hour)
+    defer ticker.Stop()
+
+    for {
+        purged, err := fs.PurgeStaleMultipartUploads(expiry)
+        if err != nil {
+            log.Printf("Erreur lors du nettoyage des uploads multipart: %v", err)
+        } else if purged > 0 {
+            log.Printf("%d upload(s) multipart abandonné(s) supprimé(s)", purged)
         }
+        <-ticker.C
     }
+}
 
-    r := router.SetupRouter() 
-    log.Println("Serving on :9090")
-    log.Fatal(http.ListenAndServe(":9090", r))
+// sweepLifecycle applique périodiquement les règles de cycle de vie des buckets.
+// Les objets expirés sont supprimés par events, qui publie leurs suppressions. En mode dry-run,
+// les objets qui seraient supprimés sont seulement journalisés.
+func sweepLifecycle(fs *storage.FileStorage, events storage.Storage, interval time.Duration, dryRun bool) {
+    if dryRun {
+        log.Printf("Cycle de vie en mode dry-run : aucune suppression ne sera effectuée")
+    }
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+
+    for {
+        fs.ApplyLifecycle(events, time.Now(), dryRun)
+        <-ticker.C
+    }
+}
+
+// rebuildUsage recalcule le compteur d'occupation de chaque bucket à partir des objets présents sur le disque,
+// après un arrêt brutal ou une modification manuelle du répertoire de données. À lancer serveur arrêté.
+func rebuildUsage(args []string) {
+    cfg, err := config.Load(args)
+    if err != nil {
+        log.Fatalf("Configuration invalide : %v", err)
+    }
+
+    fileStorage := &storage.FileStorage{Root: cfg.DataDir}
+    failed := false
+    for _, bucketName := range fileStorage.ListBuckets() {
+        usage, err := fileStorage.RebuildBucketUsage(bucketName)
+        if err != nil {
+            log.Printf("Erreur lors du recalcul de l'occupation du bucket %s: %v", bucketName, err)
+            failed = true
+            continue
+        }
+        log.Printf("Bucket %s : %d octet(s), %d objet(s)", bucketName, usage.Bytes, usage.Objects)
+    }
+    if failed {
+        os.Exit(1)
+    }
 }
@@ -0,0 +1,9 @@
+package middleware
+
+type contextKey string
+
+// CredentialKey donne accès au *auth.Credential de la requête authentifiée
+const CredentialKey contextKey = "credential"
+
+// authorizerKey donne accès à l'auth.Authorizer qui décide des droits de la requête
+const authorizerKey contextKey = "authorizer"
@@ -0,0 +1,161 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/s3errors"
+)
+
+// En-têtes autorisés pour les origines de la configuration du serveur
+const defaultAllowedHeaders = "Content-Type, Authorization, X-Amz-Content-Sha256, X-Amz-Decoded-Content-Length"
+
+// BucketCORS renvoie les règles CORS d'un bucket, ou une erreur s'il n'en a pas
+type BucketCORS func(bucketName string) (dto.CORSConfiguration, error)
+
+// CORSMiddleware renvoie les en-têtes CORS des requêtes portant une origine.
+// Les règles du bucket visé (PUT /{bucket}/?cors) s'appliquent en priorité : une requête preflight
+// qu'aucune règle n'autorise est refusée. Sans règle sur le bucket, ou pour les routes propres au serveur
+// (/_admin, /probe-bsign), les origines allowedOrigins de la configuration du serveur sont autorisées ("*" pour toutes).
+func CORSMiddleware(allowedOrigins []string, bucketCORS BucketCORS) func(http.Handler) http.Handler {
+	allowed := make(map[string]bool, len(allowedOrigins))
+	for _, origin := range allowedOrigins {
+		allowed[origin] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// La réponse dépend de l'origine : les caches ne doivent pas la partager entre origines
+			w.Header().Add("Vary", "Origin, Access-Control-Request-Headers, Access-Control-Request-Method")
+
+			origin := r.Header.Get("Origin")
+			preflight := r.Method == http.MethodOptions
+			if origin != "" {
+				var bucketRules *dto.CORSConfiguration
+				if !serverPath(r.URL.Path) {
+					if config, err := bucketCORS(requestBucket(r)); err == nil {
+						bucketRules = &config
+					}
+				}
+				if bucketRules != nil {
+					rule := matchCORSRule(*bucketRules, r, preflight)
+					if rule != nil {
+						setCORSHeaders(w, r, *rule, preflight)
+					} else if preflight {
+						s3errors.WriteErrorResponse(w, r, s3errors.ErrCORSForbidden)
+						return
+					}
+				} else if allowed[origin] || allowed["*"] {
+					w.Header().Set("Access-Control-Allow-Origin", origin)
+					w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+					w.Header().Set("Access-Control-Allow-Headers", defaultAllowedHeaders)
+				}
+			}
+
+			// Gérer les requêtes preflight (OPTIONS)
+			if preflight {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Routes propres au serveur, qu'aucun bucket ne peut ouvrir par ses règles CORS
+func serverPath(path string) bool {
+	return path == "/_admin" || strings.HasPrefix(path, "/_admin/") || strings.HasPrefix(path, "/probe-bsign")
+}
+
+// Bucket visé par une requête : premier segment du chemin
+func requestBucket(r *http.Request) string {
+	bucket, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	return bucket
+}
+
+// Première règle autorisant l'origine, la méthode et, pour une requête preflight, les en-têtes demandés
+func matchCORSRule(config dto.CORSConfiguration, r *http.Request, preflight bool) *dto.CORSRule {
+	method := r.Method
+	var headers []string
+	if preflight {
+		method = r.Header.Get("Access-Control-Request-Method")
+		headers = requestedHeaders(r)
+	}
+
+	for i, rule := range config.Rules {
+		if matchAny(rule.AllowedOrigins, r.Header.Get("Origin"), false) &&
+			contains(rule.AllowedMethods, method) && allHeadersAllowed(rule.AllowedHeaders, headers) {
+			return &config.Rules[i]
+		}
+	}
+	return nil
+}
+
+func setCORSHeaders(w http.ResponseWriter, r *http.Request, rule dto.CORSRule, preflight bool) {
+	// Une règle ouverte à toutes les origines ne transmet pas les identifiants du navigateur
+	if contains(rule.AllowedOrigins, "*") {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	} else {
+		w.Header().Set("Access-Control-Allow-Origin", r.Header.Get("Origin"))
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+	w.Header().Set("Access-Control-Allow-Methods", strings.Join(rule.AllowedMethods, ", "))
+	if len(rule.ExposeHeaders) > 0 {
+		w.Header().Set("Access-Control-Expose-Headers", strings.Join(rule.ExposeHeaders, ", "))
+	}
+	if preflight {
+		if headers := r.Header.Get("Access-Control-Request-Headers"); headers != "" {
+			w.Header().Set("Access-Control-Allow-Headers", headers)
+		}
+		if rule.MaxAgeSeconds > 0 {
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(rule.MaxAgeSeconds))
+		}
+	}
+}
+
+// En-têtes annoncés par Access-Control-Request-Headers, en minuscules
+func requestedHeaders(r *http.Request) []string {
+	var headers []string
+	for _, header := range strings.Split(r.Header.Get("Access-Control-Request-Headers"), ",") {
+		if header = strings.TrimSpace(header); header != "" {
+			headers = append(headers, strings.ToLower(header))
+		}
+	}
+	return headers
+}
+
+func allHeadersAllowed(patterns, headers []string) bool {
+	for _, header := range headers {
+		if !matchAny(patterns, header, true) {
+			return false
+		}
+	}
+	return true
+}
+
+// Correspondance avec l'un des motifs, qui peuvent contenir un caractère générique "*"
+func matchAny(patterns []string, value string, ignoreCase bool) bool {
+	for _, pattern := range patterns {
+		if ignoreCase {
+			pattern, value = strings.ToLower(pattern), strings.ToLower(value)
+		}
+		prefix, suffix, wildcard := strings.Cut(pattern, "*")
+		if pattern == value || wildcard && len(value) >= len(prefix)+len(suffix) &&
+			strings.HasPrefix(value, prefix) && strings.HasSuffix(value, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -5,51 +5,103 @@ import (
     "strings"
     "log"
     "bytes"
+    "context"
+    "crypto/sha256"
+    "encoding/hex"
+    "io"
+    "github.com/gorilla/mux"
+    "my-s3-clone/auth"
+    "my-s3-clone/s3errors"
 )
-// CorsMiddleware permet de configurer les en-têtes CORS
-func CORSMiddleware(next http.Handler) http.Handler {
-    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-        // Autoriser les origines spécifiques
-        w.Header().Set("Access-Control-Allow-Origin", "http://localhost:3000")
-        
-        // Autoriser les méthodes HTTP
-        w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-        
-        // Autoriser les en-têtes spécifiques
-        w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Amz-Content-Sha256, X-Amz-Decoded-Content-Length")
-        
-        // Gérer les requêtes preflight (OPTIONS)
-        if r.Method == "OPTIONS" {
-            w.WriteHeader(http.StatusOK)
-            return
-        }
 
+// Routes dont le contenu est vérifié par le stockage au fil de l'écriture (PutObjectOptions.ContentSha256),
+// sans être lu en mémoire par SigV4AuthMiddleware
+const (
+    PutObjectRoute  = "PutObject"
+    UploadPartRoute = "UploadPart"
+)
+
+// Taille maximale d'un contenu lu en mémoire pour en vérifier le hash signé (configurations, suppressions groupées, admin)
+const maxSignedPayloadSize = 16 << 20
+
+// RequestIDMiddleware attribue à chaque requête un x-amz-request-id et un x-amz-id-2 uniques,
+// repris dans les documents d'erreur
+func RequestIDMiddleware(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("x-amz-request-id", s3errors.NewRequestID())
+        w.Header().Set("x-amz-id-2", s3errors.NewHostID())
         next.ServeHTTP(w, r)
     })
 }
 
-func BasicAuthMiddleware(next http.Handler) http.Handler {
-    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-        if strings.HasPrefix(r.URL.Path, "/probe-bsign") {
-            next.ServeHTTP(w, r)
-            return
-        }
+// SigV4AuthMiddleware vérifie la signature AWS Signature V4 de chaque requête
+func SigV4AuthMiddleware(verifier *auth.Verifier) func(http.Handler) http.Handler {
+    return func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            // La sonde de santé et les requêtes preflight ne sont pas signées
+            if strings.HasPrefix(r.URL.Path, "/probe-bsign") || r.Method == http.MethodOptions {
+                next.ServeHTTP(w, r)
+                return
+            }
 
-        // Accepter les requêtes avec AWS4-HMAC-SHA256 dans l'en-tête Authorization
-        if strings.Contains(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256") {
-            next.ServeHTTP(w, r)
-            return
-        }
+            // Une requête sans signature est anonyme : seule la politique du bucket visé peut l'autoriser (voir Allowed)
+            if _, presigned := r.URL.Query()["X-Amz-Algorithm"]; !presigned && r.Header.Get("Authorization") == "" {
+                next.ServeHTTP(w, r)
+                return
+            }
 
-        // Appliquer l'authentification basique pour les autres routes
-        user, pass, ok := r.BasicAuth()
-        if !ok || user != "accessuser" || pass != "accesspassword" {
-            http.Error(w, "Unauthorized", http.StatusUnauthorized)
-            return
-        }
+            cred, err := verifier.Verify(r)
+            if err != nil {
+                apiErr, ok := err.(s3errors.APIError)
+                if !ok {
+                    apiErr = s3errors.ErrAccessDenied
+                }
+                log.Printf("Authentication failed for %s %s: %v", r.Method, r.URL.Path, err)
+                s3errors.WriteErrorResponse(w, r, apiErr)
+                return
+            }
 
-        next.ServeHTTP(w, r)
-    })
+            if err := verifyPayloadHash(r); err != nil {
+                log.Printf("Payload rejected for %s %s: %v", r.Method, r.URL.Path, err)
+                s3errors.WriteErrorResponse(w, r, err.(s3errors.APIError))
+                return
+            }
+
+            ctx := context.WithValue(r.Context(), CredentialKey, cred)
+            next.ServeHTTP(w, r.WithContext(ctx))
+        })
+    }
+}
+
+// verifyPayloadHash compare le contenu au x-amz-content-sha256 signé, puis le restitue au handler :
+// sans cette vérification, le corps d'une requête interceptée pourrait être remplacé sans invalider la signature
+func verifyPayloadHash(r *http.Request) error {
+    payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+    if payloadHash == "" || payloadHash == auth.UnsignedPayload || strings.HasPrefix(payloadHash, auth.StreamingPrefix) {
+        return nil
+    }
+    if route := mux.CurrentRoute(r); route != nil && (route.GetName() == PutObjectRoute || route.GetName() == UploadPartRoute) {
+        return nil
+    }
+
+    var body []byte
+    if r.Body != nil {
+        var err error
+        body, err = io.ReadAll(io.LimitReader(r.Body, maxSignedPayloadSize+1))
+        if err != nil {
+            return s3errors.ErrIncompleteBody
+        }
+        if len(body) > maxSignedPayloadSize {
+            return s3errors.ErrMaxMessageLengthExceeded
+        }
+        r.Body.Close()
+    }
+    computed := sha256.Sum256(body)
+    if !strings.EqualFold(hex.EncodeToString(computed[:]), payloadHash) {
+        return s3errors.ErrContentSHA256Mismatch
+    }
+    r.Body = io.NopCloser(bytes.NewReader(body))
+    return nil
 }
 
 func LogRequestMiddleware(next http.Handler) http.Handler {
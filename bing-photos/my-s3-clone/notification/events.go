@@ -0,0 +1,133 @@
+package notification
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/s3errors"
+)
+
+// Événements publiés, au format des notifications S3
+const (
+	ObjectCreatedPut                     = "s3:ObjectCreated:Put"
+	ObjectCreatedCopy                    = "s3:ObjectCreated:Copy"
+	ObjectCreatedCompleteMultipartUpload = "s3:ObjectCreated:CompleteMultipartUpload"
+	ObjectRemovedDelete                  = "s3:ObjectRemoved:Delete"
+	ObjectRemovedDeleteMarkerCreated     = "s3:ObjectRemoved:DeleteMarkerCreated"
+)
+
+// Événements qu'un webhook peut demander, dont les familles complètes
+var configurableEvents = map[string]bool{
+	"s3:ObjectCreated:*":                 true,
+	ObjectCreatedPut:                     true,
+	ObjectCreatedCopy:                    true,
+	ObjectCreatedCompleteMultipartUpload: true,
+	"s3:ObjectRemoved:*":                 true,
+	ObjectRemovedDelete:                  true,
+	ObjectRemovedDeleteMarkerCreated:     true,
+}
+
+// ValidateConfiguration vérifie chaque webhook d'une configuration : identifiant unique, URL http(s),
+// au moins un événement connu et au plus une règle prefix et une règle suffix
+func ValidateConfiguration(config dto.NotificationConfiguration) error {
+	ids := make(map[string]bool)
+	for _, webhook := range config.Webhooks {
+		if len(webhook.ID) > 255 || (webhook.ID != "" && ids[webhook.ID]) {
+			return fmt.Errorf("%w: webhook ID %q must be unique and at most 255 characters", s3errors.ErrInvalidNotification, webhook.ID)
+		}
+		ids[webhook.ID] = true
+
+		target, err := url.Parse(webhook.URL)
+		if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+			return fmt.Errorf("%w: webhook URL %q must be an absolute http or https URL", s3errors.ErrInvalidNotification, webhook.URL)
+		}
+		if len(webhook.Events) == 0 {
+			return fmt.Errorf("%w: webhook %q has no event", s3errors.ErrInvalidNotification, webhook.URL)
+		}
+		for _, event := range webhook.Events {
+			if !configurableEvents[event] {
+				return fmt.Errorf("%w: unsupported event %q", s3errors.ErrInvalidNotification, event)
+			}
+		}
+		if webhook.Filter != nil {
+			names := make(map[string]bool)
+			for _, rule := range webhook.Filter.Key.Rules {
+				name := strings.ToLower(rule.Name)
+				if (name != "prefix" && name != "suffix") || names[name] {
+					return fmt.Errorf("%w: filter rules must be one prefix and one suffix at most, got %q", s3errors.ErrInvalidNotification, rule.Name)
+				}
+				if len(rule.Value) > 1024 {
+					return fmt.Errorf("%w: filter value is longer than 1024 characters", s3errors.ErrInvalidNotification)
+				}
+				names[name] = true
+			}
+		}
+	}
+	return nil
+}
+
+// Un webhook reçoit un événement s'il l'a demandé, lui ou sa famille ("s3:ObjectCreated:*"), et si la clé passe son filtre
+func webhookMatches(webhook dto.WebhookConfiguration, eventName, key string) bool {
+	requested := false
+	for _, event := range webhook.Events {
+		if event == eventName || (strings.HasSuffix(event, ":*") && strings.HasPrefix(eventName, strings.TrimSuffix(event, "*"))) {
+			requested = true
+			break
+		}
+	}
+	if !requested {
+		return false
+	}
+	if webhook.Filter == nil {
+		return true
+	}
+	for _, rule := range webhook.Filter.Key.Rules {
+		switch strings.ToLower(rule.Name) {
+		case "prefix":
+			if !strings.HasPrefix(key, rule.Value) {
+				return false
+			}
+		case "suffix":
+			if !strings.HasSuffix(key, rule.Value) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Enregistrement S3 d'un événement pour un webhook
+func newRecord(webhook dto.WebhookConfiguration, eventName string, bucket dto.BucketInfo, key string, info dto.ObjectInfo, t time.Time) dto.EventRecord {
+	return dto.EventRecord{
+		EventVersion: "2.1",
+		EventSource:  "aws:s3",
+		AWSRegion:    bucket.Region,
+		EventTime:    t.UTC().Format("2006-01-02T15:04:05.000Z"),
+		EventName:    strings.TrimPrefix(eventName, "s3:"),
+		S3: dto.EventS3{
+			SchemaVersion:   "1.0",
+			ConfigurationID: webhook.ID,
+			Bucket: dto.EventBucket{
+				Name:          bucket.Name,
+				OwnerIdentity: dto.EventIdentity{PrincipalID: bucket.Owner},
+				ARN:           "arn:aws:s3:::" + bucket.Name,
+			},
+			Object: dto.EventObject{
+				Key:       encodeKey(key),
+				Size:      info.Size,
+				ETag:      strings.Trim(info.ETag, `"`),
+				VersionID: info.VersionID,
+				Sequencer: strings.ToUpper(strconv.FormatInt(t.UnixNano(), 16)),
+			},
+		},
+	}
+}
+
+// Les clés sont encodées comme dans une query string, en gardant les "/" lisibles
+func encodeKey(key string) string {
+	return strings.ReplaceAll(url.QueryEscape(key), "%2F", "/")
+}
@@ -0,0 +1,285 @@
+package notification
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/storage"
+)
+
+// Délai maximal entre deux tentatives d'envoi d'un événement
+const maxRetryDelay = time.Hour
+
+// Attente de la boucle d'envoi quand aucun événement n'est en attente
+const idleWait = time.Minute
+
+// delivery est un événement en attente d'envoi à un webhook, persisté dans la file jusqu'à son acceptation
+type delivery struct {
+	ID          string          `json:"id"`
+	URL         string          `json:"url"`
+	Payload     json.RawMessage `json:"payload"`
+	Attempts    int             `json:"attempts"`
+	NextAttempt time.Time       `json:"nextAttempt"`
+	LastError   string          `json:"lastError,omitempty"`
+}
+
+// Notifier envoie aux webhooks des buckets les événements de leurs objets. Chaque événement est d'abord
+// écrit dans la file d'attente, puis envoyé par Run et réessayé avec un délai croissant tant que le webhook
+// ne répond pas par un statut 2xx. Après MaxAttempts échecs, il est déplacé dans le sous-répertoire "failed".
+// Les événements d'une même clé peuvent arriver dans le désordre après un échec : le champ sequencer les ordonne.
+type Notifier struct {
+	Client      *http.Client
+	RetryDelay  time.Duration // délai avant la deuxième tentative, doublé à chaque échec
+	MaxAttempts int
+
+	s   storage.Storage // configuration, région et propriétaire des buckets
+	dir string          // vide : la file n'est gardée qu'en mémoire
+
+	mu      sync.Mutex
+	pending []*delivery // dans l'ordre de publication
+	seq     int
+	wake    chan struct{}
+}
+
+// NewNotifier crée un Notifier dont la file d'attente n'est gardée qu'en mémoire
+func NewNotifier(s storage.Storage) *Notifier {
+	return &Notifier{
+		Client:      &http.Client{Timeout: 10 * time.Second},
+		RetryDelay:  5 * time.Second,
+		MaxAttempts: 10,
+		s:           s,
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// OpenNotifier reprend les événements restés dans la file d'attente de dir, créé au premier événement
+func OpenNotifier(dir string, s storage.Storage) (*Notifier, error) {
+	n := NewNotifier(s)
+	n.dir = dir
+
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return n, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notification queue: %v", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read queued event: %v", err)
+		}
+		var d delivery
+		if err := json.Unmarshal(data, &d); err != nil {
+			return nil, fmt.Errorf("invalid queued event %s: %v", entry.Name(), err)
+		}
+		n.pending = append(n.pending, &d)
+	}
+	sort.Slice(n.pending, func(i, j int) bool { return n.pending[i].ID < n.pending[j].ID })
+	if len(n.pending) > 0 {
+		log.Printf("%d queued event(s) to deliver", len(n.pending))
+	}
+	return n, nil
+}
+
+// Publish met en file d'attente eventName sur la clé key pour chaque webhook du bucket qui l'a demandé
+func (n *Notifier) Publish(eventName, bucketName, key string, info dto.ObjectInfo) {
+	config, err := n.s.GetBucketNotification(bucketName)
+	if err != nil || len(config.Webhooks) == 0 {
+		return
+	}
+	var bucket *dto.BucketInfo
+	now := time.Now()
+	for _, webhook := range config.Webhooks {
+		if !webhookMatches(webhook, eventName, key) {
+			continue
+		}
+		if bucket == nil {
+			bucketInfo, err := n.s.GetBucketInfo(bucketName)
+			if err != nil {
+				log.Printf("Error reading bucket %s for event %s: %v", bucketName, eventName, err)
+				return
+			}
+			bucket = &bucketInfo
+		}
+		payload, err := json.Marshal(dto.EventRecords{Records: []dto.EventRecord{newRecord(webhook, eventName, *bucket, key, info, now)}})
+		if err != nil {
+			log.Printf("Error encoding event %s on %s/%s: %v", eventName, bucketName, key, err)
+			continue
+		}
+		n.enqueue(webhook.URL, payload, now)
+	}
+}
+
+func (n *Notifier) enqueue(url string, payload []byte, now time.Time) {
+	n.mu.Lock()
+	n.seq++
+	d := &delivery{ID: fmt.Sprintf("%d-%06d", now.UnixNano(), n.seq%1000000), URL: url, Payload: payload, NextAttempt: now}
+	if err := n.save(d); err != nil {
+		// L'événement sera tout de même envoyé, mais perdu si le serveur s'arrête avant
+		log.Printf("Error queuing event for %s: %v", url, err)
+	}
+	n.pending = append(n.pending, d)
+	n.mu.Unlock()
+
+	select {
+	case n.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run envoie les événements en attente dès leur publication et réessaie ceux qui ont échoué. Ne rend pas la main.
+func (n *Notifier) Run() {
+	for {
+		wait := n.deliverDue(time.Now())
+		timer := time.NewTimer(wait)
+		select {
+		case <-n.wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// Envoi des événements dont la tentative est due, dans l'ordre de publication.
+// Renvoie l'attente jusqu'à la prochaine tentative.
+func (n *Notifier) deliverDue(now time.Time) time.Duration {
+	n.mu.Lock()
+	var due []*delivery
+	for _, d := range n.pending {
+		if !d.NextAttempt.After(now) {
+			due = append(due, d)
+		}
+	}
+	n.mu.Unlock()
+
+	for _, d := range due {
+		err := n.send(d)
+
+		n.mu.Lock()
+		if err == nil {
+			n.remove(d)
+		} else {
+			d.Attempts++
+			d.LastError = err.Error()
+			if d.Attempts >= n.MaxAttempts {
+				log.Printf("Giving up event %s for %s after %d attempts: %v", d.ID, d.URL, d.Attempts, err)
+				n.fail(d)
+			} else {
+				delay := n.RetryDelay << (d.Attempts - 1)
+				if delay > maxRetryDelay || delay <= 0 {
+					delay = maxRetryDelay
+				}
+				d.NextAttempt = time.Now().Add(delay)
+				log.Printf("Error delivering event %s to %s (attempt %d, next in %v): %v", d.ID, d.URL, d.Attempts, delay, err)
+				if err := n.save(d); err != nil {
+					log.Printf("Error saving queued event %s: %v", d.ID, err)
+				}
+			}
+		}
+		n.mu.Unlock()
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	wait := idleWait
+	for _, d := range n.pending {
+		if until := time.Until(d.NextAttempt); until < wait {
+			wait = until
+		}
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	return wait
+}
+
+// Un événement est accepté par un statut 2xx
+func (n *Notifier) send(d *delivery) error {
+	req, err := http.NewRequest(http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "my-s3-clone")
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
+
+// Retrait d'un événement envoyé, à appeler avec mu pris
+func (n *Notifier) remove(d *delivery) {
+	for i, pending := range n.pending {
+		if pending == d {
+			n.pending = append(n.pending[:i], n.pending[i+1:]...)
+			break
+		}
+	}
+	if n.dir != "" {
+		if err := os.Remove(n.path(d)); err != nil && !os.IsNotExist(err) {
+			log.Printf("Error removing delivered event %s: %v", d.ID, err)
+		}
+	}
+}
+
+// Abandon d'un événement, gardé dans "failed" pour examen, à appeler avec mu pris
+func (n *Notifier) fail(d *delivery) {
+	n.remove(d)
+	if n.dir == "" {
+		return
+	}
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err == nil {
+		failedDir := filepath.Join(n.dir, "failed")
+		if err = os.MkdirAll(failedDir, 0o755); err == nil {
+			err = os.WriteFile(filepath.Join(failedDir, d.ID+".json"), data, 0o644)
+		}
+	}
+	if err != nil {
+		log.Printf("Error keeping failed event %s: %v", d.ID, err)
+	}
+}
+
+// Écriture d'un événement dans la file, à côté puis renommé pour ne jamais être lu à moitié écrit.
+// À appeler avec mu pris.
+func (n *Notifier) save(d *delivery) error {
+	if n.dir == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(n.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create notification queue: %v", err)
+	}
+	if err := os.WriteFile(n.path(d)+".tmp", data, 0o644); err != nil {
+		return fmt.Errorf("failed to write queued event: %v", err)
+	}
+	return os.Rename(n.path(d)+".tmp", n.path(d))
+}
+
+func (n *Notifier) path(d *delivery) string {
+	return filepath.Join(n.dir, d.ID+".json")
+}
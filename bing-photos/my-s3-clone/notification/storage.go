@@ -0,0 +1,73 @@
+package notification
+
+import (
+	"io"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/storage"
+)
+
+// eventStorage publie un événement après chaque création ou suppression d'objet réussie
+// du storage qu'il enveloppe, quelle que soit la route qui l'a demandée
+type eventStorage struct {
+	storage.Storage
+	notifier *Notifier
+}
+
+// Wrap renvoie s, dont les créations et suppressions d'objets sont publiées par n
+func Wrap(s storage.Storage, n *Notifier) storage.Storage {
+	return &eventStorage{Storage: s, notifier: n}
+}
+
+func (s *eventStorage) AddObject(bucketName, objectName string, data io.Reader, opts storage.PutObjectOptions) (dto.ObjectInfo, error) {
+	info, err := s.Storage.AddObject(bucketName, objectName, data, opts)
+	if err == nil {
+		s.notifier.Publish(ObjectCreatedPut, bucketName, objectName, info)
+	}
+	return info, err
+}
+
+func (s *eventStorage) CopyObject(sourceBucket, sourceKey, targetBucket, targetKey string, opts storage.CopyObjectOptions) (dto.ObjectInfo, error) {
+	info, err := s.Storage.CopyObject(sourceBucket, sourceKey, targetBucket, targetKey, opts)
+	if err == nil {
+		s.notifier.Publish(ObjectCreatedCopy, targetBucket, targetKey, info)
+	}
+	return info, err
+}
+
+func (s *eventStorage) CompleteMultipartUpload(bucketName, objectName, uploadID string, parts []dto.CompletedPart) (dto.ObjectInfo, error) {
+	info, err := s.Storage.CompleteMultipartUpload(bucketName, objectName, uploadID, parts)
+	if err == nil {
+		s.notifier.Publish(ObjectCreatedCompleteMultipartUpload, bucketName, objectName, info)
+	}
+	return info, err
+}
+
+// Supprimer une clé sans versionId dans un bucket versionné ne fait qu'ajouter un marqueur de suppression
+func (s *eventStorage) DeleteObject(bucketName, objectName string, opts storage.DeleteObjectOptions) (dto.ObjectInfo, error) {
+	info, err := s.Storage.DeleteObject(bucketName, objectName, opts)
+	if err == nil {
+		event := ObjectRemovedDelete
+		if info.IsDeleteMarker && opts.VersionID == "" {
+			event = ObjectRemovedDeleteMarkerCreated
+		}
+		s.notifier.Publish(event, bucketName, objectName, info)
+	}
+	return info, err
+}
+
+// Un déplacement crée la cible comme une copie et supprime la source, remplacée par un marqueur
+// de suppression si son bucket est versionné
+func (s *eventStorage) MoveObject(sourceBucket, sourceKey, targetBucket, targetKey string) (dto.ObjectInfo, error) {
+	info, err := s.Storage.MoveObject(sourceBucket, sourceKey, targetBucket, targetKey)
+	if err != nil {
+		return info, err
+	}
+	s.notifier.Publish(ObjectCreatedCopy, targetBucket, targetKey, info)
+	removed := ObjectRemovedDelete
+	if status, err := s.Storage.GetBucketVersioning(sourceBucket); err == nil && status != "" {
+		removed = ObjectRemovedDeleteMarkerCreated
+	}
+	s.notifier.Publish(removed, sourceBucket, sourceKey, dto.ObjectInfo{Key: sourceKey})
+	return info, err
+}
@@ -2,25 +2,72 @@ package router
 
 import (
     "github.com/gorilla/mux"
+    "log"
+    "my-s3-clone/auth"
+    "my-s3-clone/config"
     "my-s3-clone/handlers"
     "my-s3-clone/middleware"
+    "my-s3-clone/notification"
     "my-s3-clone/storage"
     "net/http"
 )
 
-// SetupRouter sets up the router with default storage
-func SetupRouter() *mux.Router {
-    // Use default storage (e.g., FileStorage)
-    return SetupRouterWithStorage(&storage.FileStorage{})
+// SetupRouter sets up the router on a FileStorage rooted at cfg.DataDir,
+// with the credentials, CORS origins and log level of cfg
+func SetupRouter(cfg config.Config) *mux.Router {
+    masterKey, err := storage.ParseMasterKey(cfg.MasterKey)
+    if err != nil {
+        log.Fatalf("Invalid master key: %v", err)
+    }
+    return SetupRouterWithConfig(&storage.FileStorage{Root: cfg.DataDir, MasterKey: masterKey}, cfg)
 }
 
 // SetupRouterWithStorage allows injecting custom storage (e.g., mock storage for tests)
-func SetupRouterWithStorage(s storage.Storage) *mux.Router {
+// and the credential store used to verify request signatures, with the default configuration.
+// Access keys created through the admin API and pending webhook events are only kept in memory.
+func SetupRouterWithStorage(s storage.Storage, creds auth.CredentialStore) *mux.Router {
+    return newRouter(s, auth.NewKeyStore(creds), notification.NewNotifier(s), config.Default())
+}
+
+// SetupRouterWithConfig serves s with the credentials, access keys, CORS origins and log level of cfg,
+// queuing webhook events in the data directory
+func SetupRouterWithConfig(s storage.Storage, cfg config.Config) *mux.Router {
+    notifier, err := notification.OpenNotifier(cfg.NotificationQueueDir(), s)
+    if err != nil {
+        log.Fatalf("Invalid notification queue: %v", err)
+    }
+    return SetupRouterWithNotifier(s, notifier, cfg)
+}
+
+// SetupRouterWithNotifier is SetupRouterWithConfig with a notifier opened by the caller,
+// which can also publish the removals made outside the router (lifecycle expirations)
+func SetupRouterWithNotifier(s storage.Storage, notifier *notification.Notifier, cfg config.Config) *mux.Router {
+    keys, err := auth.OpenKeyStore(cfg.KeysPath(), cfg.Credentials)
+    if err != nil {
+        log.Fatalf("Invalid access keys: %v", err)
+    }
+    return newRouter(s, keys, notifier, cfg)
+}
+
+// newRouter serves s to the configured credentials, which have every right, and to the access keys
+// managed through the admin API, limited by their policy. Every route is wrapped with the action it performs.
+// Object creations and removals are published to the bucket webhooks by notifier, started here.
+func newRouter(s storage.Storage, keys *auth.KeyStore, notifier *notification.Notifier, cfg config.Config) *mux.Router {
+    go notifier.Run()
+    s = notification.Wrap(s, notifier)
+
     r := mux.NewRouter()
+    r.MethodNotAllowedHandler = handlers.HandleMethodNotAllowed()
 
-    r.Use(middleware.CORSMiddleware)
-    r.Use(middleware.LogRequestMiddleware)
-    r.Use(middleware.LogResponseMiddleware)
+    r.Use(middleware.RequestIDMiddleware)
+    r.Use(middleware.CORSMiddleware(cfg.AllowedOrigins, s.GetBucketCors))
+    if cfg.LogLevel == config.LogLevelDebug {
+        r.Use(middleware.LogRequestMiddleware)
+        r.Use(middleware.LogResponseMiddleware)
+    }
+    r.Use(middleware.SigV4AuthMiddleware(auth.NewVerifier(keys)))
+    r.Use(middleware.AuthorizationMiddleware(keys, s.GetBucketPolicy))
+    allow := middleware.Allow
 
     // Health check route
     r.HandleFunc("/probe-bsign{suffix:.*}", func(w http.ResponseWriter, r *http.Request) {
@@ -29,27 +76,90 @@ func SetupRouterWithStorage(s storage.Storage) *mux.Router {
         w.Write([]byte("<Response></Response>"))
     }).Methods("GET", "HEAD")
 
-    // Batch delete route
+    // Admin routes: usage, quotas and access keys. "_admin" is not a valid bucket name, so they shadow no bucket
+    r.HandleFunc("/_admin/usage", allow(auth.ActionGetUsage, handlers.HandleListUsage(s))).Methods("GET")
+    r.HandleFunc("/_admin/usage/{bucketName}", allow(auth.ActionGetUsage, handlers.HandleGetBucketUsage(s))).Methods("GET")
+    r.HandleFunc("/_admin/quota/{bucketName}", allow(auth.ActionManageQuotas, handlers.HandleGetBucketQuota(s))).Methods("GET")
+    r.HandleFunc("/_admin/quota/{bucketName}", allow(auth.ActionManageQuotas, handlers.HandlePutBucketQuota(s))).Methods("PUT")
+    r.HandleFunc("/_admin/quota/{bucketName}", allow(auth.ActionManageQuotas, handlers.HandleDeleteBucketQuota(s))).Methods("DELETE")
+
+    r.HandleFunc("/_admin/keys", allow(auth.ActionManageKeys, handlers.HandleListAccessKeys(keys))).Methods("GET")
+    r.HandleFunc("/_admin/keys", allow(auth.ActionManageKeys, handlers.HandleCreateAccessKey(keys))).Methods("POST")
+    r.HandleFunc("/_admin/keys/{accessKey}", allow(auth.ActionManageKeys, handlers.HandleGetAccessKey(keys))).Methods("GET")
+    r.HandleFunc("/_admin/keys/{accessKey}", allow(auth.ActionManageKeys, handlers.HandleDeleteAccessKey(keys))).Methods("DELETE")
+    r.HandleFunc("/_admin/keys/{accessKey}/policy", allow(auth.ActionManageKeys, handlers.HandlePutAccessKeyPolicy(keys))).Methods("PUT")
+
+    // Batch delete route: the handler checks the rights on each key
     r.HandleFunc("/{bucketName}/", handlers.HandleDeleteObject(s)).Queries("delete", "").Methods("POST", "OPTIONS")
 
+    // Object keys may contain slashes ("2024/trip/img.jpg"): the key pattern matches the rest of the path
+
+    // Multipart upload routes
+    r.HandleFunc("/{bucketName}/", allow(auth.ActionListBucketMultipartUploads, handlers.HandleListMultipartUploads(s))).Queries("uploads", "").Methods("GET", "OPTIONS")
+    r.HandleFunc("/{bucketName}/{objectName:.+}", allow(auth.ActionPutObject, handlers.HandleCreateMultipartUpload(s))).Queries("uploads", "").Methods("POST", "OPTIONS")
+    r.HandleFunc("/{bucketName}/{objectName:.+}", allow(auth.ActionPutObject, handlers.HandleUploadPart(s))).Queries("partNumber", "{partNumber}", "uploadId", "{uploadId}").Methods("PUT", "OPTIONS").Name(middleware.UploadPartRoute)
+    r.HandleFunc("/{bucketName}/{objectName:.+}", allow(auth.ActionListMultipartUploadParts, handlers.HandleListParts(s))).Queries("uploadId", "{uploadId}").Methods("GET", "OPTIONS")
+    r.HandleFunc("/{bucketName}/{objectName:.+}", allow(auth.ActionPutObject, handlers.HandleCompleteMultipartUpload(s))).Queries("uploadId", "{uploadId}").Methods("POST", "OPTIONS")
+    r.HandleFunc("/{bucketName}/{objectName:.+}", allow(auth.ActionAbortMultipartUpload, handlers.HandleAbortMultipartUpload(s))).Queries("uploadId", "{uploadId}").Methods("DELETE", "OPTIONS")
+
+    // Versioning routes, registered before the generic bucket routes that would shadow them
+    r.HandleFunc("/{bucketName}/", allow(auth.ActionGetBucketVersioning, handlers.HandleGetBucketVersioning(s))).Queries("versioning", "").Methods("GET", "OPTIONS")
+    r.HandleFunc("/{bucketName}/", allow(auth.ActionPutBucketVersioning, handlers.HandlePutBucketVersioning(s))).Queries("versioning", "").Methods("PUT", "OPTIONS")
+    r.HandleFunc("/{bucketName}/", allow(auth.ActionListBucketVersions, handlers.HandleListObjectVersions(s))).Queries("versions", "").Methods("GET", "OPTIONS")
+
+    // Lifecycle routes
+    r.HandleFunc("/{bucketName}/", allow(auth.ActionGetLifecycleConfiguration, handlers.HandleGetBucketLifecycle(s))).Queries("lifecycle", "").Methods("GET", "OPTIONS")
+    r.HandleFunc("/{bucketName}/", allow(auth.ActionPutLifecycleConfiguration, handlers.HandlePutBucketLifecycle(s))).Queries("lifecycle", "").Methods("PUT", "OPTIONS")
+    r.HandleFunc("/{bucketName}/", allow(auth.ActionPutLifecycleConfiguration, handlers.HandleDeleteBucketLifecycle(s))).Queries("lifecycle", "").Methods("DELETE", "OPTIONS")
+
+    // CORS routes
+    r.HandleFunc("/{bucketName}/", allow(auth.ActionGetBucketCORS, handlers.HandleGetBucketCors(s))).Queries("cors", "").Methods("GET", "OPTIONS")
+    r.HandleFunc("/{bucketName}/", allow(auth.ActionPutBucketCORS, handlers.HandlePutBucketCors(s))).Queries("cors", "").Methods("PUT", "OPTIONS")
+    r.HandleFunc("/{bucketName}/", allow(auth.ActionPutBucketCORS, handlers.HandleDeleteBucketCors(s))).Queries("cors", "").Methods("DELETE", "OPTIONS")
+
+    // Bucket policy routes: the policy decides what anonymous requests may do
+    r.HandleFunc("/{bucketName}/", allow(auth.ActionGetBucketPolicy, handlers.HandleGetBucketPolicy(s))).Queries("policy", "").Methods("GET", "OPTIONS")
+    r.HandleFunc("/{bucketName}/", allow(auth.ActionPutBucketPolicy, handlers.HandlePutBucketPolicy(s))).Queries("policy", "").Methods("PUT", "OPTIONS")
+    r.HandleFunc("/{bucketName}/", allow(auth.ActionDeleteBucketPolicy, handlers.HandleDeleteBucketPolicy(s))).Queries("policy", "").Methods("DELETE", "OPTIONS")
+
+    // Notification routes
+    r.HandleFunc("/{bucketName}/", allow(auth.ActionGetBucketNotification, handlers.HandleGetBucketNotification(s))).Queries("notification", "").Methods("GET", "OPTIONS")
+    r.HandleFunc("/{bucketName}/", allow(auth.ActionPutBucketNotification, handlers.HandlePutBucketNotification(s))).Queries("notification", "").Methods("PUT", "OPTIONS")
+
+    // Object Lock routes
+    r.HandleFunc("/{bucketName}/", allow(auth.ActionGetBucketObjectLockConfiguration, handlers.HandleGetObjectLockConfig(s))).Queries("object-lock", "").Methods("GET", "OPTIONS")
+    r.HandleFunc("/{bucketName}/", allow(auth.ActionPutBucketObjectLockConfiguration, handlers.HandlePutObjectLockConfig(s))).Queries("object-lock", "").Methods("PUT", "OPTIONS")
+    r.HandleFunc("/{bucketName}/{objectName:.+}", allow(auth.ActionGetObjectRetention, handlers.HandleGetObjectRetention(s))).Queries("retention", "").Methods("GET", "OPTIONS")
+    r.HandleFunc("/{bucketName}/{objectName:.+}", allow(auth.ActionPutObjectRetention, handlers.HandlePutObjectRetention(s))).Queries("retention", "").Methods("PUT", "OPTIONS")
+    r.HandleFunc("/{bucketName}/{objectName:.+}", allow(auth.ActionGetObjectLegalHold, handlers.HandleGetObjectLegalHold(s))).Queries("legal-hold", "").Methods("GET", "OPTIONS")
+    r.HandleFunc("/{bucketName}/{objectName:.+}", allow(auth.ActionPutObjectLegalHold, handlers.HandlePutObjectLegalHold(s))).Queries("legal-hold", "").Methods("PUT", "OPTIONS")
+
+    // Object tagging routes
+    r.HandleFunc("/{bucketName}/{objectName:.+}", allow(auth.ActionGetObjectTagging, handlers.HandleGetObjectTagging(s))).Queries("tagging", "").Methods("GET", "OPTIONS")
+    r.HandleFunc("/{bucketName}/{objectName:.+}", allow(auth.ActionPutObjectTagging, handlers.HandlePutObjectTagging(s))).Queries("tagging", "").Methods("PUT", "OPTIONS")
+    r.HandleFunc("/{bucketName}/{objectName:.+}", allow(auth.ActionDeleteObjectTagging, handlers.HandleDeleteObjectTagging(s))).Queries("tagging", "").Methods("DELETE", "OPTIONS")
+
+    // Bucket location, registered before the object listing that would shadow it
+    r.HandleFunc("/{bucketName}/", allow(auth.ActionGetBucketLocation, handlers.HandleBucketLocation(s))).Queries("location", "").Methods("GET", "OPTIONS")
+
     // Object-specific routes
-    r.HandleFunc("/{bucketName}/{objectName}", handlers.HandleAddObject(s)).Methods("PUT", "OPTIONS")
-    r.HandleFunc("/{bucketName}/{objectName}", handlers.HandleCheckObjectExist(s)).Methods("HEAD", "OPTIONS")
-    r.HandleFunc("/{bucketName}/{objectName}", handlers.HandleDownloadObject(s)).Methods("GET","OPTIONS")
-    r.HandleFunc("/{bucketName}/", handlers.HandleListObjects(s)).Methods("GET", "HEAD", "OPTIONS")
-    r.HandleFunc("/{bucketName}/", handlers.HandleBucketLocation(s)).Queries("location", "").Methods("GET", "OPTIONS")
-    r.HandleFunc("/{bucketName}/", handlers.HandleBucketLockConfig(s)).Queries("object-lock", "").Methods("GET", "OPTIONS")
-    r.HandleFunc("/{bucketName}/", handlers.HandleBucketDelimiter(s)).Queries("delimiter", "").Methods("GET","OPTIONS")
+    r.HandleFunc("/{bucketName}/{objectName:.+}", allow(auth.ActionPutObject, handlers.HandleCopyObject(s))).Headers("X-Amz-Copy-Source", "").Methods("PUT")
+    r.HandleFunc("/{bucketName}/{objectName:.+}", allow(auth.ActionPutObject, handlers.HandleAddObject(s))).Methods("PUT", "OPTIONS").Name(middleware.PutObjectRoute)
+    r.HandleFunc("/{bucketName}/{objectName:.+}", allow(auth.ActionGetObject, handlers.HandleCheckObjectExist(s))).Methods("HEAD", "OPTIONS")
+    r.HandleFunc("/{bucketName}/{objectName:.+}", allow(auth.ActionGetObject, handlers.HandleDownloadObject(s))).Methods("GET","OPTIONS")
+    r.HandleFunc("/{bucketName}/{objectName:.+}", allow(auth.ActionDeleteObject, handlers.HandleDeleteSingleObject(s))).Methods("DELETE", "OPTIONS")
+    r.HandleFunc("/{bucketName}/", allow(auth.ActionListBucket, handlers.HandleListObjects(s))).Methods("GET", "HEAD", "OPTIONS")
+    // The move handler checks the rights on each source and target key
     r.HandleFunc("/{bucketName}/", handlers.HandleMoveObject(s)).Queries("move", "").Methods("POST", "OPTIONS")
     
 
     // Bucket-specific routes
-    r.HandleFunc("/{bucketName}/", handlers.HandleGetBucket(s)).Methods("GET", "OPTIONS")
-    r.HandleFunc("/{bucketName}/", handlers.HandleCreateBucket(s)).Methods("PUT", "OPTIONS")
-    r.HandleFunc("/{bucketName}/", handlers.HandleDeleteBucket(s)).Methods("DELETE", "OPTIONS")
+    r.HandleFunc("/{bucketName}/", allow(auth.ActionListBucket, handlers.HandleGetBucket(s))).Methods("GET", "OPTIONS")
+    r.HandleFunc("/{bucketName}/", allow(auth.ActionCreateBucket, handlers.HandleCreateBucket(s))).Methods("PUT", "OPTIONS")
+    r.HandleFunc("/{bucketName}/", allow(auth.ActionDeleteBucket, handlers.HandleDeleteBucket(s))).Methods("DELETE", "OPTIONS")
 
     // Route for listing all buckets
-    r.HandleFunc("/", handlers.HandleListBuckets(s)).Methods("GET", "HEAD", "OPTIONS")
+    r.HandleFunc("/", allow(auth.ActionListAllMyBuckets, handlers.HandleListBuckets(s))).Methods("GET", "HEAD", "OPTIONS")
 
     return r
 }
@@ -0,0 +1,464 @@
+package s3errors
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"log"
+	"net/http"
+	"strings"
+
+	"my-s3-clone/dto"
+)
+
+// APIError décrit une erreur S3 : son code, son message et le statut HTTP associé
+type APIError struct {
+	Code           string
+	Description    string
+	HTTPStatusCode int
+}
+
+func (e APIError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+// Erreurs S3 renvoyées par le serveur
+var (
+	ErrAccessDenied = APIError{
+		Code:           "AccessDenied",
+		Description:    "Access Denied.",
+		HTTPStatusCode: http.StatusForbidden,
+	}
+	ErrAuthorizationHeaderMalformed = APIError{
+		Code:           "AuthorizationHeaderMalformed",
+		Description:    "The authorization header is malformed.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrSignatureVersionNotSupported = APIError{
+		Code:           "InvalidRequest",
+		Description:    "The authorization mechanism you have provided is not supported. Please use AWS4-HMAC-SHA256.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrMissingDateHeader = APIError{
+		Code:           "AccessDenied",
+		Description:    "AWS authentication requires a valid Date or x-amz-date header.",
+		HTTPStatusCode: http.StatusForbidden,
+	}
+	ErrAuthorizationQueryParametersError = APIError{
+		Code:           "AuthorizationQueryParametersError",
+		Description:    "Query-string authentication version 4 requires the X-Amz-Algorithm, X-Amz-Credential, X-Amz-Signature, X-Amz-Date, X-Amz-SignedHeaders, and X-Amz-Expires parameters.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrInvalidPresignExpires = APIError{
+		Code:           "AuthorizationQueryParametersError",
+		Description:    "X-Amz-Expires must be between 1 and 604800 seconds.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrExpiredPresignRequest = APIError{
+		Code:           "AccessDenied",
+		Description:    "Request has expired.",
+		HTTPStatusCode: http.StatusForbidden,
+	}
+	ErrRequestNotReadyYet = APIError{
+		Code:           "AccessDenied",
+		Description:    "Request is not valid yet.",
+		HTTPStatusCode: http.StatusForbidden,
+	}
+	ErrMultipleAuthMechanisms = APIError{
+		Code:           "InvalidArgument",
+		Description:    "Only one auth mechanism allowed; only the X-Amz-Algorithm query parameter or the Authorization header should be specified.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrInvalidAccessKeyID = APIError{
+		Code:           "InvalidAccessKeyId",
+		Description:    "The AWS Access Key Id you provided does not exist in our records.",
+		HTTPStatusCode: http.StatusForbidden,
+	}
+	ErrSignatureDoesNotMatch = APIError{
+		Code:           "SignatureDoesNotMatch",
+		Description:    "The request signature we calculated does not match the signature you provided. Check your key and signing method.",
+		HTTPStatusCode: http.StatusForbidden,
+	}
+	ErrIncompleteBody = APIError{
+		Code:           "IncompleteBody",
+		Description:    "You did not provide the number of bytes specified by the Content-Length HTTP header.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrMissingContentLength = APIError{
+		Code:           "MissingContentLength",
+		Description:    "You must provide the Content-Length HTTP header.",
+		HTTPStatusCode: http.StatusLengthRequired,
+	}
+	ErrNoSuchBucket = APIError{
+		Code:           "NoSuchBucket",
+		Description:    "The specified bucket does not exist.",
+		HTTPStatusCode: http.StatusNotFound,
+	}
+	ErrBucketAlreadyOwnedByYou = APIError{
+		Code:           "BucketAlreadyOwnedByYou",
+		Description:    "Your previous request to create the named bucket succeeded and you already own it.",
+		HTTPStatusCode: http.StatusConflict,
+	}
+	ErrBucketNotEmpty = APIError{
+		Code:           "BucketNotEmpty",
+		Description:    "The bucket you tried to delete is not empty.",
+		HTTPStatusCode: http.StatusConflict,
+	}
+	ErrInvalidLocationConstraint = APIError{
+		Code:           "InvalidLocationConstraint",
+		Description:    "The specified location constraint is not valid.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrInvalidBucketName = APIError{
+		Code:           "InvalidBucketName",
+		Description:    "The specified bucket is not valid.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrInvalidObjectName = APIError{
+		Code:           "InvalidObjectName",
+		Description:    "Object name contains unsupported path segments or characters.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrKeyTooLong = APIError{
+		Code:           "KeyTooLongError",
+		Description:    "Your key is too long.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrObjectPathConflict = APIError{
+		Code:           "InvalidObjectName",
+		Description:    "Object name conflicts with an existing object or prefix of other objects.",
+		HTTPStatusCode: http.StatusConflict,
+	}
+	ErrNoSuchKey = APIError{
+		Code:           "NoSuchKey",
+		Description:    "The specified key does not exist.",
+		HTTPStatusCode: http.StatusNotFound,
+	}
+	ErrNoSuchVersion = APIError{
+		Code:           "NoSuchVersion",
+		Description:    "The specified version does not exist.",
+		HTTPStatusCode: http.StatusNotFound,
+	}
+	ErrInvalidVersionID = APIError{
+		Code:           "InvalidArgument",
+		Description:    "Invalid version id specified.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrIllegalVersioningConfiguration = APIError{
+		Code:           "IllegalVersioningConfigurationException",
+		Description:    "The versioning configuration specified in the request is invalid.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrNoSuchLifecycleConfiguration = APIError{
+		Code:           "NoSuchLifecycleConfiguration",
+		Description:    "The lifecycle configuration does not exist.",
+		HTTPStatusCode: http.StatusNotFound,
+	}
+	ErrInvalidLifecycle = APIError{
+		Code:           "InvalidArgument",
+		Description:    "The lifecycle configuration is invalid.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrNoSuchCORSConfiguration = APIError{
+		Code:           "NoSuchCORSConfiguration",
+		Description:    "The CORS configuration does not exist.",
+		HTTPStatusCode: http.StatusNotFound,
+	}
+	ErrInvalidCORS = APIError{
+		Code:           "InvalidArgument",
+		Description:    "The CORS configuration is invalid.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrInvalidTag = APIError{
+		Code:           "InvalidTag",
+		Description:    "The tag provided was not a valid tag.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrInvalidTaggingDirective = APIError{
+		Code:           "InvalidArgument",
+		Description:    "Unknown tagging directive.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrQuotaExceeded = APIError{
+		Code:           "QuotaExceeded",
+		Description:    "The bucket quota does not allow this write.",
+		HTTPStatusCode: http.StatusForbidden,
+	}
+	ErrInvalidQuota = APIError{
+		Code:           "InvalidArgument",
+		Description:    "The bucket quota is invalid.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrMalformedPolicy = APIError{
+		Code:           "MalformedPolicy",
+		Description:    "The policy is not valid.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrInvalidNotification = APIError{
+		Code:           "InvalidArgument",
+		Description:    "The notification configuration is invalid.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrNoSuchBucketPolicy = APIError{
+		Code:           "NoSuchBucketPolicy",
+		Description:    "The bucket policy does not exist.",
+		HTTPStatusCode: http.StatusNotFound,
+	}
+	ErrNoSuchAccessKey = APIError{
+		Code:           "NoSuchAccessKey",
+		Description:    "The specified access key does not exist.",
+		HTTPStatusCode: http.StatusNotFound,
+	}
+	ErrCORSForbidden = APIError{
+		Code:           "AccessForbidden",
+		Description:    "CORSResponse: This CORS request is not allowed. This is usually because the evaluation of Origin, request method / Access-Control-Request-Method or Access-Control-Request-Headers are not whitelisted by the resource's CORS spec.",
+		HTTPStatusCode: http.StatusForbidden,
+	}
+	ErrObjectLocked = APIError{
+		Code:           "AccessDenied",
+		Description:    "Access Denied because object protected by object lock.",
+		HTTPStatusCode: http.StatusForbidden,
+	}
+	ErrObjectLockNotEnabled = APIError{
+		Code:           "InvalidRequest",
+		Description:    "Bucket is missing Object Lock Configuration.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrObjectLockConfigurationNotFound = APIError{
+		Code:           "ObjectLockConfigurationNotFoundError",
+		Description:    "Object Lock configuration does not exist for this bucket.",
+		HTTPStatusCode: http.StatusNotFound,
+	}
+	ErrNoSuchObjectLockConfiguration = APIError{
+		Code:           "NoSuchObjectLockConfiguration",
+		Description:    "The specified object does not have a ObjectLock configuration.",
+		HTTPStatusCode: http.StatusNotFound,
+	}
+	ErrInvalidObjectLock = APIError{
+		Code:           "InvalidArgument",
+		Description:    "The Object Lock configuration or retention is invalid.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrInvalidBucketState = APIError{
+		Code:           "InvalidBucketState",
+		Description:    "The request is not valid with the current state of the bucket.",
+		HTTPStatusCode: http.StatusConflict,
+	}
+	ErrSSENotConfigured = APIError{
+		Code:           "InvalidRequest",
+		Description:    "Server-side encryption with AES256 is not configured on this server.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrSSECustomerKeyRequired = APIError{
+		Code:           "InvalidRequest",
+		Description:    "The object was stored using a form of Server Side Encryption. The correct parameters must be provided to retrieve the object.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrSSECustomerKeyMismatch = APIError{
+		Code:           "AccessDenied",
+		Description:    "The provided encryption key does not match the key used to encrypt the object.",
+		HTTPStatusCode: http.StatusForbidden,
+	}
+	ErrInvalidEncryptionAlgorithm = APIError{
+		Code:           "InvalidEncryptionAlgorithmError",
+		Description:    "The encryption request you specified is not valid. The valid value is AES256.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrInvalidSSECustomerKey = APIError{
+		Code:           "InvalidArgument",
+		Description:    "The secret key was invalid for the specified algorithm.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrSSECustomerKeyMD5Mismatch = APIError{
+		Code:           "InvalidArgument",
+		Description:    "The calculated MD5 hash of the key did not match the hash that was provided.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrIncompatibleEncryption = APIError{
+		Code:           "InvalidArgument",
+		Description:    "Server side encryption specified with both SSE-C and SSE-S3 headers.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrNoSuchUpload = APIError{
+		Code:           "NoSuchUpload",
+		Description:    "The specified multipart upload does not exist. The upload ID may be invalid, or the upload may have been aborted or completed.",
+		HTTPStatusCode: http.StatusNotFound,
+	}
+	ErrInvalidPart = APIError{
+		Code:           "InvalidPart",
+		Description:    "One or more of the specified parts could not be found. The part may not have been uploaded, or the specified entity tag may not match the part's entity tag.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrInvalidPartOrder = APIError{
+		Code:           "InvalidPartOrder",
+		Description:    "The list of parts was not in ascending order. The parts list must be specified in order by part number.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrEntityTooSmall = APIError{
+		Code:           "EntityTooSmall",
+		Description:    "Your proposed upload is smaller than the minimum allowed object size.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrInvalidPartNumber = APIError{
+		Code:           "InvalidArgument",
+		Description:    "Part number must be an integer between 1 and 10000, inclusive.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrInvalidMaxParts = APIError{
+		Code:           "InvalidArgument",
+		Description:    "Argument max-parts must be an integer between 0 and 2147483647.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrInvalidMaxUploads = APIError{
+		Code:           "InvalidArgument",
+		Description:    "Argument max-uploads must be an integer between 0 and 2147483647.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrInvalidMaxKeys = APIError{
+		Code:           "InvalidArgument",
+		Description:    "Argument max-keys must be an integer between 0 and 2147483647.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrInvalidContinuationToken = APIError{
+		Code:           "InvalidArgument",
+		Description:    "The continuation token provided is incorrect.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrInvalidEncodingType = APIError{
+		Code:           "InvalidArgument",
+		Description:    "Invalid Encoding Method specified in Request.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrPreconditionFailed = APIError{
+		Code:           "PreconditionFailed",
+		Description:    "At least one of the pre-conditions you specified did not hold.",
+		HTTPStatusCode: http.StatusPreconditionFailed,
+	}
+	ErrNotImplemented = APIError{
+		Code:           "NotImplemented",
+		Description:    "A header you provided implies functionality that is not implemented.",
+		HTTPStatusCode: http.StatusNotImplemented,
+	}
+	ErrInvalidRange = APIError{
+		Code:           "InvalidRange",
+		Description:    "The requested range is not satisfiable.",
+		HTTPStatusCode: http.StatusRequestedRangeNotSatisfiable,
+	}
+	ErrBadDigest = APIError{
+		Code:           "BadDigest",
+		Description:    "The Content-MD5 you specified did not match what we received.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrInvalidDigest = APIError{
+		Code:           "InvalidDigest",
+		Description:    "The Content-MD5 you specified is not valid.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrContentSHA256Mismatch = APIError{
+		Code:           "XAmzContentSHA256Mismatch",
+		Description:    "The provided 'x-amz-content-sha256' header does not match what was computed.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrInvalidContentSHA256 = APIError{
+		Code:           "InvalidArgument",
+		Description:    "x-amz-content-sha256 must be UNSIGNED-PAYLOAD, STREAMING-AWS4-HMAC-SHA256-PAYLOAD, or a valid sha256 value.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrMaxMessageLengthExceeded = APIError{
+		Code:           "MaxMessageLengthExceeded",
+		Description:    "Your request was too big.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrMetadataTooLarge = APIError{
+		Code:           "MetadataTooLarge",
+		Description:    "Your metadata headers exceed the maximum allowed metadata size.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrInvalidMetadataDirective = APIError{
+		Code:           "InvalidArgument",
+		Description:    "Unknown metadata directive.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrInvalidCopySource = APIError{
+		Code:           "InvalidArgument",
+		Description:    "Copy Source must mention the source bucket and key: sourcebucket/sourcekey.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrInvalidCopyDest = APIError{
+		Code:           "InvalidRequest",
+		Description:    "This copy request is illegal because it is trying to copy an object to itself without changing the object's metadata.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrMissingTargetBucket = APIError{
+		Code:           "InvalidArgument",
+		Description:    "TargetBucket is missing in the request.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrMalformedXML = APIError{
+		Code:           "MalformedXML",
+		Description:    "The XML you provided was not well-formed or did not validate against our published schema.",
+		HTTPStatusCode: http.StatusBadRequest,
+	}
+	ErrMethodNotAllowed = APIError{
+		Code:           "MethodNotAllowed",
+		Description:    "The specified method is not allowed against this resource.",
+		HTTPStatusCode: http.StatusMethodNotAllowed,
+	}
+	ErrInternalError = APIError{
+		Code:           "InternalError",
+		Description:    "We encountered an internal error, please try again.",
+		HTTPStatusCode: http.StatusInternalServerError,
+	}
+	ErrRequestTimeTooSkewed = APIError{
+		Code:           "RequestTimeTooSkewed",
+		Description:    "The difference between the request time and the server's time is too large.",
+		HTTPStatusCode: http.StatusForbidden,
+	}
+)
+
+// NewRequestID génère un identifiant de requête au format S3 (16 caractères hexadécimaux)
+func NewRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		log.Printf("Failed to generate request id: %v", err)
+	}
+	return strings.ToUpper(hex.EncodeToString(b))
+}
+
+// NewHostID génère un identifiant étendu (x-amz-id-2) pour le support
+func NewHostID() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		log.Printf("Failed to generate host id: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// WriteErrorResponse écrit un document <Error> S3 pour la ressource demandée
+func WriteErrorResponse(w http.ResponseWriter, r *http.Request, apiErr APIError) {
+	requestID := w.Header().Get("x-amz-request-id")
+	if requestID == "" {
+		requestID = NewRequestID()
+	}
+
+	response := dto.ErrorResponse{
+		Code:      apiErr.Code,
+		Message:   apiErr.Description,
+		Resource:  r.URL.Path,
+		RequestId: requestID,
+		HostId:    w.Header().Get("x-amz-id-2"),
+	}
+
+	body, err := xml.Marshal(response)
+	if err != nil {
+		log.Printf("Error generating XML error response: %v", err)
+		http.Error(w, apiErr.Description, apiErr.HTTPStatusCode)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(apiErr.HTTPStatusCode)
+	w.Write([]byte(xml.Header))
+	w.Write(body)
+}
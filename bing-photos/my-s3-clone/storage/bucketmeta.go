@@ -0,0 +1,201 @@
+package storage
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"my-s3-clone/dto"
+)
+
+// Région des buckets créés sans LocationConstraint
+const DefaultRegion = "us-east-1"
+
+const bucketMetadataFile = "bucket.json"
+
+// Fichiers de configuration écrits avant l'enregistrement unique des buckets, repris à leur première lecture
+const (
+	legacyVersioningFile = "versioning.json"
+	legacyObjectLockFile = "objectlock.json"
+	legacyLifecycleFile  = "lifecycle.json"
+	legacyCORSFile       = "cors.json"
+)
+
+// Nom de région : lettres minuscules, chiffres et tirets, comme "eu-west-3"
+var regionPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// CreateBucketOptions décrit un nouveau bucket
+type CreateBucketOptions struct {
+	Owner  string // access key du créateur, vide sans authentification
+	Region string // LocationConstraint demandée, DefaultRegion si vide
+}
+
+// bucketMetadata est l'enregistrement persisté d'un bucket : sa création et toute sa configuration.
+// MemoryStorage garde le même enregistrement en mémoire.
+type bucketMetadata struct {
+	CreationDate time.Time                      `json:"creationDate"`
+	Owner        string                         `json:"owner,omitempty"`
+	Region       string                         `json:"region"`
+	Versioning   string                         `json:"versioning,omitempty"`
+	ObjectLock   *dto.ObjectLockConfiguration   `json:"objectLock,omitempty"`
+	Lifecycle    *dto.LifecycleConfiguration    `json:"lifecycle,omitempty"`
+	CORS         *dto.CORSConfiguration         `json:"cors,omitempty"`
+	Quota        *dto.BucketQuota               `json:"quota,omitempty"`
+	Policy       *dto.BucketPolicy              `json:"policy,omitempty"`
+	Notification *dto.NotificationConfiguration `json:"notification,omitempty"`
+}
+
+// Enregistrement d'un bucket créé maintenant
+func newBucketMetadata(opts CreateBucketOptions) (bucketMetadata, error) {
+	region := opts.Region
+	if region == "" {
+		region = DefaultRegion
+	}
+	if len(region) > 32 || !regionPattern.MatchString(region) {
+		return bucketMetadata{}, fmt.Errorf("%w: %q", ErrInvalidRegion, opts.Region)
+	}
+	return bucketMetadata{CreationDate: time.Now().UTC(), Owner: opts.Owner, Region: region}, nil
+}
+
+func (meta bucketMetadata) info(bucketName string) dto.BucketInfo {
+	return dto.BucketInfo{
+		Name:         bucketName,
+		CreationDate: meta.CreationDate,
+		Owner:        meta.Owner,
+		Region:       meta.Region,
+	}
+}
+
+func (fs *FileStorage) bucketMetadataPath(bucketName string) string {
+	return filepath.Join(fs.bucketConfigDir(bucketName), bucketMetadataFile)
+}
+
+// Lecture de la date de création, du propriétaire et de la région d'un bucket
+func (fs *FileStorage) GetBucketInfo(bucketName string) (dto.BucketInfo, error) {
+	meta, err := fs.existingBucketMetadata(bucketName)
+	if err != nil {
+		return dto.BucketInfo{}, err
+	}
+	return meta.info(bucketName), nil
+}
+
+func (fs *FileStorage) existingBucketMetadata(bucketName string) (bucketMetadata, error) {
+	exists, err := fs.CheckBucketExists(bucketName)
+	if err != nil {
+		return bucketMetadata{}, err
+	}
+	if !exists {
+		return bucketMetadata{}, ErrNoSuchBucket
+	}
+	return fs.bucketMetadata(bucketName)
+}
+
+// Lecture de l'enregistrement d'un bucket existant
+func (fs *FileStorage) bucketMetadata(bucketName string) (bucketMetadata, error) {
+	fs.bucketMu.Lock()
+	defer fs.bucketMu.Unlock()
+	return fs.loadBucketMetadata(bucketName)
+}
+
+// Modification de l'enregistrement d'un bucket : update n'est appliqué qu'à une copie,
+// enregistrée seulement s'il réussit
+func (fs *FileStorage) updateBucketMetadata(bucketName string, update func(*bucketMetadata) error) error {
+	exists, err := fs.CheckBucketExists(bucketName)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNoSuchBucket
+	}
+
+	fs.bucketMu.Lock()
+	defer fs.bucketMu.Unlock()
+	meta, err := fs.loadBucketMetadata(bucketName)
+	if err != nil {
+		return err
+	}
+	if err := update(&meta); err != nil {
+		return err
+	}
+	return fs.saveBucketMetadata(bucketName, meta)
+}
+
+// Lecture de l'enregistrement, à appeler avec bucketMu pris. Un bucket créé avant l'enregistrement est migré :
+// sa date de création est celle de son répertoire et sa configuration est reprise des anciens fichiers.
+func (fs *FileStorage) loadBucketMetadata(bucketName string) (bucketMetadata, error) {
+	var meta bucketMetadata
+	err := readJSONFile(fs.bucketMetadataPath(bucketName), &meta)
+	if err == nil {
+		return meta, nil
+	}
+	if !os.IsNotExist(err) {
+		return meta, fmt.Errorf("failed to read metadata of bucket %s: %v", bucketName, err)
+	}
+
+	if meta, err = fs.legacyBucketMetadata(bucketName); err != nil {
+		return meta, err
+	}
+	if err := fs.saveBucketMetadata(bucketName, meta); err != nil {
+		return meta, err
+	}
+	log.Printf("Metadata of bucket %s migrated, creation date %s", bucketName, meta.CreationDate.Format(time.RFC3339))
+	return meta, nil
+}
+
+func (fs *FileStorage) legacyBucketMetadata(bucketName string) (bucketMetadata, error) {
+	bucketPath, err := fs.resolveBucketPath(bucketName)
+	if err != nil {
+		return bucketMetadata{}, err
+	}
+	stat, err := os.Stat(bucketPath)
+	if os.IsNotExist(err) {
+		return bucketMetadata{}, ErrNoSuchBucket
+	}
+	if err != nil {
+		return bucketMetadata{}, err
+	}
+
+	meta := bucketMetadata{CreationDate: stat.ModTime().UTC(), Region: DefaultRegion}
+	var versioning struct {
+		Status string `json:"status"`
+	}
+	for file, config := range map[string]interface{}{
+		legacyVersioningFile: &versioning,
+		legacyObjectLockFile: &meta.ObjectLock,
+		legacyLifecycleFile:  &meta.Lifecycle,
+		legacyCORSFile:       &meta.CORS,
+	} {
+		err := readJSONFile(filepath.Join(fs.bucketConfigDir(bucketName), file), config)
+		if err != nil && !os.IsNotExist(err) {
+			return meta, fmt.Errorf("failed to read %s of bucket %s: %v", file, bucketName, err)
+		}
+	}
+	meta.Versioning = versioning.Status
+	return meta, nil
+}
+
+// Écriture de l'enregistrement, à appeler avec bucketMu pris. Il est écrit à côté puis renommé
+// pour ne jamais être lu à moitié écrit ; les anciens fichiers de configuration sont ensuite supprimés.
+func (fs *FileStorage) saveBucketMetadata(bucketName string, meta bucketMetadata) error {
+	dir := fs.bucketConfigDir(bucketName)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create bucket configuration directory: %v", err)
+	}
+	path := fs.bucketMetadataPath(bucketName)
+	if err := writeJSONFile(path+".tmp", meta); err != nil {
+		return err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("failed to save metadata of bucket %s: %v", bucketName, err)
+	}
+
+	for _, file := range []string{legacyVersioningFile, legacyObjectLockFile, legacyLifecycleFile, legacyCORSFile} {
+		if err := os.Remove(filepath.Join(dir, file)); err != nil && !os.IsNotExist(err) {
+			log.Printf("Failed to remove %s of bucket %s: %v", file, bucketName, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"log"
+
+	"my-s3-clone/dto"
+)
+
+// Lecture de la politique d'un bucket, ErrNoSuchBucketPolicy s'il n'en a pas.
+// La politique est validée par l'appelant (auth.ValidateBucketPolicy) avant son enregistrement.
+func (fs *FileStorage) GetBucketPolicy(bucketName string) (dto.BucketPolicy, error) {
+	exists, err := fs.CheckBucketExists(bucketName)
+	if err != nil {
+		return dto.BucketPolicy{}, err
+	}
+	if !exists {
+		return dto.BucketPolicy{}, ErrNoSuchBucket
+	}
+	meta, err := fs.bucketMetadata(bucketName)
+	if err != nil {
+		return dto.BucketPolicy{}, err
+	}
+	if meta.Policy == nil {
+		return dto.BucketPolicy{}, ErrNoSuchBucketPolicy
+	}
+	return *meta.Policy, nil
+}
+
+// Remplacement de la politique d'un bucket
+func (fs *FileStorage) PutBucketPolicy(bucketName string, policy dto.BucketPolicy) error {
+	err := fs.updateBucketMetadata(bucketName, func(meta *bucketMetadata) error {
+		meta.Policy = &policy
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("Policy of bucket %s set (%d statements)", bucketName, len(policy.Statements))
+	return nil
+}
+
+// Suppression de la politique d'un bucket : il n'est plus accessible anonymement
+func (fs *FileStorage) DeleteBucketPolicy(bucketName string) error {
+	return fs.updateBucketMetadata(bucketName, func(meta *bucketMetadata) error {
+		meta.Policy = nil
+		return nil
+	})
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"my-s3-clone/dto"
+)
+
+// Nombre maximal de règles CORS par bucket, comme sur S3
+const maxCORSRules = 100
+
+// Méthodes qu'une règle CORS peut autoriser
+var corsMethods = map[string]bool{"GET": true, "PUT": true, "POST": true, "DELETE": true, "HEAD": true}
+
+// Lecture des règles CORS d'un bucket, ErrNoSuchCORS s'il n'en a pas
+func (fs *FileStorage) GetBucketCors(bucketName string) (dto.CORSConfiguration, error) {
+	exists, err := fs.CheckBucketExists(bucketName)
+	if err != nil {
+		return dto.CORSConfiguration{}, err
+	}
+	if !exists {
+		return dto.CORSConfiguration{}, ErrNoSuchBucket
+	}
+	meta, err := fs.bucketMetadata(bucketName)
+	if err != nil {
+		return dto.CORSConfiguration{}, err
+	}
+	if meta.CORS == nil {
+		return dto.CORSConfiguration{}, ErrNoSuchCORS
+	}
+	return *meta.CORS, nil
+}
+
+// Remplacement des règles CORS d'un bucket
+func (fs *FileStorage) PutBucketCors(bucketName string, config dto.CORSConfiguration) error {
+	if err := validateCORS(config); err != nil {
+		return err
+	}
+	err := fs.updateBucketMetadata(bucketName, func(meta *bucketMetadata) error {
+		meta.CORS = &config
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("CORS configuration of bucket %s set (%d rules)", bucketName, len(config.Rules))
+	return nil
+}
+
+// Suppression des règles CORS d'un bucket
+func (fs *FileStorage) DeleteBucketCors(bucketName string) error {
+	return fs.updateBucketMetadata(bucketName, func(meta *bucketMetadata) error {
+		meta.CORS = nil
+		return nil
+	})
+}
+
+// Vérification d'une configuration avant son enregistrement : chaque règle autorise au moins une origine
+// et une méthode connue, avec au plus un caractère générique par origine ou en-tête
+func validateCORS(config dto.CORSConfiguration) error {
+	if len(config.Rules) == 0 || len(config.Rules) > maxCORSRules {
+		return fmt.Errorf("%w: between 1 and %d rules are required", ErrInvalidCORS, maxCORSRules)
+	}
+
+	for _, rule := range config.Rules {
+		if len(rule.ID) > 255 {
+			return fmt.Errorf("%w: rule ID is longer than 255 characters", ErrInvalidCORS)
+		}
+		if len(rule.AllowedOrigins) == 0 || len(rule.AllowedMethods) == 0 {
+			return fmt.Errorf("%w: rule must allow at least one origin and one method", ErrInvalidCORS)
+		}
+		for _, method := range rule.AllowedMethods {
+			if !corsMethods[method] {
+				return fmt.Errorf("%w: unsupported method %q", ErrInvalidCORS, method)
+			}
+		}
+		for _, patterns := range [][]string{rule.AllowedOrigins, rule.AllowedHeaders} {
+			for _, pattern := range patterns {
+				if pattern == "" || strings.Count(pattern, "*") > 1 {
+					return fmt.Errorf("%w: %q must be non-empty with at most one wildcard", ErrInvalidCORS, pattern)
+				}
+			}
+		}
+		if rule.MaxAgeSeconds < 0 {
+			return fmt.Errorf("%w: MaxAgeSeconds must not be negative", ErrInvalidCORS)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,335 @@
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Chiffrement côté serveur : chaque version chiffrée a sa propre clé de données AES-256, elle-même chiffrée
+// par la clé maître du serveur (SSE-S3) ou par la clé fournie par le client (SSE-C). Le contenu est découpé
+// en blocs de sseChunkSize octets scellés séparément avec AES-GCM, ce qui permet de lire une plage
+// sans déchiffrer tout l'objet.
+const (
+	SSEAlgorithm    = "AES256"
+	SSEModeS3       = "SSE-S3"
+	SSEModeCustomer = "SSE-C"
+
+	sseKeySize         = 32
+	sseChunkSize       = 64 * 1024
+	sseTagSize         = 16
+	sseNoncePrefixSize = 8
+)
+
+// SSEOptions décrit le chiffrement demandé pour écrire un objet
+type SSEOptions struct {
+	S3          bool   // x-amz-server-side-encryption: AES256
+	CustomerKey []byte // SSE-C : clé AES-256 fournie par le client, nil sinon
+}
+
+// objectEncryption est l'état de chiffrement persisté d'une version
+type objectEncryption struct {
+	Mode           string             `json:"mode"`
+	WrappedKey     []byte             `json:"wrappedKey"` // clé de données chiffrée par la clé maître ou la clé du client
+	CustomerKeyMD5 string             `json:"customerKeyMD5,omitempty"`
+	Segments       []encryptedSegment `json:"segments,omitempty"`
+}
+
+// encryptedSegment est une suite de blocs chiffrés : tout l'objet pour un upload simple, une part pour un upload
+// multipart. Le nonce d'un bloc est le préfixe du segment suivi du numéro du bloc dans le segment.
+type encryptedSegment struct {
+	NoncePrefix []byte `json:"noncePrefix"`
+	Size        int64  `json:"size"` // taille en clair
+}
+
+func (s encryptedSegment) chunks() int64 {
+	return (s.Size + sseChunkSize - 1) / sseChunkSize
+}
+
+func (s encryptedSegment) storedSize() int64 {
+	return s.Size + s.chunks()*sseTagSize
+}
+
+// Taille en clair d'une version chiffrée
+func (e *objectEncryption) size() int64 {
+	var size int64
+	for _, segment := range e.Segments {
+		size += segment.Size
+	}
+	return size
+}
+
+// Taille sur le disque d'une version chiffrée
+func (e *objectEncryption) storedSize() int64 {
+	var size int64
+	for _, segment := range e.Segments {
+		size += segment.storedSize()
+	}
+	return size
+}
+
+// ParseMasterKey décode la clé maître SSE-S3 (32 octets encodés en base64, S3_MASTER_KEY).
+// Sans clé maître, les objets sont stockés en clair sauf en SSE-C, et les requêtes SSE-S3 sont refusées.
+func ParseMasterKey(value string) ([]byte, error) {
+	if value == "" {
+		return nil, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(key) != sseKeySize {
+		return nil, fmt.Errorf("master key must be %d bytes encoded in base64", sseKeySize)
+	}
+	return key, nil
+}
+
+// CustomerKeyMD5 est l'empreinte d'une clé SSE-C, encodée en base64 comme dans x-amz-server-side-encryption-customer-key-MD5
+func CustomerKeyMD5(key []byte) string {
+	sum := md5.Sum(key)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// CheckCustomerKey vérifie qu'une version chiffrée en SSE-C (keyMD5 non vide) est lue avec sa clé
+func CheckCustomerKey(keyMD5 string, customerKey []byte) error {
+	switch {
+	case keyMD5 == "":
+		return nil
+	case customerKey == nil:
+		return ErrSSECustomerKeyNeeded
+	case CustomerKeyMD5(customerKey) != keyMD5:
+		return ErrSSECustomerKeyMismatch
+	}
+	return nil
+}
+
+// Chiffrement d'une nouvelle version : SSE-C si le client fournit une clé, SSE-S3 s'il le demande ou par défaut
+// quand une clé maître est configurée, aucun sinon. Renvoie aussi la clé de données en clair.
+func (fs *FileStorage) newObjectEncryption(opts SSEOptions) (*objectEncryption, []byte, error) {
+	var wrappingKey []byte
+	encryption := &objectEncryption{}
+	switch {
+	case opts.CustomerKey != nil:
+		if len(opts.CustomerKey) != sseKeySize {
+			return nil, nil, ErrInvalidSSECustomerKey
+		}
+		encryption.Mode, encryption.CustomerKeyMD5 = SSEModeCustomer, CustomerKeyMD5(opts.CustomerKey)
+		wrappingKey = opts.CustomerKey
+	case fs.MasterKey != nil:
+		encryption.Mode, wrappingKey = SSEModeS3, fs.MasterKey
+	case opts.S3:
+		return nil, nil, ErrSSENotConfigured
+	default:
+		return nil, nil, nil
+	}
+
+	dataKey := make([]byte, sseKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate data key: %v", err)
+	}
+	wrapped, err := sealKey(wrappingKey, dataKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	encryption.WrappedKey = wrapped
+	return encryption, dataKey, nil
+}
+
+// Clé de données d'une version chiffrée. Une version SSE-C ne peut être lue qu'avec la clé qui l'a chiffrée.
+func (fs *FileStorage) dataKey(encryption *objectEncryption, customerKey []byte) ([]byte, error) {
+	wrappingKey := fs.MasterKey
+	if encryption.Mode == SSEModeCustomer {
+		if err := CheckCustomerKey(encryption.CustomerKeyMD5, customerKey); err != nil {
+			return nil, err
+		}
+		wrappingKey = customerKey
+	} else if wrappingKey == nil {
+		return nil, ErrSSENotConfigured
+	}
+
+	aead, err := newAEAD(wrappingKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(encryption.WrappedKey) < aead.NonceSize() {
+		return nil, errors.New("wrapped data key is truncated")
+	}
+	nonce, sealed := encryption.WrappedKey[:aead.NonceSize()], encryption.WrappedKey[aead.NonceSize():]
+	dataKey, err := aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %v", err)
+	}
+	return dataKey, nil
+}
+
+// Chiffrement d'une clé de données : nonce aléatoire suivi de la clé scellée
+func sealKey(wrappingKey, dataKey []byte) ([]byte, error) {
+	aead, err := newAEAD(wrappingKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %v", err)
+	}
+	return aead.Seal(nonce, nonce, dataKey, nil), nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(prefix []byte, index int64) []byte {
+	nonce := make([]byte, sseNoncePrefixSize+4)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[sseNoncePrefixSize:], uint32(index))
+	return nonce
+}
+
+// Écriture d'un segment dans dst : write reçoit un writer qui chiffre à la volée avec dataKey,
+// ou dst lui-même si dataKey est nil (objet en clair)
+func writeSegment(dst io.Writer, dataKey []byte, write func(io.Writer) error) (encryptedSegment, error) {
+	if dataKey == nil {
+		return encryptedSegment{}, write(dst)
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return encryptedSegment{}, err
+	}
+	writer := &segmentWriter{dst: dst, aead: aead, buf: make([]byte, 0, sseChunkSize)}
+	writer.segment.NoncePrefix = make([]byte, sseNoncePrefixSize)
+	if _, err := rand.Read(writer.segment.NoncePrefix); err != nil {
+		return encryptedSegment{}, fmt.Errorf("failed to generate nonce: %v", err)
+	}
+
+	if err := write(writer); err != nil {
+		return encryptedSegment{}, err
+	}
+	// Le dernier bloc, incomplet, est scellé à la fin de l'écriture
+	if len(writer.buf) > 0 {
+		if err := writer.flush(); err != nil {
+			return encryptedSegment{}, err
+		}
+	}
+	return writer.segment, nil
+}
+
+// segmentWriter découpe le flux en blocs de sseChunkSize octets et les écrit scellés
+type segmentWriter struct {
+	dst     io.Writer
+	aead    cipher.AEAD
+	buf     []byte
+	segment encryptedSegment
+}
+
+func (w *segmentWriter) Write(p []byte) (int, error) {
+	written := len(p)
+	for len(p) > 0 {
+		n := copy(w.buf[len(w.buf):cap(w.buf)], p)
+		w.buf, p = w.buf[:len(w.buf)+n], p[n:]
+		if len(w.buf) == sseChunkSize {
+			if err := w.flush(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return written, nil
+}
+
+func (w *segmentWriter) flush() error {
+	sealed := w.aead.Seal(nil, chunkNonce(w.segment.NoncePrefix, w.segment.chunks()), w.buf, nil)
+	if _, err := w.dst.Write(sealed); err != nil {
+		return err
+	}
+	w.segment.Size += int64(len(w.buf))
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// decryptingReader lit une version chiffrée : seul le bloc contenant la position courante est déchiffré,
+// Seek permet donc de servir une plage
+type decryptingReader struct {
+	file     *os.File
+	aead     cipher.AEAD
+	segments []encryptedSegment
+	size     int64
+	offset   int64
+
+	chunk      []byte // bloc déchiffré courant
+	chunkStart int64  // position de chunk[0] dans le contenu en clair
+}
+
+func newDecryptingReader(file *os.File, dataKey []byte, encryption *objectEncryption) (*decryptingReader, error) {
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptingReader{file: file, aead: aead, segments: encryption.Segments, size: encryption.size()}, nil
+}
+
+func (r *decryptingReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	if r.chunk == nil || r.offset < r.chunkStart || r.offset >= r.chunkStart+int64(len(r.chunk)) {
+		if err := r.loadChunk(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.chunk[r.offset-r.chunkStart:])
+	r.offset += int64(n)
+	return n, nil
+}
+
+// Déchiffrement du bloc qui contient la position courante
+func (r *decryptingReader) loadChunk() error {
+	var plainStart, storedStart int64
+	for _, segment := range r.segments {
+		if r.offset >= plainStart+segment.Size {
+			plainStart += segment.Size
+			storedStart += segment.storedSize()
+			continue
+		}
+
+		index := (r.offset - plainStart) / sseChunkSize
+		length := min(sseChunkSize, segment.Size-index*sseChunkSize)
+		sealed := make([]byte, length+sseTagSize)
+		if _, err := r.file.ReadAt(sealed, storedStart+index*(sseChunkSize+sseTagSize)); err != nil {
+			return fmt.Errorf("failed to read encrypted chunk: %v", err)
+		}
+		chunk, err := r.aead.Open(sealed[:0], chunkNonce(segment.NoncePrefix, index), sealed, nil)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt chunk: %v", err)
+		}
+		r.chunk, r.chunkStart = chunk, plainStart+index*sseChunkSize
+		return nil
+	}
+	return io.ErrUnexpectedEOF
+}
+
+func (r *decryptingReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+func (r *decryptingReader) Close() error {
+	return r.file.Close()
+}
@@ -0,0 +1,47 @@
+package storage
+
+import "errors"
+
+// Erreurs renvoyées par les implémentations de Storage
+var (
+	ErrNoSuchBucket           = errors.New("bucket does not exist")
+	ErrBucketAlreadyExists    = errors.New("bucket already exists")
+	ErrBucketNotEmpty         = errors.New("bucket is not empty")
+	ErrInvalidRegion          = errors.New("invalid bucket location constraint")
+	ErrQuotaExceeded          = errors.New("bucket quota exceeded")
+	ErrInvalidQuota           = errors.New("invalid bucket quota")
+	ErrChunkSignatureMismatch = errors.New("chunk signature does not match")
+	ErrIncompleteBody         = errors.New("decoded content length does not match the received data")
+	ErrMalformedChunk         = errors.New("malformed chunked payload")
+	ErrNoSuchUpload           = errors.New("multipart upload does not exist")
+	ErrInvalidPartNumber      = errors.New("part number must be between 1 and 10000")
+	ErrInvalidPart            = errors.New("part not found or ETag mismatch")
+	ErrInvalidPartOrder       = errors.New("parts must be listed in ascending order")
+	ErrEntityTooSmall         = errors.New("part is smaller than the minimum allowed size")
+	ErrBadDigest              = errors.New("Content-MD5 does not match the received data")
+	ErrContentSHA256Mismatch  = errors.New("x-amz-content-sha256 does not match the received data")
+	ErrInvalidBucketName      = errors.New("invalid bucket name")
+	ErrInvalidObjectName      = errors.New("invalid object name")
+	ErrKeyTooLong             = errors.New("object name is longer than 1024 bytes")
+	ErrObjectPathConflict     = errors.New("object name conflicts with an existing object or prefix")
+	ErrNoSuchVersion          = errors.New("object version does not exist")
+	ErrInvalidVersionID       = errors.New("invalid version id")
+	ErrDeleteMarker           = errors.New("object version is a delete marker")
+	ErrInvalidVersioning      = errors.New("versioning status must be Enabled or Suspended")
+	ErrNoSuchLifecycle        = errors.New("bucket has no lifecycle configuration")
+	ErrInvalidLifecycle       = errors.New("invalid lifecycle configuration")
+	ErrNoSuchCORS             = errors.New("bucket has no CORS configuration")
+	ErrInvalidCORS            = errors.New("invalid CORS configuration")
+	ErrNoSuchBucketPolicy     = errors.New("bucket has no policy")
+	ErrInvalidTag             = errors.New("invalid object tags")
+	ErrPreconditionFailed     = errors.New("precondition does not hold for the object")
+	ErrInvalidMove            = errors.New("an object cannot be moved onto itself")
+	ErrObjectLocked           = errors.New("object version is protected by object lock")
+	ErrObjectLockNotEnabled   = errors.New("bucket is missing object lock configuration")
+	ErrInvalidObjectLock      = errors.New("invalid object lock configuration or retention")
+	ErrInvalidBucketState     = errors.New("request is not valid with the current state of the bucket")
+	ErrSSENotConfigured       = errors.New("server-side encryption with a master key is not configured")
+	ErrSSECustomerKeyNeeded   = errors.New("object is encrypted with a customer-provided key")
+	ErrSSECustomerKeyMismatch = errors.New("customer-provided key does not match the object key")
+	ErrInvalidSSECustomerKey  = errors.New("customer-provided key must be 256 bits long")
+)
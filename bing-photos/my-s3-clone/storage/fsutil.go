@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// countingWriter compte les octets qui le traversent
+type countingWriter struct {
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
+
+func appendFile(dst io.Writer, path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer src.Close()
+	if _, err := io.Copy(dst, src); err != nil {
+		return fmt.Errorf("failed to append %s: %v", path, err)
+	}
+	return nil
+}
+
+// Fichier temporaire du répertoire système : il est sur le même système de fichiers que les buckets
+// et peut donc être mis en place par un simple renommage
+func (fs *FileStorage) createTempFile(pattern string) (*os.File, error) {
+	dir := filepath.Join(fs.root(), systemDir, "tmp")
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	return os.CreateTemp(dir, pattern)
+}
+
+// Le contenu d'un fichier temporaire est écrit sur le disque avant sa mise en place : après un arrêt
+// brutal, le renommage ne peut pas exposer un fichier dont les données sont encore en cache
+func syncAndClose(file *os.File) error {
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to sync %s: %v", file.Name(), err)
+	}
+	return file.Close()
+}
+
+// Synchronisation d'un répertoire, pour rendre durable un renommage dans ce répertoire
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// RemoveTempFiles supprime les fichiers temporaires laissés par des écritures interrompues par un arrêt
+// du serveur (uploads, copies, parts et assemblages multipart). À appeler au démarrage, avant de servir
+// des requêtes. Renvoie le nombre de fichiers supprimés.
+func (fs *FileStorage) RemoveTempFiles() (int, error) {
+	tmpDir := filepath.Join(fs.root(), systemDir, "tmp")
+	leftovers, err := filepath.Glob(filepath.Join(tmpDir, "*"))
+	if err != nil {
+		return 0, err
+	}
+	staging, err := filepath.Glob(filepath.Join(fs.root(), systemDir, "multipart", "*", "*", "tmp-*"))
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, path := range append(leftovers, staging...) {
+		if err := os.RemoveAll(path); err != nil {
+			return removed, fmt.Errorf("failed to remove temporary file %s: %v", path, err)
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+func writeJSONFile(path string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %v", path, err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return nil
+}
+
+func readJSONFile(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,293 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"my-s3-clone/dto"
+)
+
+// Nombre maximal de règles par bucket, comme sur S3
+const maxLifecycleRules = 1000
+
+// Un jour de cycle de vie dure 24 heures à partir de la date de l'objet
+const lifecycleDay = 24 * time.Hour
+
+// Lecture des règles de cycle de vie d'un bucket, ErrNoSuchLifecycle s'il n'en a pas
+func (fs *FileStorage) GetBucketLifecycle(bucketName string) (dto.LifecycleConfiguration, error) {
+	exists, err := fs.CheckBucketExists(bucketName)
+	if err != nil {
+		return dto.LifecycleConfiguration{}, err
+	}
+	if !exists {
+		return dto.LifecycleConfiguration{}, ErrNoSuchBucket
+	}
+	return fs.bucketLifecycle(bucketName)
+}
+
+// Remplacement des règles de cycle de vie d'un bucket
+func (fs *FileStorage) PutBucketLifecycle(bucketName string, config dto.LifecycleConfiguration) error {
+	if err := validateLifecycle(config); err != nil {
+		return err
+	}
+	err := fs.updateBucketMetadata(bucketName, func(meta *bucketMetadata) error {
+		meta.Lifecycle = &config
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("Lifecycle configuration of bucket %s set (%d rules)", bucketName, len(config.Rules))
+	return nil
+}
+
+// Suppression des règles de cycle de vie d'un bucket
+func (fs *FileStorage) DeleteBucketLifecycle(bucketName string) error {
+	return fs.updateBucketMetadata(bucketName, func(meta *bucketMetadata) error {
+		meta.Lifecycle = nil
+		return nil
+	})
+}
+
+func (fs *FileStorage) bucketLifecycle(bucketName string) (dto.LifecycleConfiguration, error) {
+	meta, err := fs.bucketMetadata(bucketName)
+	if err != nil {
+		return dto.LifecycleConfiguration{}, err
+	}
+	if meta.Lifecycle == nil {
+		return dto.LifecycleConfiguration{}, ErrNoSuchLifecycle
+	}
+	return *meta.Lifecycle, nil
+}
+
+// Vérification d'une configuration avant son enregistrement : chaque règle a un statut, au moins une action
+// et des durées positives
+func validateLifecycle(config dto.LifecycleConfiguration) error {
+	if len(config.Rules) == 0 || len(config.Rules) > maxLifecycleRules {
+		return fmt.Errorf("%w: between 1 and %d rules are required", ErrInvalidLifecycle, maxLifecycleRules)
+	}
+
+	ids := make(map[string]bool, len(config.Rules))
+	for _, rule := range config.Rules {
+		if len(rule.ID) > 255 {
+			return fmt.Errorf("%w: rule ID is longer than 255 characters", ErrInvalidLifecycle)
+		}
+		if rule.ID != "" && ids[rule.ID] {
+			return fmt.Errorf("%w: duplicate rule ID %q", ErrInvalidLifecycle, rule.ID)
+		}
+		ids[rule.ID] = true
+
+		if rule.Status != "Enabled" && rule.Status != "Disabled" {
+			return fmt.Errorf("%w: rule status must be Enabled or Disabled", ErrInvalidLifecycle)
+		}
+		if rule.Filter != nil && rule.Prefix != "" {
+			return fmt.Errorf("%w: rule cannot have both Prefix and Filter", ErrInvalidLifecycle)
+		}
+		if rule.Filter != nil {
+			set := 0
+			for _, present := range []bool{rule.Filter.Prefix != "", rule.Filter.Tag != nil, rule.Filter.And != nil} {
+				if present {
+					set++
+				}
+			}
+			if set > 1 {
+				return fmt.Errorf("%w: filter must use And to combine a prefix and tags", ErrInvalidLifecycle)
+			}
+		}
+
+		if rule.Expiration == nil && rule.NoncurrentVersionExpiration == nil && rule.AbortIncompleteMultipartUpload == nil {
+			return fmt.Errorf("%w: rule must specify at least one action", ErrInvalidLifecycle)
+		}
+		if rule.Expiration != nil && rule.Expiration.Days <= 0 {
+			return fmt.Errorf("%w: expiration days must be a positive integer", ErrInvalidLifecycle)
+		}
+		if rule.NoncurrentVersionExpiration != nil && rule.NoncurrentVersionExpiration.NoncurrentDays <= 0 {
+			return fmt.Errorf("%w: noncurrent days must be a positive integer", ErrInvalidLifecycle)
+		}
+		if rule.AbortIncompleteMultipartUpload != nil {
+			if rule.AbortIncompleteMultipartUpload.DaysAfterInitiation <= 0 {
+				return fmt.Errorf("%w: days after initiation must be a positive integer", ErrInvalidLifecycle)
+			}
+			// Les uploads en cours n'ont pas encore d'étiquettes
+			if len(ruleTags(rule)) > 0 {
+				return fmt.Errorf("%w: AbortIncompleteMultipartUpload cannot be used with tag filters", ErrInvalidLifecycle)
+			}
+		}
+	}
+	return nil
+}
+
+// Préfixe des clés concernées par une règle
+func rulePrefix(rule dto.LifecycleRule) string {
+	switch {
+	case rule.Filter == nil:
+		return rule.Prefix
+	case rule.Filter.And != nil:
+		return rule.Filter.And.Prefix
+	}
+	return rule.Filter.Prefix
+}
+
+// Étiquettes que doit porter un objet pour être concerné par une règle
+func ruleTags(rule dto.LifecycleRule) []dto.Tag {
+	switch {
+	case rule.Filter == nil:
+		return nil
+	case rule.Filter.And != nil:
+		return rule.Filter.And.Tags
+	case rule.Filter.Tag != nil:
+		return []dto.Tag{*rule.Filter.Tag}
+	}
+	return nil
+}
+
+func ruleMatches(rule dto.LifecycleRule, key string, tags map[string]string) bool {
+	if !strings.HasPrefix(key, rulePrefix(rule)) {
+		return false
+	}
+	for _, tag := range ruleTags(rule) {
+		if value, ok := tags[tag.Key]; !ok || value != tag.Value {
+			return false
+		}
+	}
+	return true
+}
+
+// LifecycleReport résume un passage du balayage du cycle de vie
+type LifecycleReport struct {
+	DryRun           bool
+	BucketsProcessed int // buckets ayant une configuration de cycle de vie
+	ExpiredObjects   int // versions courantes supprimées (ou masquées par un marqueur de suppression)
+	ExpiredVersions  int // versions non courantes supprimées définitivement
+	AbortedUploads   int // uploads multipart abandonnés
+	Errors           int
+}
+
+// ApplyLifecycle applique les règles actives de tous les buckets à la date now. En mode dryRun,
+// les actions sont seulement journalisées. Les objets expirés sont supprimés par s : fs lui-même,
+// ou fs enveloppé par notification.Wrap pour que leurs suppressions soient publiées.
+func (fs *FileStorage) ApplyLifecycle(s Storage, now time.Time, dryRun bool) LifecycleReport {
+	report := LifecycleReport{DryRun: dryRun}
+
+	for _, bucketName := range fs.ListBuckets() {
+		config, err := fs.bucketLifecycle(bucketName)
+		if errors.Is(err, ErrNoSuchLifecycle) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Lifecycle: %v", err)
+			report.Errors++
+			continue
+		}
+
+		report.BucketsProcessed++
+		for _, rule := range config.Rules {
+			if rule.Status != "Enabled" {
+				continue
+			}
+			fs.applyLifecycleRule(s, bucketName, rule, now, &report)
+		}
+	}
+
+	log.Printf("Lifecycle sweep done (dry run: %t): %d buckets, %d objects expired, %d noncurrent versions expired, %d uploads aborted, %d errors",
+		dryRun, report.BucketsProcessed, report.ExpiredObjects, report.ExpiredVersions, report.AbortedUploads, report.Errors)
+	return report
+}
+
+func (fs *FileStorage) applyLifecycleRule(s Storage, bucketName string, rule dto.LifecycleRule, now time.Time, report *LifecycleReport) {
+	prefix := rulePrefix(rule)
+	// En mode dry-run, les actions sont journalisées sans être appliquées
+	dryRun := ""
+	if report.DryRun {
+		dryRun = " (dry run)"
+	}
+
+	if rule.Expiration != nil {
+		keys, err := fs.listBucketKeys(bucketName, prefix)
+		if err != nil {
+			log.Printf("Lifecycle: failed to list bucket %s: %v", bucketName, err)
+			report.Errors++
+		}
+		deadline := now.Add(-time.Duration(rule.Expiration.Days) * lifecycleDay)
+		for _, key := range keys {
+			_, info, err := fs.currentVersion(bucketName, key)
+			if err != nil || !ruleMatches(rule, key, info.Tags) || info.LastModified.After(deadline) {
+				continue
+			}
+			log.Printf("Lifecycle rule %q: expiring object %s/%s, last modified %s%s", rule.ID, bucketName, key, info.LastModified.Format(time.RFC3339), dryRun)
+			if !report.DryRun {
+				if _, err := s.DeleteObject(bucketName, key, DeleteObjectOptions{}); err != nil {
+					log.Printf("Lifecycle: failed to expire %s/%s: %v", bucketName, key, err)
+					report.Errors++
+					continue
+				}
+			}
+			report.ExpiredObjects++
+		}
+	}
+
+	if rule.NoncurrentVersionExpiration != nil {
+		archived, err := fs.archivedVersionsByKey(bucketName, prefix)
+		if err != nil {
+			log.Printf("Lifecycle: failed to list versions of bucket %s: %v", bucketName, err)
+			report.Errors++
+		}
+		maxAge := time.Duration(rule.NoncurrentVersionExpiration.NoncurrentDays) * lifecycleDay
+		for key, versions := range archived {
+			// Une version devient non courante quand la version suivante est écrite
+			_, current, err := fs.currentVersion(bucketName, key)
+			replacedAt := current.LastModified
+			for i, version := range versions {
+				if i == 0 && err != nil {
+					// Sans version courante, la plus récente version archivée est un marqueur de suppression courant
+					replacedAt = version.LastModified
+					continue
+				}
+				noncurrentSince := replacedAt
+				replacedAt = version.LastModified
+				if !ruleMatches(rule, key, version.Tags) || now.Sub(noncurrentSince) < maxAge {
+					continue
+				}
+				// Les versions verrouillées par Object Lock ne sont pas expirées
+				if checkObjectLock(version.info().Lock, false, now) != nil {
+					continue
+				}
+				log.Printf("Lifecycle rule %q: expiring version %s of %s/%s, noncurrent since %s%s", rule.ID, version.VersionID, bucketName, key, noncurrentSince.Format(time.RFC3339), dryRun)
+				if !report.DryRun {
+					if _, err := s.DeleteObject(bucketName, key, DeleteObjectOptions{VersionID: version.VersionID}); err != nil {
+						log.Printf("Lifecycle: failed to expire version %s of %s/%s: %v", version.VersionID, bucketName, key, err)
+						report.Errors++
+						continue
+					}
+				}
+				report.ExpiredVersions++
+			}
+		}
+	}
+
+	if rule.AbortIncompleteMultipartUpload != nil {
+		uploads, err := fs.listBucketUploads(bucketName)
+		if err != nil {
+			log.Printf("Lifecycle: failed to list multipart uploads of bucket %s: %v", bucketName, err)
+			report.Errors++
+		}
+		deadline := now.Add(-time.Duration(rule.AbortIncompleteMultipartUpload.DaysAfterInitiation) * lifecycleDay)
+		for _, upload := range uploads {
+			if !strings.HasPrefix(upload.Key, prefix) || upload.Initiated.After(deadline) {
+				continue
+			}
+			log.Printf("Lifecycle rule %q: aborting multipart upload %s of %s/%s, initiated %s%s", rule.ID, upload.UploadID, bucketName, upload.Key, upload.Initiated.Format(time.RFC3339), dryRun)
+			if !report.DryRun {
+				if err := fs.AbortMultipartUpload(bucketName, upload.Key, upload.UploadID); err != nil {
+					log.Printf("Lifecycle: failed to abort upload %s: %v", upload.UploadID, err)
+					report.Errors++
+					continue
+				}
+			}
+			report.AbortedUploads++
+		}
+	}
+}
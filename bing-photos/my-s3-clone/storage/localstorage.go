@@ -8,17 +8,106 @@ import (
     "fmt"
     "io"
     "bufio"  
+    "sort"
     "strconv"
-    "time"
+    "bytes"
+    "crypto/md5"
+    "crypto/sha256"
+    "encoding/hex"
+    "sync"
     "my-s3-clone/dto"
 )
 
 // FileStorage implémente l'interface Storage avec un stockage basé sur le système de fichiers
-type FileStorage struct{}
+type FileStorage struct {
+    // Répertoire contenant les buckets et le répertoire système, DefaultRoot si vide
+    Root string
 
-const storageRoot = "/mydata/data"
+    // Clé maîtresse (32 octets) chiffrant les clés de données des objets SSE-S3 ;
+    // sans elle, les objets sont écrits en clair sauf demande SSE-C
+    MasterKey []byte
 
-func ProcessChunkedStream(reader io.Reader, writer io.Writer) error {
+    // Sérialise la mise en place des nouvelles versions et la réécriture de leurs métadonnées : la vérification
+    // de If-None-Match et l'archivage de la version courante ne doivent pas s'entrelacer avec une autre écriture
+    commitMu sync.Mutex
+
+    // Sérialise les lectures et modifications de l'enregistrement des buckets (création, configuration)
+    bucketMu sync.Mutex
+
+    // Sérialise les mises à jour du compteur d'occupation des buckets
+    usageMu sync.Mutex
+}
+
+// DefaultRoot est le répertoire de données utilisé quand FileStorage.Root n'est pas renseigné
+const DefaultRoot = "/mydata/data"
+
+func (fs *FileStorage) root() string {
+    if fs.Root == "" {
+        return DefaultRoot
+    }
+    return fs.Root
+}
+
+// ChunkVerifier vérifie la signature de chaque chunk d'un upload streaming
+type ChunkVerifier interface {
+    VerifyChunk(chunkSHA256 []byte, signature string) error
+}
+
+// PutObjectOptions regroupe les paramètres d'un upload
+type PutObjectOptions struct {
+    ContentSha256        string        // x-amz-content-sha256 : hash hexadécimal, UNSIGNED-PAYLOAD ou STREAMING-...
+    ContentMD5           []byte        // Content-MD5 décodé, nil si absent
+    Metadata             dto.ObjectMetadata
+    DecodedContentLength int64         // X-Amz-Decoded-Content-Length, -1 si absent
+    ChunkVerifier        ChunkVerifier // obligatoire pour STREAMING-AWS4-HMAC-SHA256-PAYLOAD
+    Lock                 dto.ObjectLock // x-amz-object-lock-*, vide pour la rétention par défaut du bucket
+    Encryption           SSEOptions     // x-amz-server-side-encryption*, SSE-S3 par défaut si une clé maîtresse est configurée
+    IfNoneMatch          bool           // If-None-Match: * : échec (ErrPreconditionFailed) si la clé a déjà une version courante
+    Tags                 map[string]string // x-amz-tagging, nil pour un objet sans étiquette
+}
+
+// MultipartUploadOptions regroupe les en-têtes fournis au démarrage d'un upload multipart, appliqués à l'objet final
+type MultipartUploadOptions struct {
+    Metadata   dto.ObjectMetadata
+    Encryption SSEOptions        // x-amz-server-side-encryption*
+    Lock       dto.ObjectLock    // x-amz-object-lock-*, vide pour la rétention par défaut du bucket
+    Tags       map[string]string // x-amz-tagging
+}
+
+// CopyObjectOptions regroupe les paramètres d'une copie
+type CopyObjectOptions struct {
+    SourceVersionID   string              // version de la source, "" pour la version courante
+    SourceCustomerKey []byte              // clé SSE-C de la source, nil si elle n'est pas chiffrée par le client
+    Metadata          *dto.ObjectMetadata // remplace les métadonnées de la source, nil pour les conserver
+    Encryption        SSEOptions          // chiffrement de la copie
+
+    // x-amz-copy-source-if-* : vérification de la source lue, nil sans condition. Une erreur interrompt la copie.
+    SourceCondition func(source dto.ObjectInfo) error
+
+    // x-amz-tagging-directive: REPLACE : étiquettes de la copie (vides pour n'en garder aucune), nil pour conserver celles de la source
+    Tags map[string]string
+}
+
+// DeleteObjectOptions regroupe les paramètres d'une suppression
+type DeleteObjectOptions struct {
+    VersionID        string // version à supprimer définitivement, "" pour la version courante
+    BypassGovernance bool   // x-amz-bypass-governance-retention : ignorer une rétention GOVERNANCE
+}
+
+const (
+    unsignedPayload  = "UNSIGNED-PAYLOAD"
+    streamingPayload = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+)
+
+// writtenObject décrit les données écrites par writeObjectToFile
+type writtenObject struct {
+    ETag string
+    Size int64
+}
+
+// ProcessChunkedStream décode un payload STREAMING-AWS4-HMAC-SHA256-PAYLOAD, vérifie la
+// signature de chaque chunk et contrôle la taille totale par rapport à decodedLength (-1 pour l'ignorer)
+func ProcessChunkedStream(reader io.Reader, writer io.Writer, verifier ChunkVerifier, decodedLength int64) error {
     bufReader := bufio.NewReader(reader)
     log.Println("Started processing chunked stream")
 
@@ -29,22 +118,45 @@ func ProcessChunkedStream(reader io.Reader, writer io.Writer) error {
         line, err := bufReader.ReadString('\n')
         if err != nil {
             log.Printf("Error reading chunk size: %v", err)
-            return fmt.Errorf("error reading chunk size: %v", err)
+            return fmt.Errorf("%w: error reading chunk size: %v", ErrMalformedChunk, err)
         }
-        log.Printf("Received chunk size line: %s", line)
 
-        // Parse chunk size (ignore chunk extensions)
+        // Parse chunk size and chunk-signature extension
         line = strings.TrimSpace(line)
         parts := strings.SplitN(line, ";", 2)
         chunkSizeHex := parts[0]
 
         chunkSize, err := strconv.ParseInt(chunkSizeHex, 16, 64)
-        if err != nil {
+        if err != nil || chunkSize < 0 {
             log.Printf("Error parsing chunk size: %v", err)
-            return fmt.Errorf("error parsing chunk size: %v", err)
+            return fmt.Errorf("%w: invalid chunk size %q", ErrMalformedChunk, chunkSizeHex)
+        }
+
+        chunkSignature := ""
+        if len(parts) > 1 {
+            chunkSignature = strings.TrimPrefix(parts[1], "chunk-signature=")
+        }
+        if verifier != nil && chunkSignature == "" {
+            return fmt.Errorf("%w: missing chunk-signature", ErrMalformedChunk)
         }
 
-        log.Printf("Parsed chunk size: %d", chunkSize)
+        if decodedLength >= 0 && totalBytesProcessed+chunkSize > decodedLength {
+            return fmt.Errorf("%w: received more than %d bytes", ErrIncompleteBody, decodedLength)
+        }
+
+        // Copy chunk data to writer while hashing it
+        hasher := sha256.New()
+        if _, err := io.CopyN(io.MultiWriter(writer, hasher), bufReader, chunkSize); err != nil {
+            log.Printf("Error reading chunk data: %v", err)
+            return fmt.Errorf("%w: error reading chunk data: %v", ErrIncompleteBody, err)
+        }
+
+        if verifier != nil {
+            if err := verifier.VerifyChunk(hasher.Sum(nil), chunkSignature); err != nil {
+                log.Printf("Invalid signature for chunk of %d bytes after %d bytes", chunkSize, totalBytesProcessed)
+                return fmt.Errorf("%w: %v", ErrChunkSignatureMismatch, err)
+            }
+        }
 
         // End of stream (zero-size chunk)
         if chunkSize == 0 {
@@ -52,26 +164,17 @@ func ProcessChunkedStream(reader io.Reader, writer io.Writer) error {
             break
         }
 
-        // Copy chunk data to writer
-        if _, err := io.CopyN(writer, bufReader, chunkSize); err != nil {
-            log.Printf("Error reading chunk data: %v", err)
-            return fmt.Errorf("error reading chunk data: %v", err)
-        }
-
         totalBytesProcessed += chunkSize
-        log.Printf("Successfully processed a chunk, total bytes processed: %d", totalBytesProcessed)
 
         // Discard the CRLF after the chunk
         if _, err := bufReader.Discard(2); err != nil {
             log.Printf("Error discarding CRLF: %v", err)
-            return fmt.Errorf("error discarding CRLF: %v", err)
+            return fmt.Errorf("%w: error discarding CRLF: %v", ErrMalformedChunk, err)
         }
+    }
 
-        // Log chunk signature 
-        if len(parts) > 1 {
-            chunkSignature := parts[1]
-            log.Printf("Chunk signature: %s", chunkSignature)
-        }
+    if decodedLength >= 0 && totalBytesProcessed != decodedLength {
+        return fmt.Errorf("%w: expected %d bytes, received %d", ErrIncompleteBody, decodedLength, totalBytesProcessed)
     }
 
     log.Printf("Completed processing chunked stream, total bytes processed: %d", totalBytesProcessed)
@@ -79,116 +182,257 @@ func ProcessChunkedStream(reader io.Reader, writer io.Writer) error {
 }
 
 
-// Ajout d'un objet dans un bucket
-func (fs *FileStorage) AddObject(bucketName, objectName string, data io.Reader, contentSha256 string) error {
+// Ajout d'un objet dans un bucket. Le contenu est écrit dans un fichier temporaire, synchronisé sur le disque
+// puis mis en place par renommage : un upload interrompu ne laisse jamais d'objet tronqué sous son nom.
+// L'objet existant est écrasé, ou devient une version non courante si le bucket est versionné.
+func (fs *FileStorage) AddObject(bucketName, objectName string, data io.Reader, opts PutObjectOptions) (dto.ObjectInfo, error) {
     log.Printf("Starting object upload: %s in bucket: %s", objectName, bucketName)
 
-    objectPath, err := getUniqueObjectPath(bucketName, objectName)
+    if _, err := fs.resolveObjectPath(bucketName, objectName); err != nil {
+        return dto.ObjectInfo{}, err
+    }
+    exists, err := fs.CheckBucketExists(bucketName)
     if err != nil {
-        log.Printf("Failed to create object path for %s in bucket %s: %v", objectName, bucketName, err)
-        return fmt.Errorf("Failed to create object path: %v", err)
+        return dto.ObjectInfo{}, err
+    }
+    if !exists {
+        return dto.ObjectInfo{}, ErrNoSuchBucket
     }
 
-    log.Printf("Object path created: %s", objectPath)
+    if err := validateTags(opts.Tags); err != nil {
+        return dto.ObjectInfo{}, err
+    }
+    encryption, dataKey, err := fs.newObjectEncryption(opts.Encryption)
+    if err != nil {
+        return dto.ObjectInfo{}, err
+    }
 
-    file, err := os.Create(objectPath)
+    file, err := fs.createTempFile("object-")
     if err != nil {
-        log.Printf("Failed to create file: %s, error: %v", objectPath, err)
-        return fmt.Errorf("Failed to create file: %v", err)
+        log.Printf("Failed to create temporary file for %s: %v", objectName, err)
+        return dto.ObjectInfo{}, fmt.Errorf("Failed to create file: %v", err)
     }
+    defer os.Remove(file.Name())
     defer file.Close()
 
-    log.Printf("Writing data to object: %s", objectPath)
+    log.Printf("Writing data to object: %s", objectName)
 
-    if err := writeObjectToFile(data, file, contentSha256); err != nil {
-        log.Printf("Error writing object to file: %v", err)
+    // Le contenu est chiffré à la volée ; l'ETag reste le MD5 du contenu en clair
+    var written writtenObject
+    segment, err := writeSegment(file, dataKey, func(w io.Writer) error {
+        written, err = writeObjectToFile(data, w, opts)
         return err
+    })
+    if err == nil {
+        err = syncAndClose(file)
     }
-
-    log.Printf("Successfully uploaded file: %s", objectPath)
-    return nil
-}
-
-// Fonction pour obtenir un chemin unique si l'objet existe déjà
-func getUniqueObjectPath(bucketName, objectName string) (string, error) {
-    objectPath := filepath.Join(storageRoot, bucketName, objectName)
-    if _, err := os.Stat(objectPath); os.IsNotExist(err) {
-        return objectPath, nil
+    if err != nil {
+        log.Printf("Error writing object %s, discarding partial file: %v", objectName, err)
+        return dto.ObjectInfo{}, err
+    }
+    if encryption != nil {
+        encryption.Segments = []encryptedSegment{segment}
     }
 
-    log.Printf("Object already exists, generating new name for: %s", objectPath)
+    meta := objectMetadata{Key: objectName, ETag: written.ETag, Metadata: opts.Metadata, Tags: copyTags(opts.Tags), Encryption: encryption}
+    info, err := fs.commitObject(bucketName, file.Name(), meta, opts.Lock, opts.IfNoneMatch)
+    if err != nil {
+        log.Printf("Failed to store object %s in bucket %s: %v", objectName, bucketName, err)
+        return dto.ObjectInfo{}, err
+    }
 
-    objectNameWithoutExt := strings.TrimSuffix(objectName, filepath.Ext(objectName))
-    extension := filepath.Ext(objectName)
-    newObjectName := objectNameWithoutExt
-    suffix := 1
+    log.Printf("Successfully uploaded object: %s in bucket %s (ETag %s, version %q)", objectName, bucketName, info.ETag, info.VersionID)
+    return info, nil
+}
 
-    for {
-        newObjectName = fmt.Sprintf("%s-%d%s", objectNameWithoutExt, suffix, extension)
-        newObjectPath := filepath.Join(storageRoot, bucketName, newObjectName)
-        if _, err := os.Stat(newObjectPath); os.IsNotExist(err) {
-            return newObjectPath, nil
-        }
-        suffix++
+// Fonction qui gère l'écriture du flux dans le fichier.
+// Le MD5 (ETag) est calculé au fil de l'écriture et comparé à Content-MD5 et x-amz-content-sha256 s'ils sont fournis.
+func writeObjectToFile(data io.Reader, file io.Writer, opts PutObjectOptions) (writtenObject, error) {
+    md5Hasher := md5.New()
+    sha256Hasher := sha256.New()
+    counter := &countingWriter{}
+    writer := io.MultiWriter(file, md5Hasher, counter)
+
+    checkSha256 := opts.ContentSha256 != "" && opts.ContentSha256 != unsignedPayload && opts.ContentSha256 != streamingPayload
+    if checkSha256 {
+        writer = io.MultiWriter(writer, sha256Hasher)
     }
-}
 
-// Fonction qui gère l'écriture du flux dans le fichier
-func writeObjectToFile(data io.Reader, file *os.File, contentSha256 string) error {
-    if contentSha256 == "STREAMING-AWS4-HMAC-SHA256-PAYLOAD" {
+    if opts.ContentSha256 == streamingPayload {
         log.Println("Processing as chunked stream")
-        if err := ProcessChunkedStream(data, file); err != nil {
+        if opts.ChunkVerifier == nil {
+            return writtenObject{}, fmt.Errorf("%w: no chunk verifier for a signed streaming payload", ErrChunkSignatureMismatch)
+        }
+        if err := ProcessChunkedStream(data, writer, opts.ChunkVerifier, opts.DecodedContentLength); err != nil {
             log.Printf("Failed to write chunked data: %v", err)
-            return fmt.Errorf("Failed to write chunked data: %v", err)
+            return writtenObject{}, fmt.Errorf("Failed to write chunked data: %w", err)
         }
     } else {
         log.Println("Processing as regular stream")
-        if _, err := io.Copy(file, data); err != nil {
+        if _, err := io.Copy(writer, data); err != nil {
             log.Printf("Failed to write data: %v", err)
-            return fmt.Errorf("Failed to write data: %v", err)
+            return writtenObject{}, fmt.Errorf("Failed to write data: %v", err)
         }
     }
-    return nil
+
+    sum := md5Hasher.Sum(nil)
+    if opts.ContentMD5 != nil && !bytes.Equal(sum, opts.ContentMD5) {
+        return writtenObject{}, fmt.Errorf("%w: received %x, expected %x", ErrBadDigest, sum, opts.ContentMD5)
+    }
+    if checkSha256 {
+        computed := hex.EncodeToString(sha256Hasher.Sum(nil))
+        if !strings.EqualFold(computed, opts.ContentSha256) {
+            return writtenObject{}, fmt.Errorf("%w: computed %s, header %s", ErrContentSHA256Mismatch, computed, opts.ContentSha256)
+        }
+    }
+
+    return writtenObject{ETag: `"` + hex.EncodeToString(sum) + `"`, Size: counter.n}, nil
 }
 
-// Lister les objets dans un bucket
-func (fs *FileStorage) ListObjects(bucketName, prefix, marker string, maxKeys int) (dto.ListObjectsResponse, error) {
-    bucketPath := filepath.Join(storageRoot, bucketName)
+// ListObjectsOptions regroupe les paramètres d'un listing
+type ListObjectsOptions struct {
+    Prefix     string
+    Delimiter  string
+    StartAfter string // seules les clés strictement supérieures sont renvoyées (marker, start-after ou continuation token)
+    MaxKeys    int
+    Tags       map[string]string // seuls les objets portant toutes ces étiquettes sont renvoyés
+}
+
+// Lister les objets dans un bucket, dans l'ordre lexicographique des clés.
+// Avec un délimiteur, les clés partageant un préfixe jusqu'au délimiteur sont regroupées en un préfixe commun.
+func (fs *FileStorage) ListObjects(bucketName string, opts ListObjectsOptions) (dto.ObjectListing, error) {
+    listing := dto.ObjectListing{Objects: make([]dto.Object, 0)}
 
-    objects, err := filepath.Glob(filepath.Join(bucketPath, prefix+"*"))
+    exists, err := fs.CheckBucketExists(bucketName)
     if err != nil {
-        return dto.ListObjectsResponse{}, fmt.Errorf("error while listing objects: %v", err)
+        return listing, err
+    }
+    if !exists {
+        return listing, ErrNoSuchBucket
+    }
+
+    keys, err := fs.listBucketKeys(bucketName, opts.Prefix)
+    if err != nil {
+        return listing, fmt.Errorf("error while listing objects: %v", err)
+    }
+
+    record := func(key string) (objectMetadata, error) {
+        objectPath := filepath.Join(fs.root(), bucketName, filepath.FromSlash(key))
+        fileInfo, err := os.Stat(objectPath)
+        if err != nil {
+            return objectMetadata{}, fmt.Errorf("error retrieving file info: %v", err)
+        }
+
+        info, err := fs.loadObjectMetadata(bucketName, key, objectPath, fileInfo)
+        if err != nil {
+            return objectMetadata{}, fmt.Errorf("error retrieving object metadata: %v", err)
+        }
+        return info, nil
     }
 
-    response := dto.ListObjectsResponse{
-        Xmlns:       "http://s3.amazonaws.com/doc/2006-03-01/",
-        Name:        bucketName,
-        Prefix:      prefix,
-        Marker:      marker,
-        MaxKeys:     maxKeys,
-        IsTruncated: false,
-        Contents:    make([]dto.Object, 0),
+    keys, err = keysWithTags(keys, opts.Tags, func(key string) (map[string]string, error) {
+        info, err := record(key)
+        return info.Tags, err
+    })
+    if err != nil {
+        return listing, err
     }
 
-    for i, object := range objects {
-        if i >= maxKeys {
-            response.IsTruncated = true
+    return listObjectsPage(keys, opts, func(key string) (dto.Object, error) {
+        info, err := record(key)
+        if err != nil {
+            return dto.Object{}, err
+        }
+        return dto.Object{
+            Key:          info.Key,
+            LastModified: info.LastModified,
+            ETag:         info.ETag,
+            Size:         int(info.Size),
+        }, nil
+    })
+}
+
+// Page d'un listing à partir des clés triées d'un bucket ; object fournit l'entrée d'une clé renvoyée
+func listObjectsPage(keys []string, opts ListObjectsOptions, object func(key string) (dto.Object, error)) (dto.ObjectListing, error) {
+    listing := dto.ObjectListing{Objects: make([]dto.Object, 0)}
+
+    count := 0
+    for _, key := range keys {
+        if !strings.HasPrefix(key, opts.Prefix) || key <= opts.StartAfter {
+            continue
+        }
+
+        commonPrefix := ""
+        if opts.Delimiter != "" {
+            rest := key[len(opts.Prefix):]
+            if i := strings.Index(rest, opts.Delimiter); i >= 0 {
+                commonPrefix = opts.Prefix + rest[:i+len(opts.Delimiter)]
+            }
+        }
+        // Les clés d'un préfixe commun déjà renvoyé (ou égal au marker) sont ignorées
+        if commonPrefix != "" && (commonPrefix <= opts.StartAfter || commonPrefix == listing.NextMarker) {
+            continue
+        }
+
+        if count >= opts.MaxKeys {
+            listing.IsTruncated = true
             break
         }
+        count++
+
+        if commonPrefix != "" {
+            listing.CommonPrefixes = append(listing.CommonPrefixes, commonPrefix)
+            listing.NextMarker = commonPrefix
+            continue
+        }
 
-        fileInfo, err := os.Stat(object)
+        entry, err := object(key)
         if err != nil {
-            return dto.ListObjectsResponse{}, fmt.Errorf("error retrieving file info: %v", err)
+            return listing, err
         }
+        listing.Objects = append(listing.Objects, entry)
+        listing.NextMarker = key
+    }
+
+    return listing, nil
+}
 
-        response.Contents = append(response.Contents, dto.Object{
-            Key:          filepath.Base(object),
-            LastModified: fileInfo.ModTime(),
-            Size:         int(fileInfo.Size()),
-        })
+// Clés de tous les objets d'un bucket commençant par prefix, triées.
+// Les répertoires qui ne peuvent pas contenir de clé avec ce préfixe ne sont pas parcourus.
+func (fs *FileStorage) listBucketKeys(bucketName, prefix string) ([]string, error) {
+    bucketPath, err := fs.resolveBucketPath(bucketName)
+    if err != nil {
+        return nil, err
+    }
+
+    var keys []string
+    err = filepath.WalkDir(bucketPath, func(path string, entry os.DirEntry, err error) error {
+        if err != nil {
+            return err
+        }
+        if path == bucketPath {
+            return nil
+        }
+
+        key := fs.objectKey(bucketName, path)
+        if entry.IsDir() {
+            dirPrefix := key + "/"
+            if !strings.HasPrefix(dirPrefix, prefix) && !strings.HasPrefix(prefix, dirPrefix) {
+                return filepath.SkipDir
+            }
+            return nil
+        }
+        if strings.HasPrefix(key, prefix) {
+            keys = append(keys, key)
+        }
+        return nil
+    })
+    if err != nil {
+        return nil, err
     }
 
-    return response, nil
+    sort.Strings(keys)
+    return keys, nil
 }
 
 // Lister les buckets
@@ -196,19 +440,23 @@ func (fs *FileStorage) ListBuckets() []string {
     var buckets []string
 
     // Ajout de log pour vérifier si le répertoire existe
-    log.Printf("Vérification de l'existence du répertoire de stockage des buckets : %s", storageRoot)
+    log.Printf("Vérification de l'existence du répertoire de stockage des buckets : %s", fs.root())
 
-    files, err := os.ReadDir(storageRoot)
+    files, err := os.ReadDir(fs.root())
     if err != nil {
-        log.Printf("Erreur lors de la lecture du répertoire %s : %v", storageRoot, err)
+        log.Printf("Erreur lors de la lecture du répertoire %s : %v", fs.root(), err)
         return buckets
     }
 
     // Ajout de log pour voir combien de fichiers/répertoires sont trouvés
-    log.Printf("Nombre d'éléments trouvés dans le répertoire %s : %d", storageRoot, len(files))
+    log.Printf("Nombre d'éléments trouvés dans le répertoire %s : %d", fs.root(), len(files))
 
     // Parcourir chaque élément trouvé
     for _, file := range files {
+        // Les répertoires cachés (données internes du serveur) ne sont pas des buckets
+        if strings.HasPrefix(file.Name(), ".") {
+            continue
+        }
         if file.IsDir() {
             // Ajout de log pour chaque répertoire trouvé
             log.Printf("Bucket trouvé : %s", file.Name())
@@ -225,56 +473,86 @@ func (fs *FileStorage) ListBuckets() []string {
     return buckets
 }
 
-// Créer un bucket
-func (fs *FileStorage) CreateBucket(bucketName string) error {
-    bucketPath := filepath.Join(storageRoot, bucketName)
-    if err := os.MkdirAll(bucketPath, os.ModePerm); err != nil {
+// Créer un bucket, avec son enregistrement : date de création, propriétaire et région
+func (fs *FileStorage) CreateBucket(bucketName string, opts CreateBucketOptions) error {
+    bucketPath, err := fs.resolveBucketPath(bucketName)
+    if err != nil {
+        return err
+    }
+    meta, err := newBucketMetadata(opts)
+    if err != nil {
+        return err
+    }
+    if err := os.MkdirAll(fs.root(), os.ModePerm); err != nil {
+        return err
+    }
+
+    fs.bucketMu.Lock()
+    defer fs.bucketMu.Unlock()
+    if err := os.Mkdir(bucketPath, os.ModePerm); err != nil {
+        if os.IsExist(err) {
+            return ErrBucketAlreadyExists
+        }
+        return err
+    }
+    if err := fs.saveBucketMetadata(bucketName, meta); err != nil {
+        if removeErr := os.Remove(bucketPath); removeErr != nil {
+            log.Printf("Failed to roll back bucket %s: %v", bucketName, removeErr)
+        }
         return err
     }
     return nil
 }
 
-// Récupération d'un objet dans un bucket
-func (fs *FileStorage) GetObject(bucketName, objectName string) ([]byte, dto.FileInfo, error) {
-	objectPath := filepath.Join(storageRoot, bucketName, objectName)
-	log.Printf("Tentative de récupération de l'objet : %s", objectPath)
-
-	// Lire le fichier
-	data, err := os.ReadFile(objectPath)
-	if err != nil {
-		log.Printf("Erreur lors de la lecture de l'objet: %v", err)
-		return nil, nil, err
-	}
-
-	// Récupérer les métadonnées du fichier
-	FileInfo, err := os.Stat(objectPath)
-	if err != nil {
-		log.Printf("Erreur lors de la récupération des métadonnées du fichier: %v", err)
-		return nil, nil, err
-	}
-
-	// Retourner le contenu du fichier et les métadonnées encapsulées dans fileInfoWrapper
-	return data, &dto.FileInfoWrapper{FileInfo: FileInfo}, nil
-}
+// Récupération d'un objet dans un bucket, ou d'une de ses versions : le fichier est renvoyé ouvert,
+// à charge de l'appelant de le fermer
+func (fs *FileStorage) GetObject(bucketName, objectName, versionID string, customerKey []byte) (io.ReadSeekCloser, dto.ObjectInfo, error) {
+    objectPath, record, err := fs.versionRecord(bucketName, objectName, versionID)
+    info := record.info()
+    if err != nil {
+        return nil, info, err
+    }
+    log.Printf("Tentative de récupération de l'objet : %s", objectPath)
 
-// Vérification de l'existence d'un objet dans un bucket
-func (fs *FileStorage) CheckObjectExist(bucketName, objectName string) (bool, time.Time, int64, error) {
-    objectPath := filepath.Join(storageRoot, bucketName, objectName)
+    // Une version SSE-C ne peut être lue qu'avec la clé du client
+    var dataKey []byte
+    if record.Encryption != nil {
+        if dataKey, err = fs.dataKey(record.Encryption, customerKey); err != nil {
+            return nil, info, err
+        }
+    }
 
-    fileInfo, err := os.Stat(objectPath)
-    if os.IsNotExist(err) {
-        return false, time.Time{}, 0, nil
-    } else if err != nil {
-        log.Printf("Error checking object: %v", err)
-        return false, time.Time{}, 0, fmt.Errorf("error checking object existence: %v", err)
+    // Ouvrir le fichier sans le charger en mémoire
+    file, err := os.Open(objectPath)
+    if err != nil {
+        log.Printf("Erreur lors de l'ouverture de l'objet: %v", err)
+        return nil, dto.ObjectInfo{}, err
+    }
+    if dataKey == nil {
+        return file, info, nil
+    }
+
+    // Les données sont déchiffrées bloc par bloc à la lecture, ce qui permet toujours de servir une plage
+    reader, err := newDecryptingReader(file, dataKey, record.Encryption)
+    if err != nil {
+        file.Close()
+        return nil, dto.ObjectInfo{}, err
     }
+    return reader, info, nil
+}
 
-    return true, fileInfo.ModTime(), fileInfo.Size(), nil
+// Lecture des métadonnées d'un objet ou d'une de ses versions, os.ErrNotExist s'il n'existe pas
+func (fs *FileStorage) StatObject(bucketName, objectName, versionID string) (dto.ObjectInfo, error) {
+    _, info, err := fs.objectVersion(bucketName, objectName, versionID)
+    return info, err
 }
 
 // Vérification de l'existence d'un bucket
 func (fs *FileStorage) CheckBucketExists(bucketName string) (bool, error) {
-    bucketPath := filepath.Join(storageRoot, bucketName)
+    bucketPath, err := fs.resolveBucketPath(bucketName)
+    if err != nil {
+        return false, err
+    }
     if _, err := os.Stat(bucketPath); os.IsNotExist(err) {
         return false, nil
     } else if err != nil {
@@ -285,67 +563,251 @@ func (fs *FileStorage) CheckBucketExists(bucketName string) (bool, error) {
 
 // Suppression d'un bucket
 func (fs *FileStorage) DeleteBucket(bucketName string) error {
-    bucketPath := filepath.Join(storageRoot, bucketName)
+    bucketPath, err := fs.resolveBucketPath(bucketName)
+    if err != nil {
+        return err
+    }
 
     if _, err := os.Stat(bucketPath); os.IsNotExist(err) {
         log.Printf("Bucket %s does not exist", bucketName)
+        return ErrNoSuchBucket
+    }
+
+    // Comme sur S3, seul un bucket vide peut être supprimé
+    keys, err := fs.listBucketKeys(bucketName, "")
+    if err != nil {
         return err
     }
+    if len(keys) > 0 {
+        log.Printf("Bucket %s still contains %d objects", bucketName, len(keys))
+        return ErrBucketNotEmpty
+    }
+    // Les versions non courantes et les marqueurs de suppression comptent aussi : un bucket
+    // qui contient des versions verrouillées par Object Lock ne peut donc pas être supprimé
+    if versions, _ := filepath.Glob(filepath.Join(fs.bucketVersionsDir(bucketName), "*", "*.json")); len(versions) > 0 {
+        log.Printf("Bucket %s still contains %d object versions", bucketName, len(versions))
+        return ErrBucketNotEmpty
+    }
 
-    err := os.RemoveAll(bucketPath)
+    err = os.RemoveAll(bucketPath)
     if err != nil {
         log.Printf("Failed to delete bucket %s: %v", bucketName, err)
         return err
     }
+    if err := os.RemoveAll(fs.bucketMetadataDir(bucketName)); err != nil {
+        log.Printf("Failed to delete metadata of bucket %s: %v", bucketName, err)
+    }
+    if err := os.RemoveAll(filepath.Join(fs.root(), systemDir, "multipart", bucketName)); err != nil {
+        log.Printf("Failed to delete multipart uploads of bucket %s: %v", bucketName, err)
+    }
+    for _, dir := range []string{fs.bucketVersionsDir(bucketName), fs.bucketConfigDir(bucketName)} {
+        if err := os.RemoveAll(dir); err != nil {
+            log.Printf("Failed to delete %s: %v", dir, err)
+        }
+    }
 
     log.Printf("Bucket %s successfully deleted", bucketName)
     return nil
 }
 
-// Suppression d'un objet dans un bucket
-func (fs *FileStorage) DeleteObject(bucketName, objectName string) error {
-    objectPath := filepath.Join(storageRoot, bucketName, objectName)
+// Suppression d'un objet dans un bucket. Dans un bucket versionné, un marqueur de suppression masque l'objet
+// sans effacer ses versions ; avec opts.VersionID, la version indiquée est supprimée définitivement,
+// sauf si elle est verrouillée (ErrObjectLocked).
+func (fs *FileStorage) DeleteObject(bucketName, objectName string, opts DeleteObjectOptions) (dto.ObjectInfo, error) {
+    objectPath, err := fs.resolveObjectPath(bucketName, objectName)
+    if err != nil {
+        return dto.ObjectInfo{}, err
+    }
+    if _, err := os.Stat(filepath.Join(fs.root(), bucketName)); os.IsNotExist(err) {
+        return dto.ObjectInfo{}, ErrNoSuchBucket
+    }
+
+    if opts.VersionID != "" {
+        return fs.deleteObjectVersion(bucketName, objectName, opts.VersionID, opts.BypassGovernance)
+    }
+
+    // La taille lue, la suppression et l'occupation du bucket ne doivent pas croiser une écriture de la clé,
+    // ni le nettoyage des répertoires la création de ceux d'un nouvel objet
+    fs.commitMu.Lock()
+    defer fs.commitMu.Unlock()
+
+    status, err := fs.versioningStatus(bucketName)
+    if err != nil {
+        return dto.ObjectInfo{}, err
+    }
+    if status != "" {
+        marker, err := fs.putDeleteMarker(bucketName, objectName)
+        if err != nil {
+            log.Printf("Failed to create delete marker for %s in bucket %s: %v", objectName, bucketName, err)
+            return dto.ObjectInfo{}, err
+        }
+        fs.pruneEmptyDirs(bucketName, objectPath)
+        log.Printf("Delete marker %s created for %s in bucket %s", marker.VersionID, objectName, bucketName)
+        return marker, nil
+    }
 
-    if _, err := os.Stat(objectPath); os.IsNotExist(err) {
+    // Un répertoire n'est que le préfixe d'autres clés, pas un objet
+    if fileInfo, err := os.Stat(objectPath); os.IsNotExist(err) || (err == nil && fileInfo.IsDir()) {
         log.Printf("Object %s does not exist in bucket %s", objectName, bucketName)
-        return fmt.Errorf("object not found: %w", err) // Retourne une erreur "object not found" encapsulant l'erreur 404
+        return dto.ObjectInfo{}, fmt.Errorf("object not found: %w", os.ErrNotExist) // Retourne une erreur "object not found" encapsulant l'erreur 404
     }
 
-    err := os.Remove(objectPath)
+    _, record, err := fs.lockedCurrentRecord(bucketName, objectName)
+    if err != nil {
+        return dto.ObjectInfo{}, err
+    }
+    err = os.Remove(objectPath)
     if err != nil {
         log.Printf("Failed to delete object %s in bucket %s: %v", objectName, bucketName, err)
-        return err
+        return dto.ObjectInfo{}, err
     }
+    fs.removeObjectMetadata(bucketName, objectName)
+    fs.addUsage(bucketName, -record.Size, -1)
+    fs.pruneEmptyDirs(bucketName, objectPath)
 
     log.Printf("Object %s in bucket %s successfully deleted", objectName, bucketName)
-    return nil
+    return dto.ObjectInfo{Key: objectName}, nil
+}
+
+// Copie d'un objet ou d'une de ses versions (opts.SourceVersionID). opts.Metadata remplace les métadonnées de la source
+// (x-amz-metadata-directive: REPLACE), nil pour les conserver. La copie devient une nouvelle version de la cible,
+// chiffrée selon opts.Encryption indépendamment du chiffrement de la source.
+func (fs *FileStorage) CopyObject(sourceBucket, sourceKey, targetBucket, targetKey string, opts CopyObjectOptions) (dto.ObjectInfo, error) {
+    if _, err := fs.resolveObjectPath(targetBucket, targetKey); err != nil {
+        return dto.ObjectInfo{}, err
+    }
+    if err := validateTags(opts.Tags); err != nil {
+        return dto.ObjectInfo{}, err
+    }
+    if exists, err := fs.CheckBucketExists(targetBucket); err != nil {
+        return dto.ObjectInfo{}, err
+    } else if !exists {
+        return dto.ObjectInfo{}, ErrNoSuchBucket
+    }
+
+    // La source est lue en clair, puis chiffrée pour la cible comme un upload
+    input, source, err := fs.GetObject(sourceBucket, sourceKey, opts.SourceVersionID, opts.SourceCustomerKey)
+    if err != nil {
+        return source, err
+    }
+    defer input.Close()
+    if opts.SourceCondition != nil {
+        if err := opts.SourceCondition(source); err != nil {
+            return dto.ObjectInfo{}, err
+        }
+    }
+
+    metadata := opts.Metadata
+    if metadata == nil {
+        metadata = &source.Metadata
+    }
+    encryption, dataKey, err := fs.newObjectEncryption(opts.Encryption)
+    if err != nil {
+        return dto.ObjectInfo{}, err
+    }
+
+    output, err := fs.createTempFile("copy-")
+    if err != nil {
+        return dto.ObjectInfo{}, fmt.Errorf("impossible de créer le fichier cible : %v", err)
+    }
+    defer os.Remove(output.Name())
+    defer output.Close()
+
+    md5Hasher := md5.New()
+    segment, err := writeSegment(output, dataKey, func(w io.Writer) error {
+        _, err := io.Copy(w, io.TeeReader(input, md5Hasher))
+        return err
+    })
+    if err == nil {
+        err = syncAndClose(output)
+    }
+    if err != nil {
+        return dto.ObjectInfo{}, fmt.Errorf("erreur lors de la copie : %v", err)
+    }
+    if encryption != nil {
+        encryption.Segments = []encryptedSegment{segment}
+    }
+
+    // La copie est un objet envoyé en une fois : son ETag est le MD5 du contenu, même si la source
+    // était un upload multipart ("...-N"). Elle ne reprend pas le verrouillage de la source.
+    tags := source.Tags
+    if opts.Tags != nil {
+        tags = opts.Tags
+    }
+    eTag := `"` + hex.EncodeToString(md5Hasher.Sum(nil)) + `"`
+    meta := objectMetadata{Key: targetKey, ETag: eTag, Metadata: *metadata, Tags: copyTags(tags), Encryption: encryption}
+    return fs.commitObject(targetBucket, output.Name(), meta, dto.ObjectLock{}, false)
 }
 
-func (fs *FileStorage) CopyObject(sourceBucket, sourceKey, targetBucket, targetKey string) error {
-	sourcePath := filepath.Join(storageRoot, sourceBucket, sourceKey)
-	targetPath := filepath.Join(storageRoot, targetBucket, targetKey)
-
-	// Assurez-vous que le répertoire cible existe
-	if err := os.MkdirAll(filepath.Dir(targetPath), os.ModePerm); err != nil {
-		return fmt.Errorf("impossible de créer le répertoire cible : %v", err)
-	}
-
-	// Copier le fichier
-	input, err := os.Open(sourcePath)
-	if err != nil {
-		return fmt.Errorf("impossible d'ouvrir le fichier source : %v", err)
-	}
-	defer input.Close()
-
-	output, err := os.Create(targetPath)
-	if err != nil {
-		return fmt.Errorf("impossible de créer le fichier cible : %v", err)
-	}
-	defer output.Close()
-
-	if _, err := io.Copy(output, input); err != nil {
-		return fmt.Errorf("erreur lors de la copie : %v", err)
-	}
-
-	return nil
+// Déplacement de la version courante d'un objet vers une autre clé, éventuellement dans un autre bucket.
+// Si le bucket source n'est pas versionné, le fichier est simplement renommé : l'objet n'existe à aucun moment
+// sous les deux noms ni sous aucun. Sinon, les données sont recopiées telles quelles et la source reçoit
+// un marqueur de suppression. Dans les deux cas, le contenu (chiffré ou non), les métadonnées et la date sont conservés.
+func (fs *FileStorage) MoveObject(sourceBucket, sourceKey, targetBucket, targetKey string) (dto.ObjectInfo, error) {
+    if sourceBucket == targetBucket && sourceKey == targetKey {
+        return dto.ObjectInfo{}, ErrInvalidMove
+    }
+    if _, err := fs.resolveObjectPath(targetBucket, targetKey); err != nil {
+        return dto.ObjectInfo{}, err
+    }
+    for _, bucketName := range []string{sourceBucket, targetBucket} {
+        if exists, err := fs.CheckBucketExists(bucketName); err != nil {
+            return dto.ObjectInfo{}, err
+        } else if !exists {
+            return dto.ObjectInfo{}, ErrNoSuchBucket
+        }
+    }
+
+    // La source ne doit être ni remplacée ni supprimée entre sa lecture et son retrait
+    fs.commitMu.Lock()
+    defer fs.commitMu.Unlock()
+    sourcePath, source, err := fs.lockedCurrentRecord(sourceBucket, sourceKey)
+    if err != nil {
+        return dto.ObjectInfo{}, err
+    }
+    status, err := fs.versioningStatus(sourceBucket)
+    if err != nil {
+        return dto.ObjectInfo{}, err
+    }
+    meta := objectMetadata{Key: targetKey, ETag: source.ETag, Metadata: source.Metadata, Tags: source.Tags, Encryption: source.Encryption}
+
+    if status == "" {
+        info, err := fs.lockedCommitObject(targetBucket, sourcePath, meta, dto.ObjectLock{}, false)
+        if err != nil {
+            return dto.ObjectInfo{}, err
+        }
+        fs.removeObjectMetadata(sourceBucket, sourceKey)
+        fs.addUsage(sourceBucket, -source.Size, -1)
+        fs.pruneEmptyDirs(sourceBucket, sourcePath)
+        log.Printf("Renamed %s/%s to %s/%s", sourceBucket, sourceKey, targetBucket, targetKey)
+        return info, nil
+    }
+
+    // La version courante de la source reste dans son historique : ses données sont dupliquées
+    tmp, err := fs.createTempFile("move-")
+    if err != nil {
+        return dto.ObjectInfo{}, fmt.Errorf("failed to create temporary file: %v", err)
+    }
+    defer os.Remove(tmp.Name())
+    defer tmp.Close()
+    if err := appendFile(tmp, sourcePath); err != nil {
+        return dto.ObjectInfo{}, err
+    }
+    if err := syncAndClose(tmp); err != nil {
+        return dto.ObjectInfo{}, err
+    }
+    // Comme un renommage, le déplacement conserve la date de l'objet
+    if err := os.Chtimes(tmp.Name(), source.LastModified, source.LastModified); err != nil {
+        return dto.ObjectInfo{}, err
+    }
+    info, err := fs.lockedCommitObject(targetBucket, tmp.Name(), meta, dto.ObjectLock{}, false)
+    if err != nil {
+        return dto.ObjectInfo{}, err
+    }
+    if _, err := fs.putDeleteMarker(sourceBucket, sourceKey); err != nil {
+        return dto.ObjectInfo{}, err
+    }
+    fs.pruneEmptyDirs(sourceBucket, sourcePath)
+    log.Printf("Moved %s/%s to %s/%s", sourceBucket, sourceKey, targetBucket, targetKey)
+    return info, nil
 }
@@ -0,0 +1,1076 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"my-s3-clone/dto"
+)
+
+// MemoryStorage implémente l'interface Storage en mémoire, pour les tests et les déploiements éphémères.
+// Elle suit les mêmes règles que FileStorage (versioning, multipart, cycle de vie, Object Lock) et peut être
+// utilisée par plusieurs goroutines à la fois.
+type MemoryStorage struct {
+	mu      sync.RWMutex
+	buckets map[string]*memoryBucket
+}
+
+type memoryBucket struct {
+	objects  map[string]*memoryObject   // version courante de chaque clé
+	archived map[string][]*memoryObject // versions non courantes et marqueurs de suppression, du plus récent au plus ancien
+	uploads  map[string]*memoryUpload
+	meta     bucketMetadata
+}
+
+// memoryObject est une version d'un objet : ses métadonnées et son contenu, qui n'est jamais modifié une fois écrit
+type memoryObject struct {
+	meta objectMetadata
+	data []byte
+}
+
+type memoryUpload struct {
+	upload multipartUpload
+	parts  map[int]memoryPart
+}
+
+type memoryPart struct {
+	uploadedPart
+	data []byte
+}
+
+// memoryReader sert le contenu d'un objet sans le copier
+type memoryReader struct {
+	*bytes.Reader
+}
+
+func (memoryReader) Close() error {
+	return nil
+}
+
+// NewMemoryStorage crée un stockage en mémoire vide
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{buckets: make(map[string]*memoryBucket)}
+}
+
+// Bucket existant, à appeler avec le verrou pris
+func (m *MemoryStorage) bucket(bucketName string) (*memoryBucket, error) {
+	if err := ValidateBucketName(bucketName); err != nil {
+		return nil, err
+	}
+	b, ok := m.buckets[bucketName]
+	if !ok {
+		return nil, ErrNoSuchBucket
+	}
+	return b, nil
+}
+
+// Chiffrement annoncé d'une nouvelle version. Rien n'étant écrit sur disque, le contenu reste en clair ;
+// comme pour FileStorage, une version SSE-C ne peut être lue qu'avec la clé du client.
+func memoryEncryption(opts SSEOptions) (*objectEncryption, error) {
+	switch {
+	case opts.CustomerKey != nil:
+		if len(opts.CustomerKey) != sseKeySize {
+			return nil, ErrInvalidSSECustomerKey
+		}
+		return &objectEncryption{Mode: SSEModeCustomer, CustomerKeyMD5: CustomerKeyMD5(opts.CustomerKey)}, nil
+	case opts.S3:
+		return &objectEncryption{Mode: SSEModeS3}, nil
+	}
+	return nil, nil
+}
+
+func customerKeyMD5(encryption *objectEncryption) string {
+	if encryption == nil {
+		return ""
+	}
+	return encryption.CustomerKeyMD5
+}
+
+// Lecture du contenu d'un upload, hors verrou : MD5, x-amz-content-sha256 et signatures des chunks sont vérifiés
+func readMemoryObject(data io.Reader, opts PutObjectOptions) ([]byte, writtenObject, error) {
+	var buf bytes.Buffer
+	written, err := writeObjectToFile(data, &buf, opts)
+	if err != nil {
+		return nil, writtenObject{}, err
+	}
+	return buf.Bytes(), written, nil
+}
+
+func (m *MemoryStorage) AddObject(bucketName, objectName string, data io.Reader, opts PutObjectOptions) (dto.ObjectInfo, error) {
+	if err := ValidateBucketName(bucketName); err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	if err := ValidateObjectName(objectName); err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	if exists, _ := m.CheckBucketExists(bucketName); !exists {
+		return dto.ObjectInfo{}, ErrNoSuchBucket
+	}
+	if err := validateTags(opts.Tags); err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	encryption, err := memoryEncryption(opts.Encryption)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+
+	content, written, err := readMemoryObject(data, opts)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, err := m.bucket(bucketName)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	if opts.IfNoneMatch && b.objects[objectName] != nil {
+		return dto.ObjectInfo{}, fmt.Errorf("%w: %s", ErrPreconditionFailed, objectName)
+	}
+	meta := objectMetadata{Key: objectName, ETag: written.ETag, Metadata: opts.Metadata, Tags: copyTags(opts.Tags), Encryption: encryption}
+	return b.commit(meta, content, opts.Lock)
+}
+
+// Mise en place d'une nouvelle version courante, comme commitObject pour FileStorage
+func (b *memoryBucket) commit(meta objectMetadata, data []byte, lock dto.ObjectLock) (dto.ObjectInfo, error) {
+	now := time.Now()
+	lock, err := lockNewVersion(b.meta.ObjectLock, lock, now)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	if err := b.checkQuota(meta.Key, int64(len(data))); err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	versionID, err := b.prepareNewVersion(meta.Key)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+
+	meta.Size, meta.LastModified, meta.VersionID = int64(len(data)), now, versionID
+	meta.setLock(lock)
+	b.objects[meta.Key] = &memoryObject{meta: meta, data: data}
+	return meta.info(), nil
+}
+
+// Archivage ou remplacement de la version courante selon le statut de versioning, comme prepareNewVersion
+func (b *memoryBucket) prepareNewVersion(objectName string) (string, error) {
+	current := b.objects[objectName]
+	switch b.meta.Versioning {
+	case VersioningEnabled:
+		if current != nil {
+			b.archive(current)
+		}
+		return newVersionID()
+	case VersioningSuspended:
+		b.removeArchived(objectName, nullVersionID)
+		if current != nil && exposedVersionID(current.meta.info()) != nullVersionID {
+			b.archive(current)
+		}
+		delete(b.objects, objectName)
+		return nullVersionID, nil
+	}
+	return "", nil
+}
+
+func (b *memoryBucket) archive(current *memoryObject) {
+	archived := *current
+	archived.meta.VersionID = exposedVersionID(current.meta.info())
+	b.archived[archived.meta.Key] = append([]*memoryObject{&archived}, b.archived[archived.meta.Key]...)
+	delete(b.objects, archived.meta.Key)
+}
+
+func (b *memoryBucket) removeArchived(objectName, versionID string) {
+	versions := b.archived[objectName]
+	for i, version := range versions {
+		if version.meta.VersionID == versionID {
+			versions = append(versions[:i:i], versions[i+1:]...)
+			break
+		}
+	}
+	if len(versions) == 0 {
+		delete(b.archived, objectName)
+		return
+	}
+	b.archived[objectName] = versions
+}
+
+// Version demandée d'une clé ("" pour la version courante), comme versionRecord pour FileStorage
+func (b *memoryBucket) version(objectName, versionID string) (*memoryObject, error) {
+	current := b.objects[objectName]
+	if versionID == "" {
+		if current == nil {
+			return nil, os.ErrNotExist
+		}
+		return current, nil
+	}
+	if err := validateVersionID(versionID); err != nil {
+		return nil, err
+	}
+	if current != nil && exposedVersionID(current.meta.info()) == versionID {
+		version := *current
+		version.meta.VersionID = versionID
+		return &version, nil
+	}
+
+	for _, version := range b.archived[objectName] {
+		if version.meta.VersionID != versionID {
+			continue
+		}
+		if version.meta.IsDeleteMarker {
+			return version, ErrDeleteMarker
+		}
+		return version, nil
+	}
+	return nil, ErrNoSuchVersion
+}
+
+func (m *MemoryStorage) DeleteObject(bucketName, objectName string, opts DeleteObjectOptions) (dto.ObjectInfo, error) {
+	if err := ValidateObjectName(objectName); err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, err := m.bucket(bucketName)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+
+	if opts.VersionID != "" {
+		return b.deleteVersion(objectName, opts.VersionID, opts.BypassGovernance)
+	}
+
+	return b.deleteCurrent(objectName)
+}
+
+// Suppression de la version courante : marqueur de suppression dans un bucket versionné
+func (b *memoryBucket) deleteCurrent(objectName string) (dto.ObjectInfo, error) {
+	if b.meta.Versioning != "" {
+		versionID, err := b.prepareNewVersion(objectName)
+		if err != nil {
+			return dto.ObjectInfo{}, err
+		}
+		marker := &memoryObject{meta: objectMetadata{
+			Key:            objectName,
+			LastModified:   time.Now(),
+			VersionID:      versionID,
+			IsDeleteMarker: true,
+		}}
+		b.archived[objectName] = append([]*memoryObject{marker}, b.archived[objectName]...)
+		return marker.meta.info(), nil
+	}
+
+	if b.objects[objectName] == nil {
+		return dto.ObjectInfo{}, fmt.Errorf("object not found: %w", os.ErrNotExist)
+	}
+	delete(b.objects, objectName)
+	return dto.ObjectInfo{Key: objectName}, nil
+}
+
+// Suppression définitive d'une version, la précédente redevenant courante, comme deleteObjectVersion
+func (b *memoryBucket) deleteVersion(objectName, versionID string, bypassGovernance bool) (dto.ObjectInfo, error) {
+	if err := validateVersionID(versionID); err != nil {
+		return dto.ObjectInfo{}, err
+	}
+
+	var target *memoryObject
+	current := b.objects[objectName]
+	isCurrent := current != nil && exposedVersionID(current.meta.info()) == versionID
+	if isCurrent {
+		target = current
+	} else {
+		for _, version := range b.archived[objectName] {
+			if version.meta.VersionID == versionID {
+				target = version
+				break
+			}
+		}
+	}
+	if target == nil {
+		return dto.ObjectInfo{}, ErrNoSuchVersion
+	}
+
+	info := target.meta.info()
+	if err := checkObjectLock(info.Lock, bypassGovernance, time.Now()); err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	if isCurrent {
+		delete(b.objects, objectName)
+	} else {
+		b.removeArchived(objectName, versionID)
+	}
+
+	// La plus récente des versions restantes redevient courante, sauf s'il s'agit d'un marqueur de suppression
+	if versions := b.archived[objectName]; b.objects[objectName] == nil && len(versions) > 0 && !versions[0].meta.IsDeleteMarker {
+		b.objects[objectName] = versions[0]
+		b.removeArchived(objectName, versions[0].meta.VersionID)
+	}
+
+	info.VersionID = versionID
+	return info, nil
+}
+
+func (m *MemoryStorage) DeleteBucket(bucketName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, err := m.bucket(bucketName)
+	if err != nil {
+		return err
+	}
+	// Comme pour FileStorage, les versions non courantes et les marqueurs de suppression comptent
+	if len(b.objects) > 0 || len(b.archived) > 0 {
+		return ErrBucketNotEmpty
+	}
+	delete(m.buckets, bucketName)
+	return nil
+}
+
+func (m *MemoryStorage) GetObject(bucketName, objectName, versionID string, customerKey []byte) (io.ReadSeekCloser, dto.ObjectInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	b, err := m.bucket(bucketName)
+	if err != nil {
+		return nil, dto.ObjectInfo{}, err
+	}
+	version, err := b.version(objectName, versionID)
+	if err != nil {
+		if version != nil {
+			return nil, version.meta.info(), err
+		}
+		return nil, dto.ObjectInfo{}, err
+	}
+	if err := CheckCustomerKey(customerKeyMD5(version.meta.Encryption), customerKey); err != nil {
+		return nil, version.meta.info(), err
+	}
+	return memoryReader{bytes.NewReader(version.data)}, version.meta.info(), nil
+}
+
+func (m *MemoryStorage) StatObject(bucketName, objectName, versionID string) (dto.ObjectInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	b, err := m.bucket(bucketName)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	version, err := b.version(objectName, versionID)
+	if version == nil {
+		return dto.ObjectInfo{}, err
+	}
+	return version.meta.info(), err
+}
+
+func (m *MemoryStorage) CheckBucketExists(bucketName string) (bool, error) {
+	if err := ValidateBucketName(bucketName); err != nil {
+		return false, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.buckets[bucketName]
+	return ok, nil
+}
+
+func (m *MemoryStorage) ListBuckets() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	buckets := make([]string, 0, len(m.buckets))
+	for name := range m.buckets {
+		buckets = append(buckets, name)
+	}
+	sort.Strings(buckets)
+	return buckets
+}
+
+func (m *MemoryStorage) ListObjects(bucketName string, opts ListObjectsOptions) (dto.ObjectListing, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	b, err := m.bucket(bucketName)
+	if err != nil {
+		return dto.ObjectListing{Objects: make([]dto.Object, 0)}, err
+	}
+
+	keys := make([]string, 0, len(b.objects))
+	for key := range b.objects {
+		if strings.HasPrefix(key, opts.Prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	keys, _ = keysWithTags(keys, opts.Tags, func(key string) (map[string]string, error) {
+		return b.objects[key].meta.Tags, nil
+	})
+
+	return listObjectsPage(keys, opts, func(key string) (dto.Object, error) {
+		meta := b.objects[key].meta
+		return dto.Object{Key: key, LastModified: meta.LastModified, ETag: meta.ETag, Size: int(meta.Size)}, nil
+	})
+}
+
+func (m *MemoryStorage) CreateBucket(bucketName string, opts CreateBucketOptions) error {
+	if err := ValidateBucketName(bucketName); err != nil {
+		return err
+	}
+	meta, err := newBucketMetadata(opts)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.buckets[bucketName]; ok {
+		return ErrBucketAlreadyExists
+	}
+	m.buckets[bucketName] = &memoryBucket{
+		objects:  make(map[string]*memoryObject),
+		archived: make(map[string][]*memoryObject),
+		uploads:  make(map[string]*memoryUpload),
+		meta:     meta,
+	}
+	return nil
+}
+
+func (m *MemoryStorage) GetBucketInfo(bucketName string) (dto.BucketInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	b, err := m.bucket(bucketName)
+	if err != nil {
+		return dto.BucketInfo{}, err
+	}
+	return b.meta.info(bucketName), nil
+}
+
+func (m *MemoryStorage) CopyObject(sourceBucket, sourceKey, targetBucket, targetKey string, opts CopyObjectOptions) (dto.ObjectInfo, error) {
+	if err := ValidateObjectName(targetKey); err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	if err := validateTags(opts.Tags); err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	encryption, err := memoryEncryption(opts.Encryption)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	target, err := m.bucket(targetBucket)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	source, err := m.bucket(sourceBucket)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	version, err := source.version(sourceKey, opts.SourceVersionID)
+	if err != nil {
+		if version != nil {
+			return version.meta.info(), err
+		}
+		return dto.ObjectInfo{}, err
+	}
+	if err := CheckCustomerKey(customerKeyMD5(version.meta.Encryption), opts.SourceCustomerKey); err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	if opts.SourceCondition != nil {
+		if err := opts.SourceCondition(version.meta.info()); err != nil {
+			return dto.ObjectInfo{}, err
+		}
+	}
+
+	metadata := version.meta.Metadata
+	if opts.Metadata != nil {
+		metadata = *opts.Metadata
+	}
+	// Le contenu est partagé : une version n'est jamais modifiée. L'ETag est celui d'un objet envoyé en une fois.
+	tags := version.meta.Tags
+	if opts.Tags != nil {
+		tags = opts.Tags
+	}
+	sum := md5.Sum(version.data)
+	meta := objectMetadata{Key: targetKey, ETag: `"` + hex.EncodeToString(sum[:]) + `"`, Metadata: metadata, Tags: copyTags(tags), Encryption: encryption}
+	return target.commit(meta, version.data, dto.ObjectLock{})
+}
+
+// Déplacement de la version courante d'un objet, atomique sous le verrou comme le renommage de FileStorage
+func (m *MemoryStorage) MoveObject(sourceBucket, sourceKey, targetBucket, targetKey string) (dto.ObjectInfo, error) {
+	if sourceBucket == targetBucket && sourceKey == targetKey {
+		return dto.ObjectInfo{}, ErrInvalidMove
+	}
+	if err := ValidateObjectName(targetKey); err != nil {
+		return dto.ObjectInfo{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	source, err := m.bucket(sourceBucket)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	target, err := m.bucket(targetBucket)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	current := source.objects[sourceKey]
+	if current == nil {
+		return dto.ObjectInfo{}, fmt.Errorf("object not found: %w", os.ErrNotExist)
+	}
+
+	meta := objectMetadata{Key: targetKey, ETag: current.meta.ETag, Metadata: current.meta.Metadata, Tags: current.meta.Tags, Encryption: current.meta.Encryption}
+	info, err := target.commit(meta, current.data, dto.ObjectLock{})
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	// Le déplacement conserve la date de l'objet
+	target.objects[targetKey].meta.LastModified = current.meta.LastModified
+	info.LastModified = current.meta.LastModified
+
+	if _, err := source.deleteCurrent(sourceKey); err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	return info, nil
+}
+
+func (m *MemoryStorage) CreateMultipartUpload(bucketName, objectName string, opts MultipartUploadOptions) (string, error) {
+	if err := ValidateObjectName(objectName); err != nil {
+		return "", err
+	}
+	if err := validateTags(opts.Tags); err != nil {
+		return "", err
+	}
+	uploadEncryption, err := memoryEncryption(opts.Encryption)
+	if err != nil {
+		return "", err
+	}
+	uploadID, err := newUploadID()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate upload id: %v", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, err := m.bucket(bucketName)
+	if err != nil {
+		return "", err
+	}
+	if _, err := lockNewVersion(b.meta.ObjectLock, opts.Lock, time.Now()); err != nil {
+		return "", err
+	}
+	b.uploads[uploadID] = &memoryUpload{
+		upload: multipartUpload{
+			Bucket:     bucketName,
+			Key:        objectName,
+			UploadID:   uploadID,
+			Initiated:  time.Now().UTC(),
+			Metadata:   opts.Metadata,
+			Encryption: uploadEncryption,
+			Lock:       uploadLock(opts.Lock),
+			Tags:       copyTags(opts.Tags),
+		},
+		parts: make(map[int]memoryPart),
+	}
+	return uploadID, nil
+}
+
+// Upload en cours d'une clé, à appeler avec le verrou pris
+func (m *MemoryStorage) multipartUpload(bucketName, objectName, uploadID string) (*memoryBucket, *memoryUpload, error) {
+	if err := ValidateBucketName(bucketName); err != nil {
+		return nil, nil, err
+	}
+	b, ok := m.buckets[bucketName]
+	if !ok {
+		return nil, nil, ErrNoSuchUpload
+	}
+	upload, ok := b.uploads[uploadID]
+	if !ok || upload.upload.Key != objectName {
+		return nil, nil, ErrNoSuchUpload
+	}
+	return b, upload, nil
+}
+
+func (m *MemoryStorage) UploadPart(bucketName, objectName, uploadID string, partNumber int, data io.Reader, opts PutObjectOptions) (string, error) {
+	if partNumber < 1 || partNumber > maxPartNumber {
+		return "", ErrInvalidPartNumber
+	}
+
+	m.mu.RLock()
+	_, upload, err := m.multipartUpload(bucketName, objectName, uploadID)
+	if err == nil {
+		// Toutes les parts d'un upload SSE-C sont envoyées avec la clé du client
+		err = CheckCustomerKey(customerKeyMD5(upload.upload.Encryption), opts.Encryption.CustomerKey)
+	}
+	m.mu.RUnlock()
+	if err != nil {
+		return "", err
+	}
+
+	content, written, err := readMemoryObject(data, opts)
+	if err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// L'upload a pu être finalisé ou abandonné pendant l'envoi
+	if _, upload, err = m.multipartUpload(bucketName, objectName, uploadID); err != nil {
+		return "", err
+	}
+	upload.parts[partNumber] = memoryPart{
+		uploadedPart: uploadedPart{Part: dto.Part{
+			PartNumber:   partNumber,
+			LastModified: time.Now().UTC(),
+			ETag:         written.ETag,
+			Size:         written.Size,
+		}},
+		data: content,
+	}
+	return written.ETag, nil
+}
+
+// Parts envoyées, triées par numéro
+func (u *memoryUpload) sortedParts() []uploadedPart {
+	parts := make([]uploadedPart, 0, len(u.parts))
+	for _, part := range u.parts {
+		parts = append(parts, part.uploadedPart)
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].PartNumber < parts[j].PartNumber })
+	return parts
+}
+
+func (m *MemoryStorage) ListParts(bucketName, objectName, uploadID string, partNumberMarker, maxParts int) (dto.ListPartsResult, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, upload, err := m.multipartUpload(bucketName, objectName, uploadID)
+	if err != nil {
+		return dto.ListPartsResult{}, err
+	}
+	return listPartsPage(bucketName, objectName, uploadID, upload.sortedParts(), partNumberMarker, maxParts), nil
+}
+
+func (m *MemoryStorage) CompleteMultipartUpload(bucketName, objectName, uploadID string, parts []dto.CompletedPart) (dto.ObjectInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, upload, err := m.multipartUpload(bucketName, objectName, uploadID)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+
+	selected, eTag, err := selectCompletedParts(parts, upload.sortedParts())
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	var content bytes.Buffer
+	for _, part := range selected {
+		content.Write(upload.parts[part.PartNumber].data)
+	}
+
+	meta := objectMetadata{Key: objectName, ETag: eTag, Metadata: upload.upload.Metadata, Tags: copyTags(upload.upload.Tags), Encryption: upload.upload.Encryption}
+	info, err := b.commit(meta, content.Bytes(), upload.upload.lock())
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	delete(b.uploads, uploadID)
+	return info, nil
+}
+
+func (m *MemoryStorage) AbortMultipartUpload(bucketName, objectName, uploadID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, _, err := m.multipartUpload(bucketName, objectName, uploadID)
+	if err != nil {
+		return err
+	}
+	delete(b.uploads, uploadID)
+	return nil
+}
+
+func (m *MemoryStorage) ListMultipartUploads(bucketName, prefix, keyMarker, uploadIDMarker string, maxUploads int) (dto.ListMultipartUploadsResult, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	b, err := m.bucket(bucketName)
+	if err != nil {
+		return dto.ListMultipartUploadsResult{}, err
+	}
+
+	uploads := make([]multipartUpload, 0, len(b.uploads))
+	for _, upload := range b.uploads {
+		uploads = append(uploads, upload.upload)
+	}
+	return listUploadsPage(bucketName, uploads, prefix, keyMarker, uploadIDMarker, maxUploads), nil
+}
+
+func (m *MemoryStorage) GetBucketVersioning(bucketName string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	b, err := m.bucket(bucketName)
+	if err != nil {
+		return "", err
+	}
+	return b.meta.Versioning, nil
+}
+
+func (m *MemoryStorage) SetBucketVersioning(bucketName, status string) error {
+	if status != VersioningEnabled && status != VersioningSuspended {
+		return ErrInvalidVersioning
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, err := m.bucket(bucketName)
+	if err != nil {
+		return err
+	}
+	if status == VersioningSuspended && b.meta.ObjectLock != nil {
+		return fmt.Errorf("%w: versioning cannot be suspended on a bucket with object lock enabled", ErrInvalidBucketState)
+	}
+	b.meta.Versioning = status
+	return nil
+}
+
+func (m *MemoryStorage) ListObjectVersions(bucketName string, opts ListObjectVersionsOptions) (dto.ObjectVersionListing, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	b, err := m.bucket(bucketName)
+	if err != nil {
+		return dto.ObjectVersionListing{Versions: make([]dto.ObjectVersion, 0)}, err
+	}
+
+	keys := make([]string, 0, len(b.objects)+len(b.archived))
+	for key := range b.objects {
+		if strings.HasPrefix(key, opts.Prefix) {
+			keys = append(keys, key)
+		}
+	}
+	for key := range b.archived {
+		if _, current := b.objects[key]; !current && strings.HasPrefix(key, opts.Prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	return listVersionsPage(keys, opts, func(key string) []objectMetadata {
+		versions := make([]objectMetadata, 0, len(b.archived[key])+1)
+		if current, ok := b.objects[key]; ok {
+			meta := current.meta
+			meta.VersionID = exposedVersionID(meta.info())
+			versions = append(versions, meta)
+		}
+		for _, version := range b.archived[key] {
+			versions = append(versions, version.meta)
+		}
+		return versions
+	}), nil
+}
+
+func (m *MemoryStorage) GetBucketLifecycle(bucketName string) (dto.LifecycleConfiguration, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	b, err := m.bucket(bucketName)
+	if err != nil {
+		return dto.LifecycleConfiguration{}, err
+	}
+	if b.meta.Lifecycle == nil {
+		return dto.LifecycleConfiguration{}, ErrNoSuchLifecycle
+	}
+	return *b.meta.Lifecycle, nil
+}
+
+func (m *MemoryStorage) PutBucketLifecycle(bucketName string, config dto.LifecycleConfiguration) error {
+	if err := validateLifecycle(config); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, err := m.bucket(bucketName)
+	if err != nil {
+		return err
+	}
+	b.meta.Lifecycle = &config
+	return nil
+}
+
+func (m *MemoryStorage) DeleteBucketLifecycle(bucketName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, err := m.bucket(bucketName)
+	if err != nil {
+		return err
+	}
+	b.meta.Lifecycle = nil
+	return nil
+}
+
+func (m *MemoryStorage) GetBucketCors(bucketName string) (dto.CORSConfiguration, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	b, err := m.bucket(bucketName)
+	if err != nil {
+		return dto.CORSConfiguration{}, err
+	}
+	if b.meta.CORS == nil {
+		return dto.CORSConfiguration{}, ErrNoSuchCORS
+	}
+	return *b.meta.CORS, nil
+}
+
+func (m *MemoryStorage) PutBucketCors(bucketName string, config dto.CORSConfiguration) error {
+	if err := validateCORS(config); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, err := m.bucket(bucketName)
+	if err != nil {
+		return err
+	}
+	b.meta.CORS = &config
+	return nil
+}
+
+func (m *MemoryStorage) DeleteBucketCors(bucketName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, err := m.bucket(bucketName)
+	if err != nil {
+		return err
+	}
+	b.meta.CORS = nil
+	return nil
+}
+
+func (m *MemoryStorage) GetBucketPolicy(bucketName string) (dto.BucketPolicy, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	b, err := m.bucket(bucketName)
+	if err != nil {
+		return dto.BucketPolicy{}, err
+	}
+	if b.meta.Policy == nil {
+		return dto.BucketPolicy{}, ErrNoSuchBucketPolicy
+	}
+	return *b.meta.Policy, nil
+}
+
+func (m *MemoryStorage) PutBucketPolicy(bucketName string, policy dto.BucketPolicy) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, err := m.bucket(bucketName)
+	if err != nil {
+		return err
+	}
+	b.meta.Policy = &policy
+	return nil
+}
+
+func (m *MemoryStorage) DeleteBucketPolicy(bucketName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, err := m.bucket(bucketName)
+	if err != nil {
+		return err
+	}
+	b.meta.Policy = nil
+	return nil
+}
+
+func (m *MemoryStorage) GetBucketNotification(bucketName string) (dto.NotificationConfiguration, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	b, err := m.bucket(bucketName)
+	if err != nil {
+		return dto.NotificationConfiguration{}, err
+	}
+	if b.meta.Notification == nil {
+		return dto.NotificationConfiguration{}, nil
+	}
+	return *b.meta.Notification, nil
+}
+
+func (m *MemoryStorage) PutBucketNotification(bucketName string, config dto.NotificationConfiguration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, err := m.bucket(bucketName)
+	if err != nil {
+		return err
+	}
+	b.meta.Notification = notificationOrNil(config)
+	return nil
+}
+
+func (m *MemoryStorage) GetObjectLockConfiguration(bucketName string) (dto.ObjectLockConfiguration, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	b, err := m.bucket(bucketName)
+	if err != nil {
+		return dto.ObjectLockConfiguration{}, err
+	}
+	if b.meta.ObjectLock == nil {
+		return dto.ObjectLockConfiguration{}, ErrObjectLockNotEnabled
+	}
+	return *b.meta.ObjectLock, nil
+}
+
+func (m *MemoryStorage) PutObjectLockConfiguration(bucketName string, config dto.ObjectLockConfiguration) error {
+	if err := validateObjectLockConfig(config); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, err := m.bucket(bucketName)
+	if err != nil {
+		return err
+	}
+	if b.meta.Versioning != VersioningEnabled {
+		return fmt.Errorf("%w: versioning must be enabled to use object lock", ErrInvalidBucketState)
+	}
+	b.meta.ObjectLock = &config
+	return nil
+}
+
+func (m *MemoryStorage) PutObjectRetention(bucketName, objectName, versionID, mode string, retainUntil time.Time, bypassGovernance bool) (dto.ObjectInfo, error) {
+	now := time.Now()
+	if err := validateRetention(mode, retainUntil, now); err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	return m.updateObjectLock(bucketName, objectName, versionID, retentionUpdate(mode, retainUntil, bypassGovernance, now))
+}
+
+func (m *MemoryStorage) PutObjectLegalHold(bucketName, objectName, versionID string, on bool) (dto.ObjectInfo, error) {
+	return m.updateObjectLock(bucketName, objectName, versionID, func(lock *dto.ObjectLock) error {
+		lock.LegalHold = on
+		return nil
+	})
+}
+
+// Modification du verrouillage d'une version, dans un bucket où Object Lock est activé
+func (m *MemoryStorage) updateObjectLock(bucketName, objectName, versionID string, update func(*dto.ObjectLock) error) (dto.ObjectInfo, error) {
+	if _, err := m.GetObjectLockConfiguration(bucketName); err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	return m.updateVersion(bucketName, objectName, versionID, func(meta *objectMetadata) error {
+		lock := meta.info().Lock
+		if err := update(&lock); err != nil {
+			return err
+		}
+		meta.setLock(lock)
+		return nil
+	})
+}
+
+func (m *MemoryStorage) PutObjectTagging(bucketName, objectName, versionID string, tags map[string]string) (dto.ObjectInfo, error) {
+	if err := validateTags(tags); err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	return m.updateVersion(bucketName, objectName, versionID, func(meta *objectMetadata) error {
+		meta.Tags = copyTags(tags)
+		return nil
+	})
+}
+
+// Modification des métadonnées de la version courante ou d'une version archivée, comme updateVersion pour FileStorage
+func (m *MemoryStorage) updateVersion(bucketName, objectName, versionID string, update func(*objectMetadata) error) (dto.ObjectInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, err := m.bucket(bucketName)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+
+	// b.version renvoie une copie pour la version courante désignée par son identifiant
+	target := b.objects[objectName]
+	if target == nil || (versionID != "" && exposedVersionID(target.meta.info()) != versionID) {
+		if target, err = b.version(objectName, versionID); err != nil {
+			if target != nil {
+				return target.meta.info(), err
+			}
+			return dto.ObjectInfo{}, err
+		}
+	}
+
+	// La version n'est modifiée que si la mise à jour réussit
+	meta := target.meta
+	if err := update(&meta); err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	target.meta = meta
+	return meta.info(), nil
+}
+
+func (m *MemoryStorage) GetBucketUsage(bucketName string) (dto.BucketUsage, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	b, err := m.bucket(bucketName)
+	if err != nil {
+		return dto.BucketUsage{}, err
+	}
+	return b.usage().info(bucketName, b.meta.Quota), nil
+}
+
+func (m *MemoryStorage) PutBucketQuota(bucketName string, quota dto.BucketQuota) error {
+	if err := validateQuota(quota); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, err := m.bucket(bucketName)
+	if err != nil {
+		return err
+	}
+	b.meta.Quota = &quota
+	return nil
+}
+
+func (m *MemoryStorage) DeleteBucketQuota(bucketName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, err := m.bucket(bucketName)
+	if err != nil {
+		return err
+	}
+	b.meta.Quota = nil
+	return nil
+}
+
+// Occupation du bucket, calculée à la demande comme computeUsage : versions courantes et archivées,
+// hors marqueurs de suppression
+func (b *memoryBucket) usage() bucketUsage {
+	var usage bucketUsage
+	for _, current := range b.objects {
+		usage.Bytes += current.meta.Size
+		usage.Objects++
+	}
+	for _, versions := range b.archived {
+		for _, version := range versions {
+			if !version.meta.IsDeleteMarker {
+				usage.Bytes += version.meta.Size
+				usage.Objects++
+			}
+		}
+	}
+	return usage
+}
+
+// Vérification du quota avant une nouvelle version de size octets, la version "null" remplacée
+// étant déduite comme dans replacedUsage
+func (b *memoryBucket) checkQuota(objectName string, size int64) error {
+	if b.meta.Quota == nil {
+		return nil
+	}
+	bytes, objects := size, int64(1)
+	if current := b.objects[objectName]; current != nil && b.meta.Versioning != VersioningEnabled &&
+		exposedVersionID(current.meta.info()) == nullVersionID {
+		bytes, objects = bytes-current.meta.Size, objects-1
+	}
+	if b.meta.Versioning == VersioningSuspended {
+		for _, version := range b.archived[objectName] {
+			if version.meta.VersionID == nullVersionID && !version.meta.IsDeleteMarker {
+				bytes, objects = bytes-version.meta.Size, objects-1
+			}
+		}
+	}
+	return checkQuota(b.meta.Quota, b.usage(), bytes, objects)
+}
@@ -0,0 +1,196 @@
+package storage
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"my-s3-clone/dto"
+)
+
+// objectMetadata est l'état persisté d'un objet, à côté de ses données (.s3clone/meta)
+type objectMetadata struct {
+	Key          string             `json:"key"`
+	ETag         string             `json:"etag"`
+	Size         int64              `json:"size"`
+	LastModified time.Time          `json:"lastModified"`
+	Metadata     dto.ObjectMetadata `json:"metadata"`
+
+	// Versioning : "" pour un objet écrit dans un bucket jamais versionné
+	VersionID      string `json:"versionId,omitempty"`
+	IsDeleteMarker bool   `json:"deleteMarker,omitempty"`
+
+	Tags map[string]string `json:"tags,omitempty"`
+
+	// Object Lock, absent pour une version sans rétention ni conservation légale
+	Lock *dto.ObjectLock `json:"lock,omitempty"`
+
+	// Chiffrement côté serveur, absent pour une version stockée en clair
+	Encryption *objectEncryption `json:"encryption,omitempty"`
+}
+
+// Répertoire des métadonnées des objets d'un bucket
+func (fs *FileStorage) bucketMetadataDir(bucketName string) string {
+	return filepath.Join(fs.root(), systemDir, "meta", bucketName)
+}
+
+// Les clés sont hachées pour ne pas dépendre des caractères autorisés par le système de fichiers
+func (fs *FileStorage) objectMetadataPath(bucketName, objectName string) string {
+	sum := sha256.Sum256([]byte(objectName))
+	return filepath.Join(fs.bucketMetadataDir(bucketName), hex.EncodeToString(sum[:])+".json")
+}
+
+func (m objectMetadata) info() dto.ObjectInfo {
+	info := dto.ObjectInfo{
+		Key:          m.Key,
+		Size:         m.Size,
+		LastModified: m.LastModified,
+		ETag:         m.ETag,
+		Metadata:     m.Metadata,
+
+		VersionID:      m.VersionID,
+		IsDeleteMarker: m.IsDeleteMarker,
+		Tags:           m.Tags,
+	}
+	if m.Lock != nil {
+		info.Lock = *m.Lock
+	}
+	if m.Encryption != nil {
+		info.Encryption, info.SSECustomerKeyMD5 = m.Encryption.Mode, m.Encryption.CustomerKeyMD5
+	}
+	return info
+}
+
+func (m *objectMetadata) setLock(lock dto.ObjectLock) {
+	m.Lock = nil
+	if !lock.IsZero() {
+		m.Lock = &lock
+	}
+}
+
+// Taille des données sur le disque, supérieure à la taille de l'objet s'il est chiffré
+func (m objectMetadata) storedSize() int64 {
+	if m.Encryption != nil {
+		return m.Encryption.storedSize()
+	}
+	return m.Size
+}
+
+// Enregistrement des métadonnées d'un objet qui vient d'être écrit dans objectPath.
+// La taille et la date de modification sont relues sur le fichier.
+func (fs *FileStorage) saveObjectMetadata(bucketName, objectPath string, meta objectMetadata) (objectMetadata, error) {
+	fileInfo, err := os.Stat(objectPath)
+	if err != nil {
+		return meta, fmt.Errorf("failed to stat object: %v", err)
+	}
+
+	meta.Size = fileInfo.Size()
+	if meta.Encryption != nil {
+		if meta.Encryption.storedSize() != fileInfo.Size() {
+			return meta, fmt.Errorf("encrypted object has %d bytes, expected %d", fileInfo.Size(), meta.Encryption.storedSize())
+		}
+		meta.Size = meta.Encryption.size()
+	}
+	meta.LastModified = fileInfo.ModTime()
+	if err := os.MkdirAll(fs.bucketMetadataDir(bucketName), os.ModePerm); err != nil {
+		return meta, fmt.Errorf("failed to create metadata directory: %v", err)
+	}
+	if err := writeJSONFile(fs.objectMetadataPath(bucketName, meta.Key), meta); err != nil {
+		return meta, err
+	}
+	return meta, nil
+}
+
+// Lecture des métadonnées d'un objet sans commitMu. Si elles ne correspondent pas au fichier, une écriture peut être
+// en train de les remplacer (fichier déjà renommé, métadonnées pas encore enregistrées) : elles ne sont réparées
+// qu'une fois l'écriture terminée, sous commitMu, si le fichier relu ne leur correspond toujours pas.
+func (fs *FileStorage) loadObjectMetadata(bucketName, objectName, objectPath string, fileInfo os.FileInfo) (objectMetadata, error) {
+	if meta, ok := fs.storedObjectMetadata(bucketName, objectName, fileInfo); ok {
+		return meta, nil
+	}
+
+	fs.commitMu.Lock()
+	defer fs.commitMu.Unlock()
+	fileInfo, err := os.Stat(objectPath)
+	if err != nil {
+		return objectMetadata{}, err
+	}
+	if fileInfo.IsDir() {
+		return objectMetadata{}, os.ErrNotExist
+	}
+	return fs.repairObjectMetadata(bucketName, objectName, objectPath, fileInfo)
+}
+
+// Lecture des métadonnées d'un objet, à appeler avec commitMu pris. Si elles sont absentes ou ne correspondent plus
+// au fichier (objet déposé directement sur le disque), l'ETag est recalculé puis enregistré en conservant les en-têtes connus.
+// Les métadonnées d'un objet chiffré ne sont jamais recalculées : sans elles, sa clé de données serait perdue.
+func (fs *FileStorage) repairObjectMetadata(bucketName, objectName, objectPath string, fileInfo os.FileInfo) (objectMetadata, error) {
+	var meta objectMetadata
+	err := readJSONFile(fs.objectMetadataPath(bucketName, objectName), &meta)
+	if err == nil && meta.matches(fileInfo) {
+		return meta, nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("Unreadable metadata for %s in bucket %s, recomputing: %v", objectName, bucketName, err)
+	}
+	if err == nil && meta.Encryption != nil {
+		log.Printf("Encrypted object %s in bucket %s does not match its metadata, keeping them", objectName, bucketName)
+		return meta, nil
+	}
+
+	eTag, err := fileETag(objectPath)
+	if err != nil {
+		return objectMetadata{}, err
+	}
+	meta.Key, meta.ETag = objectName, eTag
+	return fs.saveObjectMetadata(bucketName, objectPath, meta)
+}
+
+// Métadonnées enregistrées d'un objet, sans verrou ni réparation ; ok est faux si elles sont illisibles
+// ou ne correspondent pas au fichier
+func (fs *FileStorage) storedObjectMetadata(bucketName, objectName string, fileInfo os.FileInfo) (meta objectMetadata, ok bool) {
+	err := readJSONFile(fs.objectMetadataPath(bucketName, objectName), &meta)
+	return meta, err == nil && meta.matches(fileInfo)
+}
+
+// Des métadonnées correspondent au fichier qui porte la même taille et la même date de modification
+func (m objectMetadata) matches(fileInfo os.FileInfo) bool {
+	return m.storedSize() == fileInfo.Size() && m.LastModified.Equal(fileInfo.ModTime())
+}
+
+// Suppression des métadonnées d'un objet
+func (fs *FileStorage) removeObjectMetadata(bucketName, objectName string) {
+	if err := os.Remove(fs.objectMetadataPath(bucketName, objectName)); err != nil && !os.IsNotExist(err) {
+		log.Printf("Failed to remove metadata of %s in bucket %s: %v", objectName, bucketName, err)
+	}
+}
+
+// ETag d'un fichier : MD5 de son contenu, entre guillemets
+func fileETag(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hasher := md5.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", fmt.Errorf("failed to hash %s: %v", path, err)
+	}
+	return `"` + hex.EncodeToString(hasher.Sum(nil)) + `"`, nil
+}
+
+// Clé d'un objet à partir de son chemin dans le bucket
+func (fs *FileStorage) objectKey(bucketName, objectPath string) string {
+	key, err := filepath.Rel(filepath.Join(fs.root(), bucketName), objectPath)
+	if err != nil {
+		return filepath.Base(objectPath)
+	}
+	return filepath.ToSlash(key)
+}
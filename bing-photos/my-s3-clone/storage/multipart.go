@@ -0,0 +1,513 @@
+package storage
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"my-s3-clone/dto"
+)
+
+const (
+	// Répertoire système (ignoré par ListBuckets) contenant les données internes du serveur
+	systemDir = ".s3clone"
+
+	maxPartNumber  = 10000
+	minPartSize    = 5 * 1024 * 1024
+	uploadInfoFile = "upload.json"
+	partFilePrefix = "part."
+	partInfoSuffix = ".json"
+)
+
+// multipartUpload est l'état persisté d'un upload multipart (upload.json)
+type multipartUpload struct {
+	Bucket    string    `json:"bucket"`
+	Key       string    `json:"key"`
+	UploadID  string    `json:"uploadId"`
+	Initiated time.Time `json:"initiated"`
+
+	// En-têtes fournis au démarrage de l'upload, appliqués à l'objet final
+	Metadata dto.ObjectMetadata `json:"metadata"`
+
+	// Chiffrement de l'objet final, sans segments : chaque part est chiffrée avec la même clé de données
+	Encryption *objectEncryption `json:"encryption,omitempty"`
+
+	// Verrouillage demandé au démarrage, nil pour la rétention par défaut du bucket
+	Lock *dto.ObjectLock `json:"lock,omitempty"`
+
+	// Étiquettes de l'objet final (x-amz-tagging)
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+func uploadLock(lock dto.ObjectLock) *dto.ObjectLock {
+	if lock.IsZero() {
+		return nil
+	}
+	return &lock
+}
+
+// Verrouillage à appliquer à l'objet final
+func (u multipartUpload) lock() dto.ObjectLock {
+	if u.Lock == nil {
+		return dto.ObjectLock{}
+	}
+	return *u.Lock
+}
+
+// uploadedPart est l'état persisté d'une part envoyée (part.NNNNN.json)
+type uploadedPart struct {
+	dto.Part
+	NoncePrefix []byte `json:",omitempty"` // préfixe des nonces de la part si l'upload est chiffré
+}
+
+// Répertoire de staging des parts d'un upload
+func (fs *FileStorage) multipartUploadDir(bucketName, uploadID string) string {
+	return filepath.Join(fs.root(), systemDir, "multipart", bucketName, uploadID)
+}
+
+func partPath(uploadDir string, partNumber int) string {
+	return filepath.Join(uploadDir, fmt.Sprintf("%s%05d", partFilePrefix, partNumber))
+}
+
+func newUploadID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Démarrage d'un upload multipart
+func (fs *FileStorage) CreateMultipartUpload(bucketName, objectName string, opts MultipartUploadOptions) (string, error) {
+	if err := ValidateObjectName(objectName); err != nil {
+		return "", err
+	}
+	exists, err := fs.CheckBucketExists(bucketName)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return "", ErrNoSuchBucket
+	}
+
+	// Un verrouillage ou des étiquettes invalides sont refusés dès le démarrage plutôt qu'après l'envoi des parts
+	if _, err := fs.newObjectLock(bucketName, opts.Lock, time.Now()); err != nil {
+		return "", err
+	}
+	if err := validateTags(opts.Tags); err != nil {
+		return "", err
+	}
+
+	// La clé de données est générée dès le démarrage : la clé SSE-C devra accompagner chaque part
+	uploadEncryption, _, err := fs.newObjectEncryption(opts.Encryption)
+	if err != nil {
+		return "", err
+	}
+
+	uploadID, err := newUploadID()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate upload id: %v", err)
+	}
+
+	uploadDir := fs.multipartUploadDir(bucketName, uploadID)
+	if err := os.MkdirAll(uploadDir, os.ModePerm); err != nil {
+		return "", fmt.Errorf("failed to create upload directory: %v", err)
+	}
+
+	upload := multipartUpload{
+		Bucket:     bucketName,
+		Key:        objectName,
+		UploadID:   uploadID,
+		Initiated:  time.Now().UTC(),
+		Metadata:   opts.Metadata,
+		Encryption: uploadEncryption,
+		Lock:       uploadLock(opts.Lock),
+		Tags:       copyTags(opts.Tags),
+	}
+	if err := writeJSONFile(filepath.Join(uploadDir, uploadInfoFile), upload); err != nil {
+		os.RemoveAll(uploadDir)
+		return "", err
+	}
+
+	log.Printf("Multipart upload %s created for %s in bucket %s", uploadID, objectName, bucketName)
+	return uploadID, nil
+}
+
+// Lecture de l'état d'un upload, en vérifiant qu'il correspond bien à l'objet demandé
+func (fs *FileStorage) loadMultipartUpload(bucketName, objectName, uploadID string) (multipartUpload, string, error) {
+	var upload multipartUpload
+	if err := ValidateBucketName(bucketName); err != nil {
+		return upload, "", err
+	}
+	if uploadID == "" || strings.ContainsAny(uploadID, `/\.`) {
+		return upload, "", ErrNoSuchUpload
+	}
+
+	uploadDir := fs.multipartUploadDir(bucketName, uploadID)
+	if err := readJSONFile(filepath.Join(uploadDir, uploadInfoFile), &upload); err != nil {
+		if os.IsNotExist(err) {
+			return upload, "", ErrNoSuchUpload
+		}
+		return upload, "", err
+	}
+	if upload.Key != objectName {
+		return upload, "", ErrNoSuchUpload
+	}
+	return upload, uploadDir, nil
+}
+
+// Envoi d'une part : elle est écrite dans un fichier temporaire puis renommée, une part renvoyée remplace la précédente
+func (fs *FileStorage) UploadPart(bucketName, objectName, uploadID string, partNumber int, data io.Reader, opts PutObjectOptions) (string, error) {
+	if partNumber < 1 || partNumber > maxPartNumber {
+		return "", ErrInvalidPartNumber
+	}
+
+	upload, uploadDir, err := fs.loadMultipartUpload(bucketName, objectName, uploadID)
+	if err != nil {
+		return "", err
+	}
+	var dataKey []byte
+	if upload.Encryption != nil {
+		if dataKey, err = fs.dataKey(upload.Encryption, opts.Encryption.CustomerKey); err != nil {
+			return "", err
+		}
+	}
+
+	tmp, err := os.CreateTemp(uploadDir, "tmp-part-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create part file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	var written writtenObject
+	segment, err := writeSegment(tmp, dataKey, func(w io.Writer) error {
+		written, err = writeObjectToFile(data, w, opts)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	if err := syncAndClose(tmp); err != nil {
+		return "", fmt.Errorf("failed to close part file: %v", err)
+	}
+
+	part := uploadedPart{
+		Part: dto.Part{
+			PartNumber:   partNumber,
+			LastModified: time.Now().UTC(),
+			ETag:         written.ETag,
+			Size:         written.Size,
+		},
+		NoncePrefix: segment.NoncePrefix,
+	}
+
+	path := partPath(uploadDir, partNumber)
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", fmt.Errorf("failed to store part %d: %v", partNumber, err)
+	}
+	if err := writeJSONFile(path+partInfoSuffix, part); err != nil {
+		return "", err
+	}
+
+	log.Printf("Stored part %d (%d bytes) of upload %s", partNumber, part.Size, uploadID)
+	return part.ETag, nil
+}
+
+// Lecture des parts déjà envoyées, triées par numéro
+func listUploadedParts(uploadDir string) ([]uploadedPart, error) {
+	infos, err := filepath.Glob(filepath.Join(uploadDir, partFilePrefix+"*"+partInfoSuffix))
+	if err != nil {
+		return nil, err
+	}
+
+	parts := make([]uploadedPart, 0, len(infos))
+	for _, info := range infos {
+		var part uploadedPart
+		if err := readJSONFile(info, &part); err != nil {
+			return nil, err
+		}
+		parts = append(parts, part)
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].PartNumber < parts[j].PartNumber })
+	return parts, nil
+}
+
+// Liste des parts d'un upload
+func (fs *FileStorage) ListParts(bucketName, objectName, uploadID string, partNumberMarker, maxParts int) (dto.ListPartsResult, error) {
+	_, uploadDir, err := fs.loadMultipartUpload(bucketName, objectName, uploadID)
+	if err != nil {
+		return dto.ListPartsResult{}, err
+	}
+
+	parts, err := listUploadedParts(uploadDir)
+	if err != nil {
+		return dto.ListPartsResult{}, fmt.Errorf("failed to list parts: %v", err)
+	}
+
+	return listPartsPage(bucketName, objectName, uploadID, parts, partNumberMarker, maxParts), nil
+}
+
+// Page de parts suivant partNumberMarker, parts étant triées par numéro
+func listPartsPage(bucketName, objectName, uploadID string, parts []uploadedPart, partNumberMarker, maxParts int) dto.ListPartsResult {
+	result := dto.ListPartsResult{
+		Xmlns:            "http://s3.amazonaws.com/doc/2006-03-01/",
+		Bucket:           bucketName,
+		Key:              objectName,
+		UploadId:         uploadID,
+		PartNumberMarker: partNumberMarker,
+		MaxParts:         maxParts,
+		Parts:            make([]dto.Part, 0),
+	}
+
+	for _, part := range parts {
+		if part.PartNumber <= partNumberMarker {
+			continue
+		}
+		if len(result.Parts) >= maxParts {
+			result.IsTruncated = true
+			break
+		}
+		result.Parts = append(result.Parts, part.Part)
+		result.NextPartNumberMarker = part.PartNumber
+	}
+	return result
+}
+
+// Finalisation d'un upload : les parts sont concaténées dans l'ordre demandé puis l'objet est mis en place
+func (fs *FileStorage) CompleteMultipartUpload(bucketName, objectName, uploadID string, parts []dto.CompletedPart) (dto.ObjectInfo, error) {
+	upload, uploadDir, err := fs.loadMultipartUpload(bucketName, objectName, uploadID)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+
+	uploaded, err := listUploadedParts(uploadDir)
+	if err != nil {
+		return dto.ObjectInfo{}, fmt.Errorf("failed to list parts: %v", err)
+	}
+	selected, eTag, err := selectCompletedParts(parts, uploaded)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	segments := make([]encryptedSegment, 0, len(selected))
+	for _, part := range selected {
+		segments = append(segments, encryptedSegment{NoncePrefix: part.NoncePrefix, Size: part.Size})
+	}
+
+	// Assemblage dans un fichier temporaire du répertoire de staging
+	assembled, err := os.CreateTemp(uploadDir, "tmp-object-")
+	if err != nil {
+		return dto.ObjectInfo{}, fmt.Errorf("failed to create object file: %v", err)
+	}
+	defer os.Remove(assembled.Name())
+	defer assembled.Close()
+
+	for _, part := range selected {
+		if err := appendFile(assembled, partPath(uploadDir, part.PartNumber)); err != nil {
+			return dto.ObjectInfo{}, err
+		}
+	}
+	if err := syncAndClose(assembled); err != nil {
+		return dto.ObjectInfo{}, fmt.Errorf("failed to close object file: %v", err)
+	}
+
+	meta := objectMetadata{
+		Key:        objectName,
+		ETag:       eTag,
+		Metadata:   upload.Metadata,
+		Tags:       copyTags(upload.Tags),
+		Encryption: upload.Encryption,
+	}
+	// Les parts chiffrées sont concaténées telles quelles, chacune formant un segment de l'objet
+	if meta.Encryption != nil {
+		meta.Encryption.Segments = segments
+	}
+	info, err := fs.commitObject(bucketName, assembled.Name(), meta, upload.lock(), false)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+
+	if err := os.RemoveAll(uploadDir); err != nil {
+		log.Printf("Failed to remove staging directory of upload %s: %v", uploadID, err)
+	}
+
+	log.Printf("Multipart upload %s completed into %s in bucket %s", uploadID, objectName, bucketName)
+	return info, nil
+}
+
+// Validation de la liste de parts envoyée par le client à la finalisation : parts envoyées, dans l'ordre,
+// avec le bon ETag et, sauf la dernière, d'au moins 5 Mo. Renvoie ces parts et l'ETag de l'objet final.
+func selectCompletedParts(requested []dto.CompletedPart, uploaded []uploadedPart) ([]uploadedPart, string, error) {
+	if len(requested) == 0 {
+		return nil, "", ErrInvalidPart
+	}
+	uploadedByNumber := make(map[int]uploadedPart, len(uploaded))
+	for _, part := range uploaded {
+		uploadedByNumber[part.PartNumber] = part
+	}
+
+	selected := make([]uploadedPart, 0, len(requested))
+	md5s := make([]byte, 0, len(requested)*md5.Size)
+	for i, completed := range requested {
+		if i > 0 && completed.PartNumber <= requested[i-1].PartNumber {
+			return nil, "", ErrInvalidPartOrder
+		}
+		part, ok := uploadedByNumber[completed.PartNumber]
+		if !ok || strings.Trim(completed.ETag, `"`) != strings.Trim(part.ETag, `"`) {
+			return nil, "", ErrInvalidPart
+		}
+		if i < len(requested)-1 && part.Size < minPartSize {
+			return nil, "", ErrEntityTooSmall
+		}
+		sum, err := hex.DecodeString(strings.Trim(part.ETag, `"`))
+		if err != nil {
+			return nil, "", ErrInvalidPart
+		}
+		md5s = append(md5s, sum...)
+		selected = append(selected, part)
+	}
+
+	sum := md5.Sum(md5s)
+	return selected, fmt.Sprintf(`"%s-%d"`, hex.EncodeToString(sum[:]), len(requested)), nil
+}
+
+// Abandon d'un upload et suppression de ses parts
+func (fs *FileStorage) AbortMultipartUpload(bucketName, objectName, uploadID string) error {
+	_, uploadDir, err := fs.loadMultipartUpload(bucketName, objectName, uploadID)
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(uploadDir); err != nil {
+		return fmt.Errorf("failed to remove upload %s: %v", uploadID, err)
+	}
+	log.Printf("Multipart upload %s aborted", uploadID)
+	return nil
+}
+
+// Lecture de tous les uploads en cours d'un bucket
+func (fs *FileStorage) listBucketUploads(bucketName string) ([]multipartUpload, error) {
+	infos, err := filepath.Glob(filepath.Join(fs.root(), systemDir, "multipart", bucketName, "*", uploadInfoFile))
+	if err != nil {
+		return nil, err
+	}
+
+	uploads := make([]multipartUpload, 0, len(infos))
+	for _, info := range infos {
+		var upload multipartUpload
+		if err := readJSONFile(info, &upload); err != nil {
+			log.Printf("Skipping unreadable multipart upload %s: %v", info, err)
+			continue
+		}
+		uploads = append(uploads, upload)
+	}
+	return uploads, nil
+}
+
+// Liste des uploads en cours, triés par clé puis par date de création
+func (fs *FileStorage) ListMultipartUploads(bucketName, prefix, keyMarker, uploadIDMarker string, maxUploads int) (dto.ListMultipartUploadsResult, error) {
+	exists, err := fs.CheckBucketExists(bucketName)
+	if err != nil {
+		return dto.ListMultipartUploadsResult{}, err
+	}
+	if !exists {
+		return dto.ListMultipartUploadsResult{}, ErrNoSuchBucket
+	}
+
+	uploads, err := fs.listBucketUploads(bucketName)
+	if err != nil {
+		return dto.ListMultipartUploadsResult{}, fmt.Errorf("failed to list multipart uploads: %v", err)
+	}
+	return listUploadsPage(bucketName, uploads, prefix, keyMarker, uploadIDMarker, maxUploads), nil
+}
+
+// Page d'uploads en cours suivant keyMarker/uploadIDMarker, triés par clé puis par date de création
+func listUploadsPage(bucketName string, uploads []multipartUpload, prefix, keyMarker, uploadIDMarker string, maxUploads int) dto.ListMultipartUploadsResult {
+	sort.Slice(uploads, func(i, j int) bool {
+		if uploads[i].Key != uploads[j].Key {
+			return uploads[i].Key < uploads[j].Key
+		}
+		if !uploads[i].Initiated.Equal(uploads[j].Initiated) {
+			return uploads[i].Initiated.Before(uploads[j].Initiated)
+		}
+		return uploads[i].UploadID < uploads[j].UploadID
+	})
+
+	result := dto.ListMultipartUploadsResult{
+		Xmlns:          "http://s3.amazonaws.com/doc/2006-03-01/",
+		Bucket:         bucketName,
+		KeyMarker:      keyMarker,
+		UploadIdMarker: uploadIDMarker,
+		Prefix:         prefix,
+		MaxUploads:     maxUploads,
+		Uploads:        make([]dto.Upload, 0),
+	}
+
+	// Sans upload-id-marker, le key-marker exclut toutes les uploads de cette clé
+	passedMarker := keyMarker == ""
+	for _, upload := range uploads {
+		if !strings.HasPrefix(upload.Key, prefix) {
+			continue
+		}
+		if !passedMarker {
+			if upload.Key < keyMarker || (upload.Key == keyMarker && uploadIDMarker == "") {
+				continue
+			}
+			if upload.Key == keyMarker {
+				if upload.UploadID == uploadIDMarker {
+					passedMarker = true
+				}
+				continue
+			}
+			passedMarker = true
+		}
+		if len(result.Uploads) >= maxUploads {
+			result.IsTruncated = true
+			break
+		}
+		result.Uploads = append(result.Uploads, dto.Upload{
+			Key:       upload.Key,
+			UploadId:  upload.UploadID,
+			Initiated: upload.Initiated,
+		})
+		result.NextKeyMarker = upload.Key
+		result.NextUploadIdMarker = upload.UploadID
+	}
+
+	return result
+}
+
+// PurgeStaleMultipartUploads supprime les uploads démarrés depuis plus de maxAge et jamais finalisés
+func (fs *FileStorage) PurgeStaleMultipartUploads(maxAge time.Duration) (int, error) {
+	infos, err := filepath.Glob(filepath.Join(fs.root(), systemDir, "multipart", "*", "*", uploadInfoFile))
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	deadline := time.Now().Add(-maxAge)
+	for _, info := range infos {
+		var upload multipartUpload
+		if err := readJSONFile(info, &upload); err != nil {
+			log.Printf("Skipping unreadable multipart upload %s: %v", info, err)
+			continue
+		}
+		if upload.Initiated.After(deadline) {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Dir(info)); err != nil {
+			log.Printf("Failed to purge multipart upload %s: %v", upload.UploadID, err)
+			continue
+		}
+		log.Printf("Purged abandoned multipart upload %s (%s in bucket %s)", upload.UploadID, upload.Key, upload.Bucket)
+		purged++
+	}
+	return purged, nil
+}
@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"log"
+
+	"my-s3-clone/dto"
+)
+
+// Lecture des webhooks d'un bucket : une configuration vide s'il n'en a pas, comme sur S3.
+// La configuration est validée par l'appelant (notification.ValidateConfiguration) avant son enregistrement.
+func (fs *FileStorage) GetBucketNotification(bucketName string) (dto.NotificationConfiguration, error) {
+	exists, err := fs.CheckBucketExists(bucketName)
+	if err != nil {
+		return dto.NotificationConfiguration{}, err
+	}
+	if !exists {
+		return dto.NotificationConfiguration{}, ErrNoSuchBucket
+	}
+	meta, err := fs.bucketMetadata(bucketName)
+	if err != nil {
+		return dto.NotificationConfiguration{}, err
+	}
+	if meta.Notification == nil {
+		return dto.NotificationConfiguration{}, nil
+	}
+	return *meta.Notification, nil
+}
+
+// Remplacement des webhooks d'un bucket, supprimés par une configuration vide
+func (fs *FileStorage) PutBucketNotification(bucketName string, config dto.NotificationConfiguration) error {
+	err := fs.updateBucketMetadata(bucketName, func(meta *bucketMetadata) error {
+		meta.Notification = notificationOrNil(config)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("Notification configuration of bucket %s set (%d webhooks)", bucketName, len(config.Webhooks))
+	return nil
+}
+
+func notificationOrNil(config dto.NotificationConfiguration) *dto.NotificationConfiguration {
+	if len(config.Webhooks) == 0 {
+		return nil
+	}
+	return &config
+}
@@ -0,0 +1,203 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"my-s3-clone/dto"
+)
+
+const (
+	// Durées maximales d'une rétention par défaut
+	maxRetentionDays  = 36500
+	maxRetentionYears = 100
+)
+
+// Lecture de la configuration Object Lock d'un bucket, ErrObjectLockNotEnabled s'il n'en a pas
+func (fs *FileStorage) GetObjectLockConfiguration(bucketName string) (dto.ObjectLockConfiguration, error) {
+	exists, err := fs.CheckBucketExists(bucketName)
+	if err != nil {
+		return dto.ObjectLockConfiguration{}, err
+	}
+	if !exists {
+		return dto.ObjectLockConfiguration{}, ErrNoSuchBucket
+	}
+	return fs.bucketObjectLock(bucketName)
+}
+
+// Activation d'Object Lock sur un bucket, ou remplacement de sa rétention par défaut.
+// Le verrouillage porte sur les versions : le versioning doit être activé.
+func (fs *FileStorage) PutObjectLockConfiguration(bucketName string, config dto.ObjectLockConfiguration) error {
+	if err := validateObjectLockConfig(config); err != nil {
+		return err
+	}
+	err := fs.updateBucketMetadata(bucketName, func(meta *bucketMetadata) error {
+		if meta.Versioning != VersioningEnabled {
+			return fmt.Errorf("%w: versioning must be enabled to use object lock", ErrInvalidBucketState)
+		}
+		meta.ObjectLock = &config
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("Object lock configuration of bucket %s set", bucketName)
+	return nil
+}
+
+func (fs *FileStorage) bucketObjectLock(bucketName string) (dto.ObjectLockConfiguration, error) {
+	meta, err := fs.bucketMetadata(bucketName)
+	if err != nil {
+		return dto.ObjectLockConfiguration{}, err
+	}
+	if meta.ObjectLock == nil {
+		return dto.ObjectLockConfiguration{}, ErrObjectLockNotEnabled
+	}
+	return *meta.ObjectLock, nil
+}
+
+// Une configuration active toujours le verrouillage ; sa rétention par défaut, facultative,
+// a un mode et une durée en jours ou en années
+func validateObjectLockConfig(config dto.ObjectLockConfiguration) error {
+	if config.ObjectLockEnabled != "Enabled" {
+		return fmt.Errorf("%w: ObjectLockEnabled must be Enabled", ErrInvalidObjectLock)
+	}
+	if config.Rule == nil {
+		return nil
+	}
+
+	retention := config.Rule.DefaultRetention
+	switch {
+	case retention == nil:
+		return fmt.Errorf("%w: rule must specify a default retention", ErrInvalidObjectLock)
+	case retention.Mode != dto.RetentionGovernance && retention.Mode != dto.RetentionCompliance:
+		return fmt.Errorf("%w: retention mode must be GOVERNANCE or COMPLIANCE", ErrInvalidObjectLock)
+	case (retention.Days > 0) == (retention.Years > 0):
+		return fmt.Errorf("%w: default retention needs either Days or Years", ErrInvalidObjectLock)
+	case retention.Days < 0 || retention.Years < 0 || retention.Days > maxRetentionDays || retention.Years > maxRetentionYears:
+		return fmt.Errorf("%w: default retention period is out of range", ErrInvalidObjectLock)
+	}
+	return nil
+}
+
+// Une rétention est un mode et une date future, ou rien du tout pour la retirer
+func validateRetention(mode string, retainUntil, now time.Time) error {
+	switch {
+	case mode == "" && retainUntil.IsZero():
+		return nil
+	case mode != dto.RetentionGovernance && mode != dto.RetentionCompliance:
+		return fmt.Errorf("%w: retention mode must be GOVERNANCE or COMPLIANCE", ErrInvalidObjectLock)
+	case !retainUntil.After(now):
+		return fmt.Errorf("%w: retain until date must be in the future", ErrInvalidObjectLock)
+	}
+	return nil
+}
+
+// Verrouillage d'une nouvelle version : celui demandé à l'upload, ou à défaut la rétention par défaut du bucket
+func (fs *FileStorage) newObjectLock(bucketName string, requested dto.ObjectLock, now time.Time) (dto.ObjectLock, error) {
+	config, err := fs.bucketObjectLock(bucketName)
+	if errors.Is(err, ErrObjectLockNotEnabled) {
+		return lockNewVersion(nil, requested, now)
+	}
+	if err != nil {
+		return requested, err
+	}
+	return lockNewVersion(&config, requested, now)
+}
+
+// Application de la configuration Object Lock d'un bucket (nil s'il n'en a pas) au verrouillage demandé
+func lockNewVersion(config *dto.ObjectLockConfiguration, requested dto.ObjectLock, now time.Time) (dto.ObjectLock, error) {
+	if config == nil {
+		if requested.IsZero() {
+			return requested, nil
+		}
+		return requested, ErrObjectLockNotEnabled
+	}
+	if err := validateRetention(requested.Mode, requested.RetainUntilDate, now); err != nil {
+		return requested, err
+	}
+
+	if requested.Mode == "" && config.Rule != nil && config.Rule.DefaultRetention != nil {
+		retention := config.Rule.DefaultRetention
+		requested.Mode = retention.Mode
+		requested.RetainUntilDate = now.AddDate(retention.Years, 0, retention.Days).UTC()
+	}
+	return requested, nil
+}
+
+func retentionActive(lock dto.ObjectLock, now time.Time) bool {
+	return lock.Mode != "" && lock.RetainUntilDate.After(now)
+}
+
+// Une version est protégée tant qu'elle est sous conservation légale ou que sa rétention court encore.
+// Seule une rétention GOVERNANCE peut être contournée, avec bypassGovernance.
+func checkObjectLock(lock dto.ObjectLock, bypassGovernance bool, now time.Time) error {
+	if lock.LegalHold {
+		return fmt.Errorf("%w: legal hold is on", ErrObjectLocked)
+	}
+	if !retentionActive(lock, now) || (lock.Mode == dto.RetentionGovernance && bypassGovernance) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s retention until %s", ErrObjectLocked, lock.Mode, lock.RetainUntilDate.Format(time.RFC3339))
+}
+
+// Modification de la rétention d'une version ("" pour la version courante). Une rétention en cours ne peut
+// qu'être prolongée, sauf une rétention GOVERNANCE avec bypassGovernance ; mode "" et date nulle la retirent.
+func (fs *FileStorage) PutObjectRetention(bucketName, objectName, versionID, mode string, retainUntil time.Time, bypassGovernance bool) (dto.ObjectInfo, error) {
+	now := time.Now()
+	if err := validateRetention(mode, retainUntil, now); err != nil {
+		return dto.ObjectInfo{}, err
+	}
+
+	return fs.updateObjectLock(bucketName, objectName, versionID, retentionUpdate(mode, retainUntil, bypassGovernance, now))
+}
+
+// Modification de rétention appliquée au verrouillage d'une version par PutObjectRetention
+func retentionUpdate(mode string, retainUntil time.Time, bypassGovernance bool, now time.Time) func(*dto.ObjectLock) error {
+	return func(lock *dto.ObjectLock) error {
+		if retentionActive(*lock, now) {
+			shortened := mode == "" || retainUntil.Before(lock.RetainUntilDate)
+			switch {
+			case lock.Mode == dto.RetentionCompliance && (shortened || mode != dto.RetentionCompliance):
+				return fmt.Errorf("%w: COMPLIANCE retention cannot be shortened or changed", ErrObjectLocked)
+			case lock.Mode == dto.RetentionGovernance && shortened && !bypassGovernance:
+				return fmt.Errorf("%w: GOVERNANCE retention can only be shortened with bypass", ErrObjectLocked)
+			}
+		}
+		lock.Mode, lock.RetainUntilDate = mode, retainUntil.UTC()
+		return nil
+	}
+}
+
+// Activation ou levée de la conservation légale d'une version ("" pour la version courante)
+func (fs *FileStorage) PutObjectLegalHold(bucketName, objectName, versionID string, on bool) (dto.ObjectInfo, error) {
+	return fs.updateObjectLock(bucketName, objectName, versionID, func(lock *dto.ObjectLock) error {
+		lock.LegalHold = on
+		return nil
+	})
+}
+
+// Modification du verrouillage d'une version, dans un bucket où Object Lock est activé
+func (fs *FileStorage) updateObjectLock(bucketName, objectName, versionID string, update func(*dto.ObjectLock) error) (dto.ObjectInfo, error) {
+	exists, err := fs.CheckBucketExists(bucketName)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	if !exists {
+		return dto.ObjectInfo{}, ErrNoSuchBucket
+	}
+	if _, err := fs.bucketObjectLock(bucketName); err != nil {
+		return dto.ObjectInfo{}, err
+	}
+
+	return fs.updateVersion(bucketName, objectName, versionID, func(meta *objectMetadata) error {
+		lock := meta.info().Lock
+		if err := update(&lock); err != nil {
+			return err
+		}
+		meta.setLock(lock)
+		return nil
+	})
+}
@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// Longueur maximale d'une clé S3, en octets
+const maxObjectNameLength = 1024
+
+// ValidateBucketName refuse les noms de bucket qui ne désignent pas un répertoire direct de la racine
+// (séparateurs, "." et "..", répertoires cachés réservés au serveur).
+func ValidateBucketName(bucketName string) error {
+	if bucketName == "" || strings.HasPrefix(bucketName, ".") || strings.ContainsAny(bucketName, "/\\\x00") {
+		return fmt.Errorf("%w: %q", ErrInvalidBucketName, bucketName)
+	}
+	return nil
+}
+
+// ValidateObjectName refuse les clés qui ne peuvent pas être stockées telles quelles sous leur bucket :
+// segments vides, "." ou "..", caractère NUL, ou clé de plus de 1024 octets.
+func ValidateObjectName(objectName string) error {
+	if len(objectName) > maxObjectNameLength {
+		return ErrKeyTooLong
+	}
+	if objectName == "" || strings.ContainsRune(objectName, 0) {
+		return fmt.Errorf("%w: %q", ErrInvalidObjectName, objectName)
+	}
+	for _, segment := range strings.Split(objectName, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return fmt.Errorf("%w: %q", ErrInvalidObjectName, objectName)
+		}
+	}
+	return nil
+}
+
+// Chemin du répertoire d'un bucket
+func (fs *FileStorage) resolveBucketPath(bucketName string) (string, error) {
+	if err := ValidateBucketName(bucketName); err != nil {
+		return "", err
+	}
+	return filepath.Join(fs.root(), bucketName), nil
+}
+
+// Chemin du fichier d'un objet, en vérifiant qu'il reste bien sous le répertoire de son bucket
+func (fs *FileStorage) resolveObjectPath(bucketName, objectName string) (string, error) {
+	bucketDir, err := fs.resolveBucketPath(bucketName)
+	if err != nil {
+		return "", err
+	}
+	if err := ValidateObjectName(objectName); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(bucketDir, filepath.FromSlash(objectName))
+	rel, err := filepath.Rel(bucketDir, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidObjectName, objectName)
+	}
+	return path, nil
+}
+
+// Création des répertoires parents d'un objet. Échoue si un objet existe déjà à la place d'un des répertoires,
+// ou si la clé est elle-même le préfixe d'autres objets.
+func createObjectDirs(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		if errors.Is(err, syscall.ENOTDIR) {
+			return fmt.Errorf("%w: %v", ErrObjectPathConflict, err)
+		}
+		return fmt.Errorf("failed to create object directories: %v", err)
+	}
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return fmt.Errorf("%w: %s is a prefix of other objects", ErrObjectPathConflict, path)
+	}
+	return nil
+}
+
+// Suppression des répertoires devenus vides entre l'objet supprimé et la racine de son bucket
+func (fs *FileStorage) pruneEmptyDirs(bucketName, path string) {
+	bucketDir := filepath.Join(fs.root(), bucketName)
+	for dir := filepath.Dir(path); strings.HasPrefix(dir, bucketDir+string(filepath.Separator)); dir = filepath.Dir(dir) {
+		if err := os.Remove(dir); err != nil {
+			if !errors.Is(err, syscall.ENOTEMPTY) && !errors.Is(err, syscall.EEXIST) && !os.IsNotExist(err) {
+				log.Printf("Failed to remove empty directory %s: %v", dir, err)
+			}
+			return
+		}
+	}
+}
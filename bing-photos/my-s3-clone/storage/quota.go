@@ -0,0 +1,219 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"my-s3-clone/dto"
+)
+
+const usageFile = "usage.json"
+
+// bucketUsage est l'occupation persistée d'un bucket, tenue à jour à chaque écriture et suppression
+type bucketUsage struct {
+	Bytes   int64 `json:"bytes"`
+	Objects int64 `json:"objects"`
+}
+
+func validateQuota(quota dto.BucketQuota) error {
+	if quota.MaxBytes < 0 || quota.MaxObjects < 0 {
+		return fmt.Errorf("%w: limits must not be negative", ErrInvalidQuota)
+	}
+	return nil
+}
+
+// Vérification qu'une écriture ajoutant bytes octets et objects versions à l'occupation usage respecte le quota
+func checkQuota(quota *dto.BucketQuota, usage bucketUsage, bytes, objects int64) error {
+	if quota == nil {
+		return nil
+	}
+	if quota.MaxBytes > 0 && bytes > 0 && usage.Bytes+bytes > quota.MaxBytes {
+		return fmt.Errorf("%w: %d of %d bytes used", ErrQuotaExceeded, usage.Bytes, quota.MaxBytes)
+	}
+	if quota.MaxObjects > 0 && objects > 0 && usage.Objects+objects > quota.MaxObjects {
+		return fmt.Errorf("%w: %d of %d objects stored", ErrQuotaExceeded, usage.Objects, quota.MaxObjects)
+	}
+	return nil
+}
+
+func (usage bucketUsage) info(bucketName string, quota *dto.BucketQuota) dto.BucketUsage {
+	return dto.BucketUsage{Bucket: bucketName, Bytes: usage.Bytes, Objects: usage.Objects, Quota: quota}
+}
+
+func (fs *FileStorage) usagePath(bucketName string) string {
+	return filepath.Join(fs.bucketConfigDir(bucketName), usageFile)
+}
+
+// Lecture de l'occupation d'un bucket et de son quota
+func (fs *FileStorage) GetBucketUsage(bucketName string) (dto.BucketUsage, error) {
+	meta, err := fs.existingBucketMetadata(bucketName)
+	if err != nil {
+		return dto.BucketUsage{}, err
+	}
+	fs.usageMu.Lock()
+	defer fs.usageMu.Unlock()
+	usage, err := fs.loadUsage(bucketName)
+	if err != nil {
+		return dto.BucketUsage{}, err
+	}
+	return usage.info(bucketName, meta.Quota), nil
+}
+
+// Définition du quota d'un bucket. L'occupation déjà atteinte n'est pas remise en cause :
+// seules les écritures suivantes sont refusées.
+func (fs *FileStorage) PutBucketQuota(bucketName string, quota dto.BucketQuota) error {
+	if err := validateQuota(quota); err != nil {
+		return err
+	}
+	err := fs.updateBucketMetadata(bucketName, func(meta *bucketMetadata) error {
+		meta.Quota = &quota
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("Quota of bucket %s set to %d bytes, %d objects", bucketName, quota.MaxBytes, quota.MaxObjects)
+	return nil
+}
+
+// Suppression du quota d'un bucket
+func (fs *FileStorage) DeleteBucketQuota(bucketName string) error {
+	return fs.updateBucketMetadata(bucketName, func(meta *bucketMetadata) error {
+		meta.Quota = nil
+		return nil
+	})
+}
+
+// RebuildBucketUsage recalcule l'occupation d'un bucket à partir de ses objets et de ses versions,
+// pour corriger un compteur faussé par un arrêt brutal. À lancer serveur arrêté.
+func (fs *FileStorage) RebuildBucketUsage(bucketName string) (dto.BucketUsage, error) {
+	meta, err := fs.existingBucketMetadata(bucketName)
+	if err != nil {
+		return dto.BucketUsage{}, err
+	}
+	fs.usageMu.Lock()
+	defer fs.usageMu.Unlock()
+	usage, err := fs.computeUsage(bucketName)
+	if err != nil {
+		return dto.BucketUsage{}, err
+	}
+	if err := fs.saveUsage(bucketName, usage); err != nil {
+		return dto.BucketUsage{}, err
+	}
+	return usage.info(bucketName, meta.Quota), nil
+}
+
+// Vérification du quota avant la mise en place d'une écriture
+func (fs *FileStorage) checkBucketQuota(bucketName string, bytes, objects int64) error {
+	meta, err := fs.bucketMetadata(bucketName)
+	if err != nil || meta.Quota == nil {
+		return err
+	}
+	fs.usageMu.Lock()
+	defer fs.usageMu.Unlock()
+	usage, err := fs.loadUsage(bucketName)
+	if err != nil {
+		return err
+	}
+	return checkQuota(meta.Quota, usage, bytes, objects)
+}
+
+// Report d'une écriture ou d'une suppression déjà effectuée sur le disque dans l'occupation du bucket
+func (fs *FileStorage) addUsage(bucketName string, bytes, objects int64) {
+	fs.usageMu.Lock()
+	defer fs.usageMu.Unlock()
+
+	// Sans compteur, l'occupation recalculée inclut déjà le changement
+	var usage bucketUsage
+	err := readJSONFile(fs.usagePath(bucketName), &usage)
+	if os.IsNotExist(err) {
+		if _, err := fs.loadUsage(bucketName); err != nil {
+			log.Printf("Failed to compute usage of bucket %s: %v", bucketName, err)
+		}
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to read usage of bucket %s: %v", bucketName, err)
+		return
+	}
+	usage.Bytes += bytes
+	usage.Objects += objects
+	if err := fs.saveUsage(bucketName, usage); err != nil {
+		log.Printf("Failed to update usage of bucket %s: %v", bucketName, err)
+	}
+}
+
+// Lecture du compteur d'un bucket, à appeler avec usageMu pris. Un bucket créé avant le suivi
+// de l'occupation n'en a pas : elle est alors calculée puis enregistrée.
+func (fs *FileStorage) loadUsage(bucketName string) (bucketUsage, error) {
+	var usage bucketUsage
+	err := readJSONFile(fs.usagePath(bucketName), &usage)
+	if err == nil {
+		return usage, nil
+	}
+	if !os.IsNotExist(err) {
+		return usage, fmt.Errorf("failed to read usage of bucket %s: %v", bucketName, err)
+	}
+	if usage, err = fs.computeUsage(bucketName); err != nil {
+		return usage, err
+	}
+	return usage, fs.saveUsage(bucketName, usage)
+}
+
+func (fs *FileStorage) saveUsage(bucketName string, usage bucketUsage) error {
+	if err := os.MkdirAll(fs.bucketConfigDir(bucketName), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create bucket configuration directory: %v", err)
+	}
+	path := fs.usagePath(bucketName)
+	if err := writeJSONFile(path+".tmp", usage); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// Occupation réelle d'un bucket : versions courantes et versions archivées, hors marqueurs de suppression.
+// Appelée avec ou sans commitMu, elle ne répare pas les métadonnées : un objet qui ne correspond plus aux siennes
+// compte pour la taille de son fichier.
+func (fs *FileStorage) computeUsage(bucketName string) (bucketUsage, error) {
+	var usage bucketUsage
+	keys, err := fs.listBucketKeys(bucketName, "")
+	if err != nil {
+		return usage, err
+	}
+	for _, key := range keys {
+		objectPath, err := fs.resolveObjectPath(bucketName, key)
+		if err != nil {
+			return usage, err
+		}
+		fileInfo, err := os.Stat(objectPath)
+		if errors.Is(err, os.ErrNotExist) || (err == nil && fileInfo.IsDir()) {
+			continue
+		}
+		if err != nil {
+			return usage, err
+		}
+		size := fileInfo.Size()
+		if record, ok := fs.storedObjectMetadata(bucketName, key, fileInfo); ok {
+			size = record.Size
+		}
+		usage.Bytes += size
+		usage.Objects++
+	}
+
+	archived, err := fs.archivedVersionsByKey(bucketName, "")
+	if err != nil {
+		return usage, err
+	}
+	for _, versions := range archived {
+		for _, version := range versions {
+			if !version.IsDeleteMarker {
+				usage.Bytes += version.Size
+				usage.Objects++
+			}
+		}
+	}
+	return usage, nil
+}
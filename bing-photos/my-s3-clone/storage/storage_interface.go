@@ -9,16 +9,64 @@ import (
 
 // Storage interface définissant les méthodes de gestion des objets et des buckets
 type Storage interface {
-    AddObject(bucketName, objectName string, data io.Reader, contentSha256 string) error
-    DeleteObject(bucketName, objectName string) error
+    AddObject(bucketName, objectName string, data io.Reader, opts PutObjectOptions) (dto.ObjectInfo, error)
+    DeleteObject(bucketName, objectName string, opts DeleteObjectOptions) (dto.ObjectInfo, error)
     DeleteBucket(bucketName string) error
-    GetObject(bucketName, objectName string) ([]byte, dto.FileInfo, error)
-    CheckObjectExist(bucketName, objectName string) (bool, time.Time, int64, error)
+    GetObject(bucketName, objectName, versionID string, customerKey []byte) (io.ReadSeekCloser, dto.ObjectInfo, error)
+    StatObject(bucketName, objectName, versionID string) (dto.ObjectInfo, error)
     CheckBucketExists(bucketName string) (bool, error)
     ListBuckets() []string
-    ListObjects(bucketName, prefix, marker string, maxKeys int) (dto.ListObjectsResponse, error)
-    CreateBucket(bucketName string) error
-    CopyObject(sourceBucket, sourceKey, targetBucket, targetKey string) error
+    ListObjects(bucketName string, opts ListObjectsOptions) (dto.ObjectListing, error)
+    CreateBucket(bucketName string, opts CreateBucketOptions) error
+    GetBucketInfo(bucketName string) (dto.BucketInfo, error)
+    CopyObject(sourceBucket, sourceKey, targetBucket, targetKey string, opts CopyObjectOptions) (dto.ObjectInfo, error)
+    MoveObject(sourceBucket, sourceKey, targetBucket, targetKey string) (dto.ObjectInfo, error)
+
+    // Upload multipart
+    CreateMultipartUpload(bucketName, objectName string, opts MultipartUploadOptions) (string, error)
+    UploadPart(bucketName, objectName, uploadID string, partNumber int, data io.Reader, opts PutObjectOptions) (string, error)
+    ListParts(bucketName, objectName, uploadID string, partNumberMarker, maxParts int) (dto.ListPartsResult, error)
+    CompleteMultipartUpload(bucketName, objectName, uploadID string, parts []dto.CompletedPart) (dto.ObjectInfo, error)
+    AbortMultipartUpload(bucketName, objectName, uploadID string) error
+    ListMultipartUploads(bucketName, prefix, keyMarker, uploadIDMarker string, maxUploads int) (dto.ListMultipartUploadsResult, error)
+
+    // Versioning
+    GetBucketVersioning(bucketName string) (string, error)
+    SetBucketVersioning(bucketName, status string) error
+    ListObjectVersions(bucketName string, opts ListObjectVersionsOptions) (dto.ObjectVersionListing, error)
+
+    // Cycle de vie
+    GetBucketLifecycle(bucketName string) (dto.LifecycleConfiguration, error)
+    PutBucketLifecycle(bucketName string, config dto.LifecycleConfiguration) error
+    DeleteBucketLifecycle(bucketName string) error
+
+    // Étiquetage des objets : les étiquettes sont lues avec StatObject
+    PutObjectTagging(bucketName, objectName, versionID string, tags map[string]string) (dto.ObjectInfo, error)
+
+    // CORS
+    GetBucketCors(bucketName string) (dto.CORSConfiguration, error)
+    PutBucketCors(bucketName string, config dto.CORSConfiguration) error
+    DeleteBucketCors(bucketName string) error
+
+    // Politique de bucket, appliquée aux requêtes anonymes
+    GetBucketPolicy(bucketName string) (dto.BucketPolicy, error)
+    PutBucketPolicy(bucketName string, policy dto.BucketPolicy) error
+    DeleteBucketPolicy(bucketName string) error
+
+    // Notifications : webhooks appelés à la création et à la suppression des objets
+    GetBucketNotification(bucketName string) (dto.NotificationConfiguration, error)
+    PutBucketNotification(bucketName string, config dto.NotificationConfiguration) error
+
+    // Occupation et quotas
+    GetBucketUsage(bucketName string) (dto.BucketUsage, error)
+    PutBucketQuota(bucketName string, quota dto.BucketQuota) error
+    DeleteBucketQuota(bucketName string) error
+
+    // Object Lock
+    GetObjectLockConfiguration(bucketName string) (dto.ObjectLockConfiguration, error)
+    PutObjectLockConfiguration(bucketName string, config dto.ObjectLockConfiguration) error
+    PutObjectRetention(bucketName, objectName, versionID, mode string, retainUntil time.Time, bypassGovernance bool) (dto.ObjectInfo, error)
+    PutObjectLegalHold(bucketName, objectName, versionID string, on bool) (dto.ObjectInfo, error)
 }
 
 
@@ -0,0 +1,1193 @@
+// Package storagetest is the conformance suite every storage.Storage implementation must pass,
+// so that new backends and refactors of existing ones are verified against the same behaviour.
+package storagetest
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/storage"
+)
+
+// Run runs the conformance suite against the backends returned by newStorage, called once per subtest.
+// Buckets get random names and are emptied and deleted when each subtest ends, so a backend
+// sharing its data between calls (such as two FileStorage on the same root) can be tested too.
+func Run(t *testing.T, newStorage func(t *testing.T) storage.Storage) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, s storage.Storage)
+	}{
+		{"Buckets", testBuckets},
+		{"BucketInfo", testBucketInfo},
+		{"DeleteNonEmptyBucket", testDeleteNonEmptyBucket},
+		{"PutGetObject", testPutGetObject},
+		{"BadDigest", testBadDigest},
+		{"CopyObject", testCopyObject},
+		{"MoveObject", testMoveObject},
+		{"ObjectTagging", testObjectTagging},
+		{"DeleteObject", testDeleteObject},
+		{"ListObjects", testListObjects},
+		{"NotFound", testNotFound},
+		{"InvalidNames", testInvalidNames},
+		{"MultipartUpload", testMultipartUpload},
+		{"Versioning", testVersioning},
+		{"BucketCors", testBucketCors},
+		{"BucketPolicy", testBucketPolicy},
+		{"BucketNotification", testBucketNotification},
+		{"BucketUsage", testBucketUsage},
+		{"BucketQuota", testBucketQuota},
+		{"ConditionalPut", testConditionalPut},
+		{"ConcurrentAccess", testConcurrentAccess},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.run(t, newStorage(t))
+		})
+	}
+}
+
+// newBucket creates a bucket with a random name, removed with all its versions when the test ends
+func newBucket(t *testing.T, s storage.Storage) string {
+	t.Helper()
+	return newBucketWithOptions(t, s, storage.CreateBucketOptions{})
+}
+
+func newBucketWithOptions(t *testing.T, s storage.Storage, opts storage.CreateBucketOptions) string {
+	t.Helper()
+	suffix := make([]byte, 6)
+	if _, err := rand.Read(suffix); err != nil {
+		t.Fatal(err)
+	}
+	bucket := "conformance-" + hex.EncodeToString(suffix)
+	if err := s.CreateBucket(bucket, opts); err != nil {
+		t.Fatalf("CreateBucket(%q): %v", bucket, err)
+	}
+	t.Cleanup(func() { removeBucket(t, s, bucket) })
+	return bucket
+}
+
+func removeBucket(t *testing.T, s storage.Storage, bucket string) {
+	if exists, _ := s.CheckBucketExists(bucket); !exists {
+		return
+	}
+	uploads, err := s.ListMultipartUploads(bucket, "", "", "", 1000)
+	if err != nil {
+		t.Errorf("cleanup: ListMultipartUploads(%q): %v", bucket, err)
+	}
+	for _, upload := range uploads.Uploads {
+		s.AbortMultipartUpload(bucket, upload.Key, upload.UploadId)
+	}
+	for {
+		listing, err := s.ListObjectVersions(bucket, storage.ListObjectVersionsOptions{MaxKeys: 1000})
+		if err != nil {
+			t.Errorf("cleanup: ListObjectVersions(%q): %v", bucket, err)
+			return
+		}
+		for _, version := range listing.Versions {
+			opts := storage.DeleteObjectOptions{VersionID: version.VersionID, BypassGovernance: true}
+			if _, err := s.DeleteObject(bucket, version.Key, opts); err != nil {
+				t.Errorf("cleanup: DeleteObject(%q, %q, %q): %v", bucket, version.Key, version.VersionID, err)
+				return
+			}
+		}
+		if !listing.IsTruncated {
+			break
+		}
+	}
+	if err := s.DeleteBucket(bucket); err != nil {
+		t.Errorf("cleanup: DeleteBucket(%q): %v", bucket, err)
+	}
+}
+
+func put(t *testing.T, s storage.Storage, bucket, key, content string) dto.ObjectInfo {
+	t.Helper()
+	info, err := s.AddObject(bucket, key, strings.NewReader(content), storage.PutObjectOptions{DecodedContentLength: -1})
+	if err != nil {
+		t.Fatalf("AddObject(%q, %q): %v", bucket, key, err)
+	}
+	return info
+}
+
+func get(t *testing.T, s storage.Storage, bucket, key, versionID string) (string, dto.ObjectInfo) {
+	t.Helper()
+	reader, info, err := s.GetObject(bucket, key, versionID, nil)
+	if err != nil {
+		t.Fatalf("GetObject(%q, %q, %q): %v", bucket, key, versionID, err)
+	}
+	defer reader.Close()
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("reading %q: %v", key, err)
+	}
+	return string(content), info
+}
+
+func eTag(content string) string {
+	sum := md5.Sum([]byte(content))
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func testBuckets(t *testing.T, s storage.Storage) {
+	bucket := newBucket(t, s)
+
+	if exists, err := s.CheckBucketExists(bucket); err != nil || !exists {
+		t.Errorf("CheckBucketExists(%q) = %v, %v, want true", bucket, exists, err)
+	}
+	if buckets := s.ListBuckets(); !contains(buckets, bucket) {
+		t.Errorf("ListBuckets() = %v, want it to contain %q", buckets, bucket)
+	}
+	if err := s.CreateBucket(bucket, storage.CreateBucketOptions{}); !errors.Is(err, storage.ErrBucketAlreadyExists) {
+		t.Errorf("creating %q twice: got %v, want ErrBucketAlreadyExists", bucket, err)
+	}
+
+	other := newBucket(t, s)
+	buckets := s.ListBuckets()
+	if !contains(buckets, other) {
+		t.Errorf("ListBuckets() = %v, want it to contain %q", buckets, other)
+	}
+	for i := 1; i < len(buckets); i++ {
+		if buckets[i-1] >= buckets[i] {
+			t.Errorf("ListBuckets() = %v, want sorted names", buckets)
+			break
+		}
+	}
+
+	if err := s.DeleteBucket(other); err != nil {
+		t.Fatalf("DeleteBucket(%q): %v", other, err)
+	}
+	if exists, err := s.CheckBucketExists(other); err != nil || exists {
+		t.Errorf("CheckBucketExists(%q) after deletion = %v, %v, want false", other, exists, err)
+	}
+	if buckets := s.ListBuckets(); contains(buckets, other) {
+		t.Errorf("ListBuckets() = %v, want %q to be gone", buckets, other)
+	}
+	if err := s.DeleteBucket(other); !errors.Is(err, storage.ErrNoSuchBucket) {
+		t.Errorf("deleting %q twice: got %v, want ErrNoSuchBucket", other, err)
+	}
+}
+
+func testBucketInfo(t *testing.T, s storage.Storage) {
+	before := time.Now()
+	bucket := newBucketWithOptions(t, s, storage.CreateBucketOptions{Owner: "gallery", Region: "eu-west-3"})
+	after := time.Now()
+
+	info, err := s.GetBucketInfo(bucket)
+	if err != nil {
+		t.Fatalf("GetBucketInfo: %v", err)
+	}
+	if info.Name != bucket || info.Owner != "gallery" || info.Region != "eu-west-3" {
+		t.Errorf("GetBucketInfo = %+v, want owner gallery in eu-west-3", info)
+	}
+	if info.CreationDate.Before(before.Add(-time.Second)) || info.CreationDate.After(after.Add(time.Second)) {
+		t.Errorf("creation date %v is not between %v and %v", info.CreationDate, before, after)
+	}
+
+	// Changing the configuration of the bucket keeps its record
+	if err := s.SetBucketVersioning(bucket, storage.VersioningEnabled); err != nil {
+		t.Fatal(err)
+	}
+	if again, err := s.GetBucketInfo(bucket); err != nil || !again.CreationDate.Equal(info.CreationDate) || again.Owner != info.Owner {
+		t.Errorf("GetBucketInfo after a configuration change = %+v, %v, want %+v", again, err, info)
+	}
+
+	if info, err := s.GetBucketInfo(newBucket(t, s)); err != nil || info.Region != storage.DefaultRegion || info.Owner != "" {
+		t.Errorf("GetBucketInfo of a bucket created without options = %+v, %v, want region %s", info, err, storage.DefaultRegion)
+	}
+	if _, err := s.GetBucketInfo("missing-bucket"); !errors.Is(err, storage.ErrNoSuchBucket) {
+		t.Errorf("GetBucketInfo of a missing bucket: got %v, want ErrNoSuchBucket", err)
+	}
+	for _, region := range []string{"EU", "eu west", "-eu"} {
+		if err := s.CreateBucket("invalid-region", storage.CreateBucketOptions{Region: region}); !errors.Is(err, storage.ErrInvalidRegion) {
+			t.Errorf("CreateBucket in region %q: got %v, want ErrInvalidRegion", region, err)
+		}
+	}
+	if exists, _ := s.CheckBucketExists("invalid-region"); exists {
+		t.Errorf("a bucket with an invalid region was created")
+	}
+}
+
+func testDeleteNonEmptyBucket(t *testing.T, s storage.Storage) {
+	bucket := newBucket(t, s)
+	put(t, s, bucket, "photo.jpg", "content")
+
+	if err := s.DeleteBucket(bucket); !errors.Is(err, storage.ErrBucketNotEmpty) {
+		t.Fatalf("DeleteBucket on a bucket with objects: got %v, want ErrBucketNotEmpty", err)
+	}
+	if _, err := s.DeleteObject(bucket, "photo.jpg", storage.DeleteObjectOptions{}); err != nil {
+		t.Fatalf("DeleteObject: %v", err)
+	}
+	if err := s.DeleteBucket(bucket); err != nil {
+		t.Errorf("DeleteBucket on an emptied bucket: %v", err)
+	}
+}
+
+func testPutGetObject(t *testing.T, s storage.Storage) {
+	bucket := newBucket(t, s)
+	metadata := dto.ObjectMetadata{
+		ContentType:  "image/jpeg",
+		CacheControl: "max-age=60",
+		UserMetadata: map[string]string{"album": "holidays"},
+	}
+	content := "the quick brown fox"
+
+	info, err := s.AddObject(bucket, "albums/2024/photo.jpg", strings.NewReader(content),
+		storage.PutObjectOptions{Metadata: metadata, DecodedContentLength: -1})
+	if err != nil {
+		t.Fatalf("AddObject: %v", err)
+	}
+	if info.Key != "albums/2024/photo.jpg" || info.ETag != eTag(content) || info.Size != int64(len(content)) {
+		t.Errorf("AddObject returned %+v, want key, ETag %s and size %d", info, eTag(content), len(content))
+	}
+
+	got, info := get(t, s, bucket, "albums/2024/photo.jpg", "")
+	if got != content {
+		t.Errorf("GetObject content = %q, want %q", got, content)
+	}
+	if info.ETag != eTag(content) || info.Size != int64(len(content)) || info.LastModified.IsZero() {
+		t.Errorf("GetObject info = %+v, want ETag %s, size %d and a modification date", info, eTag(content), len(content))
+	}
+	if !reflect.DeepEqual(info.Metadata, metadata) {
+		t.Errorf("GetObject metadata = %+v, want %+v", info.Metadata, metadata)
+	}
+
+	stat, err := s.StatObject(bucket, "albums/2024/photo.jpg", "")
+	if err != nil {
+		t.Fatalf("StatObject: %v", err)
+	}
+	if stat.ETag != info.ETag || stat.Size != info.Size || !reflect.DeepEqual(stat.Metadata, metadata) {
+		t.Errorf("StatObject = %+v, want the same description as GetObject %+v", stat, info)
+	}
+
+	reader, _, err := s.GetObject(bucket, "albums/2024/photo.jpg", "", nil)
+	if err != nil {
+		t.Fatalf("GetObject: %v", err)
+	}
+	defer reader.Close()
+	if _, err := reader.Seek(10, io.SeekStart); err != nil {
+		t.Fatalf("Seek: %v", err)
+	}
+	if rest, _ := io.ReadAll(reader); string(rest) != content[10:] {
+		t.Errorf("content after Seek(10) = %q, want %q", rest, content[10:])
+	}
+
+	put(t, s, bucket, "albums/2024/photo.jpg", "replaced")
+	if got, info := get(t, s, bucket, "albums/2024/photo.jpg", ""); got != "replaced" || info.ETag != eTag("replaced") {
+		t.Errorf("after overwrite: content %q with ETag %s, want %q with ETag %s", got, info.ETag, "replaced", eTag("replaced"))
+	}
+
+	put(t, s, bucket, "empty", "")
+	if got, info := get(t, s, bucket, "empty", ""); got != "" || info.Size != 0 || info.ETag != eTag("") {
+		t.Errorf("empty object: content %q, info %+v", got, info)
+	}
+}
+
+func testBadDigest(t *testing.T, s storage.Storage) {
+	bucket := newBucket(t, s)
+	wrong := md5.Sum([]byte("something else"))
+
+	_, err := s.AddObject(bucket, "photo.jpg", strings.NewReader("content"),
+		storage.PutObjectOptions{ContentMD5: wrong[:], DecodedContentLength: -1})
+	if !errors.Is(err, storage.ErrBadDigest) {
+		t.Fatalf("AddObject with a wrong Content-MD5: got %v, want ErrBadDigest", err)
+	}
+	if _, err := s.StatObject(bucket, "photo.jpg", ""); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("StatObject after a rejected upload: got %v, want os.ErrNotExist", err)
+	}
+
+	right := md5.Sum([]byte("content"))
+	if _, err := s.AddObject(bucket, "photo.jpg", strings.NewReader("content"),
+		storage.PutObjectOptions{ContentMD5: right[:], DecodedContentLength: -1}); err != nil {
+		t.Errorf("AddObject with the right Content-MD5: %v", err)
+	}
+}
+
+func testCopyObject(t *testing.T, s storage.Storage) {
+	source, target := newBucket(t, s), newBucket(t, s)
+	metadata := dto.ObjectMetadata{ContentType: "image/png", UserMetadata: map[string]string{"camera": "x100"}}
+	if _, err := s.AddObject(source, "original.png", strings.NewReader("pixels"),
+		storage.PutObjectOptions{Metadata: metadata, DecodedContentLength: -1}); err != nil {
+		t.Fatalf("AddObject: %v", err)
+	}
+
+	info, err := s.CopyObject(source, "original.png", target, "copy.png", storage.CopyObjectOptions{})
+	if err != nil {
+		t.Fatalf("CopyObject: %v", err)
+	}
+	if info.Key != "copy.png" || info.ETag != eTag("pixels") {
+		t.Errorf("CopyObject returned %+v, want key copy.png and ETag %s", info, eTag("pixels"))
+	}
+	got, copied := get(t, s, target, "copy.png", "")
+	if got != "pixels" || !reflect.DeepEqual(copied.Metadata, metadata) {
+		t.Errorf("copy: content %q with metadata %+v, want %q with the source metadata %+v", got, copied.Metadata, "pixels", metadata)
+	}
+	if got, _ := get(t, s, source, "original.png", ""); got != "pixels" {
+		t.Errorf("source after copy: content %q, want it unchanged", got)
+	}
+
+	replaced := dto.ObjectMetadata{ContentType: "text/plain"}
+	if _, err := s.CopyObject(source, "original.png", source, "renamed.txt", storage.CopyObjectOptions{Metadata: &replaced}); err != nil {
+		t.Fatalf("CopyObject with new metadata: %v", err)
+	}
+	if _, info := get(t, s, source, "renamed.txt", ""); !reflect.DeepEqual(info.Metadata, replaced) {
+		t.Errorf("copy with new metadata: got %+v, want %+v", info.Metadata, replaced)
+	}
+
+	// The source condition sees the source object and can stop the copy
+	var checked dto.ObjectInfo
+	failed := errors.New("condition failed")
+	_, err = s.CopyObject(source, "original.png", target, "conditional.png", storage.CopyObjectOptions{
+		SourceCondition: func(source dto.ObjectInfo) error {
+			checked = source
+			return failed
+		},
+	})
+	if !errors.Is(err, failed) || checked.ETag != eTag("pixels") {
+		t.Errorf("CopyObject with a failing source condition: got %v after checking %+v", err, checked)
+	}
+	if _, err := s.StatObject(target, "conditional.png", ""); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("StatObject after a failed source condition: got %v, want os.ErrNotExist", err)
+	}
+
+	if _, err := s.CopyObject(source, "missing.png", target, "copy.png", storage.CopyObjectOptions{}); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("copying a missing object: got %v, want os.ErrNotExist", err)
+	}
+	if _, err := s.CopyObject(source, "original.png", "conformance-missing", "copy.png", storage.CopyObjectOptions{}); !errors.Is(err, storage.ErrNoSuchBucket) {
+		t.Errorf("copying to a missing bucket: got %v, want ErrNoSuchBucket", err)
+	}
+}
+
+func testMoveObject(t *testing.T, s storage.Storage) {
+	source, target := newBucket(t, s), newBucket(t, s)
+	metadata := dto.ObjectMetadata{ContentType: "image/jpeg"}
+	original, err := s.AddObject(source, "album/cat.jpg", strings.NewReader("meow"),
+		storage.PutObjectOptions{Metadata: metadata, DecodedContentLength: -1})
+	if err != nil {
+		t.Fatalf("AddObject: %v", err)
+	}
+
+	info, err := s.MoveObject(source, "album/cat.jpg", target, "private/cat.jpg")
+	if err != nil {
+		t.Fatalf("MoveObject: %v", err)
+	}
+	if info.Key != "private/cat.jpg" || info.ETag != original.ETag || !info.LastModified.Equal(original.LastModified) {
+		t.Errorf("MoveObject returned %+v, want the ETag and date of %+v", info, original)
+	}
+	if got, moved := get(t, s, target, "private/cat.jpg", ""); got != "meow" || !reflect.DeepEqual(moved.Metadata, metadata) {
+		t.Errorf("moved object: content %q with metadata %+v, want %q with %+v", got, moved.Metadata, "meow", metadata)
+	}
+	if _, err := s.StatObject(source, "album/cat.jpg", ""); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("StatObject of the source after a move: got %v, want os.ErrNotExist", err)
+	}
+	if listing, _ := s.ListObjects(source, storage.ListObjectsOptions{MaxKeys: 1000}); len(listing.Objects) != 0 {
+		t.Errorf("source bucket after a move lists %v, want nothing", listedKeys(listing))
+	}
+
+	if _, err := s.MoveObject(target, "private/cat.jpg", target, "private/cat.jpg"); !errors.Is(err, storage.ErrInvalidMove) {
+		t.Errorf("moving an object onto itself: got %v, want ErrInvalidMove", err)
+	}
+	if _, err := s.MoveObject(source, "album/cat.jpg", target, "cat.jpg"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("moving a missing object: got %v, want os.ErrNotExist", err)
+	}
+	if _, err := s.MoveObject(target, "private/cat.jpg", "conformance-missing", "cat.jpg"); !errors.Is(err, storage.ErrNoSuchBucket) {
+		t.Errorf("moving to a missing bucket: got %v, want ErrNoSuchBucket", err)
+	}
+
+	// Moving out of a versioned bucket keeps the history of the source
+	if err := s.SetBucketVersioning(target, storage.VersioningEnabled); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := get(t, s, target, "private/cat.jpg", "")
+	if _, err := s.MoveObject(target, "private/cat.jpg", source, "cat.jpg"); err != nil {
+		t.Fatalf("MoveObject out of a versioned bucket: %v", err)
+	}
+	if got, _ := get(t, s, source, "cat.jpg", ""); got != before {
+		t.Errorf("object moved out of a versioned bucket = %q, want %q", got, before)
+	}
+	versions, err := s.ListObjectVersions(target, storage.ListObjectVersionsOptions{MaxKeys: 1000})
+	if err != nil || len(versions.Versions) != 2 || !versions.Versions[0].IsDeleteMarker || versions.Versions[1].IsDeleteMarker {
+		t.Errorf("versions of the source after a move = %+v, %v, want a delete marker over the moved version", versions.Versions, err)
+	}
+}
+
+func testObjectTagging(t *testing.T, s storage.Storage) {
+	bucket := newBucket(t, s)
+	original := map[string]string{"rendition": "original", "album": "2024"}
+	if _, err := s.AddObject(bucket, "cat.jpg", strings.NewReader("meow"),
+		storage.PutObjectOptions{Tags: original, DecodedContentLength: -1}); err != nil {
+		t.Fatalf("AddObject with tags: %v", err)
+	}
+	put(t, s, bucket, "cat-small.jpg", "m")
+	if _, err := s.PutObjectTagging(bucket, "cat-small.jpg", "", map[string]string{"rendition": "thumbnail", "album": "2024"}); err != nil {
+		t.Fatalf("PutObjectTagging: %v", err)
+	}
+	put(t, s, bucket, "untagged.jpg", "content")
+
+	if info, err := s.StatObject(bucket, "cat.jpg", ""); err != nil || !reflect.DeepEqual(info.Tags, original) {
+		t.Errorf("StatObject tags = %v, %v, want %v", info.Tags, err, original)
+	}
+
+	// Copies keep the tags of the source unless they are replaced
+	if _, err := s.CopyObject(bucket, "cat.jpg", bucket, "copy.jpg", storage.CopyObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := s.StatObject(bucket, "copy.jpg", ""); !reflect.DeepEqual(info.Tags, original) {
+		t.Errorf("copied tags = %v, want %v", info.Tags, original)
+	}
+	if _, err := s.CopyObject(bucket, "cat.jpg", bucket, "copy.jpg", storage.CopyObjectOptions{Tags: map[string]string{}}); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := s.StatObject(bucket, "copy.jpg", ""); len(info.Tags) != 0 {
+		t.Errorf("tags of a copy replacing them with none = %v, want none", info.Tags)
+	}
+
+	// Listing by tags, one page at a time
+	filter := storage.ListObjectsOptions{MaxKeys: 1, Tags: map[string]string{"album": "2024"}}
+	var listed []string
+	for {
+		listing, err := s.ListObjects(bucket, filter)
+		if err != nil {
+			t.Fatalf("ListObjects with tags: %v", err)
+		}
+		listed = append(listed, listedKeys(listing)...)
+		if !listing.IsTruncated {
+			break
+		}
+		filter.StartAfter = listing.NextMarker
+	}
+	if want := []string{"cat-small.jpg", "cat.jpg"}; !reflect.DeepEqual(listed, want) {
+		t.Errorf("ListObjects with tag album=2024 = %v, want %v", listed, want)
+	}
+	listing, _ := s.ListObjects(bucket, storage.ListObjectsOptions{MaxKeys: 1000, Tags: map[string]string{"album": "2024", "rendition": "original"}})
+	if keys := listedKeys(listing); !reflect.DeepEqual(keys, []string{"cat.jpg"}) {
+		t.Errorf("ListObjects with two tags = %v, want [cat.jpg]", keys)
+	}
+
+	// Each version has its own tags
+	if err := s.SetBucketVersioning(bucket, storage.VersioningEnabled); err != nil {
+		t.Fatal(err)
+	}
+	first := put(t, s, bucket, "versioned.jpg", "v1")
+	put(t, s, bucket, "versioned.jpg", "v2")
+	if _, err := s.PutObjectTagging(bucket, "versioned.jpg", first.VersionID, map[string]string{"state": "old"}); err != nil {
+		t.Fatalf("PutObjectTagging of a previous version: %v", err)
+	}
+	if info, _ := s.StatObject(bucket, "versioned.jpg", first.VersionID); info.Tags["state"] != "old" {
+		t.Errorf("tags of the previous version = %v, want state=old", info.Tags)
+	}
+	if info, _ := s.StatObject(bucket, "versioned.jpg", ""); len(info.Tags) != 0 {
+		t.Errorf("tags of the current version = %v, want none", info.Tags)
+	}
+
+	if _, err := s.PutObjectTagging(bucket, "cat.jpg", "", nil); err != nil {
+		t.Fatalf("removing tags: %v", err)
+	}
+	if info, _ := s.StatObject(bucket, "cat.jpg", ""); len(info.Tags) != 0 {
+		t.Errorf("tags after removal = %v, want none", info.Tags)
+	}
+
+	tooMany := map[string]string{}
+	for i := 0; i < 11; i++ {
+		tooMany[fmt.Sprintf("tag%d", i)] = "value"
+	}
+	for name, tags := range map[string]map[string]string{
+		"too many tags":      tooMany,
+		"empty key":          {"": "value"},
+		"reserved prefix":    {"aws:rendition": "original"},
+		"invalid characters": {"rendition": "<original>"},
+	} {
+		if _, err := s.PutObjectTagging(bucket, "cat.jpg", "", tags); !errors.Is(err, storage.ErrInvalidTag) {
+			t.Errorf("PutObjectTagging with %s: got %v, want ErrInvalidTag", name, err)
+		}
+	}
+	if _, err := s.AddObject(bucket, "invalid.jpg", strings.NewReader("x"),
+		storage.PutObjectOptions{Tags: map[string]string{"aws:x": "y"}, DecodedContentLength: -1}); !errors.Is(err, storage.ErrInvalidTag) {
+		t.Errorf("AddObject with invalid tags: got %v, want ErrInvalidTag", err)
+	}
+	if _, err := s.PutObjectTagging(bucket, "missing.jpg", "", original); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("PutObjectTagging of a missing object: got %v, want os.ErrNotExist", err)
+	}
+}
+
+func testDeleteObject(t *testing.T, s storage.Storage) {
+	bucket := newBucket(t, s)
+	put(t, s, bucket, "albums/photo.jpg", "content")
+	put(t, s, bucket, "albums/other.jpg", "content")
+
+	if _, err := s.DeleteObject(bucket, "albums/photo.jpg", storage.DeleteObjectOptions{}); err != nil {
+		t.Fatalf("DeleteObject: %v", err)
+	}
+	if _, _, err := s.GetObject(bucket, "albums/photo.jpg", "", nil); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("GetObject after deletion: got %v, want os.ErrNotExist", err)
+	}
+	if _, err := s.StatObject(bucket, "albums/photo.jpg", ""); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("StatObject after deletion: got %v, want os.ErrNotExist", err)
+	}
+	if _, err := s.DeleteObject(bucket, "albums/photo.jpg", storage.DeleteObjectOptions{}); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("deleting a missing object: got %v, want os.ErrNotExist", err)
+	}
+
+	listing, err := s.ListObjects(bucket, storage.ListObjectsOptions{MaxKeys: 1000})
+	if err != nil {
+		t.Fatalf("ListObjects: %v", err)
+	}
+	if len(listing.Objects) != 1 || listing.Objects[0].Key != "albums/other.jpg" {
+		t.Errorf("ListObjects after deletion = %+v, want only albums/other.jpg", listing.Objects)
+	}
+}
+
+func listedKeys(listing dto.ObjectListing) []string {
+	keys := make([]string, 0, len(listing.Objects))
+	for _, object := range listing.Objects {
+		keys = append(keys, object.Key)
+	}
+	return keys
+}
+
+func testListObjects(t *testing.T, s storage.Storage) {
+	bucket := newBucket(t, s)
+	// Inserted out of order: listings are sorted by key whatever the backend
+	keys := []string{"b.txt", "a/2.txt", "c/d/e.txt", "a/1.txt", "a.txt", "c/f.txt"}
+	for _, key := range keys {
+		put(t, s, bucket, key, key)
+	}
+
+	tests := []struct {
+		name     string
+		opts     storage.ListObjectsOptions
+		keys     []string
+		prefixes []string
+		more     bool
+	}{
+		{"all", storage.ListObjectsOptions{MaxKeys: 1000},
+			[]string{"a.txt", "a/1.txt", "a/2.txt", "b.txt", "c/d/e.txt", "c/f.txt"}, nil, false},
+		{"prefix", storage.ListObjectsOptions{Prefix: "a/", MaxKeys: 1000},
+			[]string{"a/1.txt", "a/2.txt"}, nil, false},
+		{"delimiter", storage.ListObjectsOptions{Delimiter: "/", MaxKeys: 1000},
+			[]string{"a.txt", "b.txt"}, []string{"a/", "c/"}, false},
+		{"prefix and delimiter", storage.ListObjectsOptions{Prefix: "c/", Delimiter: "/", MaxKeys: 1000},
+			[]string{"c/f.txt"}, []string{"c/d/"}, false},
+		{"max keys", storage.ListObjectsOptions{MaxKeys: 2},
+			[]string{"a.txt", "a/1.txt"}, nil, true},
+		{"start after", storage.ListObjectsOptions{StartAfter: "a/2.txt", MaxKeys: 1000},
+			[]string{"b.txt", "c/d/e.txt", "c/f.txt"}, nil, false},
+		{"no match", storage.ListObjectsOptions{Prefix: "z", MaxKeys: 1000},
+			[]string{}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listing, err := s.ListObjects(bucket, tt.opts)
+			if err != nil {
+				t.Fatalf("ListObjects(%+v): %v", tt.opts, err)
+			}
+			if got := listedKeys(listing); !reflect.DeepEqual(got, tt.keys) {
+				t.Errorf("keys = %v, want %v", got, tt.keys)
+			}
+			if len(listing.CommonPrefixes) != 0 || len(tt.prefixes) != 0 {
+				if !reflect.DeepEqual(listing.CommonPrefixes, tt.prefixes) {
+					t.Errorf("common prefixes = %v, want %v", listing.CommonPrefixes, tt.prefixes)
+				}
+			}
+			if listing.IsTruncated != tt.more {
+				t.Errorf("IsTruncated = %v, want %v", listing.IsTruncated, tt.more)
+			}
+		})
+	}
+
+	// Following NextMarker visits every key exactly once
+	var all []string
+	opts := storage.ListObjectsOptions{MaxKeys: 4}
+	for {
+		listing, err := s.ListObjects(bucket, opts)
+		if err != nil {
+			t.Fatalf("ListObjects(%+v): %v", opts, err)
+		}
+		all = append(all, listedKeys(listing)...)
+		if !listing.IsTruncated {
+			break
+		}
+		opts.StartAfter = listing.NextMarker
+	}
+	if want := []string{"a.txt", "a/1.txt", "a/2.txt", "b.txt", "c/d/e.txt", "c/f.txt"}; !reflect.DeepEqual(all, want) {
+		t.Errorf("paginated keys = %v, want %v", all, want)
+	}
+
+	listing, _ := s.ListObjects(bucket, storage.ListObjectsOptions{Prefix: "b.txt", MaxKeys: 1000})
+	if len(listing.Objects) != 1 || listing.Objects[0].ETag != eTag("b.txt") || listing.Objects[0].Size != len("b.txt") {
+		t.Errorf("listed object = %+v, want ETag %s and size %d", listing.Objects, eTag("b.txt"), len("b.txt"))
+	}
+}
+
+func testNotFound(t *testing.T, s storage.Storage) {
+	bucket := newBucket(t, s)
+	missingBucket := "conformance-missing"
+
+	if _, _, err := s.GetObject(bucket, "missing.jpg", "", nil); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("GetObject on a missing key: got %v, want os.ErrNotExist", err)
+	}
+	if _, err := s.StatObject(bucket, "missing.jpg", ""); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("StatObject on a missing key: got %v, want os.ErrNotExist", err)
+	}
+	if exists, err := s.CheckBucketExists(missingBucket); err != nil || exists {
+		t.Errorf("CheckBucketExists on a missing bucket = %v, %v, want false", exists, err)
+	}
+	if _, err := s.AddObject(missingBucket, "photo.jpg", strings.NewReader("content"),
+		storage.PutObjectOptions{DecodedContentLength: -1}); !errors.Is(err, storage.ErrNoSuchBucket) {
+		t.Errorf("AddObject to a missing bucket: got %v, want ErrNoSuchBucket", err)
+	}
+	if _, err := s.ListObjects(missingBucket, storage.ListObjectsOptions{MaxKeys: 1000}); !errors.Is(err, storage.ErrNoSuchBucket) {
+		t.Errorf("ListObjects on a missing bucket: got %v, want ErrNoSuchBucket", err)
+	}
+	if err := s.DeleteBucket(missingBucket); !errors.Is(err, storage.ErrNoSuchBucket) {
+		t.Errorf("DeleteBucket on a missing bucket: got %v, want ErrNoSuchBucket", err)
+	}
+	if _, err := s.CreateMultipartUpload(missingBucket, "video.mp4", storage.MultipartUploadOptions{}); !errors.Is(err, storage.ErrNoSuchBucket) {
+		t.Errorf("CreateMultipartUpload on a missing bucket: got %v, want ErrNoSuchBucket", err)
+	}
+	if _, err := s.ListParts(bucket, "video.mp4", "missing-upload", 0, 1000); !errors.Is(err, storage.ErrNoSuchUpload) {
+		t.Errorf("ListParts on a missing upload: got %v, want ErrNoSuchUpload", err)
+	}
+	if _, err := s.GetBucketLifecycle(bucket); !errors.Is(err, storage.ErrNoSuchLifecycle) {
+		t.Errorf("GetBucketLifecycle without configuration: got %v, want ErrNoSuchLifecycle", err)
+	}
+	if _, err := s.GetObjectLockConfiguration(bucket); !errors.Is(err, storage.ErrObjectLockNotEnabled) {
+		t.Errorf("GetObjectLockConfiguration without configuration: got %v, want ErrObjectLockNotEnabled", err)
+	}
+}
+
+func testInvalidNames(t *testing.T, s storage.Storage) {
+	bucket := newBucket(t, s)
+
+	for _, name := range []string{"", ".s3clone", "photos/2024", "..\\photos"} {
+		if err := s.CreateBucket(name, storage.CreateBucketOptions{}); !errors.Is(err, storage.ErrInvalidBucketName) {
+			t.Errorf("CreateBucket(%q): got %v, want ErrInvalidBucketName", name, err)
+		}
+	}
+	for _, key := range []string{"", "../escape", "a//b", "a/./b"} {
+		_, err := s.AddObject(bucket, key, strings.NewReader("content"), storage.PutObjectOptions{DecodedContentLength: -1})
+		if !errors.Is(err, storage.ErrInvalidObjectName) {
+			t.Errorf("AddObject(%q): got %v, want ErrInvalidObjectName", key, err)
+		}
+	}
+	_, err := s.AddObject(bucket, strings.Repeat("k", 1025), strings.NewReader("content"), storage.PutObjectOptions{DecodedContentLength: -1})
+	if !errors.Is(err, storage.ErrKeyTooLong) {
+		t.Errorf("AddObject with a 1025-byte key: got %v, want ErrKeyTooLong", err)
+	}
+}
+
+func testMultipartUpload(t *testing.T, s storage.Storage) {
+	bucket := newBucket(t, s)
+	metadata := dto.ObjectMetadata{ContentType: "video/mp4"}
+	uploadID, err := s.CreateMultipartUpload(bucket, "video.mp4", storage.MultipartUploadOptions{Metadata: metadata})
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload: %v", err)
+	}
+
+	uploads, err := s.ListMultipartUploads(bucket, "", "", "", 1000)
+	if err != nil || len(uploads.Uploads) != 1 || uploads.Uploads[0].UploadId != uploadID {
+		t.Errorf("ListMultipartUploads = %+v, %v, want the new upload", uploads.Uploads, err)
+	}
+
+	first := strings.Repeat("a", 5*1024*1024)
+	firstETag, err := s.UploadPart(bucket, "video.mp4", uploadID, 1, strings.NewReader(first), storage.PutObjectOptions{DecodedContentLength: -1})
+	if err != nil {
+		t.Fatalf("UploadPart(1): %v", err)
+	}
+	secondETag, err := s.UploadPart(bucket, "video.mp4", uploadID, 2, strings.NewReader("tail"), storage.PutObjectOptions{DecodedContentLength: -1})
+	if err != nil {
+		t.Fatalf("UploadPart(2): %v", err)
+	}
+	if firstETag != eTag(first) || secondETag != eTag("tail") {
+		t.Errorf("part ETags = %s, %s, want the MD5 of each part", firstETag, secondETag)
+	}
+	if _, err := s.UploadPart(bucket, "video.mp4", uploadID, 0, strings.NewReader("x"), storage.PutObjectOptions{DecodedContentLength: -1}); !errors.Is(err, storage.ErrInvalidPartNumber) {
+		t.Errorf("UploadPart(0): got %v, want ErrInvalidPartNumber", err)
+	}
+
+	parts, err := s.ListParts(bucket, "video.mp4", uploadID, 0, 1000)
+	if err != nil || len(parts.Parts) != 2 || parts.Parts[0].PartNumber != 1 || parts.Parts[1].Size != int64(len("tail")) {
+		t.Errorf("ListParts = %+v, %v, want parts 1 and 2", parts.Parts, err)
+	}
+
+	_, err = s.CompleteMultipartUpload(bucket, "video.mp4", uploadID, []dto.CompletedPart{
+		{PartNumber: 1, ETag: firstETag}, {PartNumber: 1, ETag: firstETag},
+	})
+	if !errors.Is(err, storage.ErrInvalidPartOrder) {
+		t.Errorf("completing with a repeated part: got %v, want ErrInvalidPartOrder", err)
+	}
+
+	info, err := s.CompleteMultipartUpload(bucket, "video.mp4", uploadID, []dto.CompletedPart{
+		{PartNumber: 1, ETag: firstETag}, {PartNumber: 2, ETag: secondETag},
+	})
+	if err != nil {
+		t.Fatalf("CompleteMultipartUpload: %v", err)
+	}
+	if !strings.HasSuffix(info.ETag, `-2"`) || info.Size != int64(len(first)+len("tail")) {
+		t.Errorf("CompleteMultipartUpload returned %+v, want a multipart ETag and the total size", info)
+	}
+	got, stat := get(t, s, bucket, "video.mp4", "")
+	if got != first+"tail" || stat.ETag != info.ETag || !reflect.DeepEqual(stat.Metadata, metadata) {
+		t.Errorf("completed object: %d bytes with ETag %s and metadata %+v", len(got), stat.ETag, stat.Metadata)
+	}
+	if _, err := s.ListParts(bucket, "video.mp4", uploadID, 0, 1000); !errors.Is(err, storage.ErrNoSuchUpload) {
+		t.Errorf("ListParts after completion: got %v, want ErrNoSuchUpload", err)
+	}
+
+	// A copy is uploaded in one piece: its ETag is the MD5 of the content, not the multipart ETag
+	copied, err := s.CopyObject(bucket, "video.mp4", bucket, "video-copy.mp4", storage.CopyObjectOptions{})
+	if err != nil || copied.ETag != eTag(first+"tail") {
+		t.Errorf("CopyObject of the completed object = %+v, %v, want ETag %s", copied, err, eTag(first+"tail"))
+	}
+
+	// Every part but the last must reach the minimum size
+	uploadID, err = s.CreateMultipartUpload(bucket, "small.bin", storage.MultipartUploadOptions{})
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload: %v", err)
+	}
+	var completed []dto.CompletedPart
+	for i := 1; i <= 2; i++ {
+		partETag, err := s.UploadPart(bucket, "small.bin", uploadID, i, strings.NewReader("small"), storage.PutObjectOptions{DecodedContentLength: -1})
+		if err != nil {
+			t.Fatalf("UploadPart(%d): %v", i, err)
+		}
+		completed = append(completed, dto.CompletedPart{PartNumber: i, ETag: partETag})
+	}
+	if _, err := s.CompleteMultipartUpload(bucket, "small.bin", uploadID, completed); !errors.Is(err, storage.ErrEntityTooSmall) {
+		t.Errorf("completing with a small first part: got %v, want ErrEntityTooSmall", err)
+	}
+	if err := s.AbortMultipartUpload(bucket, "small.bin", uploadID); err != nil {
+		t.Errorf("AbortMultipartUpload: %v", err)
+	}
+	if err := s.AbortMultipartUpload(bucket, "small.bin", uploadID); !errors.Is(err, storage.ErrNoSuchUpload) {
+		t.Errorf("aborting twice: got %v, want ErrNoSuchUpload", err)
+	}
+	if _, err := s.StatObject(bucket, "small.bin", ""); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("StatObject after abort: got %v, want os.ErrNotExist", err)
+	}
+}
+
+func testVersioning(t *testing.T, s storage.Storage) {
+	bucket := newBucket(t, s)
+	if status, err := s.GetBucketVersioning(bucket); err != nil || status != "" {
+		t.Errorf("GetBucketVersioning on a new bucket = %q, %v, want \"\"", status, err)
+	}
+	if err := s.SetBucketVersioning(bucket, "Sometimes"); !errors.Is(err, storage.ErrInvalidVersioning) {
+		t.Errorf("SetBucketVersioning with an unknown status: got %v, want ErrInvalidVersioning", err)
+	}
+	if err := s.SetBucketVersioning(bucket, storage.VersioningEnabled); err != nil {
+		t.Fatalf("SetBucketVersioning: %v", err)
+	}
+
+	v1 := put(t, s, bucket, "photo.jpg", "first")
+	v2 := put(t, s, bucket, "photo.jpg", "second")
+	if v1.VersionID == "" || v2.VersionID == "" || v1.VersionID == v2.VersionID {
+		t.Fatalf("version ids = %q, %q, want two distinct ids", v1.VersionID, v2.VersionID)
+	}
+	if got, _ := get(t, s, bucket, "photo.jpg", ""); got != "second" {
+		t.Errorf("current version = %q, want %q", got, "second")
+	}
+	if got, info := get(t, s, bucket, "photo.jpg", v1.VersionID); got != "first" || info.VersionID != v1.VersionID {
+		t.Errorf("version %s = %q (%s), want %q", v1.VersionID, got, info.VersionID, "first")
+	}
+	if _, err := s.StatObject(bucket, "photo.jpg", "0123456789abcdef0123456789abcdef"); err == nil {
+		t.Errorf("StatObject on an unknown version: got no error")
+	}
+
+	marker, err := s.DeleteObject(bucket, "photo.jpg", storage.DeleteObjectOptions{})
+	if err != nil || !marker.IsDeleteMarker || marker.VersionID == "" {
+		t.Fatalf("DeleteObject in a versioned bucket = %+v, %v, want a delete marker", marker, err)
+	}
+	if _, err := s.StatObject(bucket, "photo.jpg", ""); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("StatObject behind a delete marker: got %v, want os.ErrNotExist", err)
+	}
+	if _, err := s.StatObject(bucket, "photo.jpg", marker.VersionID); !errors.Is(err, storage.ErrDeleteMarker) {
+		t.Errorf("StatObject on the delete marker: got %v, want ErrDeleteMarker", err)
+	}
+
+	listing, err := s.ListObjectVersions(bucket, storage.ListObjectVersionsOptions{MaxKeys: 1000})
+	if err != nil {
+		t.Fatalf("ListObjectVersions: %v", err)
+	}
+	want := []string{marker.VersionID, v2.VersionID, v1.VersionID}
+	var got []string
+	for _, version := range listing.Versions {
+		got = append(got, version.VersionID)
+	}
+	if !reflect.DeepEqual(got, want) || !listing.Versions[0].IsLatest || !listing.Versions[0].IsDeleteMarker || listing.Versions[1].IsLatest {
+		t.Errorf("ListObjectVersions = %+v, want the marker then %s then %s, newest first", listing.Versions, v2.VersionID, v1.VersionID)
+	}
+
+	// Removing the delete marker brings the previous version back
+	if _, err := s.DeleteObject(bucket, "photo.jpg", storage.DeleteObjectOptions{VersionID: marker.VersionID}); err != nil {
+		t.Fatalf("deleting the delete marker: %v", err)
+	}
+	if got, info := get(t, s, bucket, "photo.jpg", ""); got != "second" || info.VersionID != v2.VersionID {
+		t.Errorf("current version after removing the marker = %q (%s), want %q (%s)", got, info.VersionID, "second", v2.VersionID)
+	}
+	if _, err := s.DeleteObject(bucket, "photo.jpg", storage.DeleteObjectOptions{VersionID: v2.VersionID}); err != nil {
+		t.Fatalf("deleting version %s: %v", v2.VersionID, err)
+	}
+	if got, _ := get(t, s, bucket, "photo.jpg", ""); got != "first" {
+		t.Errorf("current version after deleting the latest = %q, want %q", got, "first")
+	}
+
+	if err := s.SetBucketVersioning(bucket, storage.VersioningSuspended); err != nil {
+		t.Fatalf("SetBucketVersioning(Suspended): %v", err)
+	}
+	if info := put(t, s, bucket, "photo.jpg", "null"); info.VersionID != "null" {
+		t.Errorf("version id while suspended = %q, want \"null\"", info.VersionID)
+	}
+	if info := put(t, s, bucket, "photo.jpg", "null again"); info.VersionID != "null" {
+		t.Errorf("version id while suspended = %q, want \"null\"", info.VersionID)
+	}
+	listing, _ = s.ListObjectVersions(bucket, storage.ListObjectVersionsOptions{MaxKeys: 1000})
+	if len(listing.Versions) != 2 || listing.Versions[0].VersionID != "null" || listing.Versions[1].VersionID != v1.VersionID {
+		t.Errorf("ListObjectVersions while suspended = %+v, want the null version replaced and %s kept", listing.Versions, v1.VersionID)
+	}
+}
+
+func testBucketCors(t *testing.T, s storage.Storage) {
+	bucket := newBucket(t, s)
+	if _, err := s.GetBucketCors(bucket); !errors.Is(err, storage.ErrNoSuchCORS) {
+		t.Errorf("GetBucketCors without configuration: got %v, want ErrNoSuchCORS", err)
+	}
+
+	config := dto.CORSConfiguration{Rules: []dto.CORSRule{{
+		AllowedOrigins: []string{"https://*.example.com"},
+		AllowedMethods: []string{"GET", "PUT"},
+		AllowedHeaders: []string{"*"},
+		MaxAgeSeconds:  300,
+	}}}
+	if err := s.PutBucketCors(bucket, config); err != nil {
+		t.Fatalf("PutBucketCors: %v", err)
+	}
+	if got, err := s.GetBucketCors(bucket); err != nil || !reflect.DeepEqual(got.Rules, config.Rules) {
+		t.Errorf("GetBucketCors = %+v, %v, want %+v", got.Rules, err, config.Rules)
+	}
+
+	invalid := dto.CORSConfiguration{Rules: []dto.CORSRule{{AllowedOrigins: []string{"*"}, AllowedMethods: []string{"PATCH"}}}}
+	if err := s.PutBucketCors(bucket, invalid); !errors.Is(err, storage.ErrInvalidCORS) {
+		t.Errorf("PutBucketCors with an unknown method: got %v, want ErrInvalidCORS", err)
+	}
+	if err := s.PutBucketCors("conformance-missing", config); !errors.Is(err, storage.ErrNoSuchBucket) {
+		t.Errorf("PutBucketCors on a missing bucket: got %v, want ErrNoSuchBucket", err)
+	}
+
+	if err := s.DeleteBucketCors(bucket); err != nil {
+		t.Fatalf("DeleteBucketCors: %v", err)
+	}
+	if _, err := s.GetBucketCors(bucket); !errors.Is(err, storage.ErrNoSuchCORS) {
+		t.Errorf("GetBucketCors after deletion: got %v, want ErrNoSuchCORS", err)
+	}
+}
+
+func testBucketPolicy(t *testing.T, s storage.Storage) {
+	bucket := newBucket(t, s)
+	if _, err := s.GetBucketPolicy(bucket); !errors.Is(err, storage.ErrNoSuchBucketPolicy) {
+		t.Errorf("GetBucketPolicy without policy: got %v, want ErrNoSuchBucketPolicy", err)
+	}
+
+	policy := dto.BucketPolicy{Version: "2012-10-17", Statements: []dto.BucketPolicyStatement{{
+		Effect:     "Allow",
+		Principal:  dto.PolicyPrincipal{AWS: dto.StringList{"*"}},
+		Actions:    dto.StringList{"s3:GetObject"},
+		Resources:  dto.StringList{"arn:aws:s3:::" + bucket + "/public/*"},
+		Conditions: map[string]map[string]dto.StringList{"IpAddress": {"aws:SourceIp": {"192.0.2.0/24"}}},
+	}}}
+	if err := s.PutBucketPolicy(bucket, policy); err != nil {
+		t.Fatalf("PutBucketPolicy: %v", err)
+	}
+	if got, err := s.GetBucketPolicy(bucket); err != nil || !reflect.DeepEqual(got, policy) {
+		t.Errorf("GetBucketPolicy = %+v, %v, want %+v", got, err, policy)
+	}
+	if err := s.PutBucketPolicy("conformance-missing", policy); !errors.Is(err, storage.ErrNoSuchBucket) {
+		t.Errorf("PutBucketPolicy on a missing bucket: got %v, want ErrNoSuchBucket", err)
+	}
+
+	if err := s.DeleteBucketPolicy(bucket); err != nil {
+		t.Fatalf("DeleteBucketPolicy: %v", err)
+	}
+	if _, err := s.GetBucketPolicy(bucket); !errors.Is(err, storage.ErrNoSuchBucketPolicy) {
+		t.Errorf("GetBucketPolicy after deletion: got %v, want ErrNoSuchBucketPolicy", err)
+	}
+}
+
+func testBucketNotification(t *testing.T, s storage.Storage) {
+	bucket := newBucket(t, s)
+	if config, err := s.GetBucketNotification(bucket); err != nil || len(config.Webhooks) != 0 {
+		t.Errorf("GetBucketNotification without configuration = %+v, %v, want an empty configuration", config, err)
+	}
+
+	config := dto.NotificationConfiguration{Webhooks: []dto.WebhookConfiguration{{
+		ID:     "thumbnails",
+		URL:    "http://localhost:8080/hooks/s3",
+		Events: []string{"s3:ObjectCreated:*"},
+		Filter: &dto.NotificationFilter{Key: dto.KeyFilter{Rules: []dto.FilterRule{{Name: "suffix", Value: ".jpg"}}}},
+	}}}
+	if err := s.PutBucketNotification(bucket, config); err != nil {
+		t.Fatalf("PutBucketNotification: %v", err)
+	}
+	if got, err := s.GetBucketNotification(bucket); err != nil || !reflect.DeepEqual(got.Webhooks, config.Webhooks) {
+		t.Errorf("GetBucketNotification = %+v, %v, want %+v", got.Webhooks, err, config.Webhooks)
+	}
+	if err := s.PutBucketNotification("conformance-missing", config); !errors.Is(err, storage.ErrNoSuchBucket) {
+		t.Errorf("PutBucketNotification on a missing bucket: got %v, want ErrNoSuchBucket", err)
+	}
+
+	if err := s.PutBucketNotification(bucket, dto.NotificationConfiguration{}); err != nil {
+		t.Fatalf("PutBucketNotification with no webhook: %v", err)
+	}
+	if got, err := s.GetBucketNotification(bucket); err != nil || len(got.Webhooks) != 0 {
+		t.Errorf("GetBucketNotification after removal = %+v, %v, want an empty configuration", got, err)
+	}
+}
+
+func usage(t *testing.T, s storage.Storage, bucket string) (int64, int64) {
+	t.Helper()
+	usage, err := s.GetBucketUsage(bucket)
+	if err != nil {
+		t.Fatalf("GetBucketUsage(%q): %v", bucket, err)
+	}
+	return usage.Bytes, usage.Objects
+}
+
+func testBucketUsage(t *testing.T, s storage.Storage) {
+	bucket, other := newBucket(t, s), newBucket(t, s)
+	if bytes, objects := usage(t, s, bucket); bytes != 0 || objects != 0 {
+		t.Errorf("usage of a new bucket = %d bytes, %d objects, want nothing", bytes, objects)
+	}
+
+	put(t, s, bucket, "cat.jpg", "meow")
+	put(t, s, bucket, "dog.jpg", "woof!")
+	put(t, s, bucket, "cat.jpg", "purr purr")
+	if bytes, objects := usage(t, s, bucket); bytes != 14 || objects != 2 {
+		t.Errorf("usage after an overwrite = %d bytes, %d objects, want 14 bytes, 2 objects", bytes, objects)
+	}
+
+	if _, err := s.CopyObject(bucket, "cat.jpg", other, "cat.jpg", storage.CopyObjectOptions{}); err != nil {
+		t.Fatalf("CopyObject: %v", err)
+	}
+	if _, err := s.MoveObject(bucket, "dog.jpg", other, "dog.jpg"); err != nil {
+		t.Fatalf("MoveObject: %v", err)
+	}
+	if bytes, objects := usage(t, s, bucket); bytes != 9 || objects != 1 {
+		t.Errorf("usage of the source after a move = %d bytes, %d objects, want 9 bytes, 1 object", bytes, objects)
+	}
+	if bytes, objects := usage(t, s, other); bytes != 14 || objects != 2 {
+		t.Errorf("usage of the target = %d bytes, %d objects, want 14 bytes, 2 objects", bytes, objects)
+	}
+
+	if _, err := s.DeleteObject(bucket, "cat.jpg", storage.DeleteObjectOptions{}); err != nil {
+		t.Fatalf("DeleteObject: %v", err)
+	}
+	if bytes, objects := usage(t, s, bucket); bytes != 0 || objects != 0 {
+		t.Errorf("usage after a delete = %d bytes, %d objects, want nothing", bytes, objects)
+	}
+
+	// Noncurrent versions are stored too: they count until they are deleted for good
+	if err := s.SetBucketVersioning(other, storage.VersioningEnabled); err != nil {
+		t.Fatal(err)
+	}
+	v2 := put(t, s, other, "cat.jpg", "hiss")
+	if _, err := s.DeleteObject(other, "dog.jpg", storage.DeleteObjectOptions{}); err != nil {
+		t.Fatalf("DeleteObject in a versioned bucket: %v", err)
+	}
+	if bytes, objects := usage(t, s, other); bytes != 18 || objects != 3 {
+		t.Errorf("usage with noncurrent versions = %d bytes, %d objects, want 18 bytes, 3 objects", bytes, objects)
+	}
+	if _, err := s.DeleteObject(other, "cat.jpg", storage.DeleteObjectOptions{VersionID: v2.VersionID}); err != nil {
+		t.Fatalf("DeleteObject of a version: %v", err)
+	}
+	if bytes, objects := usage(t, s, other); bytes != 14 || objects != 2 {
+		t.Errorf("usage after deleting a version = %d bytes, %d objects, want 14 bytes, 2 objects", bytes, objects)
+	}
+
+	// While versioning is suspended, a new write replaces the null version
+	if err := s.SetBucketVersioning(other, storage.VersioningSuspended); err != nil {
+		t.Fatal(err)
+	}
+	put(t, s, other, "bird.jpg", "tweet")
+	put(t, s, other, "bird.jpg", "chirp!")
+	if bytes, objects := usage(t, s, other); bytes != 20 || objects != 3 {
+		t.Errorf("usage after replacing a null version = %d bytes, %d objects, want 20 bytes, 3 objects", bytes, objects)
+	}
+
+	if _, err := s.GetBucketUsage("conformance-missing"); !errors.Is(err, storage.ErrNoSuchBucket) {
+		t.Errorf("GetBucketUsage on a missing bucket: got %v, want ErrNoSuchBucket", err)
+	}
+}
+
+func testBucketQuota(t *testing.T, s storage.Storage) {
+	bucket := newBucket(t, s)
+	if got, err := s.GetBucketUsage(bucket); err != nil || got.Quota != nil {
+		t.Errorf("quota of a new bucket = %+v, %v, want none", got.Quota, err)
+	}
+	quota := dto.BucketQuota{MaxBytes: 10, MaxObjects: 2}
+	if err := s.PutBucketQuota(bucket, quota); err != nil {
+		t.Fatalf("PutBucketQuota: %v", err)
+	}
+	if got, err := s.GetBucketUsage(bucket); err != nil || got.Quota == nil || *got.Quota != quota {
+		t.Errorf("GetBucketUsage quota = %+v, %v, want %+v", got.Quota, err, quota)
+	}
+
+	put(t, s, bucket, "cat.jpg", "meow")
+	put(t, s, bucket, "dog.jpg", "woof")
+	if _, err := s.AddObject(bucket, "bird.jpg", strings.NewReader("a"), storage.PutObjectOptions{DecodedContentLength: -1}); !errors.Is(err, storage.ErrQuotaExceeded) {
+		t.Errorf("AddObject over the object limit: got %v, want ErrQuotaExceeded", err)
+	}
+	if _, err := s.AddObject(bucket, "cat.jpg", strings.NewReader("meow meow"), storage.PutObjectOptions{DecodedContentLength: -1}); !errors.Is(err, storage.ErrQuotaExceeded) {
+		t.Errorf("AddObject over the byte limit: got %v, want ErrQuotaExceeded", err)
+	}
+	if _, err := s.CopyObject(bucket, "cat.jpg", bucket, "copy.jpg", storage.CopyObjectOptions{}); !errors.Is(err, storage.ErrQuotaExceeded) {
+		t.Errorf("CopyObject over the quota: got %v, want ErrQuotaExceeded", err)
+	}
+	if _, err := s.StatObject(bucket, "bird.jpg", ""); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("StatObject of a rejected write: got %v, want os.ErrNotExist", err)
+	}
+	if got, _ := get(t, s, bucket, "cat.jpg", ""); got != "meow" {
+		t.Errorf("object after a rejected overwrite = %q, want %q", got, "meow")
+	}
+
+	// Overwriting within the limits is allowed: the replaced object is freed
+	put(t, s, bucket, "cat.jpg", "purr!")
+	if bytes, objects := usage(t, s, bucket); bytes != 9 || objects != 2 {
+		t.Errorf("usage after an overwrite = %d bytes, %d objects, want 9 bytes, 2 objects", bytes, objects)
+	}
+
+	if err := s.PutBucketQuota(bucket, dto.BucketQuota{MaxBytes: -1}); !errors.Is(err, storage.ErrInvalidQuota) {
+		t.Errorf("PutBucketQuota with a negative limit: got %v, want ErrInvalidQuota", err)
+	}
+	if err := s.PutBucketQuota("conformance-missing", quota); !errors.Is(err, storage.ErrNoSuchBucket) {
+		t.Errorf("PutBucketQuota on a missing bucket: got %v, want ErrNoSuchBucket", err)
+	}
+	if err := s.DeleteBucketQuota(bucket); err != nil {
+		t.Fatalf("DeleteBucketQuota: %v", err)
+	}
+	put(t, s, bucket, "bird.jpg", "tweet tweet")
+	if got, err := s.GetBucketUsage(bucket); err != nil || got.Quota != nil || got.Objects != 3 {
+		t.Errorf("usage without quota = %+v, %v, want 3 objects and no quota", got, err)
+	}
+}
+
+func putIfNoneMatch(s storage.Storage, bucket, key, content string) (dto.ObjectInfo, error) {
+	return s.AddObject(bucket, key, strings.NewReader(content), storage.PutObjectOptions{DecodedContentLength: -1, IfNoneMatch: true})
+}
+
+func testConditionalPut(t *testing.T, s storage.Storage) {
+	bucket := newBucket(t, s)
+
+	if _, err := putIfNoneMatch(s, bucket, "photo.jpg", "first"); err != nil {
+		t.Fatalf("conditional AddObject of a new key: %v", err)
+	}
+	if _, err := putIfNoneMatch(s, bucket, "photo.jpg", "second"); !errors.Is(err, storage.ErrPreconditionFailed) {
+		t.Fatalf("conditional AddObject of an existing key: got %v, want ErrPreconditionFailed", err)
+	}
+	if content, _ := get(t, s, bucket, "photo.jpg", ""); content != "first" {
+		t.Errorf("content after a rejected conditional upload = %q, want %q", content, "first")
+	}
+
+	// A deleted key, even behind a delete marker, may be written again
+	if err := s.SetBucketVersioning(bucket, storage.VersioningEnabled); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.DeleteObject(bucket, "photo.jpg", storage.DeleteObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := putIfNoneMatch(s, bucket, "photo.jpg", "third"); err != nil {
+		t.Errorf("conditional AddObject over a delete marker: %v", err)
+	}
+
+	// Of concurrent uploaders of the same key, exactly one wins
+	const workers = 8
+	var wg sync.WaitGroup
+	winners := make(chan string, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			content := fmt.Sprintf("uploader %d", i)
+			_, err := putIfNoneMatch(s, bucket, "race.jpg", content)
+			switch {
+			case err == nil:
+				winners <- content
+			case !errors.Is(err, storage.ErrPreconditionFailed):
+				t.Errorf("%s: %v", content, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(winners)
+	if len(winners) != 1 {
+		t.Fatalf("%d conditional uploads of the same key succeeded, want 1", len(winners))
+	}
+	if content, _ := get(t, s, bucket, "race.jpg", ""); content != <-winners {
+		t.Errorf("race.jpg = %q, want the content of the only successful upload", content)
+	}
+}
+
+func testConcurrentAccess(t *testing.T, s storage.Storage) {
+	bucket := newBucket(t, s)
+	const workers = 8
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := fmt.Sprintf("worker-%d.txt", i)
+			for round := 0; round < 10; round++ {
+				content := fmt.Sprintf("%s round %d", key, round)
+				if _, err := s.AddObject(bucket, key, strings.NewReader(content), storage.PutObjectOptions{DecodedContentLength: -1}); err != nil {
+					errs <- err
+					return
+				}
+				reader, _, err := s.GetObject(bucket, key, "", nil)
+				if err != nil {
+					errs <- err
+					return
+				}
+				got, err := io.ReadAll(reader)
+				reader.Close()
+				if err != nil || !bytes.Equal(got, []byte(content)) {
+					errs <- fmt.Errorf("%s: read %q, %v, want %q", key, got, err, content)
+					return
+				}
+				if _, err := s.ListObjects(bucket, storage.ListObjectsOptions{MaxKeys: 1000}); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	listing, err := s.ListObjects(bucket, storage.ListObjectsOptions{MaxKeys: 1000})
+	if err != nil || len(listing.Objects) != workers {
+		t.Errorf("ListObjects after concurrent writes = %d objects, %v, want %d", len(listing.Objects), err, workers)
+	}
+}
@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"my-s3-clone/dto"
+)
+
+// Limites S3 de l'étiquetage des objets
+const (
+	maxObjectTags     = 10
+	maxTagKeyLength   = 128
+	maxTagValueLength = 256
+)
+
+// Validation des étiquettes d'un objet : 10 au plus, clés de 1 à 128 caractères hors préfixe réservé "aws:",
+// valeurs de 256 caractères au plus, composées de lettres, chiffres, espaces et des signes + - = . _ : / @
+func validateTags(tags map[string]string) error {
+	if len(tags) > maxObjectTags {
+		return fmt.Errorf("%w: an object can have at most %d tags", ErrInvalidTag, maxObjectTags)
+	}
+	for key, value := range tags {
+		if key == "" || utf8.RuneCountInString(key) > maxTagKeyLength {
+			return fmt.Errorf("%w: tag keys must be 1 to %d characters long", ErrInvalidTag, maxTagKeyLength)
+		}
+		if utf8.RuneCountInString(value) > maxTagValueLength {
+			return fmt.Errorf("%w: tag values must be at most %d characters long", ErrInvalidTag, maxTagValueLength)
+		}
+		if strings.HasPrefix(strings.ToLower(key), "aws:") {
+			return fmt.Errorf("%w: the aws: prefix is reserved", ErrInvalidTag)
+		}
+		if !validTagText(key) || !validTagText(value) {
+			return fmt.Errorf("%w: %q=%q contains characters that are not allowed", ErrInvalidTag, key, value)
+		}
+	}
+	return nil
+}
+
+func validTagText(text string) bool {
+	for _, r := range text {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsSpace(r) && !strings.ContainsRune("+-=._:/@", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// Copie des étiquettes, nil pour un objet sans étiquette
+func copyTags(tags map[string]string) map[string]string {
+	if len(tags) == 0 {
+		return nil
+	}
+	copied := make(map[string]string, len(tags))
+	for key, value := range tags {
+		copied[key] = value
+	}
+	return copied
+}
+
+// Un objet correspond à un filtre s'il porte toutes ses étiquettes avec la même valeur
+func hasTags(tags, filter map[string]string) bool {
+	for key, value := range filter {
+		if actual, ok := tags[key]; !ok || actual != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Clés des objets portant toutes les étiquettes de filter, dans le même ordre
+func keysWithTags(keys []string, filter map[string]string, objectTags func(key string) (map[string]string, error)) ([]string, error) {
+	if len(filter) == 0 {
+		return keys, nil
+	}
+	matching := make([]string, 0, len(keys))
+	for _, key := range keys {
+		tags, err := objectTags(key)
+		if err != nil {
+			return nil, err
+		}
+		if hasTags(tags, filter) {
+			matching = append(matching, key)
+		}
+	}
+	return matching, nil
+}
+
+// Remplacement des étiquettes d'une version ("" pour la version courante), nil pour les supprimer
+func (fs *FileStorage) PutObjectTagging(bucketName, objectName, versionID string, tags map[string]string) (dto.ObjectInfo, error) {
+	if err := validateTags(tags); err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	return fs.updateVersion(bucketName, objectName, versionID, func(meta *objectMetadata) error {
+		meta.Tags = copyTags(tags)
+		return nil
+	})
+}
@@ -0,0 +1,700 @@
+package storage
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"my-s3-clone/dto"
+)
+
+// Statuts de versioning d'un bucket. Un bucket qui n'a jamais été versionné n'a pas de statut :
+// ses objets sont écrasés à chaque upload.
+const (
+	VersioningEnabled   = "Enabled"
+	VersioningSuspended = "Suspended"
+
+	// Identifiant des versions écrites alors que le versioning n'était pas actif
+	nullVersionID = "null"
+)
+
+// Répertoire de configuration d'un bucket
+func (fs *FileStorage) bucketConfigDir(bucketName string) string {
+	return filepath.Join(fs.root(), systemDir, "buckets", bucketName)
+}
+
+// Répertoire des versions non courantes et des marqueurs de suppression d'un bucket
+func (fs *FileStorage) bucketVersionsDir(bucketName string) string {
+	return filepath.Join(fs.root(), systemDir, "versions", bucketName)
+}
+
+// Les versions d'une clé sont rangées dans un répertoire nommé d'après le hash de la clé :
+// <versionId> pour les données, <versionId>.json pour les métadonnées
+func (fs *FileStorage) objectVersionsDir(bucketName, objectName string) string {
+	sum := sha256.Sum256([]byte(objectName))
+	return filepath.Join(fs.bucketVersionsDir(bucketName), hex.EncodeToString(sum[:]))
+}
+
+func (fs *FileStorage) versionRecordPath(bucketName, objectName, versionID string) string {
+	return filepath.Join(fs.objectVersionsDir(bucketName, objectName), versionID+".json")
+}
+
+// Les identifiants commencent par l'horodatage pour rester uniques et croissants
+func newVersionID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%016x%s", time.Now().UnixNano(), hex.EncodeToString(b)), nil
+}
+
+// Seuls "null" et les identifiants générés par le serveur sont acceptés, car ils servent de noms de fichiers
+func validateVersionID(versionID string) error {
+	if versionID == nullVersionID {
+		return nil
+	}
+	if len(versionID) != 32 || strings.ToLower(versionID) != versionID {
+		return ErrInvalidVersionID
+	}
+	if _, err := hex.DecodeString(versionID); err != nil {
+		return ErrInvalidVersionID
+	}
+	return nil
+}
+
+// Identifiant de version tel qu'exposé aux clients : les objets écrits hors versioning sont la version "null"
+func exposedVersionID(info dto.ObjectInfo) string {
+	if info.VersionID == "" {
+		return nullVersionID
+	}
+	return info.VersionID
+}
+
+// Lecture du statut de versioning d'un bucket
+func (fs *FileStorage) GetBucketVersioning(bucketName string) (string, error) {
+	exists, err := fs.CheckBucketExists(bucketName)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return "", ErrNoSuchBucket
+	}
+	return fs.versioningStatus(bucketName)
+}
+
+// Activation ou suspension du versioning. Un bucket versionné ne peut plus redevenir non versionné,
+// ni un bucket avec Object Lock être suspendu.
+func (fs *FileStorage) SetBucketVersioning(bucketName, status string) error {
+	if status != VersioningEnabled && status != VersioningSuspended {
+		return ErrInvalidVersioning
+	}
+	err := fs.updateBucketMetadata(bucketName, func(meta *bucketMetadata) error {
+		// Les versions verrouillées ne doivent pas pouvoir être écrasées comme version "null"
+		if status == VersioningSuspended && meta.ObjectLock != nil {
+			return fmt.Errorf("%w: versioning cannot be suspended on a bucket with object lock enabled", ErrInvalidBucketState)
+		}
+		meta.Versioning = status
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("Versioning of bucket %s set to %s", bucketName, status)
+	return nil
+}
+
+func (fs *FileStorage) versioningStatus(bucketName string) (string, error) {
+	meta, err := fs.bucketMetadata(bucketName)
+	return meta.Versioning, err
+}
+
+// Version courante d'une clé : chemin de ses données et métadonnées, os.ErrNotExist si la clé n'en a pas
+func (fs *FileStorage) currentVersion(bucketName, objectName string) (string, dto.ObjectInfo, error) {
+	objectPath, record, err := fs.currentRecord(bucketName, objectName)
+	return objectPath, record.info(), err
+}
+
+// Lecture sans commitMu : des métadonnées à réparer ne le sont qu'après les écritures en cours
+func (fs *FileStorage) currentRecord(bucketName, objectName string) (string, objectMetadata, error) {
+	return fs.readCurrentRecord(bucketName, objectName, fs.loadObjectMetadata)
+}
+
+// currentRecord pour les écritures, à appeler avec commitMu pris
+func (fs *FileStorage) lockedCurrentRecord(bucketName, objectName string) (string, objectMetadata, error) {
+	return fs.readCurrentRecord(bucketName, objectName, fs.repairObjectMetadata)
+}
+
+func (fs *FileStorage) readCurrentRecord(bucketName, objectName string, load func(bucketName, objectName, objectPath string, fileInfo os.FileInfo) (objectMetadata, error)) (string, objectMetadata, error) {
+	objectPath, err := fs.resolveObjectPath(bucketName, objectName)
+	if err != nil {
+		return "", objectMetadata{}, err
+	}
+
+	fileInfo, err := os.Stat(objectPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Error checking object: %v", err)
+		}
+		return objectPath, objectMetadata{}, err
+	}
+	// Un répertoire n'est que le préfixe d'autres clés, pas un objet
+	if fileInfo.IsDir() {
+		return objectPath, objectMetadata{}, os.ErrNotExist
+	}
+
+	record, err := load(bucketName, objectName, objectPath, fileInfo)
+	return objectPath, record, err
+}
+
+// Version demandée d'une clé ("" pour la version courante) : chemin de ses données et métadonnées
+func (fs *FileStorage) objectVersion(bucketName, objectName, versionID string) (string, dto.ObjectInfo, error) {
+	objectPath, record, err := fs.versionRecord(bucketName, objectName, versionID)
+	return objectPath, record.info(), err
+}
+
+func (fs *FileStorage) versionRecord(bucketName, objectName, versionID string) (string, objectMetadata, error) {
+	objectPath, current, err := fs.currentRecord(bucketName, objectName)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", objectMetadata{}, err
+	}
+	if versionID == "" {
+		return objectPath, current, err
+	}
+	if err := validateVersionID(versionID); err != nil {
+		return "", objectMetadata{}, err
+	}
+	if err == nil && exposedVersionID(current.info()) == versionID {
+		current.VersionID = versionID
+		return objectPath, current, nil
+	}
+
+	var record objectMetadata
+	if err := readJSONFile(fs.versionRecordPath(bucketName, objectName, versionID), &record); err != nil {
+		if os.IsNotExist(err) {
+			return "", objectMetadata{}, ErrNoSuchVersion
+		}
+		return "", objectMetadata{}, fmt.Errorf("failed to read version %s of %s: %v", versionID, objectName, err)
+	}
+	if record.IsDeleteMarker {
+		return "", record, ErrDeleteMarker
+	}
+	return filepath.Join(fs.objectVersionsDir(bucketName, objectName), versionID), record, nil
+}
+
+// Versions non courantes et marqueurs de suppression d'une clé, du plus récent au plus ancien
+func (fs *FileStorage) listArchivedVersions(bucketName, objectName string) ([]objectMetadata, error) {
+	records, err := filepath.Glob(filepath.Join(fs.objectVersionsDir(bucketName, objectName), "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]objectMetadata, 0, len(records))
+	for _, path := range records {
+		var record objectMetadata
+		if err := readJSONFile(path, &record); err != nil {
+			log.Printf("Skipping unreadable version record %s: %v", path, err)
+			continue
+		}
+		versions = append(versions, record)
+	}
+	sortVersions(versions)
+	return versions, nil
+}
+
+func sortVersions(versions []objectMetadata) {
+	sort.Slice(versions, func(i, j int) bool {
+		if !versions[i].LastModified.Equal(versions[j].LastModified) {
+			return versions[i].LastModified.After(versions[j].LastModified)
+		}
+		return versions[i].VersionID > versions[j].VersionID
+	})
+}
+
+// Mise de côté de la version courante d'une clé avant qu'elle soit remplacée ou masquée par un marqueur.
+// Avec keepNull à faux (versioning suspendu), une version "null" est supprimée au lieu d'être archivée.
+// À appeler avec commitMu pris.
+func (fs *FileStorage) archiveCurrentVersion(bucketName, objectName string, keepNull bool) error {
+	objectPath, record, err := fs.lockedCurrentRecord(bucketName, objectName)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	versionID := exposedVersionID(record.info())
+	if versionID == nullVersionID && !keepNull {
+		if err := os.Remove(objectPath); err != nil {
+			return fmt.Errorf("failed to remove null version of %s: %v", objectName, err)
+		}
+		fs.removeObjectMetadata(bucketName, objectName)
+		fs.addUsage(bucketName, -record.Size, -1)
+		return nil
+	}
+
+	versionsDir := fs.objectVersionsDir(bucketName, objectName)
+	if err := os.MkdirAll(versionsDir, os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create versions directory: %v", err)
+	}
+	record.VersionID = versionID
+	if err := writeJSONFile(fs.versionRecordPath(bucketName, objectName, versionID), record); err != nil {
+		return err
+	}
+	if err := os.Rename(objectPath, filepath.Join(versionsDir, versionID)); err != nil {
+		return fmt.Errorf("failed to archive version %s of %s: %v", versionID, objectName, err)
+	}
+	fs.removeObjectMetadata(bucketName, objectName)
+	return nil
+}
+
+// Suppression d'une version non courante (données et métadonnées). Des données déjà restaurées
+// comme version courante ne sont pas déduites de l'occupation du bucket.
+func (fs *FileStorage) removeArchivedVersion(bucketName, objectName, versionID string) {
+	versionsDir := fs.objectVersionsDir(bucketName, objectName)
+	var record objectMetadata
+	if err := readJSONFile(fs.versionRecordPath(bucketName, objectName, versionID), &record); err != nil && !os.IsNotExist(err) {
+		log.Printf("Failed to read version %s of %s: %v", versionID, objectName, err)
+	}
+	if err := os.Remove(filepath.Join(versionsDir, versionID)); err == nil {
+		fs.addUsage(bucketName, -record.Size, -1)
+	} else if !os.IsNotExist(err) {
+		log.Printf("Failed to remove version %s of %s: %v", versionID, objectName, err)
+	}
+	if err := os.Remove(fs.versionRecordPath(bucketName, objectName, versionID)); err != nil && !os.IsNotExist(err) {
+		log.Printf("Failed to remove %s: %v", fs.versionRecordPath(bucketName, objectName, versionID), err)
+	}
+	// Le répertoire de la clé n'est supprimé que s'il est vide
+	os.Remove(versionsDir)
+}
+
+// Préparation de l'écriture d'une nouvelle version courante (objet ou marqueur de suppression) selon le
+// statut de versioning : la version courante est archivée, ou remplacée si c'est la version "null"
+// d'un bucket suspendu. Renvoie l'identifiant de la nouvelle version ("" pour un bucket non versionné).
+// À appeler avec commitMu pris.
+func (fs *FileStorage) prepareNewVersion(bucketName, objectName string) (string, error) {
+	status, err := fs.versioningStatus(bucketName)
+	if err != nil {
+		return "", err
+	}
+
+	switch status {
+	case VersioningEnabled:
+		if err := fs.archiveCurrentVersion(bucketName, objectName, true); err != nil {
+			return "", err
+		}
+		return newVersionID()
+	case VersioningSuspended:
+		fs.removeArchivedVersion(bucketName, objectName, nullVersionID)
+		if err := fs.archiveCurrentVersion(bucketName, objectName, false); err != nil {
+			return "", err
+		}
+		return nullVersionID, nil
+	}
+	return "", nil
+}
+
+// Mise en place du fichier tmpPath comme nouvelle version courante de la clé meta.Key :
+// écrasement de l'objet existant si le bucket n'est pas versionné, nouvelle version sinon.
+// lock est le verrouillage demandé à l'upload ; à défaut, la rétention par défaut du bucket s'applique.
+// Avec ifNoneMatch, la mise en place échoue (ErrPreconditionFailed) si la clé a déjà une version courante.
+func (fs *FileStorage) commitObject(bucketName, tmpPath string, meta objectMetadata, lock dto.ObjectLock, ifNoneMatch bool) (dto.ObjectInfo, error) {
+	fs.commitMu.Lock()
+	defer fs.commitMu.Unlock()
+	return fs.lockedCommitObject(bucketName, tmpPath, meta, lock, ifNoneMatch)
+}
+
+// commitObject à appeler avec commitMu pris
+func (fs *FileStorage) lockedCommitObject(bucketName, tmpPath string, meta objectMetadata, lock dto.ObjectLock, ifNoneMatch bool) (dto.ObjectInfo, error) {
+	objectPath, err := fs.resolveObjectPath(bucketName, meta.Key)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+
+	if ifNoneMatch {
+		// Une clé supprimée (marqueur de suppression courant) n'a plus de fichier à son chemin
+		if info, err := os.Lstat(objectPath); err == nil && info.Mode().IsRegular() {
+			return dto.ObjectInfo{}, fmt.Errorf("%w: %s", ErrPreconditionFailed, meta.Key)
+		}
+	}
+	lock, err = fs.newObjectLock(bucketName, lock, time.Now())
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	if err := createObjectDirs(objectPath); err != nil {
+		return dto.ObjectInfo{}, err
+	}
+
+	// Le quota porte sur l'occupation après l'écriture : la version "null" remplacée hors versioning est déduite
+	tmpInfo, err := os.Stat(tmpPath)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	size := tmpInfo.Size()
+	if meta.Encryption != nil {
+		size = meta.Encryption.size()
+	}
+	replacedBytes, replacedObjects, err := fs.replacedUsage(bucketName, meta.Key)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	if err := fs.checkBucketQuota(bucketName, size-replacedBytes, 1-replacedObjects); err != nil {
+		return dto.ObjectInfo{}, err
+	}
+
+	versionID, err := fs.prepareNewVersion(bucketName, meta.Key)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	if err := os.Rename(tmpPath, objectPath); err != nil {
+		return dto.ObjectInfo{}, fmt.Errorf("failed to move object into place: %v", err)
+	}
+	if err := syncDir(filepath.Dir(objectPath)); err != nil {
+		log.Printf("Failed to sync directory of %s: %v", objectPath, err)
+	}
+
+	meta.VersionID = versionID
+	meta.setLock(lock)
+	saved, err := fs.saveObjectMetadata(bucketName, objectPath, meta)
+	if err != nil {
+		return saved.info(), err
+	}
+	// Hors versioning, l'objet remplacé a été écrasé par le renommage ; sinon prepareNewVersion
+	// a déjà déduit la version "null" qu'il a supprimée
+	if versionID != "" {
+		replacedBytes, replacedObjects = 0, 0
+	}
+	fs.addUsage(bucketName, saved.Size-replacedBytes, 1-replacedObjects)
+	return saved.info(), nil
+}
+
+// Occupation libérée par une nouvelle version de la clé : la version "null" qu'elle remplace
+// hors versioning ou avec un versioning suspendu, à appeler avec commitMu pris
+func (fs *FileStorage) replacedUsage(bucketName, objectName string) (int64, int64, error) {
+	status, err := fs.versioningStatus(bucketName)
+	if err != nil || status == VersioningEnabled {
+		return 0, 0, err
+	}
+
+	var bytes, objects int64
+	_, current, err := fs.lockedCurrentRecord(bucketName, objectName)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return 0, 0, err
+	}
+	if err == nil && exposedVersionID(current.info()) == nullVersionID {
+		bytes, objects = current.Size, 1
+	}
+	if status == VersioningSuspended {
+		var archived objectMetadata
+		if err := readJSONFile(fs.versionRecordPath(bucketName, objectName, nullVersionID), &archived); err == nil && !archived.IsDeleteMarker {
+			bytes, objects = bytes+archived.Size, objects+1
+		}
+	}
+	return bytes, objects, nil
+}
+
+// Modification des métadonnées d'une version ("" pour la version courante) : les métadonnées de la version
+// courante ou l'enregistrement de la version archivée sont réécrits. Un marqueur de suppression n'est pas modifiable.
+func (fs *FileStorage) updateVersion(bucketName, objectName, versionID string, update func(*objectMetadata) error) (dto.ObjectInfo, error) {
+	exists, err := fs.CheckBucketExists(bucketName)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	if !exists {
+		return dto.ObjectInfo{}, ErrNoSuchBucket
+	}
+
+	fs.commitMu.Lock()
+	defer fs.commitMu.Unlock()
+	objectPath, current, err := fs.lockedCurrentRecord(bucketName, objectName)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return dto.ObjectInfo{}, err
+	}
+	if err == nil && (versionID == "" || exposedVersionID(current.info()) == versionID) {
+		if err := update(&current); err != nil {
+			return dto.ObjectInfo{}, err
+		}
+		saved, err := fs.saveObjectMetadata(bucketName, objectPath, current)
+		return saved.info(), err
+	}
+	if versionID == "" {
+		return dto.ObjectInfo{}, err
+	}
+
+	if err := validateVersionID(versionID); err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	recordPath := fs.versionRecordPath(bucketName, objectName, versionID)
+	var record objectMetadata
+	if err := readJSONFile(recordPath, &record); err != nil {
+		if os.IsNotExist(err) {
+			return dto.ObjectInfo{}, ErrNoSuchVersion
+		}
+		return dto.ObjectInfo{}, fmt.Errorf("failed to read version %s of %s: %v", versionID, objectName, err)
+	}
+	if record.IsDeleteMarker {
+		return record.info(), ErrDeleteMarker
+	}
+
+	if err := update(&record); err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	if err := writeJSONFile(recordPath, record); err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	return record.info(), nil
+}
+
+// Suppression d'une clé dans un bucket versionné : un marqueur de suppression devient la version courante.
+// À appeler avec commitMu pris.
+func (fs *FileStorage) putDeleteMarker(bucketName, objectName string) (dto.ObjectInfo, error) {
+	versionID, err := fs.prepareNewVersion(bucketName, objectName)
+	if err != nil {
+		return dto.ObjectInfo{}, err
+	}
+
+	marker := objectMetadata{
+		Key:            objectName,
+		LastModified:   time.Now(),
+		VersionID:      versionID,
+		IsDeleteMarker: true,
+	}
+	if err := os.MkdirAll(fs.objectVersionsDir(bucketName, objectName), os.ModePerm); err != nil {
+		return dto.ObjectInfo{}, fmt.Errorf("failed to create versions directory: %v", err)
+	}
+	if err := writeJSONFile(fs.versionRecordPath(bucketName, objectName, versionID), marker); err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	return marker.info(), nil
+}
+
+// Suppression définitive d'une version. Si c'était la version la plus récente,
+// la précédente redevient courante. Une version verrouillée (Object Lock) n'est pas supprimée.
+func (fs *FileStorage) deleteObjectVersion(bucketName, objectName, versionID string, bypassGovernance bool) (dto.ObjectInfo, error) {
+	if err := validateVersionID(versionID); err != nil {
+		return dto.ObjectInfo{}, err
+	}
+
+	fs.commitMu.Lock()
+	defer fs.commitMu.Unlock()
+	objectPath, current, err := fs.lockedCurrentRecord(bucketName, objectName)
+	info := current.info()
+	isCurrent := err == nil && exposedVersionID(info) == versionID
+	switch {
+	case isCurrent:
+	case err != nil && !errors.Is(err, os.ErrNotExist):
+		return dto.ObjectInfo{}, err
+	default:
+		var record objectMetadata
+		if err := readJSONFile(fs.versionRecordPath(bucketName, objectName, versionID), &record); err != nil {
+			if os.IsNotExist(err) {
+				return dto.ObjectInfo{}, ErrNoSuchVersion
+			}
+			return dto.ObjectInfo{}, fmt.Errorf("failed to read version %s of %s: %v", versionID, objectName, err)
+		}
+		info = record.info()
+	}
+
+	if err := checkObjectLock(info.Lock, bypassGovernance, time.Now()); err != nil {
+		log.Printf("Refusing to delete version %s of %s in bucket %s: %v", versionID, objectName, bucketName, err)
+		return dto.ObjectInfo{}, err
+	}
+	if isCurrent {
+		if err := os.Remove(objectPath); err != nil {
+			return dto.ObjectInfo{}, fmt.Errorf("failed to delete version %s of %s: %v", versionID, objectName, err)
+		}
+		fs.removeObjectMetadata(bucketName, objectName)
+		fs.addUsage(bucketName, -info.Size, -1)
+	} else {
+		fs.removeArchivedVersion(bucketName, objectName, versionID)
+	}
+
+	if err := fs.promoteLatestVersion(bucketName, objectName); err != nil {
+		return dto.ObjectInfo{}, err
+	}
+	fs.pruneEmptyDirs(bucketName, objectPath)
+
+	info.VersionID = versionID
+	log.Printf("Version %s of %s in bucket %s permanently deleted", versionID, objectName, bucketName)
+	return info, nil
+}
+
+// Si la clé n'a plus de version courante, la plus récente des versions archivées le redevient,
+// sauf s'il s'agit d'un marqueur de suppression. À appeler avec commitMu pris.
+func (fs *FileStorage) promoteLatestVersion(bucketName, objectName string) error {
+	objectPath, err := fs.resolveObjectPath(bucketName, objectName)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(objectPath); err == nil {
+		return nil
+	}
+
+	versions, err := fs.listArchivedVersions(bucketName, objectName)
+	if err != nil {
+		return err
+	}
+	if len(versions) == 0 || versions[0].IsDeleteMarker {
+		return nil
+	}
+
+	latest := versions[0]
+	if err := createObjectDirs(objectPath); err != nil {
+		return err
+	}
+	// Le renommage conserve la date de modification du fichier, donc le LastModified de la version
+	if err := os.Rename(filepath.Join(fs.objectVersionsDir(bucketName, objectName), latest.VersionID), objectPath); err != nil {
+		return fmt.Errorf("failed to restore version %s of %s: %v", latest.VersionID, objectName, err)
+	}
+	if _, err := fs.saveObjectMetadata(bucketName, objectPath, latest); err != nil {
+		return err
+	}
+	fs.removeArchivedVersion(bucketName, objectName, latest.VersionID)
+	return nil
+}
+
+// ListObjectVersionsOptions regroupe les paramètres d'un listing des versions
+type ListObjectVersionsOptions struct {
+	Prefix          string
+	Delimiter       string
+	KeyMarker       string // le listing reprend après cette clé...
+	VersionIDMarker string // ...ou après cette version de KeyMarker
+	MaxKeys         int
+}
+
+// Lister toutes les versions et marqueurs de suppression d'un bucket, par clé puis du plus récent au plus ancien
+func (fs *FileStorage) ListObjectVersions(bucketName string, opts ListObjectVersionsOptions) (dto.ObjectVersionListing, error) {
+	listing := dto.ObjectVersionListing{Versions: make([]dto.ObjectVersion, 0)}
+
+	exists, err := fs.CheckBucketExists(bucketName)
+	if err != nil {
+		return listing, err
+	}
+	if !exists {
+		return listing, ErrNoSuchBucket
+	}
+
+	currentKeys, err := fs.listBucketKeys(bucketName, opts.Prefix)
+	if err != nil {
+		return listing, fmt.Errorf("error while listing objects: %v", err)
+	}
+	archived, err := fs.archivedVersionsByKey(bucketName, opts.Prefix)
+	if err != nil {
+		return listing, fmt.Errorf("error while listing versions: %v", err)
+	}
+
+	keys := currentKeys
+	for key := range archived {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return listVersionsPage(keys, opts, func(key string) []objectMetadata {
+		versions := archived[key]
+		if _, info, err := fs.currentVersion(bucketName, key); err == nil {
+			current := objectMetadata{
+				Key:          key,
+				ETag:         info.ETag,
+				Size:         info.Size,
+				LastModified: info.LastModified,
+				VersionID:    exposedVersionID(info),
+			}
+			versions = append([]objectMetadata{current}, versions...)
+		}
+		return versions
+	}), nil
+}
+
+// Page d'un listing des versions à partir des clés triées d'un bucket (éventuellement en double) ;
+// keyVersions fournit les versions d'une clé, de la plus récente à la plus ancienne
+func listVersionsPage(keys []string, opts ListObjectVersionsOptions, keyVersions func(key string) []objectMetadata) dto.ObjectVersionListing {
+	listing := dto.ObjectVersionListing{Versions: make([]dto.ObjectVersion, 0)}
+
+	count := 0
+	lastPrefix := ""
+	for i, key := range keys {
+		if (i > 0 && key == keys[i-1]) || key < opts.KeyMarker || (key == opts.KeyMarker && opts.VersionIDMarker == "") {
+			continue
+		}
+
+		commonPrefix := ""
+		if opts.Delimiter != "" {
+			rest := key[len(opts.Prefix):]
+			if j := strings.Index(rest, opts.Delimiter); j >= 0 {
+				commonPrefix = opts.Prefix + rest[:j+len(opts.Delimiter)]
+			}
+		}
+		if commonPrefix != "" {
+			if commonPrefix <= opts.KeyMarker || commonPrefix == lastPrefix {
+				continue
+			}
+			if count >= opts.MaxKeys {
+				listing.IsTruncated = true
+				return listing
+			}
+			count++
+			lastPrefix = commonPrefix
+			listing.CommonPrefixes = append(listing.CommonPrefixes, commonPrefix)
+			listing.NextKeyMarker, listing.NextVersionIDMarker = commonPrefix, ""
+			continue
+		}
+
+		versions := keyVersions(key)
+		skipping := key == opts.KeyMarker
+		for j, version := range versions {
+			if skipping {
+				skipping = version.VersionID != opts.VersionIDMarker
+				continue
+			}
+			if count >= opts.MaxKeys {
+				listing.IsTruncated = true
+				return listing
+			}
+			count++
+
+			listing.Versions = append(listing.Versions, dto.ObjectVersion{
+				Key:            key,
+				VersionID:      version.VersionID,
+				IsLatest:       j == 0,
+				IsDeleteMarker: version.IsDeleteMarker,
+				LastModified:   version.LastModified,
+				ETag:           version.ETag,
+				Size:           version.Size,
+			})
+			listing.NextKeyMarker, listing.NextVersionIDMarker = key, version.VersionID
+		}
+	}
+
+	return listing
+}
+
+// Versions archivées des clés commençant par prefix, regroupées par clé et triées
+func (fs *FileStorage) archivedVersionsByKey(bucketName, prefix string) (map[string][]objectMetadata, error) {
+	records, err := filepath.Glob(filepath.Join(fs.bucketVersionsDir(bucketName), "*", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	byKey := make(map[string][]objectMetadata)
+	for _, path := range records {
+		var record objectMetadata
+		if err := readJSONFile(path, &record); err != nil {
+			log.Printf("Skipping unreadable version record %s: %v", path, err)
+			continue
+		}
+		if strings.HasPrefix(record.Key, prefix) {
+			byKey[record.Key] = append(byKey[record.Key], record)
+		}
+	}
+	for _, versions := range byKey {
+		sortVersions(versions)
+	}
+	return byKey, nil
+}
@@ -0,0 +1,178 @@
+package tests
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"my-s3-clone/auth"
+	"my-s3-clone/config"
+	"my-s3-clone/dto"
+	"my-s3-clone/router"
+	"my-s3-clone/storage"
+)
+
+// sendAs sends a request signed with a managed access key
+func sendAs(r http.Handler, key dto.AccessKey, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	auth.SignRequest(req, key.AccessKey, key.SecretKey, testRegion, time.Now())
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestKeyPolicyMatching(t *testing.T) {
+	policy := dto.KeyPolicy{Statements: []dto.KeyPolicyStatement{
+		{Actions: []string{"s3:GetObject", "s3:PutObject"}, Resources: []string{"photos/tenant-a/*"}},
+		{Actions: []string{"s3:List*"}, Resources: []string{"photos/tenant-a/*", "thumbnails"}},
+	}}
+	tests := []struct {
+		action   string
+		resource string
+		allowed  bool
+	}{
+		{auth.ActionGetObject, "photos/tenant-a/2024/cat.jpg", true},
+		{auth.ActionPutObject, "photos/tenant-a/cat.jpg", true},
+		{auth.ActionDeleteObject, "photos/tenant-a/cat.jpg", false},
+		{auth.ActionGetObject, "photos/tenant-b/cat.jpg", false},
+		{auth.ActionGetObject, "photos/tenant-a", false},
+		{auth.ActionListBucket, "photos/tenant-a/", true},
+		{auth.ActionListBucket, "photos/", false},
+		{auth.ActionListBucketVersions, "thumbnails", true},
+		{auth.ActionListAllMyBuckets, "", false},
+	}
+	for _, tt := range tests {
+		if got := auth.PolicyAllows(policy, tt.action, tt.resource); got != tt.allowed {
+			t.Errorf("PolicyAllows(%s, %q) = %v, want %v", tt.action, tt.resource, got, tt.allowed)
+		}
+	}
+
+	if err := auth.ValidatePolicy(policy); err != nil {
+		t.Errorf("expected the policy to be valid, got %v", err)
+	}
+	for name, statement := range map[string]dto.KeyPolicyStatement{
+		"unknown action":   {Actions: []string{"s3:GetObjects"}, Resources: []string{"*"}},
+		"no resource":      {Actions: []string{"s3:*"}},
+		"empty resource":   {Actions: []string{"s3:*"}, Resources: []string{""}},
+		"no action at all": {Resources: []string{"*"}},
+	} {
+		if err := auth.ValidatePolicy(dto.KeyPolicy{Statements: []dto.KeyPolicyStatement{statement}}); err == nil {
+			t.Errorf("%s: expected the policy to be rejected", name)
+		}
+	}
+}
+
+func TestAccessKeyPermissions(t *testing.T) {
+	cfg := config.Default()
+	cfg.Credentials = testCredentials
+	cfg.KeysFile = filepath.Join(t.TempDir(), "keys.json")
+	s := storage.NewMemoryStorage()
+	if err := s.CreateBucket("photos", storage.CreateBucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddObject("photos", "tenant-b/dog.jpg", strings.NewReader("woof"), storage.PutObjectOptions{DecodedContentLength: -1}); err != nil {
+		t.Fatal(err)
+	}
+	r := router.SetupRouterWithConfig(s, cfg)
+
+	// The configured credentials administer the keys
+	body := `{"description": "gallery tenant A", "policy": {"statements": [
+		{"actions": ["s3:GetObject", "s3:PutObject", "s3:ListBucket"], "resources": ["photos/tenant-a/*"]}
+	]}}`
+	rr := sendWithHeaders(r, "POST", "/_admin/keys", body, nil)
+	var key dto.AccessKey
+	if err := json.Unmarshal(rr.Body.Bytes(), &key); rr.Code != http.StatusOK || err != nil || key.AccessKey == "" || key.SecretKey == "" {
+		t.Fatalf("expected a new access key but got %d: %s", rr.Code, rr.Body.String())
+	}
+	var list dto.AccessKeyList
+	rr = sendWithHeaders(r, "GET", "/_admin/keys", "", nil)
+	if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil || len(list.Keys) != 1 || list.Keys[0].SecretKey != "" || list.Keys[0].Description != "gallery tenant A" {
+		t.Errorf("expected the key to be listed without its secret, got %d: %s", rr.Code, rr.Body.String())
+	}
+	rr = sendWithHeaders(r, "POST", "/_admin/keys", `{"policy": {"statements": [{"actions": ["s3:Teleport"], "resources": ["*"]}]}}`, nil)
+	if rr.Code != http.StatusBadRequest || errorCode(t, rr) != "MalformedPolicy" {
+		t.Errorf("expected MalformedPolicy but got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := sendWithHeaders(r, "GET", "/_admin/keys/AKIAUNKNOWN", "", nil); rr.Code != http.StatusNotFound || errorCode(t, rr) != "NoSuchAccessKey" {
+		t.Errorf("expected NoSuchAccessKey but got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// The new key only reaches its own prefix
+	tests := []struct {
+		method  string
+		path    string
+		body    string
+		allowed bool
+	}{
+		{"PUT", "/photos/tenant-a/cat.jpg", "meow", true},
+		{"GET", "/photos/tenant-a/cat.jpg", "", true},
+		{"GET", "/photos/?prefix=tenant-a/", "", true},
+		{"PUT", "/photos/tenant-b/cat.jpg", "meow", false},
+		{"GET", "/photos/tenant-b/dog.jpg", "", false},
+		{"DELETE", "/photos/tenant-a/cat.jpg", "", false},
+		{"GET", "/photos/", "", false},
+		{"GET", "/", "", false},
+		{"PUT", "/albums/", "", false},
+		{"GET", "/_admin/keys", "", false},
+		{"GET", "/_admin/usage", "", false},
+	}
+	for _, tt := range tests {
+		rr := sendAs(r, key, tt.method, tt.path, tt.body)
+		if tt.allowed && rr.Code >= 300 {
+			t.Errorf("%s %s: expected success but got %d: %s", tt.method, tt.path, rr.Code, rr.Body.String())
+		}
+		if !tt.allowed && (rr.Code != http.StatusForbidden || errorCode(t, rr) != "AccessDenied") {
+			t.Errorf("%s %s: expected AccessDenied but got %d: %s", tt.method, tt.path, rr.Code, rr.Body.String())
+		}
+	}
+
+	// Copies also need the right to read the source
+	req := httptest.NewRequest("PUT", "/photos/tenant-a/dog.jpg", nil)
+	req.Header.Set("X-Amz-Copy-Source", "/photos/tenant-b/dog.jpg")
+	auth.SignRequest(req, key.AccessKey, key.SecretKey, testRegion, time.Now())
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("expected the copy of another tenant's object to be denied, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// Batch deletes check every key
+	deleteBody := `<Delete><Object><Key>tenant-a/cat.jpg</Key></Object></Delete>`
+	rr = sendAs(r, key, "POST", "/photos/?delete", deleteBody)
+	var result dto.DeleteResult
+	if err := xml.Unmarshal(rr.Body.Bytes(), &result); err != nil || len(result.Errors) != 1 || result.Errors[0].Code != "AccessDenied" {
+		t.Errorf("expected the key to be denied in the batch, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// A new policy applies to the next request and survives a restart
+	policy := `{"statements": [{"actions": ["s3:GetObject", "s3:DeleteObject"], "resources": ["photos/tenant-a/*"]}]}`
+	if rr := sendWithHeaders(r, "PUT", "/_admin/keys/"+key.AccessKey+"/policy", policy, nil); rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	restarted := router.SetupRouterWithConfig(s, cfg)
+	rr = sendAs(restarted, key, "POST", "/photos/?delete", deleteBody)
+	result = dto.DeleteResult{}
+	if err := xml.Unmarshal(rr.Body.Bytes(), &result); err != nil || len(result.Errors) != 0 || len(result.DeletedResult) != 1 {
+		t.Errorf("expected the key to be deleted, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := sendAs(restarted, key, "PUT", "/photos/tenant-a/cat.jpg", "meow"); rr.Code != http.StatusForbidden {
+		t.Errorf("expected the new policy to deny uploads, got %d", rr.Code)
+	}
+	if info, err := os.Stat(cfg.KeysFile); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("expected the keys file to be private, got %v, %v", info, err)
+	}
+
+	// A revoked key no longer authenticates
+	if rr := sendWithHeaders(restarted, "DELETE", "/_admin/keys/"+key.AccessKey, "", nil); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusNoContent, rr.Code, rr.Body.String())
+	}
+	if rr := sendAs(restarted, key, "GET", "/photos/tenant-a/dog.jpg", ""); rr.Code != http.StatusForbidden || errorCode(t, rr) != "InvalidAccessKeyId" {
+		t.Errorf("expected InvalidAccessKeyId but got %d: %s", rr.Code, rr.Body.String())
+	}
+}
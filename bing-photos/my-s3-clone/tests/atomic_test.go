@@ -0,0 +1,208 @@
+package tests
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/router"
+	"my-s3-clone/storage"
+)
+
+// failingReader returns its content then fails, like a client dropping the connection mid-upload
+type failingReader struct {
+	data io.Reader
+}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	n, err := f.data.Read(p)
+	if err == io.EOF {
+		return n, errors.New("connection reset by peer")
+	}
+	return n, err
+}
+
+func TestInterruptedUploadLeavesNoObject(t *testing.T) {
+	root := t.TempDir()
+	s := &storage.FileStorage{Root: root}
+	if err := s.CreateBucket("photos", storage.CreateBucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddObject("photos", "2024/cat.jpg", strings.NewReader("original"), storage.PutObjectOptions{DecodedContentLength: -1}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"2024/cat.jpg", "2024/dog.jpg"} {
+		body := &failingReader{data: strings.NewReader(strings.Repeat("partial photo ", 4096))}
+		if _, err := s.AddObject("photos", key, body, storage.PutObjectOptions{DecodedContentLength: -1}); err == nil {
+			t.Fatalf("expected the interrupted upload of %s to fail", key)
+		}
+	}
+
+	// The existing object is untouched and the new key never appears
+	reader, _, err := s.GetObject("photos", "2024/cat.jpg", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := io.ReadAll(reader)
+	reader.Close()
+	if string(content) != "original" {
+		t.Errorf("expected the previous content to be kept, got %q", content)
+	}
+	if _, err := s.StatObject("photos", "2024/dog.jpg", ""); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected no object for the interrupted upload, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "photos", "2024", "dog.jpg")); !os.IsNotExist(err) {
+		t.Errorf("expected no file under the object name, got %v", err)
+	}
+
+	// Nothing is left behind in the temporary directory
+	if leftovers, _ := filepath.Glob(filepath.Join(root, ".s3clone", "tmp", "*")); len(leftovers) != 0 {
+		t.Errorf("expected the temporary files to be removed, found %v", leftovers)
+	}
+}
+
+func TestRemoveTempFiles(t *testing.T) {
+	root := t.TempDir()
+	s := &storage.FileStorage{Root: root}
+	if err := s.CreateBucket("photos", storage.CreateBucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddObject("photos", "cat.jpg", strings.NewReader("kept"), storage.PutObjectOptions{DecodedContentLength: -1}); err != nil {
+		t.Fatal(err)
+	}
+	uploadID, err := s.CreateMultipartUpload("photos", "video.mp4", storage.MultipartUploadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.UploadPart("photos", "video.mp4", uploadID, 1, strings.NewReader("part"), storage.PutObjectOptions{DecodedContentLength: -1}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Files a crash would leave behind: an upload, a copy and a part being written
+	uploadDir := filepath.Join(root, ".s3clone", "multipart", "photos", uploadID)
+	leftovers := []string{
+		filepath.Join(root, ".s3clone", "tmp", "object-123"),
+		filepath.Join(root, ".s3clone", "tmp", "copy-456"),
+		filepath.Join(uploadDir, "tmp-part-789"),
+	}
+	for _, path := range leftovers {
+		if err := os.WriteFile(path, []byte("truncated"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := s.RemoveTempFiles()
+	if err != nil || removed != len(leftovers) {
+		t.Fatalf("expected %d temporary files to be removed, got %d, %v", len(leftovers), removed, err)
+	}
+	for _, path := range leftovers {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed, got %v", path, err)
+		}
+	}
+
+	// Objects and pending uploads are kept
+	if _, err := s.StatObject("photos", "cat.jpg", ""); err != nil {
+		t.Errorf("expected the object to be kept, got %v", err)
+	}
+	parts, err := s.ListParts("photos", "video.mp4", uploadID, 0, 1000)
+	if err != nil || len(parts.Parts) != 1 {
+		t.Errorf("expected the uploaded part to be kept, got %+v, %v", parts, err)
+	}
+}
+
+func TestConditionalPutObject(t *testing.T) {
+	s := storage.NewMemoryStorage()
+	if err := s.CreateBucket("photos", storage.CreateBucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	r := router.SetupRouterWithStorage(s, testCredentials)
+	ifNoneMatch := map[string]string{"If-None-Match": "*"}
+
+	if rr := sendWithHeaders(r, "PUT", "/photos/cat.jpg", "first", ifNoneMatch); rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	rr := sendWithHeaders(r, "PUT", "/photos/cat.jpg", "second", ifNoneMatch)
+	if rr.Code != http.StatusPreconditionFailed || errorCode(t, rr) != "PreconditionFailed" {
+		t.Fatalf("expected PreconditionFailed but got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = sendWithHeaders(r, "PUT", "/photos/cat.jpg", "second", map[string]string{"If-None-Match": `"0123456789abcdef"`})
+	if rr.Code != http.StatusNotImplemented || errorCode(t, rr) != "NotImplemented" {
+		t.Fatalf("expected NotImplemented but got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// Unconditional uploads still overwrite
+	if rr := sendWithHeaders(r, "PUT", "/photos/cat.jpg", "third", nil); rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	info, err := s.StatObject("photos", "cat.jpg", "")
+	if err != nil || info.Size != int64(len("third")) {
+		t.Errorf("expected the unconditional upload to replace the object, got %+v, %v", info, err)
+	}
+}
+
+// statLoop reads the metadata of key until the returned function is called, like clients polling with HEAD
+func statLoop(t *testing.T, s storage.Storage, bucket, key string) (stop func()) {
+	t.Helper()
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					s.StatObject(bucket, key, "")
+				}
+			}
+		}()
+	}
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+func TestConcurrentReadsKeepCommittedMetadata(t *testing.T) {
+	s := &storage.FileStorage{Root: t.TempDir()}
+	if err := s.CreateBucket("photos", storage.CreateBucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetBucketVersioning("photos", storage.VersioningEnabled); err != nil {
+		t.Fatal(err)
+	}
+
+	// A read landing between the rename of a new version and the write of its metadata must not repair them
+	stop := statLoop(t, s, "photos", "cat.jpg")
+	content := strings.Repeat("meow", 64*1024)
+	var last dto.ObjectInfo
+	for i := 0; i < 200; i++ {
+		info, err := s.AddObject("photos", "cat.jpg", strings.NewReader(content), storage.PutObjectOptions{DecodedContentLength: -1})
+		if err != nil {
+			stop()
+			t.Fatal(err)
+		}
+		last = info
+	}
+	stop()
+
+	info, err := s.StatObject("photos", "cat.jpg", "")
+	if err != nil || info.VersionID != last.VersionID || info.Size != int64(len(content)) {
+		t.Fatalf("expected the last version %s to keep its metadata, got %+v, %v", last.VersionID, info, err)
+	}
+	versions, err := s.ListObjectVersions("photos", storage.ListObjectVersionsOptions{MaxKeys: 1000})
+	if err != nil || len(versions.Versions) != 200 {
+		t.Errorf("expected 200 versions, got %d, %v", len(versions.Versions), err)
+	}
+}
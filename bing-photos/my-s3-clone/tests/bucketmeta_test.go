@@ -0,0 +1,130 @@
+package tests
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/router"
+	"my-s3-clone/storage"
+)
+
+func TestBucketCreationDateAndLocation(t *testing.T) {
+	s := &storage.FileStorage{Root: t.TempDir()}
+	r := router.SetupRouterWithStorage(s, testCredentials)
+
+	body := `<CreateBucketConfiguration><LocationConstraint>eu-west-3</LocationConstraint></CreateBucketConfiguration>`
+	if rr := sendWithHeaders(r, "PUT", "/photos/", body, nil); rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if rr := sendWithHeaders(r, "PUT", "/thumbnails/", "", nil); rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	rr := sendWithHeaders(r, "PUT", "/elsewhere/", `<CreateBucketConfiguration><LocationConstraint>Mars</LocationConstraint></CreateBucketConfiguration>`, nil)
+	if rr.Code != http.StatusBadRequest || errorCode(t, rr) != "InvalidLocationConstraint" {
+		t.Errorf("expected InvalidLocationConstraint but got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	info, err := s.GetBucketInfo("photos")
+	if err != nil || info.Owner != testAccessKey || info.Region != "eu-west-3" {
+		t.Errorf("expected photos to be owned by %s in eu-west-3, got %+v, %v", testAccessKey, info, err)
+	}
+
+	for bucket, region := range map[string]string{"photos": "eu-west-3", "thumbnails": storage.DefaultRegion} {
+		rr := sendWithHeaders(r, "GET", "/"+bucket+"/?location", "", nil)
+		var location dto.LocationConstraint
+		if err := xml.Unmarshal(rr.Body.Bytes(), &location); rr.Code != http.StatusOK || err != nil || location.Region != region {
+			t.Errorf("expected the location of %s to be %s, got %d: %s", bucket, region, rr.Code, rr.Body.String())
+		}
+	}
+	if rr := sendWithHeaders(r, "GET", "/missing/?location", "", nil); rr.Code != http.StatusNotFound {
+		t.Errorf("expected status %d for a missing bucket but got %d", http.StatusNotFound, rr.Code)
+	}
+
+	// Listing twice, even from a restarted server, reports the same creation dates
+	listDates := func(r http.Handler) map[string]time.Time {
+		t.Helper()
+		rr := sendWithHeaders(r, "GET", "/", "", nil)
+		var result dto.ListAllMyBucketsResult
+		if err := xml.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+			t.Fatalf("Error unmarshaling response body: %v", err)
+		}
+		dates := map[string]time.Time{}
+		for _, bucket := range result.Buckets {
+			dates[bucket.Name] = bucket.CreationDate
+		}
+		return dates
+	}
+	first := listDates(r)
+	if len(first) != 2 || !first["photos"].Equal(info.CreationDate) {
+		t.Fatalf("expected the recorded creation dates, got %v", first)
+	}
+	time.Sleep(10 * time.Millisecond)
+	restarted := router.SetupRouterWithStorage(&storage.FileStorage{Root: s.Root}, testCredentials)
+	for name, date := range listDates(restarted) {
+		if !date.Equal(first[name]) {
+			t.Errorf("creation date of %s changed from %v to %v", name, first[name], date)
+		}
+	}
+}
+
+func TestBucketMetadataMigration(t *testing.T) {
+	root := t.TempDir()
+	bucketPath := filepath.Join(root, "photos")
+	configDir := filepath.Join(root, ".s3clone", "buckets", "photos")
+	for _, dir := range []string{bucketPath, configDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A bucket whose configuration was written as one file per setting
+	created := time.Date(2024, 9, 16, 10, 12, 24, 0, time.UTC)
+	if err := os.Chtimes(bucketPath, created, created); err != nil {
+		t.Fatal(err)
+	}
+	legacy := map[string]interface{}{
+		"versioning.json": map[string]string{"status": storage.VersioningEnabled},
+		"cors.json":       dto.CORSConfiguration{Rules: []dto.CORSRule{{AllowedOrigins: []string{"*"}, AllowedMethods: []string{"GET"}}}},
+	}
+	for file, config := range legacy {
+		data, _ := json.Marshal(config)
+		if err := os.WriteFile(filepath.Join(configDir, file), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := &storage.FileStorage{Root: root}
+	info, err := s.GetBucketInfo("photos")
+	if err != nil || !info.CreationDate.Equal(created) || info.Region != storage.DefaultRegion {
+		t.Errorf("expected the directory date %v to become the creation date, got %+v, %v", created, info, err)
+	}
+	if status, err := s.GetBucketVersioning("photos"); err != nil || status != storage.VersioningEnabled {
+		t.Errorf("expected versioning to be kept, got %q, %v", status, err)
+	}
+	if cors, err := s.GetBucketCors("photos"); err != nil || len(cors.Rules) != 1 {
+		t.Errorf("expected the CORS rules to be kept, got %+v, %v", cors, err)
+	}
+
+	if _, err := os.Stat(filepath.Join(configDir, "bucket.json")); err != nil {
+		t.Errorf("expected the bucket record to be written, got %v", err)
+	}
+	for file := range legacy {
+		if _, err := os.Stat(filepath.Join(configDir, file)); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed, got %v", file, err)
+		}
+	}
+
+	// Objects added afterwards do not change the creation date
+	if rr := sendWithHeaders(router.SetupRouterWithStorage(s, testCredentials), "PUT", "/photos/cat.jpg", "meow", nil); rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if info, _ := s.GetBucketInfo("photos"); !info.CreationDate.Equal(created) {
+		t.Errorf("expected the creation date to stay %v, got %v", created, info.CreationDate)
+	}
+}
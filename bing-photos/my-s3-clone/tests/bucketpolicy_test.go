@@ -0,0 +1,169 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"my-s3-clone/auth"
+	"my-s3-clone/dto"
+	"my-s3-clone/router"
+	"my-s3-clone/storage"
+)
+
+// sendAnonymous sends an unsigned request from remoteAddr
+func sendAnonymous(r http.Handler, method, path, remoteAddr string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.RemoteAddr = remoteAddr
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestBucketPolicyEvaluation(t *testing.T) {
+	var policy dto.BucketPolicy
+	err := json.Unmarshal([]byte(`{
+		"Version": "2012-10-17",
+		"Statement": [
+			{"Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::photos/public/*"},
+			{"Effect": "Allow", "Principal": {"AWS": ["*"]}, "Action": ["s3:ListBucket"], "Resource": ["arn:aws:s3:::photos"],
+			 "Condition": {"StringLike": {"s3:prefix": "public/*"}}},
+			{"Effect": "Deny", "Principal": "*", "Action": "s3:*", "Resource": "arn:aws:s3:::photos/*",
+			 "Condition": {"NotIpAddress": {"aws:SourceIp": ["192.0.2.0/24", "2001:db8::/32"]}}}
+		]
+	}`), &policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := auth.ValidateBucketPolicy("photos", policy); err != nil {
+		t.Fatalf("expected the policy to be valid, got %v", err)
+	}
+
+	inside, outside := []byte{192, 0, 2, 10}, []byte{198, 51, 100, 7}
+	tests := []struct {
+		req     auth.PolicyRequest
+		allowed bool
+	}{
+		{auth.PolicyRequest{Action: auth.ActionGetObject, Resource: "photos/public/cat.jpg", SourceIP: inside}, true},
+		{auth.PolicyRequest{Action: auth.ActionGetObject, Resource: "photos/public/cat.jpg", SourceIP: outside}, false},
+		{auth.PolicyRequest{Action: auth.ActionGetObject, Resource: "photos/private/cat.jpg", SourceIP: inside}, false},
+		{auth.PolicyRequest{Action: auth.ActionPutObject, Resource: "photos/public/cat.jpg", SourceIP: inside}, false},
+		{auth.PolicyRequest{Action: auth.ActionListBucket, Resource: "photos", Prefix: "public/2024/", SourceIP: inside}, true},
+		{auth.PolicyRequest{Action: auth.ActionListBucket, Resource: "photos", Prefix: "", SourceIP: inside}, false},
+		{auth.PolicyRequest{Action: auth.ActionGetUsage, Resource: "photos", SourceIP: inside}, false},
+	}
+	for _, tt := range tests {
+		if got := auth.BucketPolicyAllows(policy, tt.req); got != tt.allowed {
+			t.Errorf("BucketPolicyAllows(%+v) = %v, want %v", tt.req, got, tt.allowed)
+		}
+	}
+
+	for name, body := range map[string]string{
+		"signed principal":    `{"Statement": [{"Effect": "Allow", "Principal": {"AWS": "arn:aws:iam::1:root"}, "Action": "s3:GetObject", "Resource": "arn:aws:s3:::photos/*"}]}`,
+		"unknown effect":      `{"Statement": [{"Effect": "Maybe", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::photos/*"}]}`,
+		"admin action":        `{"Statement": [{"Effect": "Allow", "Principal": "*", "Action": "admin:GetUsage", "Resource": "arn:aws:s3:::photos"}]}`,
+		"other bucket":        `{"Statement": [{"Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::photos-private/*"}]}`,
+		"not an ARN":          `{"Statement": [{"Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "photos/*"}]}`,
+		"unknown condition":   `{"Statement": [{"Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::photos/*", "Condition": {"DateGreaterThan": {"aws:CurrentTime": "2024-01-01T00:00:00Z"}}}]}`,
+		"invalid IP range":    `{"Statement": [{"Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::photos/*", "Condition": {"IpAddress": {"aws:SourceIp": "192.0.2.0/99"}}}]}`,
+		"no statement":        `{"Version": "2012-10-17", "Statement": []}`,
+		"unsupported version": `{"Version": "2020-01-01", "Statement": [{"Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::photos/*"}]}`,
+	} {
+		var policy dto.BucketPolicy
+		if err := json.Unmarshal([]byte(body), &policy); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if err := auth.ValidateBucketPolicy("photos", policy); err == nil {
+			t.Errorf("%s: expected the policy to be rejected", name)
+		}
+	}
+}
+
+func TestAnonymousAccessThroughBucketPolicy(t *testing.T) {
+	s := storage.NewMemoryStorage()
+	if err := s.CreateBucket("photos", storage.CreateBucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"public/cat.jpg", "private/dog.jpg"} {
+		if _, err := s.AddObject("photos", key, strings.NewReader("meow"), storage.PutObjectOptions{DecodedContentLength: -1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := router.SetupRouterWithStorage(s, testCredentials)
+
+	// Without a policy, anonymous requests are denied
+	if rr := sendAnonymous(r, "GET", "/photos/public/cat.jpg", "192.0.2.1:1234"); rr.Code != http.StatusForbidden || errorCode(t, rr) != "AccessDenied" {
+		t.Errorf("expected AccessDenied but got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := sendWithHeaders(r, "GET", "/photos/?policy", "", nil); rr.Code != http.StatusNotFound || errorCode(t, rr) != "NoSuchBucketPolicy" {
+		t.Errorf("expected NoSuchBucketPolicy but got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	policy := `{
+		"Version": "2012-10-17",
+		"Statement": [
+			{"Sid": "PublicAlbum", "Effect": "Allow", "Principal": "*", "Action": "s3:GetObject",
+			 "Resource": "arn:aws:s3:::photos/public/*", "Condition": {"IpAddress": {"aws:SourceIp": "192.0.2.0/24"}}}
+		]
+	}`
+	if rr := sendWithHeaders(r, "PUT", "/photos/?policy", policy, nil); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusNoContent, rr.Code, rr.Body.String())
+	}
+	var stored dto.BucketPolicy
+	rr := sendWithHeaders(r, "GET", "/photos/?policy", "", nil)
+	if err := json.Unmarshal(rr.Body.Bytes(), &stored); err != nil || len(stored.Statements) != 1 || stored.Statements[0].Sid != "PublicAlbum" {
+		t.Errorf("unexpected policy %d: %s", rr.Code, rr.Body.String())
+	}
+	rr = sendWithHeaders(r, "PUT", "/photos/?policy", `{"Statement": [{"Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::other/*"}]}`, nil)
+	if rr.Code != http.StatusBadRequest || errorCode(t, rr) != "MalformedPolicy" {
+		t.Errorf("expected MalformedPolicy but got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	tests := []struct {
+		method     string
+		path       string
+		remoteAddr string
+		allowed    bool
+	}{
+		{"GET", "/photos/public/cat.jpg", "192.0.2.1:1234", true},
+		{"HEAD", "/photos/public/cat.jpg", "192.0.2.1:1234", true},
+		{"GET", "/photos/public/cat.jpg", "198.51.100.7:1234", false},
+		{"GET", "/photos/private/dog.jpg", "192.0.2.1:1234", false},
+		{"DELETE", "/photos/public/cat.jpg", "192.0.2.1:1234", false},
+		{"GET", "/photos/", "192.0.2.1:1234", false},
+		{"GET", "/photos/?policy", "192.0.2.1:1234", false},
+		{"GET", "/", "192.0.2.1:1234", false},
+		{"GET", "/_admin/usage/photos", "192.0.2.1:1234", false},
+	}
+	for _, tt := range tests {
+		rr := sendAnonymous(r, tt.method, tt.path, tt.remoteAddr)
+		if tt.allowed && rr.Code != http.StatusOK {
+			t.Errorf("%s %s from %s: expected success but got %d: %s", tt.method, tt.path, tt.remoteAddr, rr.Code, rr.Body.String())
+		}
+		if !tt.allowed && rr.Code != http.StatusForbidden {
+			t.Errorf("%s %s from %s: expected status %d but got %d: %s", tt.method, tt.path, tt.remoteAddr, http.StatusForbidden, rr.Code, rr.Body.String())
+		}
+	}
+	if rr := sendAnonymous(r, "GET", "/photos/public/cat.jpg", "192.0.2.1:1234"); rr.Body.String() != "meow" {
+		t.Errorf("expected the object content but got %q", rr.Body.String())
+	}
+
+	// A bad signature is still rejected rather than treated as anonymous
+	req := httptest.NewRequest("GET", "/photos/public/cat.jpg", nil)
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential=AKIAUNKNOWN/20240101/us-east-1/s3/aws4_request, SignedHeaders=host, Signature=00")
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	if rr.Code != http.StatusForbidden || errorCode(t, rr) == "" {
+		t.Errorf("expected the bad signature to be rejected, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// Removing the policy closes the bucket again
+	if rr := sendWithHeaders(r, "DELETE", "/photos/?policy", "", nil); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusNoContent, rr.Code, rr.Body.String())
+	}
+	if rr := sendAnonymous(r, "GET", "/photos/public/cat.jpg", "192.0.2.1:1234"); rr.Code != http.StatusForbidden {
+		t.Errorf("expected the object to be private again, got %d", rr.Code)
+	}
+}
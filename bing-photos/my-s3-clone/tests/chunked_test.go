@@ -0,0 +1,76 @@
+package tests
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"my-s3-clone/auth"
+	"my-s3-clone/storage"
+)
+
+// Exemple "PUT Object" en plusieurs chunks de la documentation AWS Signature Version 4
+const (
+	seedSignature   = "4f232c4386841ef735655705268965c44a0e4690baa4adea153f7db9fa80a0a9"
+	chunk1Signature = "ad80c730a21e5b8d04586a2213dd63b9a0e99e0e2307b0ade35a65485a288648"
+	chunk2Signature = "0055627c9e194cb4542bae2aa5492e3c1575bbb81b612b7d234b86a503ef5497"
+	finalSignature  = "b6c6ea8a5354eaf15b3cb7646744f4275b71ea724fed81ceb9323e279d449df9"
+)
+
+func exampleChunkVerifier() *auth.ChunkVerifier {
+	date := time.Date(2013, 5, 24, 0, 0, 0, 0, time.UTC)
+	return auth.NewChunkVerifier(&auth.Credential{
+		AccessKey:  testAccessKey,
+		Date:       date,
+		Region:     "us-east-1",
+		Service:    "s3",
+		Signature:  seedSignature,
+		SigningKey: auth.SigningKey(testSecretKey, date, "us-east-1", "s3"),
+	})
+}
+
+func exampleChunkedBody(secondChunk []byte) string {
+	var body strings.Builder
+	fmt.Fprintf(&body, "%x;chunk-signature=%s\r\n%s\r\n", 65536, chunk1Signature, bytes.Repeat([]byte("a"), 65536))
+	fmt.Fprintf(&body, "%x;chunk-signature=%s\r\n%s\r\n", len(secondChunk), chunk2Signature, secondChunk)
+	fmt.Fprintf(&body, "0;chunk-signature=%s\r\n\r\n", finalSignature)
+	return body.String()
+}
+
+func TestProcessChunkedStream(t *testing.T) {
+	validChunk := bytes.Repeat([]byte("a"), 1024)
+	tamperedChunk := append(bytes.Repeat([]byte("a"), 1023), 'b')
+
+	tests := []struct {
+		name          string
+		body          string
+		decodedLength int64
+		expectedErr   error
+	}{
+		{"valid stream", exampleChunkedBody(validChunk), 66560, nil},
+		{"tampered chunk", exampleChunkedBody(tamperedChunk), 66560, storage.ErrChunkSignatureMismatch},
+		{"decoded length mismatch", exampleChunkedBody(validChunk), 70000, storage.ErrIncompleteBody},
+		{"truncated stream", exampleChunkedBody(validChunk)[:40000], 66560, storage.ErrIncompleteBody},
+		{"missing signature", "400\r\n" + strings.Repeat("a", 1024) + "\r\n0\r\n\r\n", 1024, storage.ErrMalformedChunk},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		err := storage.ProcessChunkedStream(strings.NewReader(tt.body), &out, exampleChunkVerifier(), tt.decodedLength)
+
+		if tt.expectedErr == nil {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", tt.name, err)
+			} else if out.Len() != 66560 {
+				t.Errorf("%s: expected 66560 decoded bytes but got %d", tt.name, out.Len())
+			}
+			continue
+		}
+		if !errors.Is(err, tt.expectedErr) {
+			t.Errorf("%s: expected error %v but got %v", tt.name, tt.expectedErr, err)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"my-s3-clone/auth"
+	"my-s3-clone/config"
+	"my-s3-clone/router"
+)
+
+// clearConfigEnv isolates config.Load from the environment of the test run
+func clearConfigEnv(t *testing.T) {
+	for _, name := range []string{
+		"S3_CONFIG_FILE", "S3_DATA_DIR", "S3_ADDRESS", "S3_TLS_CERT_FILE", "S3_TLS_KEY_FILE", "S3_ALLOWED_ORIGINS",
+		"S3_ACCESS_KEY_ID", "S3_SECRET_ACCESS_KEY", "S3_CREDENTIALS", "S3_LOG_LEVEL", "S3_MASTER_KEY", "S3_KEYS_FILE",
+		"S3_MULTIPART_EXPIRY", "S3_LIFECYCLE_INTERVAL", "S3_LIFECYCLE_DRY_RUN",
+	} {
+		t.Setenv(name, "")
+	}
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	clearConfigEnv(t)
+
+	cfg, err := config.Load(nil)
+	if err != nil {
+		t.Fatalf("expected the default configuration to be valid, got %v", err)
+	}
+	if !reflect.DeepEqual(cfg, config.Default()) {
+		t.Errorf("expected the default configuration %+v but got %+v", config.Default(), cfg)
+	}
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	clearConfigEnv(t)
+	file := filepath.Join(t.TempDir(), "s3.yaml")
+	yaml := `dataDir: /srv/from-file
+address: ":7000"
+allowedOrigins: ["https://photos.example.com"]
+credentials:
+  file-key: file-secret
+logLevel: debug
+multipartExpiry: 48h
+`
+	if err := os.WriteFile(file, []byte(yaml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The file overrides the defaults, the environment the file and flags the environment
+	t.Setenv("S3_CONFIG_FILE", file)
+	t.Setenv("S3_ADDRESS", ":8000")
+	t.Setenv("S3_ACCESS_KEY_ID", "env-key")
+	t.Setenv("S3_SECRET_ACCESS_KEY", "env-secret")
+	t.Setenv("S3_LIFECYCLE_INTERVAL", "30m")
+	t.Setenv("S3_LIFECYCLE_DRY_RUN", "true")
+
+	cfg, err := config.Load([]string{"-addr", ":9000", "-allowed-origins", "https://a.example.com, https://b.example.com"})
+	if err != nil {
+		t.Fatalf("expected the configuration to load, got %v", err)
+	}
+
+	want := config.Default()
+	want.DataDir = "/srv/from-file"
+	want.Address = ":9000"
+	want.AllowedOrigins = []string{"https://a.example.com", "https://b.example.com"}
+	want.Credentials = auth.StaticCredentials{"env-key": "env-secret"}
+	want.LogLevel = config.LogLevelDebug
+	want.MultipartExpiry = 48 * time.Hour
+	want.LifecycleInterval = 30 * time.Minute
+	want.LifecycleDryRun = true
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("expected %+v but got %+v", want, cfg)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	unknownKey := filepath.Join(t.TempDir(), "s3.yaml")
+	if err := os.WriteFile(unknownKey, []byte("listen: \":9090\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+	}{
+		{"unknown flag", nil, []string{"-port", "9090"}},
+		{"missing file", nil, []string{"-config", filepath.Join(t.TempDir(), "missing.yaml")}},
+		{"unknown key in file", nil, []string{"-config", unknownKey}},
+		{"invalid duration", map[string]string{"S3_MULTIPART_EXPIRY": "a week"}, nil},
+		{"negative duration", nil, []string{"-lifecycle-interval", "-1h"}},
+		{"invalid dry run", map[string]string{"S3_LIFECYCLE_DRY_RUN": "maybe"}, nil},
+		{"unknown log level", nil, []string{"-log-level", "trace"}},
+		{"TLS cert without key", nil, []string{"-tls-cert", "cert.pem"}},
+		{"invalid master key", map[string]string{"S3_MASTER_KEY": "c2hvcnQ="}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearConfigEnv(t)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			if _, err := config.Load(tt.args); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
+
+func TestCORSAllowedOrigins(t *testing.T) {
+	cfg := config.Default()
+	cfg.AllowedOrigins = []string{"https://photos.example.com"}
+	r := router.SetupRouterWithConfig(&MockStorage{}, cfg)
+
+	tests := []struct {
+		origin string
+		want   string
+	}{
+		{"https://photos.example.com", "https://photos.example.com"},
+		{"https://evil.example.com", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("OPTIONS", "/photos/cat.jpg", nil)
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("origin %q: expected preflight status %d but got %d", tt.origin, http.StatusOK, rr.Code)
+		}
+		if got := rr.Header().Get("Access-Control-Allow-Origin"); got != tt.want {
+			t.Errorf("origin %q: expected Access-Control-Allow-Origin %q but got %q", tt.origin, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package tests
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/router"
+	"my-s3-clone/storage"
+)
+
+func TestCopyObjectSourceConditions(t *testing.T) {
+	s := storage.NewMemoryStorage()
+	if err := s.CreateBucket("photos", storage.CreateBucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	source, err := s.AddObject("photos", "cat.jpg", strings.NewReader("meow"), storage.PutObjectOptions{DecodedContentLength: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := router.SetupRouterWithStorage(s, testCredentials)
+	before := source.LastModified.Add(-time.Hour).UTC().Format(http.TimeFormat)
+	after := source.LastModified.Add(time.Hour).UTC().Format(http.TimeFormat)
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		copied  bool
+	}{
+		{"if-match", map[string]string{"X-Amz-Copy-Source-If-Match": source.ETag}, true},
+		{"if-match miss", map[string]string{"X-Amz-Copy-Source-If-Match": `"other"`}, false},
+		{"if-none-match", map[string]string{"X-Amz-Copy-Source-If-None-Match": `"other"`}, true},
+		{"if-none-match hit", map[string]string{"X-Amz-Copy-Source-If-None-Match": source.ETag}, false},
+		{"modified since", map[string]string{"X-Amz-Copy-Source-If-Modified-Since": before}, true},
+		{"not modified since", map[string]string{"X-Amz-Copy-Source-If-Modified-Since": after}, false},
+		{"unmodified since", map[string]string{"X-Amz-Copy-Source-If-Unmodified-Since": after}, true},
+		{"modified after", map[string]string{"X-Amz-Copy-Source-If-Unmodified-Since": before}, false},
+		// If-Match takes precedence over If-Unmodified-Since
+		{"if-match and modified after", map[string]string{
+			"X-Amz-Copy-Source-If-Match":            source.ETag,
+			"X-Amz-Copy-Source-If-Unmodified-Since": before,
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := strings.ReplaceAll(tt.name, " ", "-") + ".jpg"
+			headers := map[string]string{"X-Amz-Copy-Source": "/photos/cat.jpg"}
+			for name, value := range tt.headers {
+				headers[name] = value
+			}
+			rr := sendWithHeaders(r, "PUT", "/photos/"+key, "", headers)
+
+			if !tt.copied {
+				if rr.Code != http.StatusPreconditionFailed || errorCode(t, rr) != "PreconditionFailed" {
+					t.Fatalf("expected PreconditionFailed but got %d: %s", rr.Code, rr.Body.String())
+				}
+				if _, err := s.StatObject("photos", key, ""); !os.IsNotExist(err) {
+					t.Errorf("expected no copy, got %v", err)
+				}
+				return
+			}
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+			}
+			var result dto.CopyObjectResult
+			if err := xml.Unmarshal(rr.Body.Bytes(), &result); err != nil || result.ETag != source.ETag {
+				t.Errorf("expected a CopyObjectResult with ETag %s, got %s (%v)", source.ETag, rr.Body.String(), err)
+			}
+		})
+	}
+}
+
+func TestHandleMoveObject(t *testing.T) {
+	root := t.TempDir()
+	s := &storage.FileStorage{Root: root}
+	for _, bucket := range []string{"photos", "private"} {
+		if err := s.CreateBucket(bucket, storage.CreateBucketOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, key := range []string{"2024/cat.jpg", "2024/dog.jpg"} {
+		if _, err := s.AddObject("photos", key, strings.NewReader(key), storage.PutObjectOptions{DecodedContentLength: -1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	original, err := os.Stat(filepath.Join(root, "photos", "2024", "cat.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := router.SetupRouterWithStorage(s, testCredentials)
+
+	body := `<Move><TargetBucket>private</TargetBucket><Object><Key>2024/cat.jpg</Key></Object><Object><Key>2024/missing.jpg</Key></Object></Move>`
+	rr := sendWithHeaders(r, "POST", "/photos/?move", body, nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var result dto.DeleteResult
+	if err := xml.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatalf("could not parse the move result: %v", err)
+	}
+	if len(result.DeletedResult) != 1 || result.DeletedResult[0].Key != "2024/cat.jpg" ||
+		len(result.Errors) != 1 || result.Errors[0].Code != "NoSuchKey" {
+		t.Errorf("unexpected move result %s", rr.Body.String())
+	}
+
+	// The file itself is renamed, not copied
+	moved, err := os.Stat(filepath.Join(root, "private", "2024", "cat.jpg"))
+	if err != nil || !os.SameFile(original, moved) {
+		t.Errorf("expected the photo to be renamed into the private bucket, got %v", err)
+	}
+	if _, err := s.StatObject("photos", "2024/cat.jpg", ""); !os.IsNotExist(err) {
+		t.Errorf("expected the source to be gone, got %v", err)
+	}
+	if _, err := s.StatObject("photos", "2024/dog.jpg", ""); err != nil {
+		t.Errorf("expected the other photo to stay, got %v", err)
+	}
+
+	// Moving within the same bucket and key would lose the object
+	body = `<Move><TargetBucket>photos</TargetBucket><Object><Key>2024/dog.jpg</Key></Object></Move>`
+	rr = sendWithHeaders(r, "POST", "/photos/?move", body, nil)
+	var failed dto.DeleteResult
+	if err := xml.Unmarshal(rr.Body.Bytes(), &failed); err != nil || len(failed.Errors) != 1 || failed.Errors[0].Code != "InvalidRequest" {
+		t.Errorf("expected moving an object onto itself to fail, got %s", rr.Body.String())
+	}
+	if _, err := s.StatObject("photos", "2024/dog.jpg", ""); err != nil {
+		t.Errorf("expected the photo to be kept, got %v", err)
+	}
+}
+
+func TestMoveObjectDuringUploads(t *testing.T) {
+	s := &storage.FileStorage{Root: t.TempDir()}
+	for _, bucket := range []string{"photos", "archive"} {
+		if err := s.CreateBucket(bucket, storage.CreateBucketOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.SetBucketVersioning("photos", storage.VersioningEnabled); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each delete marker left by a move must hide the very version that was moved, not an upload that raced with it
+	const rounds = 50
+	uploaded := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		defer close(uploaded)
+		for i := 0; i < rounds; i++ {
+			content := fmt.Sprintf("photo %d %s", i, strings.Repeat("#", 32*1024))
+			if _, err := s.AddObject("photos", "cat.jpg", strings.NewReader(content), storage.PutObjectOptions{DecodedContentLength: -1}); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-uploaded:
+				return
+			default:
+			}
+			if _, err := s.MoveObject("photos", "cat.jpg", "archive", fmt.Sprintf("cat-%d.jpg", i)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	wg.Wait()
+
+	moved := make(map[string]bool)
+	archived, err := s.ListObjects("archive", storage.ListObjectsOptions{MaxKeys: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, object := range archived.Objects {
+		moved[object.ETag] = true
+	}
+	history, err := s.ListObjectVersions("photos", storage.ListObjectVersionsOptions{MaxKeys: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Version IDs start with the time they were committed, whereas LastModified is the time the upload started
+	sort.Slice(history.Versions, func(i, j int) bool { return history.Versions[i].VersionID > history.Versions[j].VersionID })
+	markers := 0
+	for i, version := range history.Versions {
+		if !version.IsDeleteMarker {
+			continue
+		}
+		markers++
+		if i+1 == len(history.Versions) || history.Versions[i+1].IsDeleteMarker || !moved[history.Versions[i+1].ETag] {
+			t.Errorf("delete marker %s does not hide a moved version", version.VersionID)
+		}
+	}
+	if markers != len(archived.Objects) {
+		t.Errorf("expected one delete marker per moved object, got %d markers for %d objects", markers, len(archived.Objects))
+	}
+}
@@ -0,0 +1,209 @@
+package tests
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/router"
+	"my-s3-clone/storage"
+)
+
+const testCORSConfiguration = `<CORSConfiguration>
+  <CORSRule>
+    <ID>uploads</ID>
+    <AllowedOrigin>https://*.photos.example.com</AllowedOrigin>
+    <AllowedMethod>PUT</AllowedMethod>
+    <AllowedMethod>GET</AllowedMethod>
+    <AllowedHeader>Content-Type</AllowedHeader>
+    <AllowedHeader>x-amz-*</AllowedHeader>
+    <ExposeHeader>ETag</ExposeHeader>
+    <MaxAgeSeconds>600</MaxAgeSeconds>
+  </CORSRule>
+  <CORSRule>
+    <AllowedOrigin>*</AllowedOrigin>
+    <AllowedMethod>GET</AllowedMethod>
+  </CORSRule>
+</CORSConfiguration>`
+
+func preflight(r http.Handler, path, origin, method, headers string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("OPTIONS", path, nil)
+	req.Header.Set("Origin", origin)
+	req.Header.Set("Access-Control-Request-Method", method)
+	if headers != "" {
+		req.Header.Set("Access-Control-Request-Headers", headers)
+	}
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestBucketCorsConfiguration(t *testing.T) {
+	s := storage.NewMemoryStorage()
+	if err := s.CreateBucket("photos", storage.CreateBucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	r := router.SetupRouterWithStorage(s, testCredentials)
+
+	rr := sendWithHeaders(r, "GET", "/photos/?cors", "", nil)
+	if rr.Code != http.StatusNotFound || errorCode(t, rr) != "NoSuchCORSConfiguration" {
+		t.Fatalf("expected NoSuchCORSConfiguration but got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = sendWithHeaders(r, "PUT", "/photos/?cors", testCORSConfiguration, nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	rr = sendWithHeaders(r, "GET", "/photos/?cors", "", nil)
+	var config dto.CORSConfiguration
+	if err := xml.Unmarshal(rr.Body.Bytes(), &config); err != nil {
+		t.Fatalf("could not parse CORSConfiguration: %v", err)
+	}
+	if len(config.Rules) != 2 || config.Rules[0].ID != "uploads" || config.Rules[0].MaxAgeSeconds != 600 ||
+		len(config.Rules[0].AllowedHeaders) != 2 || config.Rules[1].AllowedOrigins[0] != "*" {
+		t.Errorf("unexpected configuration %+v", config)
+	}
+
+	tests := []struct {
+		name string
+		body string
+		code string
+	}{
+		{"malformed", "<CORSConfiguration>", "MalformedXML"},
+		{"no rules", "<CORSConfiguration></CORSConfiguration>", "InvalidArgument"},
+		{"unknown method", "<CORSConfiguration><CORSRule><AllowedOrigin>*</AllowedOrigin><AllowedMethod>PATCH</AllowedMethod></CORSRule></CORSConfiguration>", "InvalidArgument"},
+		{"two wildcards", "<CORSConfiguration><CORSRule><AllowedOrigin>https://*.*.com</AllowedOrigin><AllowedMethod>GET</AllowedMethod></CORSRule></CORSConfiguration>", "InvalidArgument"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := sendWithHeaders(r, "PUT", "/photos/?cors", tt.body, nil)
+			if rr.Code != http.StatusBadRequest || errorCode(t, rr) != tt.code {
+				t.Errorf("expected %s but got %d: %s", tt.code, rr.Code, rr.Body.String())
+			}
+		})
+	}
+
+	rr = sendWithHeaders(r, "DELETE", "/photos/?cors", "", nil)
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusNoContent, rr.Code, rr.Body.String())
+	}
+	if _, err := s.GetBucketCors("photos"); err != storage.ErrNoSuchCORS {
+		t.Errorf("expected the CORS configuration to be removed, got %v", err)
+	}
+}
+
+func TestPreflightUsesBucketCorsRules(t *testing.T) {
+	s := storage.NewMemoryStorage()
+	for _, bucket := range []string{"photos", "private"} {
+		if err := s.CreateBucket(bucket, storage.CreateBucketOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := router.SetupRouterWithStorage(s, testCredentials)
+	if rr := sendWithHeaders(r, "PUT", "/photos/?cors", testCORSConfiguration, nil); rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	// A presigned upload from a deployment origin
+	rr := preflight(r, "/photos/2024/cat.jpg", "https://eu.photos.example.com", "PUT", "Content-Type, X-Amz-Meta-Album")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected the preflight to be allowed but got %d: %s", rr.Code, rr.Body.String())
+	}
+	for header, want := range map[string]string{
+		"Access-Control-Allow-Origin":      "https://eu.photos.example.com",
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Allow-Methods":     "PUT, GET",
+		"Access-Control-Allow-Headers":     "Content-Type, X-Amz-Meta-Album",
+		"Access-Control-Max-Age":           "600",
+	} {
+		if got := rr.Header().Get(header); got != want {
+			t.Errorf("expected %s %q but got %q", header, want, got)
+		}
+	}
+
+	// Any origin may read, without credentials
+	rr = preflight(r, "/photos/2024/cat.jpg", "https://blog.example.org", "GET", "")
+	if rr.Code != http.StatusOK || rr.Header().Get("Access-Control-Allow-Origin") != "*" || rr.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Errorf("expected a public read to be allowed, got %d with headers %v", rr.Code, rr.Header())
+	}
+
+	forbidden := []struct {
+		name, origin, method, headers string
+	}{
+		{"other origin uploading", "https://blog.example.org", "PUT", ""},
+		{"method not allowed", "https://eu.photos.example.com", "DELETE", ""},
+		{"header not allowed", "https://eu.photos.example.com", "PUT", "Content-Type, X-Custom"},
+		{"origin outside the wildcard", "https://photos.example.com", "PUT", ""},
+	}
+	for _, tt := range forbidden {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := preflight(r, "/photos/2024/cat.jpg", tt.origin, tt.method, tt.headers)
+			if rr.Code != http.StatusForbidden || errorCode(t, rr) != "AccessForbidden" {
+				t.Errorf("expected AccessForbidden but got %d: %s", rr.Code, rr.Body.String())
+			}
+			if rr.Header().Get("Access-Control-Allow-Origin") != "" {
+				t.Errorf("expected no Access-Control-Allow-Origin, got %q", rr.Header().Get("Access-Control-Allow-Origin"))
+			}
+		})
+	}
+
+	// Actual requests get the headers of the matching rule
+	req := httptest.NewRequest("GET", "/photos/", nil)
+	req.Header.Set("Origin", "https://eu.photos.example.com")
+	signTestRequest(req)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	if rr.Header().Get("Access-Control-Allow-Origin") != "https://eu.photos.example.com" || rr.Header().Get("Access-Control-Expose-Headers") != "ETag" {
+		t.Errorf("expected the CORS headers of the upload rule, got %v", rr.Header())
+	}
+
+	// A bucket without rules falls back to the origins of the server configuration
+	rr = preflight(r, "/private/cat.jpg", "http://localhost:3000", "PUT", "Content-Type")
+	if rr.Code != http.StatusOK || rr.Header().Get("Access-Control-Allow-Origin") != "http://localhost:3000" {
+		t.Errorf("expected the default origin to be allowed, got %d with headers %v", rr.Code, rr.Header())
+	}
+	rr = preflight(r, "/private/cat.jpg", "https://eu.photos.example.com", "PUT", "")
+	if rr.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("expected the bucket rules of photos not to apply to private, got %v", rr.Header())
+	}
+}
+
+// The admin API and the health check belong to the server: no bucket rules are looked up for them
+func TestServerRoutesUseServerCorsOrigins(t *testing.T) {
+	var lookups []string
+	mockStorage := &MockStorage{
+		GetBucketCorsFunc: func(bucketName string) (dto.CORSConfiguration, error) {
+			lookups = append(lookups, bucketName)
+			var config dto.CORSConfiguration
+			err := xml.Unmarshal([]byte(testCORSConfiguration), &config)
+			return config, err
+		},
+	}
+	r := router.SetupRouterWithStorage(mockStorage, testCredentials)
+
+	get := func(path, origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Origin", origin)
+		signTestRequest(req)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+	for _, path := range []string{"/_admin/usage", "/probe-bsign"} {
+		if rr := get(path, "http://localhost:3000"); rr.Header().Get("Access-Control-Allow-Origin") != "http://localhost:3000" {
+			t.Errorf("%s: expected the server origin to be allowed, got %d with headers %v", path, rr.Code, rr.Header())
+		}
+		if rr := get(path, "https://eu.photos.example.com"); rr.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Errorf("%s: expected bucket rules not to apply, got %v", path, rr.Header())
+		}
+	}
+	if rr := preflight(r, "/_admin/usage", "http://localhost:3000", "GET", "Authorization"); rr.Code != http.StatusOK || rr.Header().Get("Access-Control-Allow-Origin") != "http://localhost:3000" {
+		t.Errorf("expected the admin preflight to be allowed, got %d with headers %v", rr.Code, rr.Header())
+	}
+	if len(lookups) != 0 {
+		t.Errorf("expected no bucket CORS lookup, got %v", lookups)
+	}
+}
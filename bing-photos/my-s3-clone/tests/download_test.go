@@ -0,0 +1,87 @@
+package tests
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"my-s3-clone/dto"
+	"my-s3-clone/handlers"
+)
+
+// nopSeekCloser ajoute un Close sans effet à un io.ReadSeeker
+type nopSeekCloser struct {
+	io.ReadSeeker
+}
+
+func (nopSeekCloser) Close() error { return nil }
+
+func TestHandleDownloadObjectRangesAndConditions(t *testing.T) {
+	content := []byte("0123456789abcdefghij")
+	modTime := time.Date(2024, 9, 16, 10, 12, 24, 0, time.UTC)
+
+	mockStorage := &MockStorage{
+		GetObjectFunc: func(bucketName, objectName, versionID string, customerKey []byte) (io.ReadSeekCloser, dto.ObjectInfo, error) {
+			info := dto.ObjectInfo{Key: objectName, Size: int64(len(content)), LastModified: modTime, ETag: `"a2b6f8ba40b9a8bc21e0a4d0e0a7b5b7"`}
+			return nopSeekCloser{bytes.NewReader(content)}, info, nil
+		},
+	}
+
+	r := mux.NewRouter()
+	r.HandleFunc("/{bucketName}/{objectName}", handlers.HandleDownloadObject(mockStorage)).Methods("GET")
+
+	// Récupérer l'ETag renvoyé pour l'objet
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/test-bucket/video.mp4", nil))
+	eTag := rr.Header().Get("ETag")
+	if eTag == "" {
+		t.Fatalf("expected ETag header to be set")
+	}
+
+	tests := []struct {
+		name                 string
+		headers              map[string]string
+		expectedCode         int
+		expectedBody         string
+		expectedContentRange string
+	}{
+		{"full object", nil, http.StatusOK, string(content), ""},
+		{"bounded range", map[string]string{"Range": "bytes=0-4"}, http.StatusPartialContent, "01234", "bytes 0-4/20"},
+		{"open range", map[string]string{"Range": "bytes=15-"}, http.StatusPartialContent, "fghij", "bytes 15-19/20"},
+		{"suffix range", map[string]string{"Range": "bytes=-3"}, http.StatusPartialContent, "hij", "bytes 17-19/20"},
+		{"range past the end", map[string]string{"Range": "bytes=5-100"}, http.StatusPartialContent, string(content[5:]), "bytes 5-19/20"},
+		{"unsatisfiable range", map[string]string{"Range": "bytes=20-"}, http.StatusRequestedRangeNotSatisfiable, "", "bytes */20"},
+		{"if-none-match hit", map[string]string{"If-None-Match": eTag}, http.StatusNotModified, "", ""},
+		{"if-none-match miss", map[string]string{"If-None-Match": `"other"`}, http.StatusOK, string(content), ""},
+		{"if-match miss", map[string]string{"If-Match": `"other"`}, http.StatusPreconditionFailed, "", ""},
+		{"if-match hit with range", map[string]string{"If-Match": eTag, "Range": "bytes=1-2"}, http.StatusPartialContent, "12", "bytes 1-2/20"},
+		{"not modified since", map[string]string{"If-Modified-Since": modTime.Format(http.TimeFormat)}, http.StatusNotModified, "", ""},
+		{"modified since", map[string]string{"If-Modified-Since": modTime.Add(-time.Hour).Format(http.TimeFormat)}, http.StatusOK, string(content), ""},
+		{"unmodified since failure", map[string]string{"If-Unmodified-Since": modTime.Add(-time.Hour).Format(http.TimeFormat)}, http.StatusPreconditionFailed, "", ""},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/test-bucket/video.mp4", nil)
+		for name, value := range tt.headers {
+			req.Header.Set(name, value)
+		}
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedCode {
+			t.Errorf("%s: expected status %d but got %d", tt.name, tt.expectedCode, rr.Code)
+			continue
+		}
+		if tt.expectedBody != "" && rr.Body.String() != tt.expectedBody {
+			t.Errorf("%s: expected body %q but got %q", tt.name, tt.expectedBody, rr.Body.String())
+		}
+		if contentRange := rr.Header().Get("Content-Range"); contentRange != tt.expectedContentRange {
+			t.Errorf("%s: expected Content-Range %q but got %q", tt.name, tt.expectedContentRange, contentRange)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/router"
+	"my-s3-clone/storage"
+)
+
+var testCustomerKey = bytes.Repeat([]byte{0x42}, 32)
+
+func customerKeyHeaders(prefix string, key []byte) map[string]string {
+	return map[string]string{
+		prefix + "algorithm": "AES256",
+		prefix + "key":       base64.StdEncoding.EncodeToString(key),
+		prefix + "key-MD5":   storage.CustomerKeyMD5(key),
+	}
+}
+
+func sendWithHeaders(r http.Handler, method, path, body string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	for name, value := range header {
+		req.Header.Set(name, value)
+	}
+	signTestRequest(req)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestPutObjectEncryptionHeaders(t *testing.T) {
+	var got storage.SSEOptions
+	mockStorage := &MockStorage{
+		AddObjectFunc: func(bucketName, objectName string, data io.Reader, opts storage.PutObjectOptions) (dto.ObjectInfo, error) {
+			got = opts.Encryption
+			info := dto.ObjectInfo{Key: objectName, ETag: `"etag"`, Encryption: storage.SSEModeS3}
+			if opts.Encryption.CustomerKey != nil {
+				info.Encryption, info.SSECustomerKeyMD5 = storage.SSEModeCustomer, storage.CustomerKeyMD5(opts.Encryption.CustomerKey)
+			}
+			return info, nil
+		},
+	}
+	r := router.SetupRouterWithStorage(mockStorage, testCredentials)
+
+	rr := sendWithHeaders(r, "PUT", "/photos/cat.jpg", "meow", map[string]string{"x-amz-server-side-encryption": "AES256"})
+	if rr.Code != http.StatusOK || !got.S3 || got.CustomerKey != nil {
+		t.Fatalf("expected an SSE-S3 upload but got %d with options %+v", rr.Code, got)
+	}
+	if rr.Header().Get("x-amz-server-side-encryption") != "AES256" {
+		t.Errorf("expected x-amz-server-side-encryption to be echoed, got headers %v", rr.Header())
+	}
+
+	rr = sendWithHeaders(r, "PUT", "/photos/cat.jpg", "meow", customerKeyHeaders("x-amz-server-side-encryption-customer-", testCustomerKey))
+	if rr.Code != http.StatusOK || !bytes.Equal(got.CustomerKey, testCustomerKey) {
+		t.Fatalf("expected an SSE-C upload but got %d with options %+v", rr.Code, got)
+	}
+	if rr.Header().Get("x-amz-server-side-encryption-customer-key-MD5") != storage.CustomerKeyMD5(testCustomerKey) ||
+		rr.Header().Get("x-amz-server-side-encryption") != "" {
+		t.Errorf("expected the customer key MD5 to be echoed, got headers %v", rr.Header())
+	}
+
+	wrongMD5 := customerKeyHeaders("x-amz-server-side-encryption-customer-", testCustomerKey)
+	wrongMD5["x-amz-server-side-encryption-customer-key-MD5"] = storage.CustomerKeyMD5([]byte("another key"))
+	both := customerKeyHeaders("x-amz-server-side-encryption-customer-", testCustomerKey)
+	both["x-amz-server-side-encryption"] = "AES256"
+
+	tests := []struct {
+		name   string
+		header map[string]string
+		code   string
+	}{
+		{"unknown algorithm", map[string]string{"x-amz-server-side-encryption": "aws:kms"}, "InvalidEncryptionAlgorithmError"},
+		{"short customer key", customerKeyHeaders("x-amz-server-side-encryption-customer-", []byte("short")), "InvalidArgument"},
+		{"customer key MD5 mismatch", wrongMD5, "InvalidArgument"},
+		{"SSE-S3 and SSE-C together", both, "InvalidArgument"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := sendWithHeaders(r, "PUT", "/photos/cat.jpg", "meow", tt.header)
+			if rr.Code != http.StatusBadRequest || errorCode(t, rr) != tt.code {
+				t.Errorf("expected %s but got %d: %s", tt.code, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestCustomerKeyRequiredToReadObject(t *testing.T) {
+	info := dto.ObjectInfo{
+		Key: "cat.jpg", Size: 4, ETag: `"etag"`,
+		Encryption: storage.SSEModeCustomer, SSECustomerKeyMD5: storage.CustomerKeyMD5(testCustomerKey),
+	}
+	mockStorage := &MockStorage{
+		StatObjectFunc: func(bucketName, objectName, versionID string) (dto.ObjectInfo, error) {
+			return info, nil
+		},
+		GetObjectFunc: func(bucketName, objectName, versionID string, customerKey []byte) (io.ReadSeekCloser, dto.ObjectInfo, error) {
+			if err := storage.CheckCustomerKey(info.SSECustomerKeyMD5, customerKey); err != nil {
+				return nil, dto.ObjectInfo{}, err
+			}
+			return nopSeekCloser{bytes.NewReader([]byte("meow"))}, info, nil
+		},
+	}
+	r := router.SetupRouterWithStorage(mockStorage, testCredentials)
+
+	for _, method := range []string{"GET", "HEAD"} {
+		rr := sendWithHeaders(r, method, "/photos/cat.jpg", "", nil)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s without the customer key: expected status %d but got %d", method, http.StatusBadRequest, rr.Code)
+		}
+
+		rr = sendWithHeaders(r, method, "/photos/cat.jpg", "", customerKeyHeaders("x-amz-server-side-encryption-customer-", bytes.Repeat([]byte{0x01}, 32)))
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("%s with another customer key: expected status %d but got %d", method, http.StatusForbidden, rr.Code)
+		}
+
+		rr = sendWithHeaders(r, method, "/photos/cat.jpg", "", customerKeyHeaders("x-amz-server-side-encryption-customer-", testCustomerKey))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s with the customer key: expected status %d but got %d: %s", method, http.StatusOK, rr.Code, rr.Body.String())
+		}
+		if rr.Header().Get("x-amz-server-side-encryption-customer-algorithm") != "AES256" ||
+			rr.Header().Get("x-amz-server-side-encryption-customer-key-MD5") != info.SSECustomerKeyMD5 {
+			t.Errorf("%s: expected the SSE-C headers to be echoed, got %v", method, rr.Header())
+		}
+	}
+}
+
+func TestCopyObjectEncryption(t *testing.T) {
+	var got storage.CopyObjectOptions
+	mockStorage := &MockStorage{
+		CopyObjectFunc: func(sourceBucket, sourceKey, targetBucket, targetKey string, opts storage.CopyObjectOptions) (dto.ObjectInfo, error) {
+			got = opts
+			return dto.ObjectInfo{Key: targetKey, ETag: `"etag"`, Encryption: storage.SSEModeS3}, nil
+		},
+	}
+	r := router.SetupRouterWithStorage(mockStorage, testCredentials)
+
+	// Copying an SSE-C object onto itself with SSE-S3 re-encrypts it under the server key
+	header := customerKeyHeaders("x-amz-copy-source-server-side-encryption-customer-", testCustomerKey)
+	header["x-amz-copy-source"] = "/photos/cat.jpg"
+	header["x-amz-server-side-encryption"] = "AES256"
+	rr := sendWithHeaders(r, "PUT", "/photos/cat.jpg", "", header)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if !bytes.Equal(got.SourceCustomerKey, testCustomerKey) || !got.Encryption.S3 || got.Encryption.CustomerKey != nil {
+		t.Errorf("expected the source customer key and SSE-S3 for the copy, got %+v", got)
+	}
+	if rr.Header().Get("x-amz-server-side-encryption") != "AES256" {
+		t.Errorf("expected x-amz-server-side-encryption to be echoed, got headers %v", rr.Header())
+	}
+}
+
+func TestEncryptedObjectMetadataNotRecomputed(t *testing.T) {
+	s := &storage.FileStorage{Root: t.TempDir(), MasterKey: bytes.Repeat([]byte{0x07}, 32)}
+	if err := s.CreateBucket("photos", storage.CreateBucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddObject("photos", "cat.jpg", strings.NewReader("meow"), storage.PutObjectOptions{DecodedContentLength: -1}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A file that no longer matches its metadata is normally rehashed, but the wrapped data key must survive
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(s.Root, "photos", "cat.jpg"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := s.StatObject("photos", "cat.jpg", ""); err != nil || info.Encryption != storage.SSEModeS3 || info.Size != 4 {
+		t.Fatalf("expected the encrypted object metadata to be kept, got %+v, %v", info, err)
+	}
+	reader, _, err := s.GetObject("photos", "cat.jpg", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	if data, err := io.ReadAll(reader); err != nil || string(data) != "meow" {
+		t.Errorf("expected the decrypted content, got %q, %v", data, err)
+	}
+}
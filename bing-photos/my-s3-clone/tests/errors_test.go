@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/router"
+	"my-s3-clone/storage"
+)
+
+// errorCode decodes the S3 <Error> document of a response and returns its code
+func errorCode(t *testing.T, rr *httptest.ResponseRecorder) string {
+	t.Helper()
+	var errResp dto.ErrorResponse
+	if err := xml.Unmarshal(rr.Body.Bytes(), &errResp); err != nil {
+		t.Fatalf("expected an S3 error document but got %q: %v", rr.Body.String(), err)
+	}
+	if errResp.RequestId == "" {
+		t.Errorf("expected the error document to carry a request id")
+	}
+	return errResp.Code
+}
+
+func newDeleteObjectsRequest(t *testing.T, bucketName string, quiet bool, keys ...string) *http.Request {
+	deleteReq := dto.DeleteObjectRequest{Quiet: quiet}
+	for _, key := range keys {
+		deleteReq.Objects = append(deleteReq.Objects, dto.ObjectToDelete{Key: key})
+	}
+	body, err := xml.Marshal(deleteReq)
+	if err != nil {
+		t.Fatalf("Error marshaling request body: %v", err)
+	}
+
+	req := httptest.NewRequest("POST", "/"+bucketName+"/?delete=", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/xml")
+	signTestRequest(req)
+	return req
+}
+
+func TestHandleDeleteObjectsPerKeyErrors(t *testing.T) {
+	mockStorage := &MockStorage{
+		DeleteObjectFunc: func(bucketName, objectName string, opts storage.DeleteObjectOptions) (dto.ObjectInfo, error) {
+			if bucketName == "missing-bucket" {
+				return dto.ObjectInfo{}, storage.ErrNoSuchBucket
+			}
+			switch objectName {
+			case "missing.jpg":
+				return dto.ObjectInfo{}, fmt.Errorf("object not found: %w", os.ErrNotExist)
+			case "../escape.jpg":
+				return dto.ObjectInfo{}, storage.ErrInvalidObjectName
+			case "broken.jpg":
+				return dto.ObjectInfo{}, fmt.Errorf("permission denied")
+			}
+			return dto.ObjectInfo{Key: objectName}, nil
+		},
+	}
+	r := router.SetupRouterWithStorage(mockStorage, testCredentials)
+
+	tests := []struct {
+		name            string
+		quiet           bool
+		expectedDeleted []string
+	}{
+		{"verbose", false, []string{"ok.jpg", "missing.jpg"}},
+		{"quiet", true, nil},
+	}
+
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, newDeleteObjectsRequest(t, "photos", tt.quiet, "ok.jpg", "missing.jpg", "../escape.jpg", "broken.jpg"))
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected status %d but got %d", tt.name, http.StatusOK, rr.Code)
+		}
+		var result dto.DeleteResult
+		if err := xml.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+			t.Fatalf("%s: Error unmarshaling response body: %v", tt.name, err)
+		}
+
+		// A missing key counts as deleted, as on S3
+		var deleted []string
+		for _, d := range result.DeletedResult {
+			deleted = append(deleted, d.Key)
+		}
+		if strings.Join(deleted, ",") != strings.Join(tt.expectedDeleted, ",") {
+			t.Errorf("%s: expected deleted keys %v but got %v", tt.name, tt.expectedDeleted, deleted)
+		}
+
+		// Failures are reported per key, even in quiet mode
+		if len(result.Errors) != 2 {
+			t.Fatalf("%s: expected 2 errors but got %+v", tt.name, result.Errors)
+		}
+		if result.Errors[0].Key != "../escape.jpg" || result.Errors[0].Code != "InvalidObjectName" {
+			t.Errorf("%s: unexpected first error %+v", tt.name, result.Errors[0])
+		}
+		if result.Errors[1].Key != "broken.jpg" || result.Errors[1].Code != "InternalError" {
+			t.Errorf("%s: unexpected second error %+v", tt.name, result.Errors[1])
+		}
+	}
+
+	// The whole request fails when the bucket does not exist
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, newDeleteObjectsRequest(t, "missing-bucket", false, "ok.jpg"))
+	if rr.Code != http.StatusNotFound || errorCode(t, rr) != "NoSuchBucket" {
+		t.Errorf("expected NoSuchBucket for a missing bucket but got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// A request without keys is malformed
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, newDeleteObjectsRequest(t, "photos", false))
+	if rr.Code != http.StatusBadRequest || errorCode(t, rr) != "MalformedXML" {
+		t.Errorf("expected MalformedXML for an empty request but got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestErrorResponsesAreS3Documents(t *testing.T) {
+	mockStorage := &MockStorage{}
+	r := router.SetupRouterWithStorage(mockStorage, testCredentials)
+
+	tests := []struct {
+		method       string
+		path         string
+		expectedCode int
+		expectedErr  string
+	}{
+		{"GET", "/photos/missing.jpg", http.StatusNotFound, "NoSuchKey"},
+		{"PATCH", "/photos/img.jpg", http.StatusMethodNotAllowed, "MethodNotAllowed"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		signTestRequest(req)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedCode {
+			t.Errorf("%s %s: expected status %d but got %d", tt.method, tt.path, tt.expectedCode, rr.Code)
+		}
+		if code := errorCode(t, rr); code != tt.expectedErr {
+			t.Errorf("%s %s: expected error %s but got %s", tt.method, tt.path, tt.expectedErr, code)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package tests
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"my-s3-clone/config"
+	"my-s3-clone/dto"
+	"my-s3-clone/router"
+	"my-s3-clone/storage"
+)
+
+func TestHandleAddObjectIntegrityChecks(t *testing.T) {
+	content := []byte("file content")
+	sum := md5.Sum(content)
+	validMD5 := base64.StdEncoding.EncodeToString(sum[:])
+	wrongSum := md5.Sum([]byte("other content"))
+
+	mockStorage := &MockStorage{
+		AddObjectFunc: func(bucketName, objectName string, data io.Reader, opts storage.PutObjectOptions) (dto.ObjectInfo, error) {
+			io.Copy(io.Discard, data)
+			// Simulate the comparison done by the storage while writing the object
+			if opts.ContentMD5 != nil && !bytes.Equal(opts.ContentMD5, sum[:]) {
+				return dto.ObjectInfo{}, storage.ErrBadDigest
+			}
+			if opts.ContentSha256 == "0000000000000000000000000000000000000000000000000000000000000000" {
+				return dto.ObjectInfo{}, storage.ErrContentSHA256Mismatch
+			}
+			return dto.ObjectInfo{Key: objectName, Size: int64(len(content)), ETag: `"d10b4c3ff123b26dc068d43a8bef2d23"`}, nil
+		},
+	}
+	r := router.SetupRouterWithStorage(mockStorage, testCredentials)
+
+	tests := []struct {
+		name          string
+		contentMD5    string
+		contentSha256 string
+		expectedCode  int
+		expectedError string
+	}{
+		{"valid Content-MD5", validMD5, "", http.StatusOK, ""},
+		{"mismatching Content-MD5", base64.StdEncoding.EncodeToString(wrongSum[:]), "", http.StatusBadRequest, "BadDigest"},
+		{"malformed Content-MD5", "not-base64!", "", http.StatusBadRequest, "InvalidDigest"},
+		{"truncated Content-MD5", base64.StdEncoding.EncodeToString(sum[:8]), "", http.StatusBadRequest, "InvalidDigest"},
+		{"mismatching x-amz-content-sha256", "", "0000000000000000000000000000000000000000000000000000000000000000", http.StatusBadRequest, "XAmzContentSHA256Mismatch"},
+		{"x-amz-content-sha256 of the content", "", "e0ac3601005dfa1864f5392aabaf7d898b1b5bab854f1acb4491bcd806b76b0c", http.StatusOK, ""},
+		{"malformed x-amz-content-sha256", "", "dummyhash", http.StatusBadRequest, "InvalidArgument"},
+		{"truncated x-amz-content-sha256", "", "e0ac3601005dfa1864f5392aabaf7d89", http.StatusBadRequest, "InvalidArgument"},
+		{"unsigned payload with trailer", "", "STREAMING-UNSIGNED-PAYLOAD-TRAILER", http.StatusNotImplemented, "NotImplemented"},
+		{"signed payload with trailer", "", "STREAMING-AWS4-HMAC-SHA256-PAYLOAD-TRAILER", http.StatusNotImplemented, "NotImplemented"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("PUT", "/test-bucket/test-object", bytes.NewReader(content))
+		if tt.contentMD5 != "" {
+			req.Header.Set("Content-MD5", tt.contentMD5)
+		}
+		if tt.contentSha256 != "" {
+			req.Header.Set("X-Amz-Content-Sha256", tt.contentSha256)
+		}
+		signTestRequest(req)
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedCode {
+			t.Errorf("%s: expected status %d but got %d: %s", tt.name, tt.expectedCode, rr.Code, rr.Body.String())
+			continue
+		}
+		if tt.expectedError == "" {
+			continue
+		}
+
+		var errResp dto.ErrorResponse
+		if err := xml.Unmarshal(rr.Body.Bytes(), &errResp); err != nil {
+			t.Fatalf("%s: error unmarshaling response body: %v", tt.name, err)
+		}
+		if errResp.Code != tt.expectedError {
+			t.Errorf("%s: expected error %s but got %s", tt.name, tt.expectedError, errResp.Code)
+		}
+		if errResp.RequestId != rr.Header().Get("x-amz-request-id") {
+			t.Errorf("%s: expected RequestId %q to match the x-amz-request-id header %q", tt.name, errResp.RequestId, rr.Header().Get("x-amz-request-id"))
+		}
+	}
+}
+
+// A signed x-amz-content-sha256 covers the body of every request, not only object uploads:
+// a captured request replayed with another body must be refused
+func TestSignedPayloadHashIsChecked(t *testing.T) {
+	cfg := config.Default()
+	cfg.Credentials = testCredentials
+	cfg.KeysFile = filepath.Join(t.TempDir(), "keys.json")
+	s := storage.NewMemoryStorage()
+	r := router.SetupRouterWithConfig(s, cfg)
+	if rr := sendWithHeaders(r, "PUT", "/photos/", "", nil); rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	replay := func(method, path, signedBody, sentBody string) *httptest.ResponseRecorder {
+		sum := sha256.Sum256([]byte(signedBody))
+		req := httptest.NewRequest(method, path, strings.NewReader(signedBody))
+		req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(sum[:]))
+		signTestRequest(req)
+		req.Body = io.NopCloser(strings.NewReader(sentBody))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	policy := `{"Statement": [{"Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::photos/shared/*"}]}`
+	opened := strings.Replace(policy, "photos/shared/*", "photos/*", 1)
+	if rr := replay("PUT", "/photos/?policy", policy, opened); rr.Code != http.StatusBadRequest || errorCode(t, rr) != "XAmzContentSHA256Mismatch" {
+		t.Errorf("policy: expected XAmzContentSHA256Mismatch but got %d: %s", rr.Code, rr.Body.String())
+	}
+	if _, err := s.GetBucketPolicy("photos"); err == nil {
+		t.Errorf("expected the replayed policy not to be stored")
+	}
+	if rr := replay("PUT", "/photos/?policy", policy, policy); rr.Code != http.StatusNoContent {
+		t.Errorf("policy: expected status %d but got %d: %s", http.StatusNoContent, rr.Code, rr.Body.String())
+	}
+
+	key := `{"policy": {"statements": [{"actions": ["s3:GetObject"], "resources": ["photos/*"]}]}}`
+	admin := `{"policy": {"statements": [{"actions": ["s3:*"], "resources": ["*"]}]}}`
+	if rr := replay("POST", "/_admin/keys", key, admin); rr.Code != http.StatusBadRequest || errorCode(t, rr) != "XAmzContentSHA256Mismatch" {
+		t.Errorf("admin: expected XAmzContentSHA256Mismatch but got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestRequestIDsAreUnique(t *testing.T) {
+	r := router.SetupRouterWithStorage(&MockStorage{}, testCredentials)
+
+	seen := map[string]bool{}
+	for i := 0; i < 5; i++ {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("GET", "/probe-bsign", nil))
+
+		requestID := rr.Header().Get("x-amz-request-id")
+		if requestID == "" || rr.Header().Get("x-amz-id-2") == "" {
+			t.Fatalf("expected x-amz-request-id and x-amz-id-2 headers to be set")
+		}
+		if seen[requestID] {
+			t.Errorf("request id %s was returned twice", requestID)
+		}
+		seen[requestID] = true
+	}
+}
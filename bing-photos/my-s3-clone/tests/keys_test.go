@@ -0,0 +1,134 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/router"
+	"my-s3-clone/storage"
+)
+
+func TestValidateObjectName(t *testing.T) {
+	tests := []struct {
+		objectName  string
+		expectedErr error
+	}{
+		{"img.jpg", nil},
+		{"2024/trip/img.jpg", nil},
+		{"photos de vacances/été 2024.jpg", nil},
+		{"..hidden/file..jpg", nil},
+		{"", storage.ErrInvalidObjectName},
+		{"../other-bucket/img.jpg", storage.ErrInvalidObjectName},
+		{"2024/../../../etc/passwd", storage.ErrInvalidObjectName},
+		{"2024/./img.jpg", storage.ErrInvalidObjectName},
+		{"/absolute.jpg", storage.ErrInvalidObjectName},
+		{"2024//img.jpg", storage.ErrInvalidObjectName},
+		{"trailing/", storage.ErrInvalidObjectName},
+		{"nul\x00byte", storage.ErrInvalidObjectName},
+		{strings.Repeat("a", 1025), storage.ErrKeyTooLong},
+	}
+
+	for _, tt := range tests {
+		err := storage.ValidateObjectName(tt.objectName)
+		if !errors.Is(err, tt.expectedErr) && !(err == nil && tt.expectedErr == nil) {
+			t.Errorf("ValidateObjectName(%q): expected %v but got %v", tt.objectName, tt.expectedErr, err)
+		}
+	}
+}
+
+func TestValidateBucketName(t *testing.T) {
+	tests := []struct {
+		bucketName string
+		valid      bool
+	}{
+		{"photos", true},
+		{"my-album.2024", true},
+		{"", false},
+		{".", false},
+		{"..", false},
+		{".s3clone", false},
+		{"a/b", false},
+	}
+
+	for _, tt := range tests {
+		err := storage.ValidateBucketName(tt.bucketName)
+		if (err == nil) != tt.valid {
+			t.Errorf("ValidateBucketName(%q): expected valid=%v but got %v", tt.bucketName, tt.valid, err)
+		}
+	}
+}
+
+func TestNestedObjectKeys(t *testing.T) {
+	var uploadedKey, copiedKey string
+	mockStorage := &MockStorage{
+		AddObjectFunc: func(bucketName, objectName string, data io.Reader, opts storage.PutObjectOptions) (dto.ObjectInfo, error) {
+			io.Copy(io.Discard, data)
+			uploadedKey = objectName
+			return dto.ObjectInfo{Key: objectName, ETag: `"etag"`}, nil
+		},
+		StatObjectFunc: func(bucketName, objectName, versionID string) (dto.ObjectInfo, error) {
+			return dto.ObjectInfo{Key: objectName, Size: 3, ETag: `"etag"`}, nil
+		},
+		CopyObjectFunc: func(sourceBucket, sourceKey, targetBucket, targetKey string, opts storage.CopyObjectOptions) (dto.ObjectInfo, error) {
+			// Same validation as FileStorage
+			if err := storage.ValidateObjectName(sourceKey); err != nil {
+				return dto.ObjectInfo{}, err
+			}
+			copiedKey = targetKey
+			return dto.ObjectInfo{Key: targetKey, ETag: `"etag"`}, nil
+		},
+	}
+	r := router.SetupRouterWithStorage(mockStorage, testCredentials)
+
+	// PUT on a nested key
+	req := httptest.NewRequest("PUT", "/photos/2024/trip/img.jpg", bytes.NewReader([]byte("abc")))
+	signTestRequest(req)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || uploadedKey != "2024/trip/img.jpg" {
+		t.Errorf("expected the nested key to be uploaded, got status %d and key %q", rr.Code, uploadedKey)
+	}
+
+	// HEAD on a nested key
+	req = httptest.NewRequest("HEAD", "/photos/2024/trip/img.jpg", nil)
+	signTestRequest(req)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status %d for HEAD on a nested key but got %d", http.StatusOK, rr.Code)
+	}
+
+	// Copy to a nested key
+	req = httptest.NewRequest("PUT", "/backup/2024/trip/img.jpg", nil)
+	req.Header.Set("X-Amz-Copy-Source", "/photos/2024/trip/img.jpg")
+	signTestRequest(req)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || copiedKey != "2024/trip/img.jpg" {
+		t.Errorf("expected the nested key to be copied, got status %d and key %q", rr.Code, copiedKey)
+	}
+
+	// Copy from a source escaping its bucket
+	req = httptest.NewRequest("PUT", "/backup/passwd", nil)
+	req.Header.Set("X-Amz-Copy-Source", "/photos/../../../etc/passwd")
+	signTestRequest(req)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d for a traversal copy source but got %d", http.StatusBadRequest, rr.Code)
+	}
+	var errResp dto.ErrorResponse
+	if err := xml.Unmarshal(rr.Body.Bytes(), &errResp); err != nil {
+		t.Fatalf("Error unmarshaling response body: %v", err)
+	}
+	if errResp.Code != "InvalidObjectName" {
+		t.Errorf("expected error InvalidObjectName but got %s", errResp.Code)
+	}
+}
@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/router"
+	"my-s3-clone/storage"
+)
+
+func TestBucketLifecycleConfiguration(t *testing.T) {
+	var stored *dto.LifecycleConfiguration
+	mockStorage := &MockStorage{
+		GetBucketLifecycleFunc: func(bucketName string) (dto.LifecycleConfiguration, error) {
+			if stored == nil {
+				return dto.LifecycleConfiguration{}, storage.ErrNoSuchLifecycle
+			}
+			return *stored, nil
+		},
+		PutBucketLifecycleFunc: func(bucketName string, config dto.LifecycleConfiguration) error {
+			if len(config.Rules) == 0 {
+				return storage.ErrInvalidLifecycle
+			}
+			stored = &config
+			return nil
+		},
+		DeleteBucketLifecycleFunc: func(bucketName string) error {
+			stored = nil
+			return nil
+		},
+	}
+	r := router.SetupRouterWithStorage(mockStorage, testCredentials)
+
+	serve := func(method, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/photos/?lifecycle", strings.NewReader(body))
+		signTestRequest(req)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := serve("GET", "")
+	if rr.Code != http.StatusNotFound || errorCode(t, rr) != "NoSuchLifecycleConfiguration" {
+		t.Fatalf("expected NoSuchLifecycleConfiguration but got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = serve("PUT", `<LifecycleConfiguration>
+  <Rule>
+    <ID>tmp</ID>
+    <Status>Enabled</Status>
+    <Filter><And><Prefix>tmp/</Prefix><Tag><Key>kind</Key><Value>draft</Value></Tag></And></Filter>
+    <Expiration><Days>7</Days></Expiration>
+    <NoncurrentVersionExpiration><NoncurrentDays>30</NoncurrentDays></NoncurrentVersionExpiration>
+  </Rule>
+  <Rule>
+    <ID>uploads</ID>
+    <Status>Enabled</Status>
+    <Filter><Prefix></Prefix></Filter>
+    <AbortIncompleteMultipartUpload><DaysAfterInitiation>2</DaysAfterInitiation></AbortIncompleteMultipartUpload>
+  </Rule>
+</LifecycleConfiguration>`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	rule := stored.Rules[0]
+	if rule.Filter == nil || rule.Filter.And == nil || rule.Filter.And.Prefix != "tmp/" || len(rule.Filter.And.Tags) != 1 ||
+		rule.Expiration.Days != 7 || rule.NoncurrentVersionExpiration.NoncurrentDays != 30 {
+		t.Errorf("unexpected first rule %+v", rule)
+	}
+	if stored.Rules[1].AbortIncompleteMultipartUpload == nil || stored.Rules[1].AbortIncompleteMultipartUpload.DaysAfterInitiation != 2 {
+		t.Errorf("unexpected second rule %+v", stored.Rules[1])
+	}
+
+	rr = serve("GET", "")
+	var config dto.LifecycleConfiguration
+	if err := xml.Unmarshal(rr.Body.Bytes(), &config); err != nil {
+		t.Fatalf("Error unmarshaling response body: %v", err)
+	}
+	if len(config.Rules) != 2 || config.Rules[0].ID != "tmp" {
+		t.Errorf("unexpected configuration %+v", config)
+	}
+
+	rr = serve("PUT", `<LifecycleConfiguration></LifecycleConfiguration>`)
+	if rr.Code != http.StatusBadRequest || errorCode(t, rr) != "InvalidArgument" {
+		t.Errorf("expected InvalidArgument for an empty configuration but got %d: %s", rr.Code, rr.Body.String())
+	}
+	rr = serve("PUT", `<LifecycleConfiguration><Rule>`)
+	if rr.Code != http.StatusBadRequest || errorCode(t, rr) != "MalformedXML" {
+		t.Errorf("expected MalformedXML but got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = serve("DELETE", "")
+	if rr.Code != http.StatusNoContent || stored != nil {
+		t.Errorf("expected the configuration to be deleted but got %d", rr.Code)
+	}
+}
@@ -0,0 +1,177 @@
+package tests
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"my-s3-clone/dto"
+	"my-s3-clone/handlers"
+	"my-s3-clone/storage"
+)
+
+func TestHandleListObjectsV2(t *testing.T) {
+	var received storage.ListObjectsOptions
+	mockStorage := &MockStorage{
+		ListObjectsFunc: func(bucketName string, opts storage.ListObjectsOptions) (dto.ObjectListing, error) {
+			received = opts
+			if bucketName != "photos" {
+				return dto.ObjectListing{}, storage.ErrNoSuchBucket
+			}
+			if opts.StartAfter == "" {
+				return dto.ObjectListing{
+					Objects:        []dto.Object{{Key: "2024/cover photo.jpg", LastModified: time.Now(), ETag: `"etag"`, Size: 10}},
+					CommonPrefixes: []string{"2024/trip/"},
+					IsTruncated:    true,
+					NextMarker:     "2024/trip/",
+				}, nil
+			}
+			return dto.ObjectListing{
+				Objects: []dto.Object{{Key: "2024/zoo.jpg", LastModified: time.Now(), ETag: `"etag"`, Size: 20}},
+			}, nil
+		},
+	}
+
+	r := mux.NewRouter()
+	r.HandleFunc("/{bucketName}/", handlers.HandleListObjects(mockStorage)).Methods("GET")
+
+	list := func(query string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("GET", "/photos/?"+query, nil))
+		return rr
+	}
+
+	// First page
+	rr := list("list-type=2&prefix=2024/&delimiter=/&max-keys=2")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if received.Prefix != "2024/" || received.Delimiter != "/" || received.MaxKeys != 2 || received.StartAfter != "" {
+		t.Errorf("unexpected options passed to the storage: %+v", received)
+	}
+
+	var page dto.ListObjectsV2Response
+	if err := xml.Unmarshal(rr.Body.Bytes(), &page); err != nil {
+		t.Fatalf("Error unmarshaling response body: %v", err)
+	}
+	if !page.IsTruncated || page.NextContinuationToken == "" {
+		t.Fatalf("expected a truncated page with a continuation token, got %+v", page)
+	}
+	if page.KeyCount != 2 || len(page.CommonPrefixes) != 1 || page.CommonPrefixes[0].Prefix != "2024/trip/" {
+		t.Errorf("expected one key and one common prefix, got %+v", page)
+	}
+
+	// Second page: the token resumes after the last common prefix
+	rr = list("list-type=2&prefix=2024/&delimiter=/&continuation-token=" + url.QueryEscape(page.NextContinuationToken) + "&start-after=ignored")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if received.StartAfter != "2024/trip/" {
+		t.Errorf("expected the continuation token to resume after 2024/trip/, got %q", received.StartAfter)
+	}
+	page = dto.ListObjectsV2Response{}
+	if err := xml.Unmarshal(rr.Body.Bytes(), &page); err != nil {
+		t.Fatalf("Error unmarshaling response body: %v", err)
+	}
+	if page.IsTruncated || page.NextContinuationToken != "" || page.KeyCount != 1 {
+		t.Errorf("expected a last page with one key, got %+v", page)
+	}
+
+	// start-after without a token
+	list("list-type=2&start-after=2024/a.jpg")
+	if received.StartAfter != "2024/a.jpg" {
+		t.Errorf("expected start-after to be passed to the storage, got %q", received.StartAfter)
+	}
+
+	// URL encoding of keys
+	rr = list("list-type=2&encoding-type=url")
+	page = dto.ListObjectsV2Response{}
+	if err := xml.Unmarshal(rr.Body.Bytes(), &page); err != nil {
+		t.Fatalf("Error unmarshaling response body: %v", err)
+	}
+	if page.EncodingType != "url" || page.Contents[0].Key != "2024/cover+photo.jpg" {
+		t.Errorf("expected URL-encoded keys, got %+v", page.Contents)
+	}
+
+	// Large max-keys are capped to 1000
+	list("list-type=2&max-keys=5000")
+	if received.MaxKeys != 1000 {
+		t.Errorf("expected max-keys to be capped to 1000, got %d", received.MaxKeys)
+	}
+
+	errorTests := []struct {
+		name         string
+		url          string
+		expectedCode int
+		expectedErr  string
+	}{
+		{"invalid continuation token", "/photos/?list-type=2&continuation-token=%25%25", http.StatusBadRequest, "InvalidArgument"},
+		{"invalid encoding type", "/photos/?list-type=2&encoding-type=base64", http.StatusBadRequest, "InvalidArgument"},
+		{"negative max-keys", "/photos/?max-keys=-1", http.StatusBadRequest, "InvalidArgument"},
+		{"missing bucket", "/albums/?list-type=2", http.StatusNotFound, "NoSuchBucket"},
+	}
+	for _, tt := range errorTests {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("GET", tt.url, nil))
+		if rr.Code != tt.expectedCode {
+			t.Errorf("%s: expected status %d but got %d", tt.name, tt.expectedCode, rr.Code)
+			continue
+		}
+		var errResp dto.ErrorResponse
+		if err := xml.Unmarshal(rr.Body.Bytes(), &errResp); err != nil {
+			t.Fatalf("%s: error unmarshaling response body: %v", tt.name, err)
+		}
+		if errResp.Code != tt.expectedErr {
+			t.Errorf("%s: expected error %s but got %s", tt.name, tt.expectedErr, errResp.Code)
+		}
+	}
+}
+
+func TestHandleListObjectsV1NextMarker(t *testing.T) {
+	var received storage.ListObjectsOptions
+	mockStorage := &MockStorage{
+		ListObjectsFunc: func(bucketName string, opts storage.ListObjectsOptions) (dto.ObjectListing, error) {
+			received = opts
+			return dto.ObjectListing{
+				Objects:        []dto.Object{{Key: "a.jpg"}},
+				CommonPrefixes: []string{"b/"},
+				IsTruncated:    true,
+				NextMarker:     "b/",
+			}, nil
+		},
+	}
+
+	r := mux.NewRouter()
+	r.HandleFunc("/{bucketName}/", handlers.HandleListObjects(mockStorage)).Methods("GET")
+
+	tests := []struct {
+		query              string
+		expectedNextMarker string
+	}{
+		{"marker=a&delimiter=/&max-keys=2", "b/"},
+		{"marker=a&max-keys=2", ""},
+	}
+
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("GET", "/photos/?"+tt.query, nil))
+		if received.StartAfter != "a" {
+			t.Errorf("%s: expected marker to be passed as StartAfter, got %q", tt.query, received.StartAfter)
+		}
+
+		var response dto.ListObjectsResponse
+		if err := xml.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Error unmarshaling response body: %v", err)
+		}
+		if response.Marker != "a" || !response.IsTruncated {
+			t.Errorf("%s: unexpected response %+v", tt.query, response)
+		}
+		if response.NextMarker != tt.expectedNextMarker {
+			t.Errorf("%s: expected NextMarker %q but got %q", tt.query, tt.expectedNextMarker, response.NextMarker)
+		}
+	}
+}
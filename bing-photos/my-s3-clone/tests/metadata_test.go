@@ -0,0 +1,158 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/router"
+	"my-s3-clone/storage"
+)
+
+func TestObjectMetadataRoundTrip(t *testing.T) {
+	stored := map[string]dto.ObjectInfo{}
+	mockStorage := &MockStorage{
+		AddObjectFunc: func(bucketName, objectName string, data io.Reader, opts storage.PutObjectOptions) (dto.ObjectInfo, error) {
+			io.Copy(io.Discard, data)
+			info := dto.ObjectInfo{Key: objectName, Size: 5, LastModified: time.Now(), ETag: `"etag"`, Metadata: opts.Metadata}
+			stored[objectName] = info
+			return info, nil
+		},
+		StatObjectFunc: func(bucketName, objectName, versionID string) (dto.ObjectInfo, error) {
+			info, ok := stored[objectName]
+			if !ok {
+				return dto.ObjectInfo{}, os.ErrNotExist
+			}
+			return info, nil
+		},
+	}
+	r := router.SetupRouterWithStorage(mockStorage, testCredentials)
+
+	req := httptest.NewRequest("PUT", "/photos/cat.jpg", bytes.NewReader([]byte("hello")))
+	req.Header.Set("Content-Type", "image/jpeg")
+	req.Header.Set("Content-Disposition", `attachment; filename="cat.jpg"`)
+	req.Header.Set("Cache-Control", "max-age=3600")
+	req.Header.Set("Content-Encoding", "identity")
+	req.Header.Set("X-Amz-Meta-Album", "Vacances")
+	req.Header.Set("x-amz-meta-camera-model", "X100")
+	signTestRequest(req)
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	req = httptest.NewRequest("HEAD", "/photos/cat.jpg", nil)
+	signTestRequest(req)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d", http.StatusOK, rr.Code)
+	}
+
+	expectedHeaders := map[string]string{
+		"Content-Type":            "image/jpeg",
+		"Content-Disposition":     `attachment; filename="cat.jpg"`,
+		"Cache-Control":           "max-age=3600",
+		"Content-Encoding":        "identity",
+		"X-Amz-Meta-Album":        "Vacances",
+		"X-Amz-Meta-Camera-Model": "X100",
+	}
+	for name, expected := range expectedHeaders {
+		if got := rr.Header().Get(name); got != expected {
+			t.Errorf("expected %s %q but got %q", name, expected, got)
+		}
+	}
+}
+
+func TestHandleCopyObject(t *testing.T) {
+	source := dto.ObjectInfo{
+		Key:          "cat.jpg",
+		Size:         5,
+		LastModified: time.Date(2024, 9, 16, 10, 12, 24, 0, time.UTC),
+		ETag:         `"5d41402abc4b2a76b9719d911017c592"`,
+		Metadata:     dto.ObjectMetadata{ContentType: "image/jpeg"},
+	}
+
+	var copiedMetadata *dto.ObjectMetadata
+	mockStorage := &MockStorage{
+		CopyObjectFunc: func(sourceBucket, sourceKey, targetBucket, targetKey string, opts storage.CopyObjectOptions) (dto.ObjectInfo, error) {
+			if sourceBucket != "photos" || sourceKey != "cat.jpg" {
+				return dto.ObjectInfo{}, os.ErrNotExist
+			}
+			copiedMetadata = opts.Metadata
+			return source, nil
+		},
+	}
+	r := router.SetupRouterWithStorage(mockStorage, testCredentials)
+
+	tests := []struct {
+		name             string
+		target           string
+		copySource       string
+		directive        string
+		expectedCode     int
+		expectedError    string
+		expectedReplaced bool
+	}{
+		{"copy keeps metadata", "/backup/cat.jpg", "/photos/cat%2Ejpg", "", http.StatusOK, "", false},
+		{"copy replaces metadata", "/backup/cat.jpg", "photos/cat.jpg", "REPLACE", http.StatusOK, "", true},
+		{"copy onto itself with REPLACE", "/photos/cat.jpg", "photos/cat.jpg", "REPLACE", http.StatusOK, "", true},
+		{"copy onto itself without REPLACE", "/photos/cat.jpg", "photos/cat.jpg", "COPY", http.StatusBadRequest, "InvalidRequest", false},
+		{"unknown directive", "/backup/cat.jpg", "photos/cat.jpg", "MERGE", http.StatusBadRequest, "InvalidArgument", false},
+		{"malformed source", "/backup/cat.jpg", "photos", "", http.StatusBadRequest, "InvalidArgument", false},
+		{"missing source", "/backup/cat.jpg", "photos/dog.jpg", "", http.StatusNotFound, "NoSuchKey", false},
+	}
+
+	for _, tt := range tests {
+		copiedMetadata = nil
+		req := httptest.NewRequest("PUT", tt.target, nil)
+		req.Header.Set("X-Amz-Copy-Source", tt.copySource)
+		if tt.directive != "" {
+			req.Header.Set("X-Amz-Metadata-Directive", tt.directive)
+		}
+		req.Header.Set("Content-Type", "image/png")
+		signTestRequest(req)
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedCode {
+			t.Errorf("%s: expected status %d but got %d: %s", tt.name, tt.expectedCode, rr.Code, rr.Body.String())
+			continue
+		}
+
+		if tt.expectedError != "" {
+			var errResp dto.ErrorResponse
+			if err := xml.Unmarshal(rr.Body.Bytes(), &errResp); err != nil {
+				t.Fatalf("%s: error unmarshaling response body: %v", tt.name, err)
+			}
+			if errResp.Code != tt.expectedError {
+				t.Errorf("%s: expected error %s but got %s", tt.name, tt.expectedError, errResp.Code)
+			}
+			continue
+		}
+
+		var result dto.CopyObjectResult
+		if err := xml.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+			t.Fatalf("%s: error unmarshaling response body: %v", tt.name, err)
+		}
+		if result.ETag != source.ETag {
+			t.Errorf("%s: expected ETag %s but got %s", tt.name, source.ETag, result.ETag)
+		}
+		if tt.expectedReplaced {
+			if copiedMetadata == nil || copiedMetadata.ContentType != "image/png" {
+				t.Errorf("%s: expected the metadata to be replaced by the request headers, got %+v", tt.name, copiedMetadata)
+			}
+		} else if copiedMetadata != nil {
+			t.Errorf("%s: expected the source metadata to be kept, got %+v", tt.name, copiedMetadata)
+		}
+	}
+}
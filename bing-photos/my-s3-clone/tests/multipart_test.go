@@ -0,0 +1,98 @@
+package tests
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/router"
+	"my-s3-clone/storage"
+)
+
+func TestMultipartUploadRoutes(t *testing.T) {
+	var calls []string
+	mockStorage := &MockStorage{
+		CreateMultipartUploadFunc: func(bucketName, objectName string, opts storage.MultipartUploadOptions) (string, error) {
+			calls = append(calls, "create:"+bucketName+"/"+objectName)
+			return "upload-1", nil
+		},
+		UploadPartFunc: func(bucketName, objectName, uploadID string, partNumber int, data io.Reader, opts storage.PutObjectOptions) (string, error) {
+			body, _ := io.ReadAll(data)
+			calls = append(calls, "part:"+uploadID+":"+string(body))
+			if partNumber != 2 {
+				t.Errorf("expected part number 2 but got %d", partNumber)
+			}
+			return `"etag-2"`, nil
+		},
+		ListPartsFunc: func(bucketName, objectName, uploadID string, partNumberMarker, maxParts int) (dto.ListPartsResult, error) {
+			calls = append(calls, "list-parts:"+uploadID)
+			return dto.ListPartsResult{UploadId: uploadID, Parts: []dto.Part{{PartNumber: 1, ETag: `"etag-1"`, Size: 5}}}, nil
+		},
+		CompleteMultipartUploadFunc: func(bucketName, objectName, uploadID string, parts []dto.CompletedPart) (dto.ObjectInfo, error) {
+			calls = append(calls, "complete:"+uploadID)
+			if len(parts) != 2 || parts[1].PartNumber != 2 || parts[1].ETag != `"etag-2"` {
+				t.Errorf("unexpected parts: %+v", parts)
+			}
+			return dto.ObjectInfo{ETag: `"d41d8cd98f00b204e9800998ecf8427e-2"`}, nil
+		},
+		AbortMultipartUploadFunc: func(bucketName, objectName, uploadID string) error {
+			calls = append(calls, "abort:"+uploadID)
+			if uploadID != "upload-1" {
+				return storage.ErrNoSuchUpload
+			}
+			return nil
+		},
+		ListMultipartUploadsFunc: func(bucketName, prefix, keyMarker, uploadIDMarker string, maxUploads int) (dto.ListMultipartUploadsResult, error) {
+			calls = append(calls, "list-uploads:"+prefix)
+			return dto.ListMultipartUploadsResult{Bucket: bucketName}, nil
+		},
+	}
+	r := router.SetupRouterWithStorage(mockStorage, testCredentials)
+
+	completeBody := `<CompleteMultipartUpload>
+		<Part><PartNumber>1</PartNumber><ETag>"etag-1"</ETag></Part>
+		<Part><PartNumber>2</PartNumber><ETag>"etag-2"</ETag></Part>
+	</CompleteMultipartUpload>`
+
+	tests := []struct {
+		method       string
+		url          string
+		body         string
+		expectedCode int
+		expectedCall string
+		expectedBody string
+	}{
+		{"POST", "/photos/video.mp4?uploads", "", http.StatusOK, "create:photos/video.mp4", "<UploadId>upload-1</UploadId>"},
+		{"PUT", "/photos/video.mp4?partNumber=2&uploadId=upload-1", "hello", http.StatusOK, "part:upload-1:hello", ""},
+		{"PUT", "/photos/video.mp4?partNumber=abc&uploadId=upload-1", "hello", http.StatusBadRequest, "", "<Code>InvalidArgument</Code>"},
+		{"GET", "/photos/video.mp4?uploadId=upload-1", "", http.StatusOK, "list-parts:upload-1", "<PartNumber>1</PartNumber>"},
+		{"POST", "/photos/video.mp4?uploadId=upload-1", completeBody, http.StatusOK, "complete:upload-1", "<ETag>&#34;d41d8cd98f00b204e9800998ecf8427e-2&#34;</ETag>"},
+		{"POST", "/photos/video.mp4?uploadId=upload-1", "<not-xml", http.StatusBadRequest, "", "<Code>MalformedXML</Code>"},
+		{"DELETE", "/photos/video.mp4?uploadId=upload-1", "", http.StatusNoContent, "abort:upload-1", ""},
+		{"DELETE", "/photos/video.mp4?uploadId=unknown", "", http.StatusNotFound, "abort:unknown", "<Code>NoSuchUpload</Code>"},
+		{"GET", "/photos/?uploads&prefix=vid", "", http.StatusOK, "list-uploads:vid", "<ListMultipartUploadsResult"},
+	}
+
+	for _, tt := range tests {
+		calls = nil
+		req := httptest.NewRequest(tt.method, tt.url, bytes.NewBufferString(tt.body))
+		signTestRequest(req)
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedCode {
+			t.Errorf("%s %s: expected status %d but got %d (%s)", tt.method, tt.url, tt.expectedCode, rr.Code, rr.Body.String())
+		}
+		if tt.expectedCall != "" && (len(calls) != 1 || calls[0] != tt.expectedCall) {
+			t.Errorf("%s %s: expected storage call %q but got %v", tt.method, tt.url, tt.expectedCall, calls)
+		}
+		if !strings.Contains(rr.Body.String(), tt.expectedBody) {
+			t.Errorf("%s %s: expected body to contain %q but got %q", tt.method, tt.url, tt.expectedBody, rr.Body.String())
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package tests
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/notification"
+	"my-s3-clone/router"
+	"my-s3-clone/storage"
+)
+
+// webhookServer records the events posted to it; the first failures requests are answered with an error
+type webhookServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	failures int
+	requests int
+	events   chan dto.EventRecord
+}
+
+func newWebhookServer(t *testing.T, failures int) *webhookServer {
+	ws := &webhookServer{failures: failures, events: make(chan dto.EventRecord, 16)}
+	ws.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws.mu.Lock()
+		ws.requests++
+		fail := ws.requests <= ws.failures
+		ws.mu.Unlock()
+		if fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		var records dto.EventRecords
+		if err := json.Unmarshal(body, &records); err != nil || len(records.Records) != 1 || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected event %s: %s", r.Header.Get("Content-Type"), body)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		ws.events <- records.Records[0]
+	}))
+	t.Cleanup(ws.Close)
+	return ws
+}
+
+func (ws *webhookServer) next(t *testing.T) dto.EventRecord {
+	t.Helper()
+	select {
+	case record := <-ws.events:
+		return record
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for an event")
+		return dto.EventRecord{}
+	}
+}
+
+// waitFor polls condition until it holds or the test times out
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !condition(); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+}
+
+func queuedEvents(t *testing.T, pattern string) int {
+	t.Helper()
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(files)
+}
+
+func TestBucketNotificationWebhooks(t *testing.T) {
+	s := storage.NewMemoryStorage()
+	if err := s.CreateBucket("photos", storage.CreateBucketOptions{Owner: testAccessKey}); err != nil {
+		t.Fatal(err)
+	}
+	r := router.SetupRouterWithStorage(s, testCredentials)
+	created, removed := newWebhookServer(t, 0), newWebhookServer(t, 0)
+
+	if rr := sendWithHeaders(r, "GET", "/photos/?notification", "", nil); rr.Code != http.StatusOK || strings.Contains(rr.Body.String(), "WebhookConfiguration") {
+		t.Errorf("expected an empty configuration but got %d: %s", rr.Code, rr.Body.String())
+	}
+	config := fmt.Sprintf(`<NotificationConfiguration>
+		<WebhookConfiguration>
+			<Id>thumbnails</Id>
+			<Url>%s/hooks/created</Url>
+			<Event>s3:ObjectCreated:*</Event>
+			<Filter><S3Key>
+				<FilterRule><Name>prefix</Name><Value>uploads/</Value></FilterRule>
+				<FilterRule><Name>suffix</Name><Value>.jpg</Value></FilterRule>
+			</S3Key></Filter>
+		</WebhookConfiguration>
+		<WebhookConfiguration>
+			<Id>cleanup</Id>
+			<Url>%s/hooks/removed</Url>
+			<Event>s3:ObjectRemoved:*</Event>
+		</WebhookConfiguration>
+	</NotificationConfiguration>`, created.URL, removed.URL)
+	if rr := sendWithHeaders(r, "PUT", "/photos/?notification", config, nil); rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var stored dto.NotificationConfiguration
+	rr := sendWithHeaders(r, "GET", "/photos/?notification", "", nil)
+	if err := xml.Unmarshal(rr.Body.Bytes(), &stored); err != nil || len(stored.Webhooks) != 2 || stored.Webhooks[0].ID != "thumbnails" {
+		t.Errorf("unexpected configuration %d: %s", rr.Code, rr.Body.String())
+	}
+	for name, body := range map[string]string{
+		"unknown event":    `<NotificationConfiguration><WebhookConfiguration><Url>http://localhost/</Url><Event>s3:ObjectRestore:*</Event></WebhookConfiguration></NotificationConfiguration>`,
+		"not an http URL":  `<NotificationConfiguration><WebhookConfiguration><Url>ftp://localhost/</Url><Event>s3:ObjectCreated:*</Event></WebhookConfiguration></NotificationConfiguration>`,
+		"two prefix rules": `<NotificationConfiguration><WebhookConfiguration><Url>http://localhost/</Url><Event>s3:ObjectCreated:*</Event><Filter><S3Key><FilterRule><Name>prefix</Name><Value>a</Value></FilterRule><FilterRule><Name>Prefix</Name><Value>b</Value></FilterRule></S3Key></Filter></WebhookConfiguration></NotificationConfiguration>`,
+	} {
+		rr := sendWithHeaders(r, "PUT", "/photos/?notification", body, nil)
+		if rr.Code != http.StatusBadRequest || errorCode(t, rr) != "InvalidArgument" {
+			t.Errorf("%s: expected InvalidArgument but got %d: %s", name, rr.Code, rr.Body.String())
+		}
+	}
+
+	// Keys outside the filter are not published: the first event is the one of the photo
+	for _, path := range []string{"/photos/notes.txt", "/photos/uploads/notes.txt", "/photos/uploads/my%20cat.jpg"} {
+		if rr := sendWithHeaders(r, "PUT", path, "meow", nil); rr.Code != http.StatusOK {
+			t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+	}
+	record := created.next(t)
+	if record.EventName != "ObjectCreated:Put" || record.EventSource != "aws:s3" || record.AWSRegion != storage.DefaultRegion ||
+		record.S3.ConfigurationID != "thumbnails" || record.S3.Bucket.Name != "photos" || record.S3.Bucket.OwnerIdentity.PrincipalID != testAccessKey ||
+		record.S3.Object.Key != "uploads/my+cat.jpg" || record.S3.Object.Size != 4 || record.S3.Object.ETag != "4a4be40c96ac6314e91d93f38043a634" {
+		t.Errorf("unexpected record %+v", record)
+	}
+
+	rr = sendWithHeaders(r, "PUT", "/photos/uploads/copy.jpg", "", map[string]string{"X-Amz-Copy-Source": "/photos/notes.txt"})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if record := created.next(t); record.EventName != "ObjectCreated:Copy" || record.S3.Object.Key != "uploads/copy.jpg" {
+		t.Errorf("unexpected record %+v", record)
+	}
+
+	if rr := sendWithHeaders(r, "DELETE", "/photos/notes.txt", "", nil); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusNoContent, rr.Code, rr.Body.String())
+	}
+	if record := removed.next(t); record.EventName != "ObjectRemoved:Delete" || record.S3.ConfigurationID != "cleanup" || record.S3.Object.Key != "notes.txt" {
+		t.Errorf("unexpected record %+v", record)
+	}
+
+	// Removing the configuration stops the events
+	if rr := sendWithHeaders(r, "PUT", "/photos/?notification", "<NotificationConfiguration/>", nil); rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	sendWithHeaders(r, "DELETE", "/photos/uploads/notes.txt", "", nil)
+	select {
+	case record := <-removed.events:
+		t.Errorf("expected no event after removing the configuration, got %+v", record)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestNotificationDeliveryQueue(t *testing.T) {
+	s := &storage.FileStorage{Root: t.TempDir()}
+	flaky, down := newWebhookServer(t, 2), newWebhookServer(t, 1000)
+	for bucket, url := range map[string]string{"photos": flaky.URL, "archive": down.URL} {
+		if err := s.CreateBucket(bucket, storage.CreateBucketOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		config := dto.NotificationConfiguration{Webhooks: []dto.WebhookConfiguration{{URL: url, Events: []string{"s3:ObjectCreated:Put"}}}}
+		if err := s.PutBucketNotification(bucket, config); err != nil {
+			t.Fatal(err)
+		}
+	}
+	queue := filepath.Join(t.TempDir(), "notifications")
+
+	// Events are queued on disk before being delivered...
+	stopped, err := notification.OpenNotifier(queue, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := notification.Wrap(s, stopped).AddObject("photos", "cat.jpg", strings.NewReader("meow"), storage.PutObjectOptions{DecodedContentLength: -1}); err != nil {
+		t.Fatal(err)
+	}
+	if n := queuedEvents(t, filepath.Join(queue, "*.json")); n != 1 {
+		t.Fatalf("expected 1 queued event but found %d", n)
+	}
+
+	// ...so that they survive a restart, and are retried until the webhook accepts them
+	notifier, err := notification.OpenNotifier(queue, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	notifier.RetryDelay = 10 * time.Millisecond
+	notifier.MaxAttempts = 3
+	go notifier.Run()
+	if record := flaky.next(t); record.EventName != "ObjectCreated:Put" || record.S3.Object.Key != "cat.jpg" {
+		t.Errorf("unexpected record %+v", record)
+	}
+	waitFor(t, "the delivered event to leave the queue", func() bool { return queuedEvents(t, filepath.Join(queue, "*.json")) == 0 })
+
+	// An event still refused after MaxAttempts is set aside
+	if _, err := notification.Wrap(s, notifier).AddObject("archive", "old.jpg", strings.NewReader("dusty"), storage.PutObjectOptions{DecodedContentLength: -1}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the event to be given up", func() bool { return queuedEvents(t, filepath.Join(queue, "failed", "*.json")) == 1 })
+	if n := queuedEvents(t, filepath.Join(queue, "*.json")); n != 0 {
+		t.Errorf("expected the failed event to leave the queue, found %d", n)
+	}
+}
+
+func TestLifecycleExpirationsArePublished(t *testing.T) {
+	s := &storage.FileStorage{Root: t.TempDir()}
+	if err := s.CreateBucket("photos", storage.CreateBucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddObject("photos", "tmp/cat.jpg", strings.NewReader("meow"), storage.PutObjectOptions{DecodedContentLength: -1}); err != nil {
+		t.Fatal(err)
+	}
+	removed := newWebhookServer(t, 0)
+	config := dto.NotificationConfiguration{Webhooks: []dto.WebhookConfiguration{{URL: removed.URL, Events: []string{"s3:ObjectRemoved:*"}}}}
+	if err := s.PutBucketNotification("photos", config); err != nil {
+		t.Fatal(err)
+	}
+	err := s.PutBucketLifecycle("photos", dto.LifecycleConfiguration{Rules: []dto.LifecycleRule{{
+		ID:         "tmp",
+		Status:     "Enabled",
+		Filter:     &dto.LifecycleFilter{Prefix: "tmp/"},
+		Expiration: &dto.LifecycleExpiration{Days: 1},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notifier := notification.NewNotifier(s)
+	go notifier.Run()
+	report := s.ApplyLifecycle(notification.Wrap(s, notifier), time.Now().Add(48*time.Hour), false)
+	if report.ExpiredObjects != 1 || report.Errors != 0 {
+		t.Fatalf("expected one expired object, got %+v", report)
+	}
+	if record := removed.next(t); record.EventName != "ObjectRemoved:Delete" || record.S3.Object.Key != "tmp/cat.jpg" {
+		t.Errorf("unexpected record %+v", record)
+	}
+}
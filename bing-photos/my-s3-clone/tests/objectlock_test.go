@@ -0,0 +1,421 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"my-s3-clone/auth"
+	"my-s3-clone/config"
+	"my-s3-clone/dto"
+	"my-s3-clone/router"
+	"my-s3-clone/storage"
+)
+
+func TestBucketObjectLockConfiguration(t *testing.T) {
+	var stored *dto.ObjectLockConfiguration
+	versioning := ""
+	mockStorage := &MockStorage{
+		SetBucketVersioningFunc: func(bucketName, status string) error {
+			versioning = status
+			return nil
+		},
+		GetObjectLockConfigurationFunc: func(bucketName string) (dto.ObjectLockConfiguration, error) {
+			if stored == nil {
+				return dto.ObjectLockConfiguration{}, storage.ErrObjectLockNotEnabled
+			}
+			return *stored, nil
+		},
+		PutObjectLockConfigurationFunc: func(bucketName string, config dto.ObjectLockConfiguration) error {
+			if versioning != storage.VersioningEnabled {
+				return storage.ErrInvalidBucketState
+			}
+			stored = &config
+			return nil
+		},
+	}
+	r := router.SetupRouterWithStorage(mockStorage, testCredentials)
+
+	send := func(method, path, body string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		for name, value := range header {
+			req.Header.Set(name, value)
+		}
+		signTestRequest(req)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := send("GET", "/photos/?object-lock", "", nil)
+	if rr.Code != http.StatusNotFound || errorCode(t, rr) != "ObjectLockConfigurationNotFoundError" {
+		t.Fatalf("expected ObjectLockConfigurationNotFoundError but got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// Object Lock needs versioning, which an object-lock-enabled bucket gets at creation
+	lockConfig := `<ObjectLockConfiguration><ObjectLockEnabled>Enabled</ObjectLockEnabled>` +
+		`<Rule><DefaultRetention><Mode>COMPLIANCE</Mode><Years>10</Years></DefaultRetention></Rule></ObjectLockConfiguration>`
+	rr = send("PUT", "/photos/?object-lock", lockConfig, nil)
+	if rr.Code != http.StatusConflict || errorCode(t, rr) != "InvalidBucketState" {
+		t.Errorf("expected InvalidBucketState on an unversioned bucket but got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = send("PUT", "/photos/", "", map[string]string{"x-amz-bucket-object-lock-enabled": "true"})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected bucket creation to succeed but got %d: %s", rr.Code, rr.Body.String())
+	}
+	if versioning != storage.VersioningEnabled || stored == nil || stored.ObjectLockEnabled != "Enabled" {
+		t.Fatalf("expected versioning and object lock to be enabled, got versioning %q and config %+v", versioning, stored)
+	}
+
+	rr = send("PUT", "/photos/?object-lock", lockConfig, nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	rr = send("GET", "/photos/?object-lock", "", nil)
+	var config dto.ObjectLockConfiguration
+	if err := xml.Unmarshal(rr.Body.Bytes(), &config); err != nil {
+		t.Fatalf("Error unmarshaling response body: %v", err)
+	}
+	if config.ObjectLockEnabled != "Enabled" || config.Rule == nil || config.Rule.DefaultRetention == nil ||
+		config.Rule.DefaultRetention.Mode != dto.RetentionCompliance || config.Rule.DefaultRetention.Years != 10 {
+		t.Errorf("unexpected object lock configuration %s", rr.Body.String())
+	}
+
+	rr = send("PUT", "/photos/?object-lock", `<ObjectLockConfiguration>`, nil)
+	if rr.Code != http.StatusBadRequest || errorCode(t, rr) != "MalformedXML" {
+		t.Errorf("expected MalformedXML but got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestObjectRetentionAndLegalHold(t *testing.T) {
+	retainUntil := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	lock := dto.ObjectLock{Mode: dto.RetentionGovernance, RetainUntilDate: retainUntil}
+	var gotBypass bool
+	mockStorage := &MockStorage{
+		GetObjectLockConfigurationFunc: func(bucketName string) (dto.ObjectLockConfiguration, error) {
+			if bucketName != "photos" {
+				return dto.ObjectLockConfiguration{}, storage.ErrObjectLockNotEnabled
+			}
+			return dto.ObjectLockConfiguration{ObjectLockEnabled: "Enabled"}, nil
+		},
+		StatObjectFunc: func(bucketName, objectName, versionID string) (dto.ObjectInfo, error) {
+			return dto.ObjectInfo{Key: objectName, VersionID: "v1", ETag: `"etag"`, Lock: lock}, nil
+		},
+		PutObjectRetentionFunc: func(bucketName, objectName, versionID, mode string, until time.Time, bypassGovernance bool) (dto.ObjectInfo, error) {
+			gotBypass = bypassGovernance
+			if lock.Mode != "" && until.Before(lock.RetainUntilDate) && !bypassGovernance {
+				return dto.ObjectInfo{}, storage.ErrObjectLocked
+			}
+			lock.Mode, lock.RetainUntilDate = mode, until
+			return dto.ObjectInfo{Key: objectName, VersionID: "v1"}, nil
+		},
+		PutObjectLegalHoldFunc: func(bucketName, objectName, versionID string, on bool) (dto.ObjectInfo, error) {
+			lock.LegalHold = on
+			return dto.ObjectInfo{Key: objectName, VersionID: "v1"}, nil
+		},
+	}
+	r := router.SetupRouterWithStorage(mockStorage, testCredentials)
+
+	send := func(method, path, body string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		for name, value := range header {
+			req.Header.Set(name, value)
+		}
+		signTestRequest(req)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := send("GET", "/photos/2024/cat.jpg?retention", "", nil)
+	var retention dto.ObjectRetention
+	if err := xml.Unmarshal(rr.Body.Bytes(), &retention); err != nil {
+		t.Fatalf("Error unmarshaling response body: %v", err)
+	}
+	if retention.Mode != dto.RetentionGovernance || retention.RetainUntilDate == nil || !retention.RetainUntilDate.Equal(retainUntil) {
+		t.Errorf("unexpected retention %s", rr.Body.String())
+	}
+
+	// Shortening a GOVERNANCE retention needs the bypass header
+	shorter := `<Retention><Mode>GOVERNANCE</Mode><RetainUntilDate>2029-01-01T00:00:00Z</RetainUntilDate></Retention>`
+	rr = send("PUT", "/photos/2024/cat.jpg?retention", shorter, nil)
+	if rr.Code != http.StatusForbidden || errorCode(t, rr) != "AccessDenied" {
+		t.Errorf("expected AccessDenied without bypass but got %d: %s", rr.Code, rr.Body.String())
+	}
+	rr = send("PUT", "/photos/2024/cat.jpg?retention", shorter, map[string]string{"x-amz-bypass-governance-retention": "true"})
+	if rr.Code != http.StatusOK || !gotBypass || rr.Header().Get("x-amz-version-id") != "v1" {
+		t.Errorf("expected the retention to be shortened with bypass but got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = send("PUT", "/photos/2024/cat.jpg?legal-hold", `<LegalHold><Status>ON</Status></LegalHold>`, nil)
+	if rr.Code != http.StatusOK || !lock.LegalHold {
+		t.Fatalf("expected the legal hold to be placed but got %d: %s", rr.Code, rr.Body.String())
+	}
+	rr = send("GET", "/photos/2024/cat.jpg?legal-hold", "", nil)
+	var legalHold dto.ObjectLegalHold
+	if err := xml.Unmarshal(rr.Body.Bytes(), &legalHold); err != nil || legalHold.Status != "ON" {
+		t.Errorf("expected legal hold ON but got %s (%v)", rr.Body.String(), err)
+	}
+	rr = send("PUT", "/photos/2024/cat.jpg?legal-hold", `<LegalHold><Status>MAYBE</Status></LegalHold>`, nil)
+	if rr.Code != http.StatusBadRequest || errorCode(t, rr) != "MalformedXML" {
+		t.Errorf("expected MalformedXML but got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// HEAD reports the lock of the version
+	rr = send("HEAD", "/photos/2024/cat.jpg", "", nil)
+	if rr.Header().Get("x-amz-object-lock-mode") != dto.RetentionGovernance || rr.Header().Get("x-amz-object-lock-legal-hold") != "ON" ||
+		rr.Header().Get("x-amz-object-lock-retain-until-date") != "2029-01-01T00:00:00Z" {
+		t.Errorf("unexpected object lock headers %v", rr.Header())
+	}
+
+	rr = send("GET", "/albums/cat.jpg?retention", "", nil)
+	if rr.Code != http.StatusBadRequest || errorCode(t, rr) != "InvalidRequest" {
+		t.Errorf("expected InvalidRequest on a bucket without object lock but got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestObjectLockOnUploadAndDelete(t *testing.T) {
+	var gotLock dto.ObjectLock
+	var gotDelete storage.DeleteObjectOptions
+	mockStorage := &MockStorage{
+		AddObjectFunc: func(bucketName, objectName string, data io.Reader, opts storage.PutObjectOptions) (dto.ObjectInfo, error) {
+			gotLock = opts.Lock
+			return dto.ObjectInfo{Key: objectName, ETag: `"etag"`}, nil
+		},
+		DeleteObjectFunc: func(bucketName, objectName string, opts storage.DeleteObjectOptions) (dto.ObjectInfo, error) {
+			gotDelete = opts
+			if opts.VersionID != "" && !opts.BypassGovernance {
+				return dto.ObjectInfo{}, storage.ErrObjectLocked
+			}
+			return dto.ObjectInfo{Key: objectName, VersionID: opts.VersionID}, nil
+		},
+	}
+	r := router.SetupRouterWithStorage(mockStorage, testCredentials)
+
+	tests := []struct {
+		name         string
+		header       map[string]string
+		expectedCode int
+		expectedLock dto.ObjectLock
+	}{
+		{"no lock", nil, http.StatusOK, dto.ObjectLock{}},
+		{"retention and legal hold", map[string]string{
+			"x-amz-object-lock-mode":              "COMPLIANCE",
+			"x-amz-object-lock-retain-until-date": "2030-01-01T00:00:00Z",
+			"x-amz-object-lock-legal-hold":        "ON",
+		}, http.StatusOK, dto.ObjectLock{Mode: "COMPLIANCE", RetainUntilDate: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), LegalHold: true}},
+		{"mode without date", map[string]string{"x-amz-object-lock-mode": "COMPLIANCE"}, http.StatusBadRequest, dto.ObjectLock{}},
+		{"invalid date", map[string]string{
+			"x-amz-object-lock-mode":              "GOVERNANCE",
+			"x-amz-object-lock-retain-until-date": "tomorrow",
+		}, http.StatusBadRequest, dto.ObjectLock{}},
+	}
+
+	for _, tt := range tests {
+		gotLock = dto.ObjectLock{}
+		req := httptest.NewRequest("PUT", "/photos/cat.jpg", bytes.NewReader([]byte("photo")))
+		for name, value := range tt.header {
+			req.Header.Set(name, value)
+		}
+		signTestRequest(req)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedCode {
+			t.Errorf("%s: expected status %d but got %d: %s", tt.name, tt.expectedCode, rr.Code, rr.Body.String())
+			continue
+		}
+		if gotLock.Mode != tt.expectedLock.Mode || !gotLock.RetainUntilDate.Equal(tt.expectedLock.RetainUntilDate) || gotLock.LegalHold != tt.expectedLock.LegalHold {
+			t.Errorf("%s: expected lock %+v but got %+v", tt.name, tt.expectedLock, gotLock)
+		}
+	}
+
+	deleteVersion := func(header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("DELETE", "/photos/cat.jpg?versionId=v1", nil)
+		for name, value := range header {
+			req.Header.Set(name, value)
+		}
+		signTestRequest(req)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := deleteVersion(nil)
+	if rr.Code != http.StatusForbidden || errorCode(t, rr) != "AccessDenied" {
+		t.Errorf("expected a locked version to be kept but got %d: %s", rr.Code, rr.Body.String())
+	}
+	rr = deleteVersion(map[string]string{"x-amz-bypass-governance-retention": "true"})
+	if rr.Code != http.StatusNoContent || !gotDelete.BypassGovernance || gotDelete.VersionID != "v1" {
+		t.Errorf("expected the bypass to be passed to storage but got %d with %+v", rr.Code, gotDelete)
+	}
+}
+
+func TestRetentionSurvivesConcurrentReads(t *testing.T) {
+	s := &storage.FileStorage{Root: t.TempDir()}
+	if err := s.CreateBucket("vault", storage.CreateBucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetBucketVersioning("vault", storage.VersioningEnabled); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PutObjectLockConfiguration("vault", dto.ObjectLockConfiguration{ObjectLockEnabled: "Enabled"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// HEAD requests racing with the uploads must not strip the retention written with each version
+	lock := dto.ObjectLock{Mode: dto.RetentionCompliance, RetainUntilDate: time.Now().Add(time.Hour).UTC().Truncate(time.Second), LegalHold: true}
+	stop := statLoop(t, s, "vault", "ledger.csv")
+	content := strings.Repeat("entry;", 32*1024)
+	var versions []string
+	for i := 0; i < 100; i++ {
+		info, err := s.AddObject("vault", "ledger.csv", strings.NewReader(content), storage.PutObjectOptions{DecodedContentLength: -1, Lock: lock})
+		if err != nil {
+			stop()
+			t.Fatal(err)
+		}
+		versions = append(versions, info.VersionID)
+	}
+	stop()
+
+	for _, versionID := range versions {
+		info, err := s.StatObject("vault", "ledger.csv", versionID)
+		if err != nil || info.Lock.Mode != lock.Mode || !info.Lock.RetainUntilDate.Equal(lock.RetainUntilDate) || !info.Lock.LegalHold {
+			t.Fatalf("expected version %s to keep its retention, got %+v, %v", versionID, info, err)
+		}
+	}
+	last := versions[len(versions)-1]
+	if _, err := s.DeleteObject("vault", "ledger.csv", storage.DeleteObjectOptions{VersionID: last, BypassGovernance: true}); !errors.Is(err, storage.ErrObjectLocked) {
+		t.Errorf("expected the current version to stay locked, got %v", err)
+	}
+}
+
+func TestBypassGovernanceRequiresPermission(t *testing.T) {
+	cfg := config.Default()
+	cfg.Credentials = testCredentials
+	cfg.KeysFile = filepath.Join(t.TempDir(), "keys.json")
+	s := storage.NewMemoryStorage()
+	r := router.SetupRouterWithConfig(s, cfg)
+
+	if rr := sendWithHeaders(r, "PUT", "/vault/", "", map[string]string{"x-amz-bucket-object-lock-enabled": "true"}); rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	lock := map[string]string{
+		"x-amz-object-lock-mode":              "GOVERNANCE",
+		"x-amz-object-lock-retain-until-date": time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+	}
+	rr := sendWithHeaders(r, "PUT", "/vault/ledger.csv", "entry", lock)
+	versionID := rr.Header().Get("x-amz-version-id")
+	if rr.Code != http.StatusOK || versionID == "" {
+		t.Fatalf("expected a locked version but got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// A key allowed to delete and to change the retention may still not lift it
+	body := `{"policy": {"statements": [{"actions": ["s3:DeleteObject", "s3:PutObjectRetention"], "resources": ["vault/*"]}]}}`
+	var key dto.AccessKey
+	if rr := sendWithHeaders(r, "POST", "/_admin/keys", body, nil); json.Unmarshal(rr.Body.Bytes(), &key) != nil || key.AccessKey == "" {
+		t.Fatalf("expected a new access key but got %d: %s", rr.Code, rr.Body.String())
+	}
+	bypass := func(method, path, body string, sign func(*http.Request)) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("x-amz-bypass-governance-retention", "true")
+		req.RemoteAddr = "192.0.2.1:1234"
+		sign(req)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+	asKey := func(req *http.Request) { auth.SignRequest(req, key.AccessKey, key.SecretKey, testRegion, time.Now()) }
+	anonymous := func(req *http.Request) {}
+
+	shorten := `<Retention><Mode>GOVERNANCE</Mode><RetainUntilDate>` + time.Now().Add(time.Minute).UTC().Format(time.RFC3339) + `</RetainUntilDate></Retention>`
+	if rr := bypass("DELETE", "/vault/ledger.csv?versionId="+versionID, "", asKey); rr.Code != http.StatusForbidden || errorCode(t, rr) != "AccessDenied" {
+		t.Errorf("delete: expected AccessDenied but got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := bypass("PUT", "/vault/ledger.csv?retention&versionId="+versionID, shorten, asKey); rr.Code != http.StatusForbidden || errorCode(t, rr) != "AccessDenied" {
+		t.Errorf("retention: expected AccessDenied but got %d: %s", rr.Code, rr.Body.String())
+	}
+	var result dto.DeleteResult
+	rr = bypass("POST", "/vault/?delete", `<Delete><Object><Key>ledger.csv</Key><VersionId>`+versionID+`</VersionId></Object></Delete>`, asKey)
+	if err := xml.Unmarshal(rr.Body.Bytes(), &result); err != nil || len(result.Errors) != 1 || result.Errors[0].Code != "AccessDenied" {
+		t.Errorf("batch delete: expected AccessDenied but got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// So may an anonymous caller whose bucket policy allows deletes
+	policy := `{"Statement": [{"Effect": "Allow", "Principal": "*", "Action": "s3:DeleteObject", "Resource": "arn:aws:s3:::vault/*"}]}`
+	if rr := sendWithHeaders(r, "PUT", "/vault/?policy", policy, nil); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusNoContent, rr.Code, rr.Body.String())
+	}
+	if rr := bypass("DELETE", "/vault/ledger.csv?versionId="+versionID, "", anonymous); rr.Code != http.StatusForbidden || errorCode(t, rr) != "AccessDenied" {
+		t.Errorf("anonymous delete: expected AccessDenied but got %d: %s", rr.Code, rr.Body.String())
+	}
+	if _, err := s.StatObject("vault", "ledger.csv", versionID); err != nil {
+		t.Fatalf("expected the locked version to be kept, got %v", err)
+	}
+
+	// Granting s3:BypassGovernanceRetention lifts the retention
+	body = `{"statements": [{"actions": ["s3:DeleteObject", "s3:BypassGovernanceRetention"], "resources": ["vault/*"]}]}`
+	if rr := sendWithHeaders(r, "PUT", "/_admin/keys/"+key.AccessKey+"/policy", body, nil); rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if rr := bypass("DELETE", "/vault/ledger.csv?versionId="+versionID, "", asKey); rr.Code != http.StatusNoContent {
+		t.Errorf("expected the granted bypass to delete the version, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestMultipartUploadWithObjectLock(t *testing.T) {
+	s := &storage.FileStorage{Root: t.TempDir()}
+	r := router.SetupRouterWithStorage(s, testCredentials)
+
+	if rr := sendWithHeaders(r, "PUT", "/vault/", "", map[string]string{"x-amz-bucket-object-lock-enabled": "true"}); rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	retainUntil := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	lock := map[string]string{
+		"x-amz-object-lock-mode":              "COMPLIANCE",
+		"x-amz-object-lock-retain-until-date": retainUntil.Format(time.RFC3339),
+		"x-amz-object-lock-legal-hold":        "ON",
+	}
+
+	// The lock headers are checked when the upload starts, not once every part is sent
+	if rr := sendWithHeaders(r, "PUT", "/photos/", "", nil); rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if rr := sendWithHeaders(r, "POST", "/photos/ledger.csv?uploads", "", lock); rr.Code != http.StatusBadRequest || errorCode(t, rr) != "InvalidRequest" {
+		t.Errorf("expected a lock on a bucket without object lock to be refused but got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var initiated dto.InitiateMultipartUploadResult
+	rr := sendWithHeaders(r, "POST", "/vault/ledger.csv?uploads", "", lock)
+	if rr.Code != http.StatusOK || xml.Unmarshal(rr.Body.Bytes(), &initiated) != nil {
+		t.Fatalf("expected an upload id but got %d: %s", rr.Code, rr.Body.String())
+	}
+	rr = sendWithHeaders(r, "PUT", "/vault/ledger.csv?partNumber=1&uploadId="+initiated.UploadId, "entry", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	complete := `<CompleteMultipartUpload><Part><PartNumber>1</PartNumber><ETag>` + rr.Header().Get("ETag") + `</ETag></Part></CompleteMultipartUpload>`
+	rr = sendWithHeaders(r, "POST", "/vault/ledger.csv?uploadId="+initiated.UploadId, complete, nil)
+	versionID := rr.Header().Get("x-amz-version-id")
+	if rr.Code != http.StatusOK || versionID == "" {
+		t.Fatalf("expected a new version but got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	info, err := s.StatObject("vault", "ledger.csv", versionID)
+	if err != nil || info.Lock.Mode != dto.RetentionCompliance || !info.Lock.RetainUntilDate.Equal(retainUntil) || !info.Lock.LegalHold {
+		t.Fatalf("expected the completed object to keep the requested lock, got %+v, %v", info.Lock, err)
+	}
+	if rr := sendWithHeaders(r, "DELETE", "/vault/ledger.csv?versionId="+versionID, "", nil); rr.Code != http.StatusForbidden || errorCode(t, rr) != "AccessDenied" {
+		t.Errorf("expected the locked version to be kept but got %d: %s", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,207 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/router"
+	"my-s3-clone/storage"
+)
+
+func TestBucketQuotaAdminAPI(t *testing.T) {
+	s := storage.NewMemoryStorage()
+	for _, bucket := range []string{"photos", "thumbnails"} {
+		if err := s.CreateBucket(bucket, storage.CreateBucketOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := router.SetupRouterWithStorage(s, testCredentials)
+
+	if rr := sendWithHeaders(r, "PUT", "/_admin/quota/photos", `{"maxBytes": 8, "maxObjects": 10}`, nil); rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var quota dto.BucketQuota
+	rr := sendWithHeaders(r, "GET", "/_admin/quota/photos", "", nil)
+	if err := json.Unmarshal(rr.Body.Bytes(), &quota); err != nil || quota != (dto.BucketQuota{MaxBytes: 8, MaxObjects: 10}) {
+		t.Errorf("unexpected quota %d: %s", rr.Code, rr.Body.String())
+	}
+	for name, body := range map[string]string{"negative": `{"maxBytes": -1}`, "unknown field": `{"maxSize": 8}`, "not json": `8`} {
+		rr := sendWithHeaders(r, "PUT", "/_admin/quota/photos", body, nil)
+		if rr.Code != http.StatusBadRequest || errorCode(t, rr) != "InvalidArgument" {
+			t.Errorf("%s: expected InvalidArgument but got %d: %s", name, rr.Code, rr.Body.String())
+		}
+	}
+	if rr := sendWithHeaders(r, "PUT", "/_admin/quota/missing", `{"maxBytes": 8}`, nil); rr.Code != http.StatusNotFound {
+		t.Errorf("expected status %d for a missing bucket but got %d", http.StatusNotFound, rr.Code)
+	}
+
+	// Writes are accepted until the bucket is full
+	if rr := sendWithHeaders(r, "PUT", "/photos/cat.jpg", "meow", nil); rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	rr = sendWithHeaders(r, "PUT", "/photos/dog.jpg", "woof woof", nil)
+	if rr.Code != http.StatusForbidden || errorCode(t, rr) != "QuotaExceeded" {
+		t.Errorf("expected QuotaExceeded but got %d: %s", rr.Code, rr.Body.String())
+	}
+	rr = sendWithHeaders(r, "PUT", "/photos/copy.jpg", "", map[string]string{"X-Amz-Copy-Source": "/photos/cat.jpg"})
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected the copy to fit but got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := sendWithHeaders(r, "PUT", "/thumbnails/dog.jpg", "woof woof", nil); rr.Code != http.StatusOK {
+		t.Errorf("expected other buckets to be unaffected but got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var usage dto.BucketUsage
+	rr = sendWithHeaders(r, "GET", "/_admin/usage/photos", "", nil)
+	if err := json.Unmarshal(rr.Body.Bytes(), &usage); err != nil || usage.Bytes != 8 || usage.Objects != 2 || usage.Quota == nil {
+		t.Errorf("unexpected usage %d: %s", rr.Code, rr.Body.String())
+	}
+	var report dto.UsageReport
+	rr = sendWithHeaders(r, "GET", "/_admin/usage", "", nil)
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil || len(report.Buckets) != 2 {
+		t.Fatalf("unexpected usage report %d: %s", rr.Code, rr.Body.String())
+	}
+	for _, usage := range report.Buckets {
+		if usage.Bucket == "thumbnails" && (usage.Bytes != 9 || usage.Objects != 1 || usage.Quota != nil) {
+			t.Errorf("unexpected usage of thumbnails %+v", usage)
+		}
+	}
+	if rr := sendWithHeaders(r, "GET", "/_admin/usage/missing", "", nil); rr.Code != http.StatusNotFound {
+		t.Errorf("expected status %d for a missing bucket but got %d", http.StatusNotFound, rr.Code)
+	}
+
+	// Deleting frees space, removing the quota lifts the limit
+	if rr := sendWithHeaders(r, "DELETE", "/photos/copy.jpg", "", nil); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusNoContent, rr.Code, rr.Body.String())
+	}
+	if rr := sendWithHeaders(r, "PUT", "/photos/dog.jpg", "woof", nil); rr.Code != http.StatusOK {
+		t.Errorf("expected the freed space to be usable but got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := sendWithHeaders(r, "DELETE", "/_admin/quota/photos", "", nil); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusNoContent, rr.Code, rr.Body.String())
+	}
+	if rr := sendWithHeaders(r, "PUT", "/photos/bird.jpg", "tweet tweet", nil); rr.Code != http.StatusOK {
+		t.Errorf("expected no limit after removing the quota but got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestCompleteMultipartUploadOverQuota(t *testing.T) {
+	s := &storage.FileStorage{Root: t.TempDir()}
+	if err := s.CreateBucket("photos", storage.CreateBucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PutBucketQuota("photos", dto.BucketQuota{MaxBytes: 16}); err != nil {
+		t.Fatal(err)
+	}
+	uploadID, err := s.CreateMultipartUpload("photos", "video.mp4", storage.MultipartUploadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	eTag, err := s.UploadPart("photos", "video.mp4", uploadID, 1, strings.NewReader("a video longer than the quota"), storage.PutObjectOptions{DecodedContentLength: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := router.SetupRouterWithStorage(s, testCredentials)
+
+	body := fmt.Sprintf(`<CompleteMultipartUpload><Part><PartNumber>1</PartNumber><ETag>%s</ETag></Part></CompleteMultipartUpload>`, eTag)
+	rr := sendWithHeaders(r, "POST", "/photos/video.mp4?uploadId="+uploadID, body, nil)
+	if rr.Code != http.StatusForbidden || errorCode(t, rr) != "QuotaExceeded" {
+		t.Fatalf("expected QuotaExceeded but got %d: %s", rr.Code, rr.Body.String())
+	}
+	if _, err := s.StatObject("photos", "video.mp4", ""); !os.IsNotExist(err) {
+		t.Errorf("expected no object, got %v", err)
+	}
+
+	// The upload is kept so that it can be aborted, or completed once space is freed
+	if rr := sendWithHeaders(r, "DELETE", "/photos/video.mp4?uploadId="+uploadID, "", nil); rr.Code != http.StatusNoContent {
+		t.Errorf("expected status %d but got %d: %s", http.StatusNoContent, rr.Code, rr.Body.String())
+	}
+}
+
+func TestRebuildBucketUsage(t *testing.T) {
+	root := t.TempDir()
+	s := &storage.FileStorage{Root: root}
+	if err := s.CreateBucket("photos", storage.CreateBucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for key, content := range map[string]string{"cat.jpg": "meow", "2024/dog.jpg": "woof!"} {
+		if _, err := s.AddObject("photos", key, strings.NewReader(content), storage.PutObjectOptions{DecodedContentLength: -1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A counter left behind by a crash
+	usagePath := filepath.Join(root, ".s3clone", "buckets", "photos", "usage.json")
+	if err := os.WriteFile(usagePath, []byte(`{"bytes": 1000, "objects": 7}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if usage, _ := s.GetBucketUsage("photos"); usage.Bytes != 1000 {
+		t.Fatalf("expected the tampered counter to be read, got %+v", usage)
+	}
+
+	usage, err := s.RebuildBucketUsage("photos")
+	if err != nil || usage.Bytes != 9 || usage.Objects != 2 {
+		t.Errorf("expected 9 bytes in 2 objects, got %+v, %v", usage, err)
+	}
+	if usage, _ := (&storage.FileStorage{Root: root}).GetBucketUsage("photos"); usage.Bytes != 9 || usage.Objects != 2 {
+		t.Errorf("expected the rebuilt counter to be saved, got %+v", usage)
+	}
+
+	// A bucket created before usage tracking has its counter computed on first use
+	if err := os.Remove(usagePath); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.DeleteObject("photos", "cat.jpg", storage.DeleteObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if usage, err := s.GetBucketUsage("photos"); err != nil || usage.Bytes != 5 || usage.Objects != 1 {
+		t.Errorf("expected 5 bytes in 1 object, got %+v, %v", usage, err)
+	}
+}
+
+func TestUsageOfConcurrentUploadsAndDeletes(t *testing.T) {
+	s := &storage.FileStorage{Root: t.TempDir()}
+	if err := s.CreateBucket("photos", storage.CreateBucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A delete must not deduct the size of an object an upload is replacing at the same time
+	const rounds = 200
+	var wg sync.WaitGroup
+	for worker := 0; worker < 4; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				var err error
+				if worker%2 == 0 {
+					_, err = s.AddObject("photos", "cat.jpg", strings.NewReader(strings.Repeat("m", 1+i%7*1000)), storage.PutObjectOptions{DecodedContentLength: -1})
+				} else if _, err = s.DeleteObject("photos", "cat.jpg", storage.DeleteObjectOptions{}); errors.Is(err, os.ErrNotExist) {
+					err = nil
+				}
+				if err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(worker)
+	}
+	wg.Wait()
+
+	counted, err := s.GetBucketUsage("photos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual, err := s.RebuildBucketUsage("photos")
+	if err != nil || counted.Bytes != actual.Bytes || counted.Objects != actual.Objects {
+		t.Errorf("expected the counter %+v to match the bucket content %+v, %v", counted, actual, err)
+	}
+}
@@ -9,53 +9,82 @@ import (
 	"testing"
 	"github.com/gorilla/mux"
 	"my-s3-clone/handlers"
+	"my-s3-clone/middleware"
 	"my-s3-clone/router"
+	"my-s3-clone/config"
 	"my-s3-clone/dto"
+	"my-s3-clone/storage"
 	"io"
 	"time"
 	"fmt"
 )
 
-// Mock implementation of FileInfo 
-type MockFileInfo struct {
-	name    string
-	size    int64
-	modTime time.Time
-}
-
-func (m MockFileInfo) Name() string       { return m.name }
-func (m MockFileInfo) Size() int64        { return m.size }
-func (m MockFileInfo) Mode() os.FileMode  { return 0644 }
-func (m MockFileInfo) ModTime() time.Time { return m.modTime }
-func (m MockFileInfo) IsDir() bool        { return false }
-func (m MockFileInfo) Sys() interface{}   { return nil }
-
 // MockStorage is a mock implementation of the Storage interface
 type MockStorage struct {
-	AddObjectFunc         func(bucketName, objectName string, data io.Reader, contentSha256 string) error
-	DeleteObjectFunc      func(bucketName, objectName string) error
+	AddObjectFunc         func(bucketName, objectName string, data io.Reader, opts storage.PutObjectOptions) (dto.ObjectInfo, error)
+	DeleteObjectFunc      func(bucketName, objectName string, opts storage.DeleteObjectOptions) (dto.ObjectInfo, error)
 	CheckBucketExistsFunc func(bucketName string) (bool, error)
-	CheckObjectExistFunc  func(bucketName, objectName string) (bool, time.Time, int64, error)
+	StatObjectFunc        func(bucketName, objectName, versionID string) (dto.ObjectInfo, error)
 	DeleteBucketFunc      func(bucketName string) error
-	GetObjectFunc         func(bucketName, objectName string) ([]byte, dto.FileInfo, error)
+	GetObjectFunc         func(bucketName, objectName, versionID string, customerKey []byte) (io.ReadSeekCloser, dto.ObjectInfo, error)
 	ListBucketsFunc       func() []string
-	ListObjectsFunc       func(bucketName, prefix, marker string, maxKeys int) (dto.ListObjectsResponse, error)
-	CreateBucketFunc      func(bucketName string) error
+	ListObjectsFunc       func(bucketName string, opts storage.ListObjectsOptions) (dto.ObjectListing, error)
+	CreateBucketFunc      func(bucketName string, opts storage.CreateBucketOptions) error
+	GetBucketInfoFunc     func(bucketName string) (dto.BucketInfo, error)
+	CopyObjectFunc        func(sourceBucket, sourceKey, targetBucket, targetKey string, opts storage.CopyObjectOptions) (dto.ObjectInfo, error)
+	MoveObjectFunc        func(sourceBucket, sourceKey, targetBucket, targetKey string) (dto.ObjectInfo, error)
+
+	CreateMultipartUploadFunc   func(bucketName, objectName string, opts storage.MultipartUploadOptions) (string, error)
+	UploadPartFunc              func(bucketName, objectName, uploadID string, partNumber int, data io.Reader, opts storage.PutObjectOptions) (string, error)
+	ListPartsFunc               func(bucketName, objectName, uploadID string, partNumberMarker, maxParts int) (dto.ListPartsResult, error)
+	CompleteMultipartUploadFunc func(bucketName, objectName, uploadID string, parts []dto.CompletedPart) (dto.ObjectInfo, error)
+	AbortMultipartUploadFunc    func(bucketName, objectName, uploadID string) error
+	ListMultipartUploadsFunc    func(bucketName, prefix, keyMarker, uploadIDMarker string, maxUploads int) (dto.ListMultipartUploadsResult, error)
+
+	GetBucketVersioningFunc func(bucketName string) (string, error)
+	SetBucketVersioningFunc func(bucketName, status string) error
+	ListObjectVersionsFunc  func(bucketName string, opts storage.ListObjectVersionsOptions) (dto.ObjectVersionListing, error)
+
+	GetBucketLifecycleFunc    func(bucketName string) (dto.LifecycleConfiguration, error)
+	PutBucketLifecycleFunc    func(bucketName string, config dto.LifecycleConfiguration) error
+	DeleteBucketLifecycleFunc func(bucketName string) error
+
+	GetBucketCorsFunc    func(bucketName string) (dto.CORSConfiguration, error)
+	PutBucketCorsFunc    func(bucketName string, config dto.CORSConfiguration) error
+	DeleteBucketCorsFunc func(bucketName string) error
+
+	GetBucketPolicyFunc    func(bucketName string) (dto.BucketPolicy, error)
+	PutBucketPolicyFunc    func(bucketName string, policy dto.BucketPolicy) error
+	DeleteBucketPolicyFunc func(bucketName string) error
+
+	GetBucketNotificationFunc func(bucketName string) (dto.NotificationConfiguration, error)
+	PutBucketNotificationFunc func(bucketName string, config dto.NotificationConfiguration) error
+
+	GetBucketUsageFunc    func(bucketName string) (dto.BucketUsage, error)
+	PutBucketQuotaFunc    func(bucketName string, quota dto.BucketQuota) error
+	DeleteBucketQuotaFunc func(bucketName string) error
+
+	PutObjectTaggingFunc func(bucketName, objectName, versionID string, tags map[string]string) (dto.ObjectInfo, error)
+
+	GetObjectLockConfigurationFunc func(bucketName string) (dto.ObjectLockConfiguration, error)
+	PutObjectLockConfigurationFunc func(bucketName string, config dto.ObjectLockConfiguration) error
+	PutObjectRetentionFunc         func(bucketName, objectName, versionID, mode string, retainUntil time.Time, bypassGovernance bool) (dto.ObjectInfo, error)
+	PutObjectLegalHoldFunc         func(bucketName, objectName, versionID string, on bool) (dto.ObjectInfo, error)
 }
 
 // Implementations of the Storage interface using the mock functions
-func (m *MockStorage) AddObject(bucketName, objectName string, data io.Reader, contentSha256 string) error {
+func (m *MockStorage) AddObject(bucketName, objectName string, data io.Reader, opts storage.PutObjectOptions) (dto.ObjectInfo, error) {
 	if m.AddObjectFunc != nil {
-		return m.AddObjectFunc(bucketName, objectName, data, contentSha256)
+		return m.AddObjectFunc(bucketName, objectName, data, opts)
 	}
-	return nil
+	return dto.ObjectInfo{}, nil
 }
 
-func (m *MockStorage) DeleteObject(bucketName, objectName string) error {
+func (m *MockStorage) DeleteObject(bucketName, objectName string, opts storage.DeleteObjectOptions) (dto.ObjectInfo, error) {
 	if m.DeleteObjectFunc != nil {
-		return m.DeleteObjectFunc(bucketName, objectName)
+		return m.DeleteObjectFunc(bucketName, objectName, opts)
 	}
-	return nil
+	return dto.ObjectInfo{}, nil
 }
 
 func (m *MockStorage) CheckBucketExists(bucketName string) (bool, error) {
@@ -65,11 +94,11 @@ func (m *MockStorage) CheckBucketExists(bucketName string) (bool, error) {
 	return false, nil
 }
 
-func (m *MockStorage) CheckObjectExist(bucketName, objectName string) (bool, time.Time, int64, error) {
-	if m.CheckObjectExistFunc != nil {
-		return m.CheckObjectExistFunc(bucketName, objectName)
+func (m *MockStorage) StatObject(bucketName, objectName, versionID string) (dto.ObjectInfo, error) {
+	if m.StatObjectFunc != nil {
+		return m.StatObjectFunc(bucketName, objectName, versionID)
 	}
-	return false, time.Time{}, 0, nil
+	return dto.ObjectInfo{}, os.ErrNotExist
 }
 
 func (m *MockStorage) DeleteBucket(bucketName string) error {
@@ -79,11 +108,11 @@ func (m *MockStorage) DeleteBucket(bucketName string) error {
 	return nil
 }
 
-func (m *MockStorage) GetObject(bucketName, objectName string) ([]byte, dto.FileInfo, error) {
+func (m *MockStorage) GetObject(bucketName, objectName, versionID string, customerKey []byte) (io.ReadSeekCloser, dto.ObjectInfo, error) {
 	if m.GetObjectFunc != nil {
-		return m.GetObjectFunc(bucketName, objectName)
+		return m.GetObjectFunc(bucketName, objectName, versionID, customerKey)
 	}
-	return nil, nil, os.ErrNotExist
+	return nil, dto.ObjectInfo{}, os.ErrNotExist
 }
 
 func (m *MockStorage) ListBuckets() []string {
@@ -93,24 +122,243 @@ func (m *MockStorage) ListBuckets() []string {
 	return []string{}
 }
 
-func (m *MockStorage) ListObjects(bucketName, prefix, marker string, maxKeys int) (dto.ListObjectsResponse, error) {
+func (m *MockStorage) ListObjects(bucketName string, opts storage.ListObjectsOptions) (dto.ObjectListing, error) {
 	if m.ListObjectsFunc != nil {
-		return m.ListObjectsFunc(bucketName, prefix, marker, maxKeys)
+		return m.ListObjectsFunc(bucketName, opts)
 	}
-	return dto.ListObjectsResponse{}, nil
+	return dto.ObjectListing{}, nil
 }
 
 // Mock implementation of CreateBucket
-func (m *MockStorage) CreateBucket(bucketName string) error {
+func (m *MockStorage) CreateBucket(bucketName string, opts storage.CreateBucketOptions) error {
     if m.CreateBucketFunc != nil {
-        return m.CreateBucketFunc(bucketName)
+        return m.CreateBucketFunc(bucketName, opts)
     }
     return nil
 }
 
+func (m *MockStorage) GetBucketInfo(bucketName string) (dto.BucketInfo, error) {
+	if m.GetBucketInfoFunc != nil {
+		return m.GetBucketInfoFunc(bucketName)
+	}
+	return dto.BucketInfo{Name: bucketName, Region: storage.DefaultRegion}, nil
+}
+
+func (m *MockStorage) CopyObject(sourceBucket, sourceKey, targetBucket, targetKey string, opts storage.CopyObjectOptions) (dto.ObjectInfo, error) {
+	if m.CopyObjectFunc != nil {
+		return m.CopyObjectFunc(sourceBucket, sourceKey, targetBucket, targetKey, opts)
+	}
+	return dto.ObjectInfo{}, nil
+}
+
+func (m *MockStorage) MoveObject(sourceBucket, sourceKey, targetBucket, targetKey string) (dto.ObjectInfo, error) {
+	if m.MoveObjectFunc != nil {
+		return m.MoveObjectFunc(sourceBucket, sourceKey, targetBucket, targetKey)
+	}
+	return dto.ObjectInfo{}, nil
+}
+
+func (m *MockStorage) CreateMultipartUpload(bucketName, objectName string, opts storage.MultipartUploadOptions) (string, error) {
+	if m.CreateMultipartUploadFunc != nil {
+		return m.CreateMultipartUploadFunc(bucketName, objectName, opts)
+	}
+	return "", nil
+}
+
+func (m *MockStorage) UploadPart(bucketName, objectName, uploadID string, partNumber int, data io.Reader, opts storage.PutObjectOptions) (string, error) {
+	if m.UploadPartFunc != nil {
+		return m.UploadPartFunc(bucketName, objectName, uploadID, partNumber, data, opts)
+	}
+	return "", nil
+}
+
+func (m *MockStorage) ListParts(bucketName, objectName, uploadID string, partNumberMarker, maxParts int) (dto.ListPartsResult, error) {
+	if m.ListPartsFunc != nil {
+		return m.ListPartsFunc(bucketName, objectName, uploadID, partNumberMarker, maxParts)
+	}
+	return dto.ListPartsResult{}, nil
+}
+
+func (m *MockStorage) CompleteMultipartUpload(bucketName, objectName, uploadID string, parts []dto.CompletedPart) (dto.ObjectInfo, error) {
+	if m.CompleteMultipartUploadFunc != nil {
+		return m.CompleteMultipartUploadFunc(bucketName, objectName, uploadID, parts)
+	}
+	return dto.ObjectInfo{}, nil
+}
+
+func (m *MockStorage) AbortMultipartUpload(bucketName, objectName, uploadID string) error {
+	if m.AbortMultipartUploadFunc != nil {
+		return m.AbortMultipartUploadFunc(bucketName, objectName, uploadID)
+	}
+	return nil
+}
+
+func (m *MockStorage) ListMultipartUploads(bucketName, prefix, keyMarker, uploadIDMarker string, maxUploads int) (dto.ListMultipartUploadsResult, error) {
+	if m.ListMultipartUploadsFunc != nil {
+		return m.ListMultipartUploadsFunc(bucketName, prefix, keyMarker, uploadIDMarker, maxUploads)
+	}
+	return dto.ListMultipartUploadsResult{}, nil
+}
+
+func (m *MockStorage) GetBucketVersioning(bucketName string) (string, error) {
+	if m.GetBucketVersioningFunc != nil {
+		return m.GetBucketVersioningFunc(bucketName)
+	}
+	return "", nil
+}
+
+func (m *MockStorage) SetBucketVersioning(bucketName, status string) error {
+	if m.SetBucketVersioningFunc != nil {
+		return m.SetBucketVersioningFunc(bucketName, status)
+	}
+	return nil
+}
+
+func (m *MockStorage) ListObjectVersions(bucketName string, opts storage.ListObjectVersionsOptions) (dto.ObjectVersionListing, error) {
+	if m.ListObjectVersionsFunc != nil {
+		return m.ListObjectVersionsFunc(bucketName, opts)
+	}
+	return dto.ObjectVersionListing{}, nil
+}
+
+func (m *MockStorage) GetBucketLifecycle(bucketName string) (dto.LifecycleConfiguration, error) {
+	if m.GetBucketLifecycleFunc != nil {
+		return m.GetBucketLifecycleFunc(bucketName)
+	}
+	return dto.LifecycleConfiguration{}, storage.ErrNoSuchLifecycle
+}
+
+func (m *MockStorage) PutBucketLifecycle(bucketName string, config dto.LifecycleConfiguration) error {
+	if m.PutBucketLifecycleFunc != nil {
+		return m.PutBucketLifecycleFunc(bucketName, config)
+	}
+	return nil
+}
+
+func (m *MockStorage) DeleteBucketLifecycle(bucketName string) error {
+	if m.DeleteBucketLifecycleFunc != nil {
+		return m.DeleteBucketLifecycleFunc(bucketName)
+	}
+	return nil
+}
+
+func (m *MockStorage) GetBucketCors(bucketName string) (dto.CORSConfiguration, error) {
+	if m.GetBucketCorsFunc != nil {
+		return m.GetBucketCorsFunc(bucketName)
+	}
+	return dto.CORSConfiguration{}, storage.ErrNoSuchCORS
+}
+
+func (m *MockStorage) PutBucketCors(bucketName string, config dto.CORSConfiguration) error {
+	if m.PutBucketCorsFunc != nil {
+		return m.PutBucketCorsFunc(bucketName, config)
+	}
+	return nil
+}
+
+func (m *MockStorage) DeleteBucketCors(bucketName string) error {
+	if m.DeleteBucketCorsFunc != nil {
+		return m.DeleteBucketCorsFunc(bucketName)
+	}
+	return nil
+}
+
+func (m *MockStorage) GetBucketPolicy(bucketName string) (dto.BucketPolicy, error) {
+	if m.GetBucketPolicyFunc != nil {
+		return m.GetBucketPolicyFunc(bucketName)
+	}
+	return dto.BucketPolicy{}, storage.ErrNoSuchBucketPolicy
+}
+
+func (m *MockStorage) PutBucketPolicy(bucketName string, policy dto.BucketPolicy) error {
+	if m.PutBucketPolicyFunc != nil {
+		return m.PutBucketPolicyFunc(bucketName, policy)
+	}
+	return nil
+}
+
+func (m *MockStorage) DeleteBucketPolicy(bucketName string) error {
+	if m.DeleteBucketPolicyFunc != nil {
+		return m.DeleteBucketPolicyFunc(bucketName)
+	}
+	return nil
+}
+
+func (m *MockStorage) GetBucketNotification(bucketName string) (dto.NotificationConfiguration, error) {
+	if m.GetBucketNotificationFunc != nil {
+		return m.GetBucketNotificationFunc(bucketName)
+	}
+	return dto.NotificationConfiguration{}, nil
+}
+
+func (m *MockStorage) PutBucketNotification(bucketName string, config dto.NotificationConfiguration) error {
+	if m.PutBucketNotificationFunc != nil {
+		return m.PutBucketNotificationFunc(bucketName, config)
+	}
+	return nil
+}
+
+func (m *MockStorage) GetBucketUsage(bucketName string) (dto.BucketUsage, error) {
+	if m.GetBucketUsageFunc != nil {
+		return m.GetBucketUsageFunc(bucketName)
+	}
+	return dto.BucketUsage{Bucket: bucketName}, nil
+}
+
+func (m *MockStorage) PutBucketQuota(bucketName string, quota dto.BucketQuota) error {
+	if m.PutBucketQuotaFunc != nil {
+		return m.PutBucketQuotaFunc(bucketName, quota)
+	}
+	return nil
+}
+
+func (m *MockStorage) DeleteBucketQuota(bucketName string) error {
+	if m.DeleteBucketQuotaFunc != nil {
+		return m.DeleteBucketQuotaFunc(bucketName)
+	}
+	return nil
+}
+
+func (m *MockStorage) PutObjectTagging(bucketName, objectName, versionID string, tags map[string]string) (dto.ObjectInfo, error) {
+	if m.PutObjectTaggingFunc != nil {
+		return m.PutObjectTaggingFunc(bucketName, objectName, versionID, tags)
+	}
+	return dto.ObjectInfo{}, nil
+}
+
+func (m *MockStorage) GetObjectLockConfiguration(bucketName string) (dto.ObjectLockConfiguration, error) {
+	if m.GetObjectLockConfigurationFunc != nil {
+		return m.GetObjectLockConfigurationFunc(bucketName)
+	}
+	return dto.ObjectLockConfiguration{}, storage.ErrObjectLockNotEnabled
+}
+
+func (m *MockStorage) PutObjectLockConfiguration(bucketName string, config dto.ObjectLockConfiguration) error {
+	if m.PutObjectLockConfigurationFunc != nil {
+		return m.PutObjectLockConfigurationFunc(bucketName, config)
+	}
+	return nil
+}
+
+func (m *MockStorage) PutObjectRetention(bucketName, objectName, versionID, mode string, retainUntil time.Time, bypassGovernance bool) (dto.ObjectInfo, error) {
+	if m.PutObjectRetentionFunc != nil {
+		return m.PutObjectRetentionFunc(bucketName, objectName, versionID, mode, retainUntil, bypassGovernance)
+	}
+	return dto.ObjectInfo{Key: objectName}, nil
+}
+
+func (m *MockStorage) PutObjectLegalHold(bucketName, objectName, versionID string, on bool) (dto.ObjectInfo, error) {
+	if m.PutObjectLegalHoldFunc != nil {
+		return m.PutObjectLegalHoldFunc(bucketName, objectName, versionID, on)
+	}
+	return dto.ObjectInfo{Key: objectName}, nil
+}
+
 // Test for the /probe-bsign{suffix:.*} route
 func TestProbeBSignRoute(t *testing.T) {
-	r := router.SetupRouter()
+	cfg := config.Default()
+	cfg.DataDir = t.TempDir()
+	r := router.SetupRouter(cfg)
 
 	tests := []struct {
 		method       string
@@ -147,7 +395,7 @@ func TestHandleDeleteObject(t *testing.T) {
 	mockStorage := &MockStorage{}
 
 	// Initialize the router with mock storage
-	r := router.SetupRouterWithStorage(mockStorage) // Assuming you have a way to inject storage into the router
+	r := router.SetupRouterWithStorage(mockStorage, testCredentials)
 
 	// Create a DeleteBatchRequest with objects to delete
 	deleteReq := dto.DeleteObjectRequest{
@@ -169,6 +417,7 @@ func TestHandleDeleteObject(t *testing.T) {
 		t.Fatalf("could not create request: %v", err)
 	}
 	req.Header.Set("Content-Type", "application/xml")
+	signTestRequest(req)
 
 	// Create a recorder to capture the response
 	rr := httptest.NewRecorder()
@@ -205,19 +454,19 @@ func TestHandleDeleteObject(t *testing.T) {
 func TestHandleAddObject(t *testing.T) {
 	// Create a new instance of the mock storage
 	mockStorage := &MockStorage{
-		AddObjectFunc: func(bucketName, objectName string, data io.Reader, contentSha256 string) error {
+		AddObjectFunc: func(bucketName, objectName string, data io.Reader, opts storage.PutObjectOptions) (dto.ObjectInfo, error) {
 			if bucketName == "test-bucket" && objectName == "test-object" {
 				// Simulate successful upload, reading the content from the reader
 				buf := new(bytes.Buffer)
 				if _, err := buf.ReadFrom(data); err != nil {
-					return err
+					return dto.ObjectInfo{}, err
 				}
 				if buf.String() != "file content" {
-					return fmt.Errorf("unexpected file content: %s", buf.String())
+					return dto.ObjectInfo{}, fmt.Errorf("unexpected file content: %s", buf.String())
 				}
-				return nil
+				return dto.ObjectInfo{Key: objectName, Size: int64(buf.Len()), ETag: `"d10b4c3ff123b26dc068d43a8bef2d23"`}, nil
 			}
-			return os.ErrNotExist // Simulate failure
+			return dto.ObjectInfo{}, os.ErrNotExist // Simulate failure
 		},
 		CheckBucketExistsFunc: func(bucketName string) (bool, error) {
 			if bucketName == "test-bucket" {
@@ -225,16 +474,17 @@ func TestHandleAddObject(t *testing.T) {
 			}
 			return false, nil
 		},
-		CheckObjectExistFunc: func(bucketName, objectName string) (bool, time.Time, int64, error) {
+		StatObjectFunc: func(bucketName, objectName, versionID string) (dto.ObjectInfo, error) {
 			if bucketName == "test-bucket" && objectName == "test-object" {
-				return true, time.Now(), 1234, nil
+				return dto.ObjectInfo{Key: objectName, Size: 1234, LastModified: time.Now()}, nil
 			}
-			return false, time.Time{}, 0, os.ErrNotExist
+			return dto.ObjectInfo{}, os.ErrNotExist
 		},
 	}
 
 	// Initialize the router with the mock storage
 	r := mux.NewRouter()
+	r.Use(middleware.RequestIDMiddleware)
 	r.HandleFunc("/{bucketName}/{objectName}", handlers.HandleAddObject(mockStorage)).Methods("POST", "PUT")
 
 	// Create a POST request to upload an object
@@ -242,7 +492,7 @@ func TestHandleAddObject(t *testing.T) {
 	if err != nil {
 		t.Fatalf("could not create request: %v", err)
 	}
-	req.Header.Set("X-Amz-Content-Sha256", "dummyhash")
+	req.Header.Set("X-Amz-Content-Sha256", "e0ac3601005dfa1864f5392aabaf7d898b1b5bab854f1acb4491bcd806b76b0c")
 	req.Header.Set("Expect", "100-continue") // Add the Expect header
 	req.Header.Set("X-Amz-Decoded-Content-Length", "12") // Add the missing header
 
@@ -270,8 +520,8 @@ func TestHandleAddObject(t *testing.T) {
 	}
 
 	// Validate the response headers
-	if rr.Header().Get("ETag") == "" {
-		t.Errorf("expected ETag header to be set")
+	if rr.Header().Get("ETag") != `"d10b4c3ff123b26dc068d43a8bef2d23"` {
+		t.Errorf("expected ETag header to be the object MD5 but got %q", rr.Header().Get("ETag"))
 	}
 	if rr.Header().Get("x-amz-id-2") == "" {
 		t.Errorf("expected x-amz-id-2 header to be set")
@@ -284,13 +534,13 @@ func TestHandleAddObject(t *testing.T) {
 func TestHandleCheckObjectExist(t *testing.T) {
 	// Create a new instance of the mock storage
 	mockStorage := &MockStorage{
-		CheckObjectExistFunc: func(bucketName, objectName string) (bool, time.Time, int64, error) {
+		StatObjectFunc: func(bucketName, objectName, versionID string) (dto.ObjectInfo, error) {
 			// Simulate that the object exists
 			if bucketName == "test-bucket" && objectName == "test-object" {
-				return true, time.Now(), 1234, nil
+				return dto.ObjectInfo{Key: objectName, Size: 1234, LastModified: time.Now(), ETag: `"d10b4c3ff123b26dc068d43a8bef2d23"`}, nil
 			}
 			// Simulate that the object does not exist
-			return false, time.Time{}, 0, nil
+			return dto.ObjectInfo{}, os.ErrNotExist
 		},
 	}
 
@@ -335,6 +585,9 @@ func TestHandleCheckObjectExist(t *testing.T) {
 			if rr.Header().Get("Content-Length") != "1234" {
 				t.Errorf("expected Content-Length to be 1234 but got %s", rr.Header().Get("Content-Length"))
 			}
+			if rr.Header().Get("ETag") != `"d10b4c3ff123b26dc068d43a8bef2d23"` {
+				t.Errorf("expected ETag to be the stored MD5 but got %s", rr.Header().Get("ETag"))
+			}
 		}
 	}
 }
@@ -342,28 +595,20 @@ func TestHandleCheckObjectExist(t *testing.T) {
 func TestHandleListObjects(t *testing.T) {
     // Create a new instance of the mock storage
     mockStorage := &MockStorage{
-        ListObjectsFunc: func(bucketName, prefix, marker string, maxKeys int) (dto.ListObjectsResponse, error) {
+        ListObjectsFunc: func(bucketName string, opts storage.ListObjectsOptions) (dto.ObjectListing, error) {
             // Simulate a response with some objects
-            if marker == "object1.txt" {
+            if opts.StartAfter == "object1.txt" {
                 // Simulate paginated response
-                return dto.ListObjectsResponse{
-                    Name:    bucketName,
-                    Prefix:  prefix,
-                    Marker:  marker,
-                    MaxKeys: maxKeys,
-                    Contents: []dto.Object{
+                return dto.ObjectListing{
+                    Objects: []dto.Object{
                         {Key: "object2.txt", LastModified: time.Now(), Size: 5678},
                     },
                     IsTruncated: false,
                 }, nil
             }
 
-            return dto.ListObjectsResponse{
-                Name:    bucketName,
-                Prefix:  prefix,
-                Marker:  marker,
-                MaxKeys: maxKeys,
-                Contents: []dto.Object{
+            return dto.ObjectListing{
+                Objects: []dto.Object{
                     {Key: "object1.txt", LastModified: time.Now(), Size: 1234},
                     {Key: "object2.txt", LastModified: time.Now(), Size: 5678},
                 },
@@ -458,7 +703,7 @@ func TestHandleGetBucket(t *testing.T) {
 	}{
 		{"existing-bucket", "", http.StatusOK, "Bucket 'existing-bucket' exists and is accessible."},
 		{"existing-bucket", "location", http.StatusOK, "<LocationConstraint>us-east-1</LocationConstraint>"},
-		{"nonexistent-bucket", "", http.StatusNotFound, "Bucket 'nonexistent-bucket' not found\n"},
+		{"nonexistent-bucket", "", http.StatusNotFound, "NoSuchBucket"},
 	}
 
 	for _, tt := range tests {
@@ -483,8 +728,12 @@ func TestHandleGetBucket(t *testing.T) {
 			t.Errorf("expected status %d but got %d for bucket: %s", tt.expectedCode, rr.Code, tt.bucketName)
 		}
 
-		// Check the response body
-		if rr.Body.String() != tt.expectedBody {
+		// Check the response body, or the S3 error code for failures
+		if tt.expectedCode != http.StatusOK {
+			if code := errorCode(t, rr); code != tt.expectedBody {
+				t.Errorf("expected error %s but got %s", tt.expectedBody, code)
+			}
+		} else if rr.Body.String() != tt.expectedBody {
 			t.Errorf("expected body %q but got %q", tt.expectedBody, rr.Body.String())
 		}
 	}
@@ -493,7 +742,7 @@ func TestHandleGetBucket(t *testing.T) {
 func TestHandleCreateBucket(t *testing.T) {
 	// Mock storage
 	mockStorage := &MockStorage{
-		CreateBucketFunc: func(bucketName string) error {
+		CreateBucketFunc: func(bucketName string, opts storage.CreateBucketOptions) error {
 			if bucketName == "test-bucket" {
 				// Simulate successful bucket creation
 				return nil
@@ -501,6 +750,9 @@ func TestHandleCreateBucket(t *testing.T) {
 			// Simulate an error if the bucket creation fails
 			return fmt.Errorf("failed to create bucket")
 		},
+		CheckBucketExistsFunc: func(bucketName string) (bool, error) {
+			return bucketName == "existing-bucket", nil
+		},
 	}
 
 	// Initialize the router with the mock storage
@@ -513,7 +765,8 @@ func TestHandleCreateBucket(t *testing.T) {
 		expectedBody string
 	}{
 		{"test-bucket", http.StatusOK, ""},         
-		{"fail-bucket", http.StatusInternalServerError, "failed to create bucket\n"}, 
+		{"fail-bucket", http.StatusInternalServerError, "InternalError"},
+		{"existing-bucket", http.StatusConflict, "BucketAlreadyOwnedByYou"},
 	}
 
 	for _, tt := range tests {
@@ -547,8 +800,8 @@ func TestHandleCreateBucket(t *testing.T) {
 			if actualResponse != xmlResponse {
 				t.Errorf("Expected XML response to be: %s, but got: %s", xmlResponse, actualResponse)
 			}
-		} else if rr.Body.String() != tt.expectedBody {
-			t.Errorf("expected body %q but got %q", tt.expectedBody, rr.Body.String())
+		} else if code := errorCode(t, rr); code != tt.expectedBody {
+			t.Errorf("expected error %s but got %s", tt.expectedBody, code)
 		}
 	}
 }
@@ -562,6 +815,12 @@ func TestHandleDeleteBucket(t *testing.T) {
 			if bucketName == "fail-bucket" {
 				return fmt.Errorf("Failed to delete bucket\n")
 			}
+			if bucketName == "full-bucket" {
+				return storage.ErrBucketNotEmpty
+			}
+			if bucketName == "missing-bucket" {
+				return storage.ErrNoSuchBucket
+			}
 			return nil
 		},
 	}
@@ -584,7 +843,17 @@ func TestHandleDeleteBucket(t *testing.T) {
 		{
 			bucketName:   "fail-bucket",
 			expectedCode: http.StatusInternalServerError,
-			expectedBody: "Failed to delete bucket\n",
+			expectedBody: "InternalError",
+		},
+		{
+			bucketName:   "full-bucket",
+			expectedCode: http.StatusConflict,
+			expectedBody: "BucketNotEmpty",
+		},
+		{
+			bucketName:   "missing-bucket",
+			expectedCode: http.StatusNotFound,
+			expectedBody: "NoSuchBucket",
 		},
 	}
 
@@ -607,8 +876,12 @@ func TestHandleDeleteBucket(t *testing.T) {
 			t.Errorf("expected status %d but got %d for bucket: %s", tt.expectedCode, rr.Code, tt.bucketName)
 		}
 
-		// Check the response body
-		if rr.Body.String() != tt.expectedBody {
+		// Check the response body, or the S3 error code for failures
+		if tt.expectedCode != http.StatusNoContent {
+			if code := errorCode(t, rr); code != tt.expectedBody {
+				t.Errorf("expected error %s but got %s for bucket: %s", tt.expectedBody, code, tt.bucketName)
+			}
+		} else if rr.Body.String() != tt.expectedBody {
 			t.Errorf("expected body %q but got %q for bucket: %s", tt.expectedBody, rr.Body.String(), tt.bucketName)
 		}
 	}
@@ -0,0 +1,20 @@
+package tests
+
+import (
+	"testing"
+
+	"my-s3-clone/storage"
+	"my-s3-clone/storage/storagetest"
+)
+
+func TestMemoryStorageConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		return storage.NewMemoryStorage()
+	})
+}
+
+func TestFileStorageConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		return &storage.FileStorage{Root: t.TempDir()}
+	})
+}
@@ -0,0 +1,213 @@
+package tests
+
+import (
+	"encoding/xml"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/router"
+	"my-s3-clone/storage"
+)
+
+func TestObjectTagging(t *testing.T) {
+	s := storage.NewMemoryStorage()
+	if err := s.CreateBucket("photos", storage.CreateBucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	r := router.SetupRouterWithStorage(s, testCredentials)
+
+	rr := sendWithHeaders(r, "PUT", "/photos/cat.jpg", "meow", map[string]string{"X-Amz-Tagging": "rendition=original&album=2024%2F06"})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if rr := sendWithHeaders(r, "HEAD", "/photos/cat.jpg", "", nil); rr.Header().Get("x-amz-tagging-count") != "2" {
+		t.Errorf("expected x-amz-tagging-count 2, got %q", rr.Header().Get("x-amz-tagging-count"))
+	}
+
+	tagging := func(key string) map[string]string {
+		t.Helper()
+		rr := sendWithHeaders(r, "GET", "/photos/"+key+"?tagging", "", nil)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		var result dto.Tagging
+		if err := xml.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+			t.Fatalf("Error unmarshaling response body: %v", err)
+		}
+		tags := map[string]string{}
+		for _, tag := range result.TagSet.Tags {
+			tags[tag.Key] = tag.Value
+		}
+		return tags
+	}
+	if tags := tagging("cat.jpg"); !reflect.DeepEqual(tags, map[string]string{"rendition": "original", "album": "2024/06"}) {
+		t.Errorf("unexpected tags %v", tags)
+	}
+
+	body := `<Tagging><TagSet><Tag><Key>rendition</Key><Value>thumbnail</Value></Tag></TagSet></Tagging>`
+	if rr := sendWithHeaders(r, "PUT", "/photos/cat.jpg?tagging", body, nil); rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if tags := tagging("cat.jpg"); !reflect.DeepEqual(tags, map[string]string{"rendition": "thumbnail"}) {
+		t.Errorf("expected the tags to be replaced, got %v", tags)
+	}
+
+	// Invalid tag sets are rejected and leave the tags unchanged
+	for name, body := range map[string]string{
+		"duplicate key":   `<Tagging><TagSet><Tag><Key>a</Key><Value>1</Value></Tag><Tag><Key>a</Key><Value>2</Value></Tag></TagSet></Tagging>`,
+		"reserved prefix": `<Tagging><TagSet><Tag><Key>aws:a</Key><Value>1</Value></Tag></TagSet></Tagging>`,
+	} {
+		rr := sendWithHeaders(r, "PUT", "/photos/cat.jpg?tagging", body, nil)
+		if rr.Code != http.StatusBadRequest || errorCode(t, rr) != "InvalidTag" {
+			t.Errorf("%s: expected InvalidTag but got %d: %s", name, rr.Code, rr.Body.String())
+		}
+	}
+	rr = sendWithHeaders(r, "PUT", "/photos/cat.jpg?tagging", "<Tagging>", nil)
+	if rr.Code != http.StatusBadRequest || errorCode(t, rr) != "MalformedXML" {
+		t.Errorf("expected MalformedXML but got %d: %s", rr.Code, rr.Body.String())
+	}
+	rr = sendWithHeaders(r, "PUT", "/photos/dog.jpg", "woof", map[string]string{"X-Amz-Tagging": "a=1&a=2"})
+	if rr.Code != http.StatusBadRequest || errorCode(t, rr) != "InvalidTag" {
+		t.Errorf("expected InvalidTag for a repeated header tag but got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := sendWithHeaders(r, "GET", "/photos/missing.jpg?tagging", "", nil); rr.Code != http.StatusNotFound {
+		t.Errorf("expected status %d for a missing object but got %d", http.StatusNotFound, rr.Code)
+	}
+
+	// Copies keep the source tags unless the directive replaces them
+	copySource := map[string]string{"X-Amz-Copy-Source": "/photos/cat.jpg"}
+	if rr := sendWithHeaders(r, "PUT", "/photos/copy.jpg", "", copySource); rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if tags := tagging("copy.jpg"); tags["rendition"] != "thumbnail" {
+		t.Errorf("expected the copy to keep the source tags, got %v", tags)
+	}
+	rr = sendWithHeaders(r, "PUT", "/photos/copy.jpg", "", map[string]string{
+		"X-Amz-Copy-Source":        "/photos/cat.jpg",
+		"X-Amz-Tagging-Directive":  "REPLACE",
+		"X-Amz-Tagging":            "rendition=original",
+		"X-Amz-Metadata-Directive": "COPY",
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if tags := tagging("copy.jpg"); !reflect.DeepEqual(tags, map[string]string{"rendition": "original"}) {
+		t.Errorf("expected the copy tags to be replaced, got %v", tags)
+	}
+	rr = sendWithHeaders(r, "PUT", "/photos/copy.jpg", "", map[string]string{
+		"X-Amz-Copy-Source":       "/photos/cat.jpg",
+		"X-Amz-Tagging-Directive": "MERGE",
+	})
+	if rr.Code != http.StatusBadRequest || errorCode(t, rr) != "InvalidArgument" {
+		t.Errorf("expected InvalidArgument for an unknown directive but got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// Listing only the originals
+	rr = sendWithHeaders(r, "GET", "/photos/?list-type=2&tag=rendition%3Doriginal", "", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var listing dto.ListObjectsV2Response
+	if err := xml.Unmarshal(rr.Body.Bytes(), &listing); err != nil {
+		t.Fatalf("Error unmarshaling response body: %v", err)
+	}
+	if len(listing.Contents) != 1 || listing.Contents[0].Key != "copy.jpg" {
+		t.Errorf("expected only copy.jpg to be listed, got %s", rr.Body.String())
+	}
+	rr = sendWithHeaders(r, "GET", "/photos/?tag=rendition", "", nil)
+	if rr.Code != http.StatusBadRequest || errorCode(t, rr) != "InvalidTag" {
+		t.Errorf("expected InvalidTag for a tag filter without value but got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	if rr := sendWithHeaders(r, "DELETE", "/photos/cat.jpg?tagging", "", nil); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusNoContent, rr.Code, rr.Body.String())
+	}
+	if tags := tagging("cat.jpg"); len(tags) != 0 {
+		t.Errorf("expected no tags after DELETE, got %v", tags)
+	}
+	if rr := sendWithHeaders(r, "HEAD", "/photos/cat.jpg", "", nil); rr.Header().Get("x-amz-tagging-count") != "" {
+		t.Errorf("expected no x-amz-tagging-count, got %q", rr.Header().Get("x-amz-tagging-count"))
+	}
+}
+
+func TestMultipartUploadTagging(t *testing.T) {
+	for name, s := range map[string]storage.Storage{
+		"file":   &storage.FileStorage{Root: t.TempDir()},
+		"memory": storage.NewMemoryStorage(),
+	} {
+		if err := s.CreateBucket("photos", storage.CreateBucketOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		r := router.SetupRouterWithStorage(s, testCredentials)
+
+		// Invalid tags are refused before any part is sent
+		rr := sendWithHeaders(r, "POST", "/photos/video.mp4?uploads", "", map[string]string{"X-Amz-Tagging": "a=1&a=2"})
+		if rr.Code != http.StatusBadRequest || errorCode(t, rr) != "InvalidTag" {
+			t.Errorf("%s: expected InvalidTag but got %d: %s", name, rr.Code, rr.Body.String())
+		}
+
+		var initiated dto.InitiateMultipartUploadResult
+		rr = sendWithHeaders(r, "POST", "/photos/video.mp4?uploads", "", map[string]string{"X-Amz-Tagging": "rendition=original&album=2024%2F06"})
+		if rr.Code != http.StatusOK || xml.Unmarshal(rr.Body.Bytes(), &initiated) != nil {
+			t.Fatalf("%s: expected an upload id but got %d: %s", name, rr.Code, rr.Body.String())
+		}
+		rr = sendWithHeaders(r, "PUT", "/photos/video.mp4?partNumber=1&uploadId="+initiated.UploadId, "frames", nil)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected status %d but got %d: %s", name, http.StatusOK, rr.Code, rr.Body.String())
+		}
+		complete := `<CompleteMultipartUpload><Part><PartNumber>1</PartNumber><ETag>` + rr.Header().Get("ETag") + `</ETag></Part></CompleteMultipartUpload>`
+		if rr := sendWithHeaders(r, "POST", "/photos/video.mp4?uploadId="+initiated.UploadId, complete, nil); rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected status %d but got %d: %s", name, http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		rr = sendWithHeaders(r, "GET", "/photos/video.mp4?tagging", "", nil)
+		var result dto.Tagging
+		if rr.Code != http.StatusOK || xml.Unmarshal(rr.Body.Bytes(), &result) != nil {
+			t.Fatalf("%s: expected the tag set but got %d: %s", name, rr.Code, rr.Body.String())
+		}
+		tags := map[string]string{}
+		for _, tag := range result.TagSet.Tags {
+			tags[tag.Key] = tag.Value
+		}
+		if !reflect.DeepEqual(tags, map[string]string{"rendition": "original", "album": "2024/06"}) {
+			t.Errorf("%s: expected the upload tags on the completed object, got %v", name, tags)
+		}
+	}
+}
+
+func TestLifecycleExpiresTaggedObjects(t *testing.T) {
+	s := &storage.FileStorage{Root: t.TempDir()}
+	if err := s.CreateBucket("photos", storage.CreateBucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for key, rendition := range map[string]string{"cat.jpg": "original", "cat-small.jpg": "thumbnail"} {
+		opts := storage.PutObjectOptions{Tags: map[string]string{"rendition": rendition}, DecodedContentLength: -1}
+		if _, err := s.AddObject("photos", key, strings.NewReader(key), opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := s.PutBucketLifecycle("photos", dto.LifecycleConfiguration{Rules: []dto.LifecycleRule{{
+		ID:         "thumbnails",
+		Status:     "Enabled",
+		Filter:     &dto.LifecycleFilter{Tag: &dto.Tag{Key: "rendition", Value: "thumbnail"}},
+		Expiration: &dto.LifecycleExpiration{Days: 30},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := s.ApplyLifecycle(s, time.Now().Add(31*24*time.Hour), false)
+	if report.ExpiredObjects != 1 || report.Errors != 0 {
+		t.Errorf("expected one expired object, got %+v", report)
+	}
+	if _, err := s.StatObject("photos", "cat-small.jpg", ""); err == nil {
+		t.Errorf("expected the thumbnail to be expired")
+	}
+	if _, err := s.StatObject("photos", "cat.jpg", ""); err != nil {
+		t.Errorf("expected the original to be kept, got %v", err)
+	}
+}
@@ -0,0 +1,251 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"my-s3-clone/dto"
+	"my-s3-clone/router"
+	"my-s3-clone/storage"
+)
+
+func TestBucketVersioningConfiguration(t *testing.T) {
+	status := ""
+	mockStorage := &MockStorage{
+		GetBucketVersioningFunc: func(bucketName string) (string, error) {
+			if bucketName != "photos" {
+				return "", storage.ErrNoSuchBucket
+			}
+			return status, nil
+		},
+		SetBucketVersioningFunc: func(bucketName, newStatus string) error {
+			if newStatus != storage.VersioningEnabled && newStatus != storage.VersioningSuspended {
+				return storage.ErrInvalidVersioning
+			}
+			status = newStatus
+			return nil
+		},
+	}
+	r := router.SetupRouterWithStorage(mockStorage, testCredentials)
+
+	putVersioning := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", "/photos/?versioning", strings.NewReader(body))
+		signTestRequest(req)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := putVersioning(`<VersioningConfiguration><Status>Enabled</Status></VersioningConfiguration>`)
+	if rr.Code != http.StatusOK || status != storage.VersioningEnabled {
+		t.Fatalf("expected versioning to be enabled but got %d (status %q): %s", rr.Code, status, rr.Body.String())
+	}
+
+	req := httptest.NewRequest("GET", "/photos/?versioning", nil)
+	signTestRequest(req)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	var config dto.VersioningConfiguration
+	if err := xml.Unmarshal(rr.Body.Bytes(), &config); err != nil {
+		t.Fatalf("Error unmarshaling response body: %v", err)
+	}
+	if config.Status != storage.VersioningEnabled {
+		t.Errorf("expected status Enabled but got %q", config.Status)
+	}
+
+	rr = putVersioning(`<VersioningConfiguration><Status>Disabled</Status></VersioningConfiguration>`)
+	if rr.Code != http.StatusBadRequest || errorCode(t, rr) != "IllegalVersioningConfigurationException" {
+		t.Errorf("expected IllegalVersioningConfigurationException but got %d: %s", rr.Code, rr.Body.String())
+	}
+	rr = putVersioning(`<VersioningConfiguration>`)
+	if rr.Code != http.StatusBadRequest || errorCode(t, rr) != "MalformedXML" {
+		t.Errorf("expected MalformedXML but got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestListObjectVersions(t *testing.T) {
+	lastModified := time.Date(2024, 9, 16, 10, 12, 24, 0, time.UTC)
+	var gotOpts storage.ListObjectVersionsOptions
+	mockStorage := &MockStorage{
+		ListObjectVersionsFunc: func(bucketName string, opts storage.ListObjectVersionsOptions) (dto.ObjectVersionListing, error) {
+			gotOpts = opts
+			return dto.ObjectVersionListing{
+				Versions: []dto.ObjectVersion{
+					{Key: "cat.jpg", VersionID: "v3", IsLatest: true, IsDeleteMarker: true, LastModified: lastModified},
+					{Key: "cat.jpg", VersionID: "v2", LastModified: lastModified, ETag: `"etag-2"`, Size: 5},
+				},
+				IsTruncated:         true,
+				NextKeyMarker:       "cat.jpg",
+				NextVersionIDMarker: "v2",
+			}, nil
+		},
+	}
+	r := router.SetupRouterWithStorage(mockStorage, testCredentials)
+
+	req := httptest.NewRequest("GET", "/photos/?versions&prefix=c&max-keys=2&key-marker=a.jpg&version-id-marker=v1", nil)
+	signTestRequest(req)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	if gotOpts.Prefix != "c" || gotOpts.MaxKeys != 2 || gotOpts.KeyMarker != "a.jpg" || gotOpts.VersionIDMarker != "v1" {
+		t.Errorf("unexpected listing options %+v", gotOpts)
+	}
+
+	var result struct {
+		NextKeyMarker       string
+		NextVersionIdMarker string
+		IsTruncated         bool
+		Versions            []dto.VersionEntry `xml:"Version"`
+		DeleteMarkers       []dto.VersionEntry `xml:"DeleteMarker"`
+	}
+	if err := xml.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Error unmarshaling response body: %v", err)
+	}
+	if !result.IsTruncated || result.NextKeyMarker != "cat.jpg" || result.NextVersionIdMarker != "v2" {
+		t.Errorf("unexpected pagination in %s", rr.Body.String())
+	}
+	if len(result.DeleteMarkers) != 1 || result.DeleteMarkers[0].VersionId != "v3" || !result.DeleteMarkers[0].IsLatest || result.DeleteMarkers[0].Size != nil {
+		t.Errorf("unexpected delete markers %+v", result.DeleteMarkers)
+	}
+	if len(result.Versions) != 1 || result.Versions[0].VersionId != "v2" || result.Versions[0].Size == nil || *result.Versions[0].Size != 5 {
+		t.Errorf("unexpected versions %+v", result.Versions)
+	}
+}
+
+func TestVersionedObjectRequests(t *testing.T) {
+	mockStorage := &MockStorage{
+		StatObjectFunc: func(bucketName, objectName, versionID string) (dto.ObjectInfo, error) {
+			switch versionID {
+			case "", "v1":
+				return dto.ObjectInfo{Key: objectName, VersionID: "v1", ETag: `"etag"`, Size: 5}, nil
+			case "v2":
+				return dto.ObjectInfo{Key: objectName, VersionID: "v2", IsDeleteMarker: true}, storage.ErrDeleteMarker
+			}
+			return dto.ObjectInfo{}, storage.ErrNoSuchVersion
+		},
+		DeleteObjectFunc: func(bucketName, objectName string, opts storage.DeleteObjectOptions) (dto.ObjectInfo, error) {
+			if opts.VersionID != "" {
+				return dto.ObjectInfo{Key: objectName, VersionID: opts.VersionID}, nil
+			}
+			return dto.ObjectInfo{Key: objectName, VersionID: "v3", IsDeleteMarker: true}, nil
+		},
+	}
+	r := router.SetupRouterWithStorage(mockStorage, testCredentials)
+
+	tests := []struct {
+		method               string
+		path                 string
+		expectedCode         int
+		expectedVersionID    string
+		expectedDeleteMarker string
+	}{
+		{"HEAD", "/photos/cat.jpg?versionId=v1", http.StatusOK, "v1", ""},
+		{"HEAD", "/photos/cat.jpg?versionId=v2", http.StatusMethodNotAllowed, "v2", "true"},
+		{"HEAD", "/photos/cat.jpg?versionId=v9", http.StatusNotFound, "", ""},
+		{"DELETE", "/photos/cat.jpg", http.StatusNoContent, "v3", "true"},
+		{"DELETE", "/photos/cat.jpg?versionId=v1", http.StatusNoContent, "v1", ""},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		signTestRequest(req)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedCode {
+			t.Errorf("%s %s: expected status %d but got %d", tt.method, tt.path, tt.expectedCode, rr.Code)
+		}
+		if got := rr.Header().Get("x-amz-version-id"); got != tt.expectedVersionID {
+			t.Errorf("%s %s: expected version id %q but got %q", tt.method, tt.path, tt.expectedVersionID, got)
+		}
+		if got := rr.Header().Get("x-amz-delete-marker"); got != tt.expectedDeleteMarker {
+			t.Errorf("%s %s: expected delete marker %q but got %q", tt.method, tt.path, tt.expectedDeleteMarker, got)
+		}
+	}
+}
+
+func TestCopyObjectFromVersion(t *testing.T) {
+	var gotVersionID string
+	mockStorage := &MockStorage{
+		CopyObjectFunc: func(sourceBucket, sourceKey, targetBucket, targetKey string, opts storage.CopyObjectOptions) (dto.ObjectInfo, error) {
+			gotVersionID = opts.SourceVersionID
+			return dto.ObjectInfo{Key: targetKey, VersionID: "v4", ETag: `"etag"`}, nil
+		},
+	}
+	r := router.SetupRouterWithStorage(mockStorage, testCredentials)
+
+	// Restoring an old version by copying it onto its own key is allowed
+	req := httptest.NewRequest("PUT", "/photos/cat.jpg", bytes.NewReader(nil))
+	req.Header.Set("X-Amz-Copy-Source", "/photos/cat.jpg?versionId=v1")
+	signTestRequest(req)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if gotVersionID != "v1" {
+		t.Errorf("expected source version v1 but got %q", gotVersionID)
+	}
+	if rr.Header().Get("x-amz-copy-source-version-id") != "v1" || rr.Header().Get("x-amz-version-id") != "v4" {
+		t.Errorf("unexpected version headers %v", rr.Header())
+	}
+}
+
+func TestConcurrentUploadsAndDeleteMarkers(t *testing.T) {
+	s := &storage.FileStorage{Root: t.TempDir()}
+	if err := s.CreateBucket("photos", storage.CreateBucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetBucketVersioning("photos", storage.VersioningEnabled); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every upload and every delete marker must end up in the history of the key, none overwriting another
+	const rounds = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*rounds)
+	for _, remove := range []bool{false, true} {
+		wg.Add(1)
+		go func(remove bool) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				var err error
+				if remove {
+					_, err = s.DeleteObject("photos", "cat.jpg", storage.DeleteObjectOptions{})
+				} else {
+					_, err = s.AddObject("photos", "cat.jpg", strings.NewReader(strings.Repeat("meow", 4096)), storage.PutObjectOptions{DecodedContentLength: -1})
+				}
+				errs <- err
+			}
+		}(remove)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	listing, err := s.ListObjectVersions("photos", storage.ListObjectVersionsOptions{MaxKeys: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]bool)
+	for _, version := range listing.Versions {
+		seen[version.VersionID] = true
+	}
+	if len(listing.Versions) != 2*rounds || len(seen) != 2*rounds {
+		t.Errorf("expected %d distinct versions, got %d (%d distinct)", 2*rounds, len(listing.Versions), len(seen))
+	}
+}